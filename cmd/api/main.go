@@ -1,34 +1,333 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/allensuvorov/tenexlog/internal/analyze"
 	"github.com/allensuvorov/tenexlog/internal/auth"
+	"github.com/allensuvorov/tenexlog/internal/cases"
+	"github.com/allensuvorov/tenexlog/internal/catalog"
+	"github.com/allensuvorov/tenexlog/internal/config"
+	"github.com/allensuvorov/tenexlog/internal/enrich"
 	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/inbound"
+	"github.com/allensuvorov/tenexlog/internal/integrations"
+	"github.com/allensuvorov/tenexlog/internal/jobs"
+	"github.com/allensuvorov/tenexlog/internal/live"
+	"github.com/allensuvorov/tenexlog/internal/notify"
+	"github.com/allensuvorov/tenexlog/internal/parse"
+	"github.com/allensuvorov/tenexlog/internal/reqctx"
+	"github.com/allensuvorov/tenexlog/internal/rpc"
 	"github.com/allensuvorov/tenexlog/internal/upload"
 )
 
 func main() {
+	if p := os.Getenv("ACTIONS_FILE"); p != "" {
+		if err := upload.LoadRecommendedActions(p); err != nil {
+			log.Fatal("load recommended actions: ", err)
+		}
+	}
+
+	if p := os.Getenv("RULES_FILE"); p != "" {
+		n, err := config.LoadRules(p)
+		if err != nil {
+			log.Fatal("load RULES_FILE: ", err)
+		}
+		config.RulesFile = p
+		log.Printf("loaded %d rule(s) from %s", n, p)
+	}
+	upload.ConfigureDetectors(upload.EnvDetectorConfig())
+	upload.VerifyCrawlers = os.Getenv("VERIFY_CRAWLERS") != "false"
+	if p := os.Getenv("SUPPRESSIONS_FILE"); p != "" {
+		if err := upload.LoadSuppressions(p); err != nil && !os.IsNotExist(err) {
+			log.Fatal("load SUPPRESSIONS_FILE: ", err)
+		}
+		config.SuppressionsFile = p
+	}
+	store, err := jobs.Open(os.Getenv("JOB_STORE"))
+	if err != nil {
+		log.Fatal("JOB_STORE: ", err)
+	}
+	if sq, ok := store.(*jobs.SQLStore); ok {
+		sq.DecodeResult = upload.DecodeResults
+	}
+	jobs.Default = store
+	if v := os.Getenv("JOB_WORKERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Fatal("JOB_WORKERS must be a positive integer")
+		}
+		upload.Workers = n
+	}
+	if v := os.Getenv("JOB_QUEUE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Fatal("JOB_QUEUE must be a positive integer")
+		}
+		upload.QueueSize = n
+	}
+	if v := os.Getenv("MAX_UPLOAD_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			log.Fatal("MAX_UPLOAD_BYTES must be a non-negative integer")
+		}
+		upload.MaxUploadBytes = n
+	}
+	uploads := upload.NewService(upload.DiskStorage{Dir: os.TempDir()}, upload.FileParser{}, upload.BuiltinDetectors{}, jobs.Default)
+	upload.Default = uploads
+	go uploads.Run(context.Background(), upload.Workers)
+	go upload.RunTusExpiry(context.Background())
+	if v := os.Getenv("JOB_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl <= 0 {
+			log.Fatal("JOB_TTL must be a positive duration")
+		}
+		go uploads.RunJanitor(context.Background(), ttl)
+	}
+
+	if v := os.Getenv("SENSITIVE_PATHS"); v != "" {
+		if err := analyze.SetSensitivePaths(strings.Split(v, ",")); err != nil {
+			log.Fatal("SENSITIVE_PATHS: ", err)
+		}
+	}
+	if p := os.Getenv("SENSITIVE_PATHS_FILE"); p != "" {
+		if err := analyze.LoadSensitivePaths(p); err != nil {
+			log.Fatal("load SENSITIVE_PATHS_FILE: ", err)
+		}
+		config.SensitivePathsFile = p
+	}
+
+	if p := os.Getenv("GEOIP_DB"); p != "" {
+		if err := enrich.LoadGeoDB(p); err != nil {
+			log.Fatal("load GEOIP_DB: ", err)
+		}
+	}
+	if p := os.Getenv("ASN_DB"); p != "" {
+		if err := enrich.LoadASNDB(p); err != nil {
+			log.Fatal("load ASN_DB: ", err)
+		}
+	}
+	ec, err := enrich.EnvConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	enrich.Configure(ec)
+
+	if v := os.Getenv("BREAKER_THRESHOLD"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatal("BREAKER_THRESHOLD must be a non-negative integer")
+		}
+		integrations.FailureThreshold = n
+	}
+	if v := os.Getenv("BREAKER_COOLDOWN"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatal("BREAKER_COOLDOWN must be a positive duration")
+		}
+		integrations.Cooldown = d
+	}
+
+	// Outbound clients are built when each integration is configured, so
+	// the CA bundle comes first.
+	if err := httputil.ConfigureOutbound(os.Getenv("OUTBOUND_CA_FILE")); err != nil {
+		log.Fatal("load OUTBOUND_CA_FILE: ", err)
+	}
+
+	feeds, err := enrich.ParseFeeds(os.Getenv("INTEL_FEEDS"))
+	if err != nil {
+		log.Fatal("INTEL_FEEDS: ", err)
+	}
+	if v := os.Getenv("INTEL_REFRESH"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatal("INTEL_REFRESH must be a positive duration")
+		}
+		enrich.FeedRefresh = d
+	}
+	if err := enrich.ConfigureFeeds(feeds); err != nil {
+		log.Fatal(err)
+	}
+	anon, err := enrich.ParseFeeds(os.Getenv("ANONYMIZER_FEEDS"))
+	if err != nil {
+		log.Fatal("ANONYMIZER_FEEDS: ", err)
+	}
+	if err := enrich.ConfigureAnonymizerFeeds(anon); err != nil {
+		log.Fatal(err)
+	}
+	go enrich.RunFeedRefresh(context.Background())
+
+	abuse, err := enrich.AbuseIPDBEnvConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	enrich.ConfigureAbuseIPDB(abuse)
+
+	inbound.Configure(inbound.Config{
+		Address:  os.Getenv("INBOUND_EMAIL_ADDRESS"),
+		SMTPAddr: os.Getenv("SMTP_ADDR"),
+		SMTPUser: os.Getenv("SMTP_USER"),
+		SMTPPass: os.Getenv("SMTP_PASS"),
+		From:     os.Getenv("SMTP_FROM"),
+		BaseURL:  os.Getenv("PUBLIC_BASE_URL"),
+	})
+
+	notify.Default.Client = httputil.OutboundClient(10 * time.Second)
+	notify.Default.CallbackClient = httputil.PublicClient(10 * time.Second)
+
+	nc, err := notify.EnvConfig()
+	if err != nil {
+		log.Fatal("notifier config: ", err)
+	}
+	nc.BaseURL = os.Getenv("PUBLIC_BASE_URL")
+	if err := notify.Configure(nc); err != nil {
+		log.Fatal("load NOTIFY_QUEUE_FILE: ", err)
+	}
+	go notify.Default.Run(context.Background())
+	if p := os.Getenv("NOTIFY_SEEN_FILE"); p != "" {
+		if err := upload.LoadSeenFindings(p); err != nil {
+			log.Fatal("load NOTIFY_SEEN_FILE: ", err)
+		}
+	}
+
+	if v := os.Getenv("SHARE_SECRET"); v != "" {
+		jobs.ShareSecret = []byte(v)
+	}
+
+	if v := os.Getenv("MAX_LINE_BYTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Fatal("MAX_LINE_BYTES must be a positive integer")
+		}
+		parse.MaxLineBytes = n
+	}
+	parse.TruncateLongLines = os.Getenv("TRUNCATE_LONG_LINES") == "true"
+
+	if v := os.Getenv("GAP_ALERT_MINUTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Fatal("GAP_ALERT_MINUTES must be a positive integer")
+		}
+		upload.GapAlertAfter = time.Duration(n) * time.Minute
+	}
+
+	if v := os.Getenv("EVIDENCE_ROWS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatal("EVIDENCE_ROWS must be a non-negative integer")
+		}
+		upload.EvidenceRows = n
+	}
+
+	if v := os.Getenv("RECURRENCE_HALF_LIFE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatal("RECURRENCE_HALF_LIFE must be a positive duration")
+		}
+		upload.RecurrenceHalfLife = d
+	}
+
+	if v := os.Getenv("LIVE_WINDOW_LINES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Fatal("LIVE_WINDOW_LINES must be a positive integer")
+		}
+		upload.LiveWindowLines = n
+	}
+
+	if v := os.Getenv("LIVE_SOURCES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			log.Fatal("LIVE_SOURCES must be a positive integer")
+		}
+		upload.LiveSources = n
+	}
+
+	if v := os.Getenv("LIVE_EPOCH"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Second {
+			log.Fatal("LIVE_EPOCH must be a duration of at least 1s")
+		}
+		upload.LiveEpoch = d
+	}
+
 	public := http.NewServeMux()
 	public.HandleFunc("GET /healthz", healthz)
+	public.HandleFunc("GET /api/shared/{token}", jobs.Shared)
 
 	protected := http.NewServeMux()
 	protected.HandleFunc("GET /ping", ping)
-	protected.HandleFunc("POST /api/upload", upload.Handler)
+	protected.HandleFunc("POST /api/upload", uploads.Handler)
+	protected.HandleFunc("PUT /api/upload/raw", uploads.RawHandler)
+	protected.HandleFunc("POST /api/upload/batch", uploads.BatchHandler)
+	protected.HandleFunc("OPTIONS /api/upload/tus", upload.TusOptions)
+	protected.HandleFunc("POST /api/upload/tus", uploads.TusCreate)
+	protected.HandleFunc("HEAD /api/upload/tus/{id}", upload.TusHead)
+	protected.HandleFunc("PATCH /api/upload/tus/{id}", uploads.TusPatch)
+	protected.HandleFunc("DELETE /api/upload/tus/{id}", upload.TusDelete)
+	protected.HandleFunc("POST /api/quick", uploads.Quick)
+	protected.HandleFunc("POST /api/live/{source}", uploads.LiveHandler)
+	protected.HandleFunc("GET /api/capabilities", upload.Capabilities)
+	protected.HandleFunc("GET /api/blocklist", uploads.Blocklist)
+	protected.HandleFunc("GET /api/jobs", jobs.List)
+	protected.HandleFunc("DELETE /api/jobs", uploads.PurgeHandler)
+	protected.HandleFunc("DELETE /api/jobs/{id}", uploads.Delete)
+	protected.HandleFunc("GET /api/jobs/{id}", uploads.Result)
+	protected.HandleFunc("GET /api/jobs/{id}/status", uploads.Status)
+	protected.HandleFunc("GET /api/jobs/{id}/events", uploads.Events)
+	protected.HandleFunc("POST /api/jobs/{id}/cancel", uploads.Cancel)
+	protected.HandleFunc("GET /api/jobs/{id}/rows", uploads.Rows)
+	protected.HandleFunc("GET /api/jobs/{id}/export", uploads.Export)
+	protected.HandleFunc("POST /api/jobs/{id}/share", jobs.Share)
+	protected.HandleFunc("POST /api/inbound/email", inbound.EmailHandler)
+	protected.HandleFunc("POST /api/enrich/ips", enrich.Handler)
+	protected.HandleFunc("GET /api/enrich/stats", enrich.StatsHandler)
+	protected.HandleFunc("POST /api/cases", cases.Create)
+	protected.HandleFunc("GET /api/cases", cases.List)
+	protected.HandleFunc("GET /api/cases/{id}", cases.Get)
+	protected.HandleFunc("PATCH /api/cases/{id}", cases.Update)
+	protected.HandleFunc("POST /api/cases/{id}/notes", cases.AddNote)
+	protected.HandleFunc("POST /api/cases/{id}/anomalies", cases.AttachAnomalies)
+	protected.HandleFunc("GET /api/cases/{id}/report", cases.Report)
+	protected.HandleFunc("GET /api/catalog/fields", catalog.Fields)
+	protected.HandleFunc("GET /api/notify/deliveries", notify.Deliveries)
+	protected.HandleFunc("POST /api/notify/deliveries/{id}/redeliver", notify.Redeliver)
+	protected.HandleFunc("DELETE /api/notify/deliveries/{id}", notify.Discard)
+	protected.HandleFunc("GET /api/integrations/status", integrations.StatusHandler)
+	protected.HandleFunc("GET /api/config/sensitive-paths", config.GetSensitivePaths)
+	protected.HandleFunc("PUT /api/config/sensitive-paths", config.PutSensitivePaths)
+	protected.HandleFunc("GET /api/config/suppressions", config.GetSuppressions)
+	protected.HandleFunc("PUT /api/config/suppressions", config.PutSuppressions)
+	protected.HandleFunc("GET /api/config/rules", config.GetRules)
+	protected.HandleFunc("POST /api/config/rules", config.PostRules)
+	protected.HandleFunc("DELETE /api/config/rules/{name}", config.DeleteRule)
+	protected.HandleFunc("POST /api/config/reload", config.Reload)
 
 	allowedOrigin := os.Getenv("CORS_ORIGIN")
 	if allowedOrigin == "" {
 		allowedOrigin = "http://localhost:3000"
 	}
+	protected.HandleFunc("GET /ws", live.Handler(allowedOrigin))
 	protectedWithAuth := auth.EnvBasicAuth()(protected)
 	protectedWithCORS := httputil.CORS(allowedOrigin)(protectedWithAuth)
 
 	root := http.NewServeMux()
 	root.Handle("GET /healthz", public)
+	root.Handle("GET /api/shared/{token}", httputil.CORS(allowedOrigin)(public))
 	root.Handle("/", protectedWithCORS)
 
+	if v := os.Getenv("GRPC_ADDR"); v != "" {
+		srv := rpc.Server(v, reqctx.Middleware(auth.EnvBasicAuth()(rpc.Handler(uploads))))
+		log.Println("starting gRPC server on", v)
+		go func() { log.Fatal(srv.ListenAndServe()) }()
+	}
+
 	addr := ":8080"
 	if p := os.Getenv("PORT"); p != "" {
 		addr = ":" + p
@@ -37,5 +336,5 @@ func main() {
 		addr = v
 	}
 	log.Println("starting server on", addr, " (CORS origin:", allowedOrigin, ")")
-	log.Fatal(http.ListenAndServe(addr, root))
+	log.Fatal(http.ListenAndServe(addr, reqctx.Middleware(root)))
 }
@@ -0,0 +1,7 @@
+//go:build sqlite
+
+package main
+
+// Links the cgo-free SQLite driver for JOB_STORE=sqlite:<path>. Build with
+// -tags sqlite.
+import _ "modernc.org/sqlite"
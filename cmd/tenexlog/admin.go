@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/rules"
+)
+
+type client struct {
+	base       string
+	apiKey     string
+	user, pass string
+	http       *http.Client
+}
+
+func envClient() *client {
+	base := os.Getenv("TENEXLOG_URL")
+	if base == "" {
+		base = "http://localhost:8080"
+	}
+	return &client{
+		base:   strings.TrimSuffix(base, "/"),
+		apiKey: os.Getenv("TENEXLOG_API_KEY"),
+		user:   os.Getenv("TENEXLOG_USER"),
+		pass:   os.Getenv("TENEXLOG_PASS"),
+		http:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// do sends body as JSON and returns the body of a 2xx response; other
+// statuses become errors carrying the server's message.
+func (c *client) do(method, path string, body any) ([]byte, error) {
+	if body == nil {
+		return c.send(method, path, "", nil)
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return c.send(method, path, "application/json", b)
+}
+
+// send is do with a body already encoded as contentType.
+func (c *client) send(method, path, contentType string, body []byte) ([]byte, error) {
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.base+path, rd)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	switch {
+	case c.apiKey != "":
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	case c.user != "":
+		req.SetBasicAuth(c.user, c.pass)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+func runAdmin(c *client, cmd string, args []string) error {
+	switch cmd {
+	case "status":
+		return adminStatus(c)
+	case "sensitive-paths":
+		if len(args) == 0 {
+			return printJSON(c.do(http.MethodGet, "/api/config/sensitive-paths", nil))
+		}
+		if len(args) != 2 || args[0] != "set" {
+			return errors.New("usage: sensitive-paths [set P1,P2,...]")
+		}
+		return printJSON(c.do(http.MethodPut, "/api/config/sensitive-paths", map[string][]string{"paths": strings.Split(args[1], ",")}))
+	case "suppressions":
+		if len(args) == 0 {
+			return printJSON(c.do(http.MethodGet, "/api/config/suppressions", nil))
+		}
+		if len(args) != 2 || args[0] != "set" {
+			return errors.New("usage: suppressions [set FILE.json]")
+		}
+		data, err := os.ReadFile(args[1])
+		if err != nil {
+			return err
+		}
+		return printJSON(c.do(http.MethodPut, "/api/config/suppressions", json.RawMessage(data)))
+	case "deliveries":
+		q := ""
+		if len(args) > 0 {
+			q = "?status=" + url.QueryEscape(args[0])
+		}
+		return printJSON(c.do(http.MethodGet, "/api/notify/deliveries"+q, nil))
+	case "redeliver", "discard":
+		if len(args) != 1 {
+			return errors.New("usage: " + cmd + " ID")
+		}
+		path := "/api/notify/deliveries/" + url.PathEscape(args[0])
+		if cmd == "redeliver" {
+			return printJSON(c.do(http.MethodPost, path+"/redeliver", nil))
+		}
+		_, err := c.do(http.MethodDelete, path, nil)
+		return err
+	case "jobs":
+		return adminJobs(c, args)
+	case "delete-job":
+		if len(args) != 1 {
+			return errors.New("usage: delete-job ID")
+		}
+		_, err := c.do(http.MethodDelete, "/api/jobs/"+url.PathEscape(args[0]), nil)
+		return err
+	case "rules":
+		if len(args) == 0 {
+			return printJSON(c.do(http.MethodGet, "/api/config/rules", nil))
+		}
+		if len(args) != 2 {
+			return errors.New("usage: rules [check FILE.yaml | add FILE.yaml | remove NAME]")
+		}
+		switch args[0] {
+		case "check":
+			list, err := rules.Load(args[1])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s: %d rule(s) OK\n", args[1], len(list))
+			return nil
+		case "add":
+			data, err := os.ReadFile(args[1])
+			if err != nil {
+				return err
+			}
+			if _, err := rules.Parse(data); err != nil {
+				return fmt.Errorf("%s: %w", args[1], err)
+			}
+			return printJSON(c.send(http.MethodPost, "/api/config/rules", "application/yaml", data))
+		case "remove":
+			_, err := c.do(http.MethodDelete, "/api/config/rules/"+url.PathEscape(args[1]), nil)
+			return err
+		}
+		return errors.New("usage: rules [check FILE.yaml | add FILE.yaml | remove NAME]")
+	case "reload":
+		return printJSON(c.do(http.MethodPost, "/api/config/reload", nil))
+	case "purge-jobs":
+		if len(args) != 1 {
+			return errors.New("usage: purge-jobs AGE (e.g. 720h)")
+		}
+		return printJSON(c.do(http.MethodDelete, "/api/jobs?olderThan="+url.QueryEscape(args[0]), nil))
+	case "blocklist":
+		fs := flag.NewFlagSet("blocklist", flag.ContinueOnError)
+		format := fs.String("format", "json", "json or csv")
+		minSev := fs.String("min-severity", "", "low, medium, high or critical")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		q := url.Values{"format": {*format}}
+		if *minSev != "" {
+			q.Set("minSeverity", *minSev)
+		}
+		data, err := c.do(http.MethodGet, "/api/blocklist?"+q.Encode(), nil)
+		if err != nil || *format == "csv" {
+			os.Stdout.Write(data)
+			return err
+		}
+		return printJSON(data, nil)
+	}
+	return fmt.Errorf("unknown admin command %q", cmd)
+}
+
+func adminJobs(c *client, args []string) error {
+	fs := flag.NewFlagSet("jobs", flag.ContinueOnError)
+	limit := fs.Int("limit", 50, "how many jobs to list, newest first (1-500)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	data, err := c.do(http.MethodGet, "/api/jobs?limit="+strconv.Itoa(*limit), nil)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Jobs []struct {
+			ID           string    `json:"id"`
+			Filename     string    `json:"filename"`
+			SizeBytes    int64     `json:"sizeBytes"`
+			Received     time.Time `json:"received"`
+			Format       string    `json:"format"`
+			AnomalyCount int       `json:"anomalyCount"`
+			Status       string    `json:"status"`
+		} `json:"jobs"`
+		Total int `json:"total"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tRECEIVED\tSTATUS\tFORMAT\tANOMALIES\tBYTES\tFILE")
+	for _, j := range resp.Jobs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n", j.ID, j.Received.UTC().Format(time.RFC3339), j.Status, j.Format, j.AnomalyCount, j.SizeBytes, j.Filename)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if resp.Total > len(resp.Jobs) {
+		fmt.Printf("(%d of %d jobs)\n", len(resp.Jobs), resp.Total)
+	}
+	return nil
+}
+
+func adminStatus(c *client) error {
+	data, err := c.do(http.MethodGet, "/api/integrations/status", nil)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Integrations []struct {
+			Kind      string `json:"kind"`
+			Name      string `json:"name"`
+			State     string `json:"state"`
+			Breaker   string `json:"breaker"`
+			Failures  int    `json:"consecutiveFailures"`
+			LastError string `json:"lastError"`
+		} `json:"integrations"`
+		Failing int `json:"failing"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tNAME\tSTATE\tBREAKER\tFAILURES\tLAST ERROR")
+	for _, s := range resp.Integrations {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n", s.Kind, s.Name, s.State, s.Breaker, s.Failures, s.LastError)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if resp.Failing > 0 {
+		return fmt.Errorf("%d integration(s) failing", resp.Failing)
+	}
+	return nil
+}
+
+func printJSON(data []byte, err error) error {
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if json.Indent(&buf, data, "", "  ") != nil {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err = buf.WriteTo(os.Stdout)
+	return err
+}
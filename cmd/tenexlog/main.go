@@ -0,0 +1,48 @@
+// Command tenexlog is the operator CLI. It talks to a running TenexLog API
+// instance over HTTP, so it can be used from a jump host.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `usage: tenexlog admin <command> [args]
+
+Commands:
+  status                          integration health (webhooks, SMTP, feeds, AbuseIPDB)
+  sensitive-paths                 list sensitive path prefixes
+  sensitive-paths set P1,P2,...   replace sensitive path prefixes
+  suppressions                    list anomaly suppressions
+  suppressions set FILE.json      replace suppressions from {"suppressions": [...]}
+  deliveries [pending|dead]       list webhook deliveries
+  redeliver ID                    retry a delivery now
+  discard ID                      drop a delivery
+  jobs [-limit N]                 list jobs, newest first
+  delete-job ID                   delete a finished job, its upload and results
+  purge-jobs AGE                  delete jobs and uploads older than AGE (e.g. 720h)
+  rules                           list the custom rules the server loaded
+  rules check FILE.yaml           validate a rules file locally
+  rules add FILE.yaml             add the rules in a file to the server's
+  rules remove NAME               remove a custom rule
+  reload                          re-read the server's sensitive paths,
+                                  suppressions and rules files
+  blocklist [-format csv] [-min-severity LEVEL]
+                                  export block candidates
+
+The server is taken from TENEXLOG_URL (default http://localhost:8080). The
+client authenticates with TENEXLOG_API_KEY, one of the server's API_KEYS, or
+else with TENEXLOG_USER and TENEXLOG_PASS.
+`
+
+func main() {
+	if len(os.Args) < 3 || os.Args[1] != "admin" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	c := envClient()
+	if err := runAdmin(c, os.Args[2], os.Args[3:]); err != nil {
+		fmt.Fprintln(os.Stderr, "tenexlog:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,129 @@
+package analyze
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// Signals reported by DetectAbnormalUA.
+const (
+	UASignalEmpty    = "empty"
+	UASignalShort    = "single_char"
+	UASignalRotating = "rotating"
+)
+
+type AnomalyAbnormalUA struct {
+	Kind      string    `json:"kind"`
+	SrcIP     string    `json:"srcIp"`
+	Signal    string    `json:"signal"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Count     int       `json:"count"`
+	UniqueUAs int       `json:"uniqueUAs"`
+	Share     float64   `json:"share"` // of requests carrying the signal
+	Reason    string    `json:"reason"`
+}
+
+// DetectAbnormalUA flags IPs with at least minRequests requests whose
+// User-Agent is empty or a single character on most requests, or changes on
+// nearly every request (at least rotateRatio distinct UAs per request).
+// Inputs in which no row carries a User-Agent are skipped, since the source
+// format simply does not record one.
+func DetectAbnormalUA(rows []parse.Event, minRequests int, rotateRatio float64) []AnomalyAbnormalUA {
+	type agg struct {
+		first, last  time.Time
+		count        int
+		empty, short int
+		uas          map[string]struct{}
+	}
+	byIP := make(map[string]*agg)
+	anyUA := false
+
+	for _, ev := range rows {
+		if ev.SrcIP == "" || ev.TS.IsZero() {
+			continue
+		}
+		ua := strings.TrimSpace(ev.UA)
+		if ua == "-" {
+			ua = ""
+		}
+		if ua != "" {
+			anyUA = true
+		}
+		t := ev.TS.UTC()
+		a, ok := byIP[ev.SrcIP]
+		if !ok {
+			a = &agg{first: t, last: t, uas: make(map[string]struct{})}
+			byIP[ev.SrcIP] = a
+		}
+		if t.Before(a.first) {
+			a.first = t
+		}
+		if t.After(a.last) {
+			a.last = t
+		}
+		a.count++
+		switch len([]rune(ua)) {
+		case 0:
+			a.empty++
+		case 1:
+			a.short++
+		}
+		a.uas[ua] = struct{}{}
+	}
+
+	out := make([]AnomalyAbnormalUA, 0)
+	if !anyUA {
+		return out
+	}
+	for ip, a := range byIP {
+		if a.count < minRequests {
+			continue
+		}
+		var signal string
+		var share float64
+		switch {
+		case a.empty*2 > a.count:
+			signal, share = UASignalEmpty, float64(a.empty)/float64(a.count)
+		case a.short*2 > a.count:
+			signal, share = UASignalShort, float64(a.short)/float64(a.count)
+		case float64(len(a.uas)) >= rotateRatio*float64(a.count):
+			signal, share = UASignalRotating, float64(len(a.uas))/float64(a.count)
+		default:
+			continue
+		}
+
+		out = append(out, AnomalyAbnormalUA{
+			Kind:      "abnormal_ua",
+			SrcIP:     ip,
+			Signal:    signal,
+			FirstSeen: a.first,
+			LastSeen:  a.last,
+			Count:     a.count,
+			UniqueUAs: len(a.uas),
+			Share:     round2(share),
+			Reason:    buildAbnormalUAReason(ip, signal, a.count, a.empty+a.short, len(a.uas)),
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].SrcIP < out[j].SrcIP
+	})
+	return out
+}
+
+func buildAbnormalUAReason(ip, signal string, count, bad, unique int) string {
+	switch signal {
+	case UASignalEmpty:
+		return ip + " sent " + intToStr(bad) + " of " + intToStr(count) + " requests with no User-Agent."
+	case UASignalShort:
+		return ip + " sent " + intToStr(bad) + " of " + intToStr(count) + " requests with a single-character User-Agent."
+	}
+	return ip + " used " + intToStr(unique) + " different User-Agents across " + intToStr(count) + " requests."
+}
@@ -0,0 +1,47 @@
+package analyze
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectAbnormalUA(t *testing.T) {
+	const browser = "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0"
+	// from returns 25 requests from ip with the User-Agent ua(i).
+	from := func(ip string, ua func(i int) string) []parse.Event {
+		rows := repeat(parse.Event{TS: t0, SrcIP: ip, Path: "/", Status: 200}, 25, time.Second)
+		for i := range rows {
+			rows[i].UA = ua(i)
+		}
+		return rows
+	}
+	normal := from("192.0.2.1", func(int) string { return browser })
+	tests := []struct {
+		name       string
+		rows       []parse.Event
+		wantSignal string // empty when nothing fires
+	}{
+		{"no User-Agent", append(from("198.51.100.7", func(int) string { return "-" }), normal...), UASignalEmpty},
+		{"single character", append(from("198.51.100.7", func(int) string { return "x" }), normal...), UASignalShort},
+		{"rotating", append(from("198.51.100.7", func(i int) string { return browser + " r" + strconv.Itoa(i) }), normal...), UASignalRotating},
+		{"one browser", normal, ""},
+		{"format without User-Agents", from("198.51.100.7", func(int) string { return "" }), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectAbnormalUA(tt.rows, 20, 0.8)
+			if tt.wantSignal == "" {
+				if len(got) > 0 {
+					t.Errorf("got %+v, want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Kind != "abnormal_ua" || got[0].SrcIP != "198.51.100.7" || got[0].Signal != tt.wantSignal {
+				t.Errorf("got %+v, want %s from 198.51.100.7", got, tt.wantSignal)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package analyze
+
+import (
+	"sort"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// Aggregates are the per-IP, per-path and per-minute counters several
+// detectors share, built in a single pass over the rows so each detector does
+// not rebuild its own maps.
+type Aggregates struct {
+	IPMinute      map[IPMinute]int       // requests per IP and minute
+	IPMinutes     map[string][]time.Time // each IP's active minutes, sorted
+	IPs           map[string]*IPStats    // timestamped rows per IP
+	PathMinute    map[PathMinute]int     // requests per path and minute
+	PathMinuteIPs map[PathMinute]map[string]int
+	PathMinutes   map[string][]time.Time     // each path's active minutes, sorted
+	Minutes       map[time.Time]*MinuteStats // rows with a status, per minute
+	// First and Last bound the minutes of rows with a source IP.
+	First, Last time.Time
+}
+
+type IPMinute struct {
+	IP     string
+	Minute time.Time
+}
+
+type PathMinute struct {
+	Path   string
+	Minute time.Time
+}
+
+type MinuteStats struct {
+	Requests int
+	Errors   int // 4xx and 5xx
+}
+
+// IPStats summarizes one IP's timestamped rows.
+type IPStats struct {
+	First, Last time.Time
+	Requests    int
+	Statuses    int // rows with a status
+	Errors      int // 4xx and 5xx
+	Bytes       int64
+	Paths       map[string]bool
+	UAs         map[string]int
+}
+
+func (s *IPStats) add(ev parse.Event, t time.Time) {
+	if s.Requests == 0 || t.Before(s.First) {
+		s.First = t
+	}
+	if s.Requests == 0 || t.After(s.Last) {
+		s.Last = t
+	}
+	s.Requests++
+	if ev.Status > 0 {
+		s.Statuses++
+		if ev.Status >= 400 {
+			s.Errors++
+		}
+	}
+	s.Bytes += ev.Bytes
+	s.Paths[ev.Path] = true
+	s.UAs[ev.UA]++
+}
+
+func newIPStats() *IPStats {
+	return &IPStats{Paths: make(map[string]bool), UAs: make(map[string]int)}
+}
+
+// Aggregate builds the shared counters for rows.
+func Aggregate(rows []parse.Event) *Aggregates {
+	a := &Aggregates{
+		IPMinute:      make(map[IPMinute]int),
+		IPMinutes:     make(map[string][]time.Time),
+		IPs:           make(map[string]*IPStats),
+		PathMinute:    make(map[PathMinute]int),
+		PathMinuteIPs: make(map[PathMinute]map[string]int),
+		PathMinutes:   make(map[string][]time.Time),
+		Minutes:       make(map[time.Time]*MinuteStats),
+	}
+	for _, ev := range rows {
+		if ev.TS.IsZero() {
+			continue
+		}
+		t := ev.TS.UTC()
+		m := t.Truncate(time.Minute)
+
+		if ev.Status != 0 {
+			ms := a.Minutes[m]
+			if ms == nil {
+				ms = &MinuteStats{}
+				a.Minutes[m] = ms
+			}
+			ms.Requests++
+			if ev.Status >= 400 {
+				ms.Errors++
+			}
+		}
+
+		if ev.Path != "" {
+			k := PathMinute{ev.Path, m}
+			if a.PathMinute[k] == 0 {
+				a.PathMinutes[ev.Path] = append(a.PathMinutes[ev.Path], m)
+			}
+			a.PathMinute[k]++
+			if ev.SrcIP != "" {
+				if a.PathMinuteIPs[k] == nil {
+					a.PathMinuteIPs[k] = make(map[string]int)
+				}
+				a.PathMinuteIPs[k][ev.SrcIP]++
+			}
+		}
+
+		if ev.SrcIP == "" {
+			continue
+		}
+		k := IPMinute{ev.SrcIP, m}
+		if a.IPMinute[k] == 0 {
+			a.IPMinutes[ev.SrcIP] = append(a.IPMinutes[ev.SrcIP], m)
+		}
+		a.IPMinute[k]++
+		s := a.IPs[ev.SrcIP]
+		if s == nil {
+			s = newIPStats()
+			a.IPs[ev.SrcIP] = s
+		}
+		s.add(ev, t)
+		if a.First.IsZero() || m.Before(a.First) {
+			a.First = m
+		}
+		if m.After(a.Last) {
+			a.Last = m
+		}
+	}
+	for _, mins := range a.IPMinutes {
+		sortTimes(mins)
+	}
+	for _, mins := range a.PathMinutes {
+		sortTimes(mins)
+	}
+	return a
+}
+
+func sortTimes(ts []time.Time) {
+	sort.Slice(ts, func(i, j int) bool { return ts[i].Before(ts[j]) })
+}
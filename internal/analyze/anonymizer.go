@@ -0,0 +1,89 @@
+package analyze
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+type AnomalyAnonymizedProbe struct {
+	Kind       string    `json:"kind"`
+	SrcIP      string    `json:"srcIp"`
+	Anonymizer string    `json:"anonymizer"`
+	FirstSeen  time.Time `json:"firstSeen"`
+	LastSeen   time.Time `json:"lastSeen"`
+	Hits       int       `json:"hits"`
+	Paths      []string  `json:"paths"` // distinct sensitive paths, first few
+	Unique     int       `json:"unique"`
+	Reason     string    `json:"reason"`
+}
+
+const maxProbePaths = 5
+
+// DetectAnonymizedProbes flags IPs tagged as TOR exits, VPNs or proxies
+// (Event.Anonymizer) that requested at least minHits sensitive paths. Probing
+// admin panels and secrets through an anonymizer is rarely legitimate, so
+// the bar is lower than for sensitive-path probing in general.
+func DetectAnonymizedProbes(rows []parse.Event, minHits int) []AnomalyAnonymizedProbe {
+	sensitive := SensitiveMatcher()
+	found := make(map[string]*AnomalyAnonymizedProbe)
+	seen := make(map[string]map[string]bool)
+	for _, ev := range rows {
+		if ev.Anonymizer == "" || ev.SrcIP == "" || ev.TS.IsZero() || !sensitive(ev.Path) {
+			continue
+		}
+		t := ev.TS.UTC()
+		a, ok := found[ev.SrcIP]
+		if !ok {
+			a = &AnomalyAnonymizedProbe{Kind: "anonymized_probe", SrcIP: ev.SrcIP, Anonymizer: ev.Anonymizer, FirstSeen: t, LastSeen: t}
+			found[ev.SrcIP] = a
+			seen[ev.SrcIP] = make(map[string]bool)
+		}
+		if t.Before(a.FirstSeen) {
+			a.FirstSeen = t
+		}
+		if t.After(a.LastSeen) {
+			a.LastSeen = t
+		}
+		a.Hits++
+		if !seen[ev.SrcIP][ev.Path] {
+			seen[ev.SrcIP][ev.Path] = true
+			a.Unique++
+			if len(a.Paths) < maxProbePaths {
+				a.Paths = append(a.Paths, ev.Path)
+			}
+		}
+	}
+
+	out := make([]AnomalyAnonymizedProbe, 0, len(found))
+	for _, a := range found {
+		if a.Hits < minHits {
+			continue
+		}
+		a.Reason = "Sensitive paths requested through " + anonymizerLabel(a.Anonymizer) + " " + a.SrcIP + ": " +
+			intToStr(a.Hits) + " request(s) to " + intToStr(a.Unique) + " path(s) (" + strings.Join(a.Paths, ", ") +
+			") between " + a.FirstSeen.Format("15:04") + " and " + a.LastSeen.Format("15:04") + " UTC."
+		out = append(out, *a)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Hits != out[j].Hits {
+			return out[i].Hits > out[j].Hits
+		}
+		return out[i].SrcIP < out[j].SrcIP
+	})
+	return out
+}
+
+func anonymizerLabel(name string) string {
+	switch strings.ToLower(name) {
+	case "tor":
+		return "TOR exit node"
+	case "vpn":
+		return "VPN endpoint"
+	case "proxy":
+		return "open proxy"
+	}
+	return name + " address"
+}
@@ -0,0 +1,39 @@
+package analyze
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectAnonymizedProbes(t *testing.T) {
+	probe := func(anonymizer, path string) parse.Event {
+		return parse.Event{TS: t0, SrcIP: "185.220.101.4", Method: "GET", Path: path, Status: 404, Anonymizer: anonymizer}
+	}
+	tests := []struct {
+		name      string
+		rows      []parse.Event
+		wantLabel string // "" when nothing fires
+	}{
+		{"secrets through TOR", append(repeat(probe("tor", "/.env"), 2, time.Second), probe("tor", "/.git/config")), "TOR exit node"},
+		{"admin panel through a proxy", []parse.Event{probe("proxy", "/wp-admin")}, "open proxy"},
+		{"ordinary pages through TOR", repeat(probe("tor", "/blog"), 5, time.Second), ""},
+		{"secrets from an untagged address", []parse.Event{probe("", "/.env")}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectAnonymizedProbes(tt.rows, 1)
+			if tt.wantLabel == "" {
+				if len(got) > 0 {
+					t.Errorf("got %+v, want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Kind != "anonymized_probe" || got[0].Hits != len(tt.rows) || !strings.Contains(got[0].Reason, tt.wantLabel) {
+				t.Errorf("got %+v, want one probe through a %s", got, tt.wantLabel)
+			}
+		})
+	}
+}
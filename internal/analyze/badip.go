@@ -0,0 +1,96 @@
+package analyze
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// IntelLookup returns the names of the threat-intel feeds listing ip.
+type IntelLookup func(ip string) []string
+
+type AnomalyKnownBad struct {
+	Kind      string    `json:"kind"`
+	SrcIP     string    `json:"srcIp"`
+	Feeds     []string  `json:"feeds"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Count     int       `json:"count"`
+	Succeeded int       `json:"succeeded"`
+	Reason    string    `json:"reason"`
+}
+
+// DetectKnownBadIPs flags every source IP listed by at least one feed.
+func DetectKnownBadIPs(rows []parse.Event, intel IntelLookup) []AnomalyKnownBad {
+	if intel == nil {
+		return nil
+	}
+	type agg struct {
+		feeds       []string
+		first, last time.Time
+		count, ok   int
+	}
+	byIP := make(map[string]*agg)
+	checked := make(map[string]bool)
+
+	for _, ev := range rows {
+		if ev.SrcIP == "" || ev.TS.IsZero() {
+			continue
+		}
+		a, ok := byIP[ev.SrcIP]
+		if !ok {
+			if checked[ev.SrcIP] {
+				continue
+			}
+			checked[ev.SrcIP] = true
+			fs := intel(ev.SrcIP)
+			if len(fs) == 0 {
+				continue
+			}
+			t := ev.TS.UTC()
+			a = &agg{feeds: fs, first: t, last: t}
+			byIP[ev.SrcIP] = a
+		}
+		t := ev.TS.UTC()
+		if t.Before(a.first) {
+			a.first = t
+		}
+		if t.After(a.last) {
+			a.last = t
+		}
+		a.count++
+		if ev.Status >= 200 && ev.Status < 300 {
+			a.ok++
+		}
+	}
+
+	out := make([]AnomalyKnownBad, 0)
+	for ip, a := range byIP {
+		sort.Strings(a.feeds)
+		reason := ip + " is listed by " + strings.Join(a.feeds, ", ") + " and sent " + intToStr(a.count) +
+			" request(s) between " + a.first.Format("15:04") + " and " + a.last.Format("15:04") + " UTC"
+		if a.ok > 0 {
+			reason += "; " + intToStr(a.ok) + " received 2xx"
+		}
+		out = append(out, AnomalyKnownBad{
+			Kind:      "known_bad_ip",
+			SrcIP:     ip,
+			Feeds:     a.feeds,
+			FirstSeen: a.first,
+			LastSeen:  a.last,
+			Count:     a.count,
+			Succeeded: a.ok,
+			Reason:    reason + ".",
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].SrcIP < out[j].SrcIP
+	})
+	return out
+}
@@ -0,0 +1,48 @@
+package analyze
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectKnownBadIPs(t *testing.T) {
+	intel := func(ip string) []string {
+		if ip == "198.51.100.7" {
+			return []string{"spamhaus-drop", "firehol"}
+		}
+		return nil
+	}
+	bad := parse.Event{TS: t0, SrcIP: "198.51.100.7", Path: "/", Status: 200}
+	good := parse.Event{TS: t0, SrcIP: "192.0.2.1", Path: "/", Status: 200}
+	tests := []struct {
+		name          string
+		rows          []parse.Event
+		wantCount     int // 0 when nothing fires
+		wantSucceeded int
+	}{
+		{"listed source", append(repeat(bad, 3, time.Minute), parse.Event{TS: t0.Add(time.Hour), SrcIP: "198.51.100.7", Path: "/admin", Status: 403}), 4, 3},
+		{"listed source among others", append(repeat(good, 5, time.Second), bad), 1, 1},
+		{"unlisted sources", repeat(good, 5, time.Second), 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectKnownBadIPs(tt.rows, intel)
+			if tt.wantCount == 0 {
+				if len(got) > 0 {
+					t.Errorf("got %+v, want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Kind != "known_bad_ip" || got[0].Count != tt.wantCount || got[0].Succeeded != tt.wantSucceeded ||
+				!slices.Equal(got[0].Feeds, []string{"firehol", "spamhaus-drop"}) {
+				t.Errorf("got %+v, want %d requests, %d succeeded", got, tt.wantCount, tt.wantSucceeded)
+			}
+		})
+	}
+	if got := DetectKnownBadIPs([]parse.Event{bad}, nil); got != nil {
+		t.Errorf("without feeds got %+v, want nothing", got)
+	}
+}
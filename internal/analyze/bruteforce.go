@@ -0,0 +1,143 @@
+package analyze
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+var LoginPaths = []string{
+	"/login", "/signin", "/sign-in", "/logon", "/auth", "/session",
+	"/oauth/token", "/oauth2/token", "/token", "/wp-login.php",
+	"/user/login", "/users/sign_in", "/api/login", "/api/auth",
+}
+
+var usernameParams = []string{"username", "user", "login", "email", "user_name", "userid", "uid", "log"}
+
+type AnomalyBruteForce struct {
+	Kind      string    `json:"kind"`
+	SrcIP     string    `json:"srcIp"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Failures  int       `json:"failures"`
+	Peak      int       `json:"peak"`
+	Usernames int       `json:"usernames"`
+	Reason    string    `json:"reason"`
+}
+
+// DetectAuthBruteForce flags IPs whose 401/403 responses on login-like paths
+// reach minFailures within any window of the given length.
+func DetectAuthBruteForce(rows []parse.Event, minFailures int, window time.Duration) []AnomalyBruteForce {
+	failTimes := make(map[string][]time.Time)
+	users := make(map[string]map[string]struct{})
+
+	for _, ev := range rows {
+		if ev.SrcIP == "" || ev.TS.IsZero() {
+			continue
+		}
+		if !IsLoginFailure(ev) {
+			continue
+		}
+		failTimes[ev.SrcIP] = append(failTimes[ev.SrcIP], ev.TS.UTC())
+		if u := usernameOf(ev); u != "" {
+			if users[ev.SrcIP] == nil {
+				users[ev.SrcIP] = make(map[string]struct{})
+			}
+			users[ev.SrcIP][strings.ToLower(u)] = struct{}{}
+		}
+	}
+
+	out := make([]AnomalyBruteForce, 0)
+	for ip, ts := range failTimes {
+		sort.Slice(ts, func(i, j int) bool { return ts[i].Before(ts[j]) })
+		peak := peakInWindow(ts, window)
+		if peak < minFailures {
+			continue
+		}
+		uniq := len(users[ip])
+
+		out = append(out, AnomalyBruteForce{
+			Kind:      "auth_bruteforce",
+			SrcIP:     ip,
+			FirstSeen: ts[0],
+			LastSeen:  ts[len(ts)-1],
+			Failures:  len(ts),
+			Peak:      peak,
+			Usernames: uniq,
+			Reason:    buildBruteForceReason(ip, len(ts), peak, uniq, window),
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+	return out
+}
+
+// IsLoginFailure reports whether ev is a 401 or 403 on a login path, the
+// events DetectAuthBruteForce counts.
+func IsLoginFailure(ev parse.Event) bool {
+	return (ev.Status == 401 || ev.Status == 403) && IsLoginPath(ev.Path)
+}
+
+// IsLoginPath reports whether path looks like a login endpoint: whether it
+// starts or ends with the segments of one of LoginPaths, so /login/sso and
+// /app/login match but /loginhelp, /blog/login-tips and /authors do not.
+func IsLoginPath(path string) bool {
+	path, _, _ = strings.Cut(path, "?")
+	segs := pathSegments(strings.ToLower(path))
+	for _, p := range LoginPaths {
+		ps := pathSegments(p)
+		if len(ps) > len(segs) {
+			continue
+		}
+		if slices.Equal(segs[:len(ps)], ps) || slices.Equal(segs[len(segs)-len(ps):], ps) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathSegments splits path at its slashes, leaving out empty segments.
+func pathSegments(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+}
+
+func usernameOf(ev parse.Event) string {
+	if ev.User != "" {
+		return ev.User
+	}
+	for _, k := range usernameParams {
+		if v := ev.Query.Get(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// peakInWindow returns the largest number of sorted timestamps that fall
+// within any span of length w.
+func peakInWindow(ts []time.Time, w time.Duration) int {
+	peak, lo := 0, 0
+	for hi := range ts {
+		for ts[hi].Sub(ts[lo]) > w {
+			lo++
+		}
+		if n := hi - lo + 1; n > peak {
+			peak = n
+		}
+	}
+	return peak
+}
+
+func buildBruteForceReason(ip string, failures, peak, users int, window time.Duration) string {
+	r := "Repeated authentication failures from " + ip + ": " + intToStr(failures) +
+		" failed logins, peaking at " + intToStr(peak) + " within " + window.String()
+	if users >= 3 {
+		r += " across " + intToStr(users) + " distinct usernames (likely credential stuffing)"
+	} else if users > 0 {
+		r += " against " + intToStr(users) + " username(s)"
+	}
+	return r + "."
+}
@@ -0,0 +1,78 @@
+package analyze
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// t0 is the start of every fixture's timeline.
+var t0 = time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)
+
+// repeat returns n copies of ev, each step after the one before.
+func repeat(ev parse.Event, n int, step time.Duration) []parse.Event {
+	rows := make([]parse.Event, n)
+	for i := range rows {
+		rows[i] = ev
+		rows[i].TS = ev.TS.Add(time.Duration(i) * step)
+	}
+	return rows
+}
+
+func TestDetectAuthBruteForce(t *testing.T) {
+	failure := parse.Event{TS: t0, SrcIP: "203.0.113.9", Method: "POST", Path: "/login", Status: 401}
+	stuffing := repeat(failure, 12, 10*time.Second)
+	for i := range stuffing {
+		stuffing[i].Query = url.Values{"username": {"user" + intToStr(i%4)}}
+	}
+	tests := []struct {
+		name      string
+		rows      []parse.Event
+		wantFires bool
+		wantUsers int
+	}{
+		{"credential stuffing", stuffing, true, 4},
+		{"failures spread over hours", repeat(failure, 12, 10*time.Minute), false, 0},
+		{"failures off a login path", repeat(parse.Event{TS: t0, SrcIP: "203.0.113.9", Path: "/blog/login-tips", Status: 403}, 12, time.Second), false, 0},
+		{"successful logins", repeat(parse.Event{TS: t0, SrcIP: "203.0.113.9", Path: "/login", Status: 200}, 12, time.Second), false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectAuthBruteForce(tt.rows, 10, 5*time.Minute)
+			if (len(got) > 0) != tt.wantFires {
+				t.Fatalf("got %d anomalies, want fires=%v", len(got), tt.wantFires)
+			}
+			if tt.wantFires && (got[0].Kind != "auth_bruteforce" || got[0].SrcIP != "203.0.113.9" || got[0].Usernames != tt.wantUsers) {
+				t.Errorf("got %+v", got[0])
+			}
+		})
+	}
+}
+
+func TestIsLoginPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/login", true},
+		{"/LOGIN?next=/", true},
+		{"/login/sso", true},
+		{"/app/login", true},
+		{"/api/auth", true},
+		{"/oauth/token", true},
+		{"/v1/oauth/token", true},
+		{"/loginhelp", false},
+		{"/blog/login-tips", false},
+		{"/authors", false},
+		{"/oauth", false},
+		{"/", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsLoginPath(tt.path); got != tt.want {
+			t.Errorf("IsLoginPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package analyze
+
+import (
+	"sort"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+type AnomalyCampaign struct {
+	Kind      string    `json:"kind"`
+	Template  string    `json:"template"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Count     int       `json:"count"`
+	UniqueIPs int       `json:"uniqueIps"`
+	Failures  int       `json:"failures"`
+	Baseline  float64   `json:"baseline"`
+	TopShare  float64   `json:"topShare"`
+	IPs       []string  `json:"ips"` // every participating IP, busiest first
+	Reason    string    `json:"reason"`
+}
+
+// DetectCampaigns groups requests by path template and window and flags
+// windows in which at least minIPs distinct IPs hit one endpoint while the
+// busiest of them sent no more than maxShare of the requests, i.e. many
+// sources each staying under per-IP thresholds. A window must also reach
+// three times the template's median distinct IPs per active window; when the
+// log covers a single window, at least half the requests must have failed
+// (4xx/5xx) instead. Consecutive flagged windows merge into one campaign.
+func DetectCampaigns(rows []parse.Event, window time.Duration, minIPs int, maxShare float64) []AnomalyCampaign {
+	type bucket struct {
+		count, failures int
+		ips             map[string]int
+	}
+	byTemplate := make(map[string]map[time.Time]*bucket)
+
+	for _, ev := range rows {
+		if ev.SrcIP == "" || ev.TS.IsZero() || ev.Path == "" {
+			continue
+		}
+		tmpl, _, _ := PathTemplate(ev.Path)
+		w := ev.TS.UTC().Truncate(window)
+		bs := byTemplate[tmpl]
+		if bs == nil {
+			bs = make(map[time.Time]*bucket)
+			byTemplate[tmpl] = bs
+		}
+		b := bs[w]
+		if b == nil {
+			b = &bucket{ips: make(map[string]int)}
+			bs[w] = b
+		}
+		b.count++
+		b.ips[ev.SrcIP]++
+		if ev.Status >= 400 {
+			b.failures++
+		}
+	}
+
+	var out []AnomalyCampaign
+	for tmpl, bs := range byTemplate {
+		starts := make([]time.Time, 0, len(bs))
+		uniq := make([]float64, 0, len(bs))
+		for w, b := range bs {
+			starts = append(starts, w)
+			uniq = append(uniq, float64(len(b.ips)))
+		}
+		sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+		sort.Float64s(uniq)
+		median := uniq[len(uniq)/2]
+
+		hot := func(b *bucket) bool {
+			if len(b.ips) < minIPs {
+				return false
+			}
+			top := 0
+			for _, n := range b.ips {
+				top = max(top, n)
+			}
+			if float64(top) > maxShare*float64(b.count) {
+				return false
+			}
+			if len(bs) == 1 {
+				return b.failures*2 >= b.count
+			}
+			return float64(len(b.ips)) >= 3*median
+		}
+
+		var cur *AnomalyCampaign
+		var members map[string]int
+		flush := func() {
+			if cur == nil {
+				return
+			}
+			cur.UniqueIPs = len(members)
+			cur.IPs = make([]string, 0, len(members))
+			top := 0
+			for ip, n := range members {
+				cur.IPs = append(cur.IPs, ip)
+				top = max(top, n)
+			}
+			sort.Slice(cur.IPs, func(i, j int) bool {
+				a, b := members[cur.IPs[i]], members[cur.IPs[j]]
+				if a != b {
+					return a > b
+				}
+				return cur.IPs[i] < cur.IPs[j]
+			})
+			cur.TopShare = round2(float64(top) / float64(cur.Count))
+			typical := ""
+			if len(bs) > 1 {
+				typical = " (typical " + floatToStr(round2(cur.Baseline)) + " per window)"
+			}
+			cur.Reason = "Distributed attack on " + tmpl + " between " + cur.FirstSeen.Format("15:04") + " and " +
+				cur.LastSeen.Format("15:04") + " UTC: " + intToStr(cur.Count) + " request(s) from " +
+				intToStr(cur.UniqueIPs) + " IPs" + typical + ", the busiest sending " +
+				intToStr(int(cur.TopShare*100+0.5)) + "%; " + intToStr(cur.Failures) + " failed."
+			out = append(out, *cur)
+			cur, members = nil, nil
+		}
+
+		for _, w := range starts {
+			b := bs[w]
+			if !hot(b) {
+				flush()
+				continue
+			}
+			if cur == nil {
+				cur = &AnomalyCampaign{Kind: "distributed_attack", Template: tmpl, FirstSeen: w, Baseline: median}
+				members = make(map[string]int)
+			}
+			cur.LastSeen = w.Add(window - time.Second)
+			cur.Count += b.count
+			cur.Failures += b.failures
+			for ip, n := range b.ips {
+				members[ip] += n
+			}
+		}
+		flush()
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].UniqueIPs != out[j].UniqueIPs {
+			return out[i].UniqueIPs > out[j].UniqueIPs
+		}
+		return out[i].FirstSeen.Before(out[j].FirstSeen)
+	})
+	return out
+}
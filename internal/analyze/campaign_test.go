@@ -0,0 +1,51 @@
+package analyze
+
+import (
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectCampaigns(t *testing.T) {
+	// swarm sends two requests to /login from each of n IPs starting at start.
+	swarm := func(start time.Time, n, status int) []parse.Event {
+		var rows []parse.Event
+		for i := range 2 * n {
+			ip := "198.51.100." + intToStr(i%n)
+			rows = append(rows, parse.Event{TS: start.Add(time.Duration(i) * time.Second), SrcIP: ip, Method: "POST", Path: "/login", Status: status})
+		}
+		return rows
+	}
+	// quiet is an hour of three regular users logging in.
+	var quiet []parse.Event
+	for w := range 6 {
+		quiet = append(quiet, swarm(t0.Add(time.Duration(w)*10*time.Minute), 3, 200)...)
+	}
+	dominated := append(swarm(t0, 60, 401), repeat(parse.Event{TS: t0, SrcIP: "203.0.113.9", Method: "POST", Path: "/login", Status: 401}, 100, time.Second)...)
+	tests := []struct {
+		name      string
+		rows      []parse.Event
+		wantFires bool
+	}{
+		{"failed logins from many sources", swarm(t0, 60, 401), true},
+		{"surge over the usual audience", append(quiet, swarm(t0.Add(time.Hour), 60, 200)...), true},
+		{"busy single window of successes", swarm(t0, 60, 200), false},
+		{"regular users only", quiet, false},
+		{"one source dominates", dominated, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectCampaigns(tt.rows, 10*time.Minute, 50, 0.1)
+			if !tt.wantFires {
+				if len(got) > 0 {
+					t.Errorf("got %+v, want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Kind != "distributed_attack" || got[0].Template != "/login" || got[0].UniqueIPs != 60 || got[0].Count != 120 {
+				t.Errorf("got %+v, want one campaign of 60 IPs", got)
+			}
+		})
+	}
+}
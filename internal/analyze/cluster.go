@@ -0,0 +1,191 @@
+package analyze
+
+import (
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"time"
+)
+
+type AnomalyBehaviorCluster struct {
+	Kind        string    `json:"kind"`
+	SrcIP       string    `json:"srcIp"`
+	Profile     Profile   `json:"profile"`
+	ClusterSize int       `json:"clusterSize"`
+	Sources     int       `json:"sources"`
+	Distance    float64   `json:"distance"`
+	Traits      []string  `json:"traits"`
+	FirstSeen   time.Time `json:"firstSeen"`
+	LastSeen    time.Time `json:"lastSeen"`
+	Reason      string    `json:"reason"`
+}
+
+const (
+	kmeansMaxK    = 8
+	kmeansRounds  = 50
+	clusterTraits = 2
+)
+
+// DetectBehaviorClusters groups the profiles of IPs with at least
+// minRequests requests with k-means and flags every member of a small
+// cluster far from the rest: one holding at most maxShare of the sources
+// (and at least one) whose centroid lies at least minDistance standard
+// deviations from the overall mean. It needs minSources profiles to say
+// anything about what normal looks like. Traits name the features that
+// set the cluster apart most.
+func DetectBehaviorClusters(agg *Aggregates, minRequests, minSources int, maxShare, minDistance float64) []AnomalyBehaviorCluster {
+	profiles := Profiles(agg, minRequests)
+	if len(profiles) < minSources {
+		return nil
+	}
+	xs := standardize(profiles)
+	k := min(kmeansMaxK, int(math.Ceil(math.Sqrt(float64(len(xs))/2))))
+	assign, centroids := kmeans(xs, k)
+
+	sizes := make([]int, len(centroids))
+	for _, c := range assign {
+		sizes[c]++
+	}
+	maxSize := max(1, int(maxShare*float64(len(xs))))
+
+	var out []AnomalyBehaviorCluster
+	for i, p := range profiles {
+		c := assign[i]
+		dist := norm(centroids[c])
+		if sizes[c] > maxSize || dist < minDistance {
+			continue
+		}
+		traits := clusterTraitsOf(centroids[c])
+		a := AnomalyBehaviorCluster{
+			Kind:        "behavior_cluster",
+			SrcIP:       p.SrcIP,
+			Profile:     p,
+			ClusterSize: sizes[c],
+			Sources:     len(profiles),
+			Distance:    round2(dist),
+			Traits:      traits,
+			FirstSeen:   p.FirstSeen,
+			LastSeen:    p.LastSeen,
+		}
+		a.Reason = p.SrcIP + " behaves unlike the other sources: it falls in a cluster of " + intToStr(sizes[c]) +
+			" of " + intToStr(len(profiles)) + " IPs set apart by " + strings.Join(traits, " and ") + " (" +
+			floatToStr(p.RatePerMin) + " req/min, " + intToStr(int(p.ErrorRatio*100+0.5)) + "% errors, " +
+			intToStr(p.UniquePaths) + " unique paths, UA entropy " + floatToStr(p.UAEntropy) + " bits, mean " +
+			formatBytes(int64(p.MeanBytes)) + ")."
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Distance != out[j].Distance {
+			return out[i].Distance > out[j].Distance
+		}
+		return out[i].SrcIP < out[j].SrcIP
+	})
+	return out
+}
+
+// kmeans clusters xs into at most k groups with k-means++ seeding from a
+// fixed seed, so the same log always clusters the same way.
+func kmeans(xs [][]float64, k int) (assign []int, centroids [][]float64) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	centroids = append(centroids, clone(xs[rng.IntN(len(xs))]))
+	d2 := make([]float64, len(xs))
+	for len(centroids) < k {
+		var total float64
+		for i, x := range xs {
+			d2[i] = math.Inf(1)
+			for _, c := range centroids {
+				d2[i] = min(d2[i], sqDist(x, c))
+			}
+			total += d2[i]
+		}
+		if total == 0 {
+			break
+		}
+		r := rng.Float64() * total
+		next := len(xs) - 1
+		for i, d := range d2 {
+			if r -= d; r <= 0 {
+				next = i
+				break
+			}
+		}
+		centroids = append(centroids, clone(xs[next]))
+	}
+
+	assign = make([]int, len(xs))
+	for round := 0; round < kmeansRounds; round++ {
+		changed := false
+		for i, x := range xs {
+			best := 0
+			for c := range centroids {
+				if sqDist(x, centroids[c]) < sqDist(x, centroids[best]) {
+					best = c
+				}
+			}
+			if assign[i] != best {
+				assign[i] = best
+				changed = true
+			}
+		}
+		counts := make([]int, len(centroids))
+		sums := make([][]float64, len(centroids))
+		for c := range sums {
+			sums[c] = make([]float64, len(xs[0]))
+		}
+		for i, x := range xs {
+			counts[assign[i]]++
+			for j, v := range x {
+				sums[assign[i]][j] += v
+			}
+		}
+		for c := range centroids {
+			if counts[c] == 0 {
+				continue
+			}
+			for j := range sums[c] {
+				centroids[c][j] = sums[c][j] / float64(counts[c])
+			}
+		}
+		if !changed && round > 0 {
+			break
+		}
+	}
+	return assign, centroids
+}
+
+// clusterTraitsOf describes the features of a standardized centroid that
+// stray furthest from the mean, such as "high error ratio".
+func clusterTraitsOf(centroid []float64) []string {
+	idx := make([]int, len(centroid))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return math.Abs(centroid[idx[a]]) > math.Abs(centroid[idx[b]]) })
+	var out []string
+	for _, j := range idx[:min(clusterTraits, len(idx))] {
+		dir := "high "
+		if centroid[j] < 0 {
+			dir = "low "
+		}
+		out = append(out, dir+profileFeatures[j])
+	}
+	return out
+}
+
+func sqDist(a, b []float64) float64 {
+	var s float64
+	for i := range a {
+		d := a[i] - b[i]
+		s += d * d
+	}
+	return s
+}
+
+func norm(x []float64) float64 {
+	return math.Sqrt(sqDist(x, make([]float64, len(x))))
+}
+
+func clone(x []float64) []float64 {
+	return append([]float64(nil), x...)
+}
@@ -0,0 +1,65 @@
+package analyze
+
+import (
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// browse returns n page views from ip, one a minute from start over a few
+// pages, sized around 2 KB with one browser User-Agent.
+func browse(ip string, start time.Time, n int) []parse.Event {
+	rows := make([]parse.Event, n)
+	for i := range rows {
+		rows[i] = parse.Event{TS: start.Add(time.Duration(i) * time.Minute), SrcIP: ip, Method: "GET", Path: "/page/" + intToStr(i%4),
+			Status: 200, Bytes: int64(2000 + 37*i%300), UA: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Firefox/126.0"}
+	}
+	return rows
+}
+
+// probe returns n small 404s from ip within one minute from start, each for a
+// different path with a rotating User-Agent.
+func probe(ip string, start time.Time, n int) []parse.Event {
+	uas := []string{"curl/8.5.0", "python-requests/2.31", "Go-http-client/1.1", "Wget/1.21"}
+	rows := make([]parse.Event, n)
+	for i := range rows {
+		rows[i] = parse.Event{TS: start.Add(time.Duration(i) * time.Minute / time.Duration(n)), SrcIP: ip, Method: "GET", Path: "/probe/" + intToStr(i),
+			Status: 404, Bytes: 150, UA: uas[i%len(uas)]}
+	}
+	return rows
+}
+
+func TestDetectBehaviorClusters(t *testing.T) {
+	// crowd returns n browsing IPs, each starting a minute after the last.
+	crowd := func(n int) []parse.Event {
+		var rows []parse.Event
+		for i := range n {
+			rows = append(rows, browse("192.0.2."+intToStr(i), t0.Add(time.Duration(i)*time.Minute), 10+i%5)...)
+		}
+		return rows
+	}
+	scanners := append(probe("203.0.113.9", t0, 60), probe("203.0.113.10", t0.Add(time.Hour), 60)...)
+	tests := []struct {
+		name    string
+		rows    []parse.Event
+		wantIPs []string // nil when nothing fires
+	}{
+		{"two scanners among browsers", append(crowd(40), scanners...), []string{"203.0.113.10", "203.0.113.9"}},
+		{"browsers only", crowd(40), nil},
+		{"too few sources to cluster", append(crowd(10), scanners...), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectBehaviorClusters(Aggregate(tt.rows), 5, 20, 0.05, 3)
+			if len(got) != len(tt.wantIPs) {
+				t.Fatalf("got %+v, want %v flagged", got, tt.wantIPs)
+			}
+			for i, a := range got {
+				if a.Kind != "behavior_cluster" || a.SrcIP != tt.wantIPs[i] || a.ClusterSize != 2 || len(a.Traits) != 2 {
+					t.Errorf("got %+v, want %s in a cluster of 2", a, tt.wantIPs[i])
+				}
+			}
+		})
+	}
+}
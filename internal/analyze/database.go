@@ -0,0 +1,142 @@
+package analyze
+
+import (
+	"sort"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+type AnomalyDBPrivilege struct {
+	Kind      string    `json:"kind"`
+	SrcIP     string    `json:"srcIp"`
+	User      string    `json:"user,omitempty"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Count     int       `json:"count"`
+	Peak      int       `json:"peak"`
+	Reason    string    `json:"reason"`
+}
+
+type AnomalyDBBulkSelect struct {
+	Kind     string    `json:"kind"`
+	SrcIP    string    `json:"srcIp"`
+	User     string    `json:"user,omitempty"`
+	Minute   time.Time `json:"minute"`
+	Count    int       `json:"count"`
+	Baseline float64   `json:"baseline"`
+	Reason   string    `json:"reason"`
+}
+
+// dbClient identifies a database client by address and account; either may
+// be empty depending on what the server logged.
+type dbClient struct{ ip, user string }
+
+func (c dbClient) String() string {
+	switch {
+	case c.ip == "":
+		return c.user
+	case c.user == "":
+		return c.ip
+	}
+	return c.user + "@" + c.ip
+}
+
+// DetectPrivilegeBursts flags database clients issuing at least minStatements
+// privilege statements (GRANT, REVOKE, CREATE/ALTER USER or ROLE, SET ROLE)
+// within any window.
+func DetectPrivilegeBursts(rows []parse.Event, minStatements int, window time.Duration) []AnomalyDBPrivilege {
+	byClient := make(map[dbClient][]time.Time)
+	for _, ev := range rows {
+		if ev.Category != parse.CategoryPrivilege || ev.TS.IsZero() {
+			continue
+		}
+		c := dbClient{ip: ev.SrcIP, user: ev.User}
+		byClient[c] = append(byClient[c], ev.TS.UTC())
+	}
+
+	out := make([]AnomalyDBPrivilege, 0)
+	for c, ts := range byClient {
+		sort.Slice(ts, func(i, j int) bool { return ts[i].Before(ts[j]) })
+		peak := peakInWindow(ts, window)
+		if peak < minStatements {
+			continue
+		}
+		first, last := ts[0], ts[len(ts)-1]
+		out = append(out, AnomalyDBPrivilege{
+			Kind:      "db_privilege_burst",
+			SrcIP:     c.ip,
+			User:      c.user,
+			FirstSeen: first,
+			LastSeen:  last,
+			Count:     len(ts),
+			Peak:      peak,
+			Reason: "Database client " + c.String() + " issued " + intToStr(peak) +
+				" privilege statement(s) within " + window.String() + " (" + intToStr(len(ts)) +
+				" total between " + first.Format("15:04") + " and " + last.Format("15:04") + " UTC).",
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+	return out
+}
+
+// DetectBulkSelects flags minutes in which a database client ran at least
+// minPerMin SELECT-type statements and at least factor times its own median
+// per-minute read volume.
+func DetectBulkSelects(rows []parse.Event, minPerMin int, factor float64) []AnomalyDBBulkSelect {
+	perMin := make(map[dbClient]map[time.Time]int)
+	for _, ev := range rows {
+		if ev.Category != parse.CategoryRead || ev.TS.IsZero() {
+			continue
+		}
+		c := dbClient{ip: ev.SrcIP, user: ev.User}
+		m, ok := perMin[c]
+		if !ok {
+			m = make(map[time.Time]int)
+			perMin[c] = m
+		}
+		m[ev.TS.UTC().Truncate(time.Minute)]++
+	}
+
+	out := make([]AnomalyDBBulkSelect, 0)
+	for c, m := range perMin {
+		counts := make([]float64, 0, len(m))
+		for _, n := range m {
+			counts = append(counts, float64(n))
+		}
+		sort.Float64s(counts)
+		median := counts[len(counts)/2]
+		if len(counts)%2 == 0 {
+			median = (counts[len(counts)/2-1] + counts[len(counts)/2]) / 2
+		}
+		// A client seen for a single minute has no baseline of its own.
+		if len(counts) == 1 {
+			median = 0
+		}
+
+		for minute, n := range m {
+			if n < minPerMin || float64(n) < factor*median {
+				continue
+			}
+			out = append(out, AnomalyDBBulkSelect{
+				Kind:     "db_bulk_select",
+				SrcIP:    c.ip,
+				User:     c.user,
+				Minute:   minute,
+				Count:    n,
+				Baseline: round2(median),
+				Reason: "Database client " + c.String() + " ran " + intToStr(n) + " read statements in the minute of " +
+					minute.Format("15:04") + " UTC (median " + floatToStr(median) + " per minute).",
+			})
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Minute.Before(out[j].Minute)
+	})
+	return out
+}
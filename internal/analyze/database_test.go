@@ -0,0 +1,67 @@
+package analyze
+
+import (
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectPrivilegeBursts(t *testing.T) {
+	grant := parse.Event{TS: t0, SrcIP: "10.0.0.7", User: "bob", Method: "GRANT", Category: parse.CategoryPrivilege}
+	tests := []struct {
+		name      string
+		rows      []parse.Event
+		wantFires bool
+	}{
+		{"grants in a burst", repeat(grant, 6, time.Minute), true},
+		{"grants a day apart", repeat(grant, 6, 24*time.Hour), false},
+		{"reads in a burst", repeat(parse.Event{TS: t0, SrcIP: "10.0.0.7", User: "bob", Method: "SELECT", Category: parse.CategoryRead}, 6, time.Minute), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectPrivilegeBursts(tt.rows, 5, 10*time.Minute)
+			if (len(got) > 0) != tt.wantFires {
+				t.Fatalf("got %d anomalies, want fires=%v", len(got), tt.wantFires)
+			}
+			if tt.wantFires && (got[0].Kind != "db_privilege_burst" || got[0].User != "bob" || got[0].Peak != 6) {
+				t.Errorf("got %+v", got[0])
+			}
+		})
+	}
+}
+
+func TestDetectBulkSelects(t *testing.T) {
+	// reads returns per-minute counts of SELECTs from one client.
+	reads := func(counts ...int) []parse.Event {
+		var rows []parse.Event
+		for m, n := range counts {
+			ev := parse.Event{TS: t0.Add(time.Duration(m) * time.Minute), SrcIP: "10.0.0.7", User: "report", Method: "SELECT", Category: parse.CategoryRead}
+			rows = append(rows, repeat(ev, n, 50*time.Millisecond)...)
+		}
+		return rows
+	}
+	tests := []struct {
+		name       string
+		rows       []parse.Event
+		wantMinute time.Time // zero when nothing fires
+	}{
+		{"dump after a quiet baseline", reads(10, 12, 9, 11, 400, 10), t0.Add(4 * time.Minute)},
+		{"steady heavy reader", reads(300, 310, 290, 305), time.Time{}},
+		{"quiet client", reads(10, 12, 9, 11), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectBulkSelects(tt.rows, 200, 5)
+			if tt.wantMinute.IsZero() {
+				if len(got) > 0 {
+					t.Errorf("got %+v, want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Kind != "db_bulk_select" || !got[0].Minute.Equal(tt.wantMinute) || got[0].Count != 400 {
+				t.Errorf("got %+v, want one burst at %s", got, tt.wantMinute)
+			}
+		})
+	}
+}
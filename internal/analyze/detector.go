@@ -0,0 +1,70 @@
+package analyze
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// Detector is an analyzer that runs alongside the built-in detectors. Name is
+// the kind it reports and the name used to enable, disable, order or cap it.
+// Findings may leave Kind empty; it defaults to Name. A finding's confidence
+// comes from its Evidence through Calibrate; one without evidence has none.
+type Detector interface {
+	Name() string
+	Analyze(events []parse.Event) []Anomaly
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []Detector
+)
+
+// Register adds d to the set of detectors run on every upload. It panics if
+// d is nil, has no name or reuses a registered name, so mistakes surface at
+// init time.
+func Register(d Detector) {
+	if d == nil || d.Name() == "" {
+		panic("analyze: Register of nil or unnamed detector")
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, r := range registry {
+		if r.Name() == d.Name() {
+			panic("analyze: Register called twice for detector " + d.Name())
+		}
+	}
+	registry = append(registry, d)
+}
+
+// Registered returns the registered detectors in registration order.
+func Registered() []Detector {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append([]Detector(nil), registry...)
+}
+
+// Replace unregisters the detectors named in old and registers add in one
+// step, so an upload sees either the old set or the new one. Added detectors
+// are checked like Register's, against those that stay.
+func Replace(old []string, add ...Detector) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	next := make([]Detector, 0, len(registry)+len(add))
+	for _, r := range registry {
+		if !slices.Contains(old, r.Name()) {
+			next = append(next, r)
+		}
+	}
+	for _, d := range add {
+		if d == nil || d.Name() == "" {
+			panic("analyze: Replace with nil or unnamed detector")
+		}
+		if slices.ContainsFunc(next, func(r Detector) bool { return r.Name() == d.Name() }) {
+			panic("analyze: Replace would register detector " + d.Name() + " twice")
+		}
+		next = append(next, d)
+	}
+	registry = next
+}
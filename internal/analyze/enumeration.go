@@ -0,0 +1,151 @@
+package analyze
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+var (
+	numericID = regexp.MustCompile(`^\d+$`)
+	uuidID    = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hexID     = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+)
+
+type AnomalyEnumeration struct {
+	Kind        string    `json:"kind"`
+	SrcIP       string    `json:"srcIp"`
+	Template    string    `json:"template"`
+	FirstSeen   time.Time `json:"firstSeen"`
+	LastSeen    time.Time `json:"lastSeen"`
+	Count       int       `json:"count"`
+	DistinctIDs int       `json:"distinctIds"`
+	Sequential  bool      `json:"sequential"`
+	Found       int       `json:"found"` // 2xx responses
+	Samples     []string  `json:"samples"`
+	Reason      string    `json:"reason"`
+}
+
+// PathTemplate replaces numeric, UUID and long hex path segments with {id}
+// and returns the template with the last such segment's value, or ok=false
+// when the path has no ID segment.
+func PathTemplate(path string) (template, id string, ok bool) {
+	segs := strings.Split(path, "/")
+	for i, s := range segs {
+		if numericID.MatchString(s) || uuidID.MatchString(s) || hexID.MatchString(s) {
+			id, ok = s, true
+			segs[i] = "{id}"
+		}
+	}
+	return strings.Join(segs, "/"), id, ok
+}
+
+// DetectIDEnumeration flags IPs requesting at least minDistinct different
+// IDs under one path template (/users/{id}), noting whether the IDs run
+// mostly consecutively and how many were found.
+func DetectIDEnumeration(rows []parse.Event, minDistinct int) []AnomalyEnumeration {
+	type key struct{ ip, template string }
+	type agg struct {
+		first, last time.Time
+		count       int
+		found       int
+		ids         map[string]struct{}
+		order       []string
+	}
+	found := make(map[key]*agg)
+
+	for _, ev := range rows {
+		if ev.SrcIP == "" || ev.TS.IsZero() || ev.Path == "" {
+			continue
+		}
+		tmpl, id, ok := PathTemplate(ev.Path)
+		if !ok {
+			continue
+		}
+		k := key{ip: ev.SrcIP, template: tmpl}
+		t := ev.TS.UTC()
+		a, ok := found[k]
+		if !ok {
+			a = &agg{first: t, last: t, ids: make(map[string]struct{})}
+			found[k] = a
+		}
+		if t.Before(a.first) {
+			a.first = t
+		}
+		if t.After(a.last) {
+			a.last = t
+		}
+		a.count++
+		if ev.Status >= 200 && ev.Status < 300 {
+			a.found++
+		}
+		if _, seen := a.ids[id]; !seen {
+			a.ids[id] = struct{}{}
+			a.order = append(a.order, id)
+		}
+	}
+
+	out := make([]AnomalyEnumeration, 0)
+	for k, a := range found {
+		if len(a.ids) < minDistinct {
+			continue
+		}
+		seq := sequentialShare(a.order)
+		samples := a.order[:min(5, len(a.order))]
+		reason := "Identifier enumeration from " + k.ip + " on " + k.template + ": " + intToStr(len(a.ids)) +
+			" distinct IDs in " + intToStr(a.count) + " request(s) between " + a.first.Format("15:04") +
+			" and " + a.last.Format("15:04") + " UTC"
+		if seq >= 0.8 {
+			reason += ", mostly sequential"
+		}
+		reason += " (" + intToStr(a.found) + " returned 2xx)."
+
+		out = append(out, AnomalyEnumeration{
+			Kind:        "id_enumeration",
+			SrcIP:       k.ip,
+			Template:    k.template,
+			FirstSeen:   a.first,
+			LastSeen:    a.last,
+			Count:       a.count,
+			DistinctIDs: len(a.ids),
+			Sequential:  seq >= 0.8,
+			Found:       a.found,
+			Samples:     append([]string(nil), samples...),
+			Reason:      reason,
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].DistinctIDs != out[j].DistinctIDs {
+			return out[i].DistinctIDs > out[j].DistinctIDs
+		}
+		return out[i].SrcIP < out[j].SrcIP
+	})
+	return out
+}
+
+// sequentialShare is the fraction of numeric IDs that are within 2 of the
+// next one once sorted; 0 for non-numeric IDs.
+func sequentialShare(ids []string) float64 {
+	nums := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if n, err := strconv.ParseInt(id, 10, 64); err == nil {
+			nums = append(nums, n)
+		}
+	}
+	if len(nums) < 2 {
+		return 0
+	}
+	sort.Slice(nums, func(i, j int) bool { return nums[i] < nums[j] })
+	near := 0
+	for i := 1; i < len(nums); i++ {
+		if nums[i]-nums[i-1] <= 2 {
+			near++
+		}
+	}
+	return float64(near) / float64(len(nums)-1)
+}
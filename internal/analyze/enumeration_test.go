@@ -0,0 +1,74 @@
+package analyze
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectIDEnumeration(t *testing.T) {
+	// walk requests /api/users/{id} for each id, found when status is 2xx.
+	walk := func(ids []string, status int) []parse.Event {
+		rows := make([]parse.Event, len(ids))
+		for i, id := range ids {
+			rows[i] = parse.Event{TS: t0.Add(time.Duration(i) * time.Second), SrcIP: "203.0.113.9", Method: "GET", Path: "/api/users/" + id, Status: status}
+		}
+		return rows
+	}
+	ids := func(n int, format func(int) string) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = format(i)
+		}
+		return out
+	}
+	sequential := ids(40, func(i int) string { return intToStr(1000 + i) })
+	scattered := ids(40, func(i int) string { return fmt.Sprintf("%08x-0000-4000-8000-%012x", i*7919, i) })
+	tests := []struct {
+		name           string
+		rows           []parse.Event
+		wantFires      bool
+		wantSequential bool
+	}{
+		{"sequential user IDs", walk(sequential, 404), true, true},
+		{"scattered UUIDs", walk(scattered, 200), true, false},
+		{"one profile reloaded", walk(ids(40, func(int) string { return "1000" }), 200), false, false},
+		{"few IDs", walk(sequential[:10], 200), false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectIDEnumeration(tt.rows, 30)
+			if !tt.wantFires {
+				if len(got) > 0 {
+					t.Errorf("got %+v, want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Kind != "id_enumeration" || got[0].Template != "/api/users/{id}" || got[0].DistinctIDs != 40 || got[0].Sequential != tt.wantSequential {
+				t.Errorf("got %+v, want one enumeration of 40 IDs (sequential=%v)", got, tt.wantSequential)
+			}
+		})
+	}
+}
+
+func TestPathTemplate(t *testing.T) {
+	tests := []struct {
+		path, template, id string
+		ok                 bool
+	}{
+		{"/users/42/orders/7", "/users/{id}/orders/{id}", "7", true},
+		{"/files/0123456789abcdef0123", "/files/{id}", "0123456789abcdef0123", true},
+		{"/docs/550e8400-e29b-41d4-a716-446655440000", "/docs/{id}", "550e8400-e29b-41d4-a716-446655440000", true},
+		{"/blog/v2/post", "/blog/v2/post", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			template, id, ok := PathTemplate(tt.path)
+			if template != tt.template || id != tt.id || ok != tt.ok {
+				t.Errorf("PathTemplate(%q) = %q, %q, %v, want %q, %q, %v", tt.path, template, id, ok, tt.template, tt.id, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package analyze
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+type AnomalyErrorRate struct {
+	Kind      string    `json:"kind"`
+	Minute    time.Time `json:"minute"`
+	Count     int       `json:"count"`
+	Errors    int       `json:"errors"`
+	ErrorRate float64   `json:"errorRate"`
+	Baseline  float64   `json:"baseline"`
+	Z         float64   `json:"z"`
+	Reason    string    `json:"reason"`
+}
+
+// DetectErrorRateSpikes baselines the share of 4xx/5xx responses per minute
+// across all sources and flags minutes whose error rate stands out. Minutes
+// with fewer than minRequests requests are ignored as too noisy to judge.
+func DetectErrorRateSpikes(agg *Aggregates, minRequests int) []AnomalyErrorRate {
+	mins := make([]time.Time, 0, len(agg.Minutes))
+	rates := make([]float64, 0, len(agg.Minutes))
+	for m, c := range agg.Minutes {
+		if c.Requests < minRequests {
+			continue
+		}
+		mins = append(mins, m)
+		rates = append(rates, float64(c.Errors)/float64(c.Requests))
+	}
+	if len(mins) < 3 {
+		return nil
+	}
+	mean, std := meanStd(rates)
+
+	var out []AnomalyErrorRate
+	for i, m := range mins {
+		rate := rates[i]
+		if rate < mean+0.2 || rate < 0.25 {
+			continue
+		}
+		var z float64
+		if std > 0 {
+			z = (rate - mean) / std
+		}
+		if z < 2.0 {
+			continue
+		}
+
+		c := agg.Minutes[m]
+		out = append(out, AnomalyErrorRate{
+			Kind:      "error_rate",
+			Minute:    m,
+			Count:     c.Requests,
+			Errors:    c.Errors,
+			ErrorRate: round2(rate),
+			Baseline:  round2(mean),
+			Z:         round2(z),
+			Reason: "Error rate spike at " + m.Format("15:04") + " UTC: " +
+				strconv.Itoa(c.Errors) + " of " + strconv.Itoa(c.Requests) + " requests failed (" +
+				floatToStr(round2(rate*100)) + "% vs baseline ≈ " + floatToStr(round2(mean*100)) +
+				"%, z=" + floatToStr(round2(z)) + ").",
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Minute.After(out[j].Minute) })
+	return out
+}
@@ -0,0 +1,48 @@
+package analyze
+
+import (
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectErrorRateSpikes(t *testing.T) {
+	// minutes returns 20 requests a minute for the given error counts.
+	minutes := func(errors ...int) []parse.Event {
+		var rows []parse.Event
+		for m, n := range errors {
+			for i := range 20 {
+				status := 200
+				if i < n {
+					status = 500
+				}
+				rows = append(rows, parse.Event{TS: t0.Add(time.Duration(m)*time.Minute + time.Duration(i)*time.Second), SrcIP: "192.0.2.1", Path: "/", Status: status})
+			}
+		}
+		return rows
+	}
+	tests := []struct {
+		name       string
+		rows       []parse.Event
+		wantMinute time.Time // zero when nothing fires
+	}{
+		{"one failing minute", minutes(1, 0, 1, 1, 0, 1, 0, 1, 16, 1), t0.Add(8 * time.Minute)},
+		{"steady errors", minutes(5, 6, 5, 6, 5, 6, 5, 6, 5, 6), time.Time{}},
+		{"quiet minutes too small to judge", append(minutes(0, 0, 0), parse.Event{TS: t0.Add(5 * time.Minute), Path: "/", Status: 500}), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectErrorRateSpikes(Aggregate(tt.rows), 20)
+			if tt.wantMinute.IsZero() {
+				if len(got) > 0 {
+					t.Errorf("got %+v, want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Kind != "error_rate" || !got[0].Minute.Equal(tt.wantMinute) || got[0].Errors != 16 {
+				t.Errorf("got %+v, want one spike at %s", got, tt.wantMinute)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package analyze
+
+import (
+	"math"
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+type AnomalyBehavioralOutlier struct {
+	Kind      string    `json:"kind"`
+	SrcIP     string    `json:"srcIp"`
+	Minute    time.Time `json:"minute"` // the IP's most isolated minute
+	Profile   Profile   `json:"profile"`
+	Score     float64   `json:"score"`
+	Minutes   int       `json:"minutes"` // minutes at or above the threshold
+	Traits    []string  `json:"traits"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Reason    string    `json:"reason"`
+}
+
+const (
+	forestTrees  = 100
+	forestSample = 256
+)
+
+// DetectBehavioralOutliers scores every IP-minute with at least minRequests
+// requests with an isolation forest over the Profile features of that
+// minute. Points that random axis-aligned splits isolate quickly score near
+// 1; typical points score around 0.5 or less. It needs minPoints IP-minutes
+// and reports, per IP, the most isolated minute of those scoring minScore or
+// more.
+func DetectBehavioralOutliers(rows []parse.Event, minRequests, minPoints int, minScore float64) []AnomalyBehavioralOutlier {
+	byMinute := make(map[time.Time]map[string]*IPStats)
+	for _, ev := range rows {
+		if ev.SrcIP == "" || ev.TS.IsZero() {
+			continue
+		}
+		t := ev.TS.UTC()
+		m := t.Truncate(time.Minute)
+		if byMinute[m] == nil {
+			byMinute[m] = make(map[string]*IPStats)
+		}
+		s := byMinute[m][ev.SrcIP]
+		if s == nil {
+			s = newIPStats()
+			byMinute[m][ev.SrcIP] = s
+		}
+		s.add(ev, t)
+	}
+	var (
+		points  []Profile
+		minutes []time.Time
+	)
+	for m, ips := range byMinute {
+		for _, p := range profilesOf(ips, minRequests) {
+			points = append(points, p)
+			minutes = append(minutes, m)
+		}
+	}
+	if len(points) < minPoints {
+		return nil
+	}
+	// Map iteration order must not leak into the seeded forest.
+	idx := make([]int, len(points))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(a, b int) bool {
+		if !minutes[idx[a]].Equal(minutes[idx[b]]) {
+			return minutes[idx[a]].Before(minutes[idx[b]])
+		}
+		return points[idx[a]].SrcIP < points[idx[b]].SrcIP
+	})
+	sorted := make([]Profile, len(idx))
+	sortedMin := make([]time.Time, len(idx))
+	for i, j := range idx {
+		sorted[i], sortedMin[i] = points[j], minutes[j]
+	}
+	points, minutes = sorted, sortedMin
+
+	xs := standardize(points)
+	forest := newForest(xs)
+
+	found := make(map[string]*AnomalyBehavioralOutlier)
+	var order []string
+	for i, x := range xs {
+		s := forest.score(x)
+		if s < minScore {
+			continue
+		}
+		p := points[i]
+		a, ok := found[p.SrcIP]
+		if !ok {
+			a = &AnomalyBehavioralOutlier{Kind: "behavioral_outlier", SrcIP: p.SrcIP, FirstSeen: p.FirstSeen, LastSeen: p.LastSeen}
+			found[p.SrcIP] = a
+			order = append(order, p.SrcIP)
+		}
+		a.Minutes++
+		if p.FirstSeen.Before(a.FirstSeen) {
+			a.FirstSeen = p.FirstSeen
+		}
+		if p.LastSeen.After(a.LastSeen) {
+			a.LastSeen = p.LastSeen
+		}
+		if s > a.Score {
+			a.Score, a.Minute, a.Profile = s, minutes[i], p
+			a.Traits = clusterTraitsOf(x)
+		}
+	}
+
+	out := make([]AnomalyBehavioralOutlier, 0, len(found))
+	for _, ip := range order {
+		a := found[ip]
+		a.Score = round2(a.Score)
+		p := a.Profile
+		a.Reason = "Outlying behavior from " + ip + " at " + a.Minute.Format("15:04") + " UTC (isolation score " +
+			floatToStr(a.Score) + ", " + intToStr(a.Minutes) + " outlying minute(s)): " + intToStr(p.Requests) +
+			" requests, " + intToStr(int(p.ErrorRatio*100+0.5)) + "% errors, " + intToStr(p.UniquePaths) +
+			" unique paths, UA entropy " + floatToStr(p.UAEntropy) + " bits, mean " + formatBytes(int64(p.MeanBytes)) +
+			"; most unusual: " + a.Traits[0] + "."
+		out = append(out, *a)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].SrcIP < out[j].SrcIP
+	})
+	return out
+}
+
+// forest is an isolation forest (Liu, Ting and Zhou, 2008).
+type forest struct {
+	trees []*itree
+	c     float64 // average path length of an unsuccessful BST search over the sample size
+}
+
+type itree struct {
+	feature     int
+	split       float64
+	left, right *itree
+	size        int // points reaching a leaf
+}
+
+func newForest(xs [][]float64) *forest {
+	rng := rand.New(rand.NewPCG(3, 5))
+	n := min(forestSample, len(xs))
+	limit := int(math.Ceil(math.Log2(float64(n))))
+	f := &forest{c: avgPath(n)}
+	sample := make([][]float64, n)
+	for range forestTrees {
+		for i, j := range rng.Perm(len(xs))[:n] {
+			sample[i] = xs[j]
+		}
+		f.trees = append(f.trees, growTree(rng, sample, 0, limit))
+	}
+	return f
+}
+
+func growTree(rng *rand.Rand, xs [][]float64, depth, limit int) *itree {
+	if depth >= limit || len(xs) <= 1 {
+		return &itree{size: len(xs)}
+	}
+	// Pick a random feature that still varies; a leaf if none does.
+	dims := rng.Perm(len(xs[0]))
+	for _, j := range dims {
+		lo, hi := xs[0][j], xs[0][j]
+		for _, x := range xs[1:] {
+			lo, hi = min(lo, x[j]), max(hi, x[j])
+		}
+		if lo == hi {
+			continue
+		}
+		split := lo + rng.Float64()*(hi-lo)
+		var left, right [][]float64
+		for _, x := range xs {
+			if x[j] < split {
+				left = append(left, x)
+			} else {
+				right = append(right, x)
+			}
+		}
+		return &itree{
+			feature: j,
+			split:   split,
+			left:    growTree(rng, left, depth+1, limit),
+			right:   growTree(rng, right, depth+1, limit),
+		}
+	}
+	return &itree{size: len(xs)}
+}
+
+func (t *itree) pathLength(x []float64, depth int) float64 {
+	if t.left == nil {
+		return float64(depth) + avgPath(t.size)
+	}
+	if x[t.feature] < t.split {
+		return t.left.pathLength(x, depth+1)
+	}
+	return t.right.pathLength(x, depth+1)
+}
+
+// score is 2^(-E[h(x)]/c(n)).
+func (f *forest) score(x []float64) float64 {
+	var sum float64
+	for _, t := range f.trees {
+		sum += t.pathLength(x, 0)
+	}
+	if f.c == 0 {
+		return 0
+	}
+	return math.Pow(2, -sum/float64(len(f.trees))/f.c)
+}
+
+func avgPath(n int) float64 {
+	switch {
+	case n <= 1:
+		return 0
+	case n == 2:
+		return 1
+	}
+	const euler = 0.5772156649
+	return 2*(math.Log(float64(n-1))+euler) - 2*float64(n-1)/float64(n)
+}
@@ -0,0 +1,46 @@
+package analyze
+
+import (
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectBehavioralOutliers(t *testing.T) {
+	// crowd returns n browsing IPs active for five minutes each, with four
+	// to six page views a minute.
+	crowd := func(n int) []parse.Event {
+		var rows []parse.Event
+		for i := range n {
+			views := browse("192.0.2."+intToStr(i), t0, 5*(4+i%3))
+			for j := range views {
+				views[j].TS = t0.Add(time.Duration(i)*time.Minute + time.Duration(j)*time.Minute/time.Duration(4+i%3))
+			}
+			rows = append(rows, views...)
+		}
+		return rows
+	}
+	tests := []struct {
+		name    string
+		rows    []parse.Event
+		wantIPs []string // nil when nothing fires
+	}{
+		{"scanner among browsers", append(crowd(40), probe("203.0.113.9", t0.Add(10*time.Minute), 80)...), []string{"203.0.113.9"}},
+		{"browsers only", crowd(40), nil},
+		{"too few IP-minutes to score", append(crowd(5), probe("203.0.113.9", t0, 80)...), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectBehavioralOutliers(tt.rows, 3, 100, 0.7)
+			if len(got) != len(tt.wantIPs) {
+				t.Fatalf("got %+v, want %v flagged", got, tt.wantIPs)
+			}
+			for i, a := range got {
+				if a.Kind != "behavioral_outlier" || a.SrcIP != tt.wantIPs[i] || !a.Minute.Equal(t0.Add(10*time.Minute)) || a.Minutes != 1 {
+					t.Errorf("got %+v, want %s flagged at 13:10", a, tt.wantIPs[i])
+				}
+			}
+		})
+	}
+}
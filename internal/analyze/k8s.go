@@ -0,0 +1,206 @@
+package analyze
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// K8sSystemUsers are username prefixes of control-plane components whose
+// secret reads are routine and not reported by DetectSecretsAccess.
+var K8sSystemUsers = []string{
+	"system:node:", "system:kube-", "system:serviceaccount:kube-system:", "system:apiserver",
+}
+
+// K8sExecResources are the subresources that open an interactive channel
+// into a pod.
+var K8sExecResources = []string{"pods/exec", "pods/attach", "pods/portforward"}
+
+type AnomalyK8s struct {
+	Kind      string    `json:"kind"`
+	User      string    `json:"user"`
+	SrcIP     string    `json:"srcIp"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Count     int       `json:"count"`
+	Peak      int       `json:"peak"`
+	TopPaths  []string  `json:"topPaths,omitempty"`
+	Reason    string    `json:"reason"`
+}
+
+type k8sAgg struct {
+	ts      []time.Time
+	ips     map[string]int
+	targets map[string]int
+}
+
+func (a *k8sAgg) add(ev parse.Event, target string) {
+	a.ts = append(a.ts, ev.TS.UTC())
+	if ev.SrcIP != "" {
+		a.ips[ev.SrcIP]++
+	}
+	if target != "" {
+		a.targets[target]++
+	}
+}
+
+// groupByUser collects the events accepted by match per user; match returns
+// the target to count for the event and whether it counts at all.
+func groupByUser(rows []parse.Event, match func(parse.Event) (string, bool)) map[string]*k8sAgg {
+	by := make(map[string]*k8sAgg)
+	for _, ev := range rows {
+		if ev.User == "" || ev.TS.IsZero() {
+			continue
+		}
+		target, ok := match(ev)
+		if !ok {
+			continue
+		}
+		a, ok := by[ev.User]
+		if !ok {
+			a = &k8sAgg{ips: make(map[string]int), targets: make(map[string]int)}
+			by[ev.User] = a
+		}
+		a.add(ev, target)
+	}
+	for _, a := range by {
+		sort.Slice(a.ts, func(i, j int) bool { return a.ts[i].Before(a.ts[j]) })
+	}
+	return by
+}
+
+// DetectForbiddenBursts flags Kubernetes users receiving at least minDenied
+// 403 responses within any window, a sign of permission probing with a
+// stolen or over-scoped token.
+func DetectForbiddenBursts(rows []parse.Event, minDenied int, window time.Duration) []AnomalyK8s {
+	by := groupByUser(rows, func(ev parse.Event) (string, bool) {
+		return ev.Method + " " + ev.Resource, ev.Resource != "" && ev.Status == 403
+	})
+
+	out := make([]AnomalyK8s, 0)
+	for user, a := range by {
+		peak := peakInWindow(a.ts, window)
+		if peak < minDenied {
+			continue
+		}
+		out = append(out, k8sAnomaly("k8s_forbidden_burst", user, a, peak,
+			"User "+user+" was denied "+intToStr(peak)+" API request(s) within "+window.String()+
+				" across "+intToStr(len(a.targets))+" verb/resource combination(s)."))
+	}
+	sortK8s(out)
+	return out
+}
+
+// DetectExecSpikes flags users opening at least minExec exec, attach or
+// port-forward sessions into pods within any window.
+func DetectExecSpikes(rows []parse.Event, minExec int, window time.Duration) []AnomalyK8s {
+	by := groupByUser(rows, func(ev parse.Event) (string, bool) {
+		for _, r := range K8sExecResources {
+			if ev.Resource == r {
+				return ev.Dst + "/" + podName(ev.Path), true
+			}
+		}
+		return "", false
+	})
+
+	out := make([]AnomalyK8s, 0)
+	for user, a := range by {
+		peak := peakInWindow(a.ts, window)
+		if peak < minExec {
+			continue
+		}
+		out = append(out, k8sAnomaly("k8s_exec_spike", user, a, peak,
+			"User "+user+" opened "+intToStr(peak)+" exec/attach/port-forward session(s) within "+
+				window.String()+" into "+intToStr(len(a.targets))+" pod(s)."))
+	}
+	sortK8s(out)
+	return out
+}
+
+// DetectSecretsAccess flags non-system users reading at least minSecrets
+// distinct secrets, or listing secrets across all namespaces.
+func DetectSecretsAccess(rows []parse.Event, minSecrets int) []AnomalyK8s {
+	by := groupByUser(rows, func(ev parse.Event) (string, bool) {
+		if ev.Resource != "secrets" || isK8sSystemUser(ev.User) || ev.Status >= 400 {
+			return "", false
+		}
+		switch ev.Method {
+		case "GET", "LIST", "WATCH":
+		default:
+			return "", false
+		}
+		if ev.Method != "GET" {
+			if ev.Dst == "" {
+				return "* (all namespaces)", true
+			}
+			return ev.Dst + "/*", true
+		}
+		return ev.Dst + "/" + lastSegment(ev.Path), true
+	})
+
+	out := make([]AnomalyK8s, 0)
+	for user, a := range by {
+		_, clusterWide := a.targets["* (all namespaces)"]
+		if len(a.targets) < minSecrets && !clusterWide {
+			continue
+		}
+		reason := "User " + user + " read " + intToStr(len(a.targets)) + " distinct secret(s) or secret list(s)"
+		if clusterWide {
+			reason += ", including a list of secrets across all namespaces"
+		}
+		out = append(out, k8sAnomaly("k8s_secrets_access", user, a, len(a.targets), reason+"."))
+	}
+	sortK8s(out)
+	return out
+}
+
+func k8sAnomaly(kind, user string, a *k8sAgg, peak int, reason string) AnomalyK8s {
+	ip, best := "", 0
+	for k, n := range a.ips {
+		if n > best || n == best && k < ip {
+			ip, best = k, n
+		}
+	}
+	return AnomalyK8s{
+		Kind:      kind,
+		User:      user,
+		SrcIP:     ip,
+		FirstSeen: a.ts[0],
+		LastSeen:  a.ts[len(a.ts)-1],
+		Count:     len(a.ts),
+		Peak:      peak,
+		TopPaths:  topKeys(a.targets, 5),
+		Reason:    reason,
+	}
+}
+
+func sortK8s(out []AnomalyK8s) {
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+}
+
+func isK8sSystemUser(u string) bool {
+	for _, p := range K8sSystemUsers {
+		if strings.HasPrefix(u, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// podName returns the segment after "pods" in an API path.
+func podName(path string) string {
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 0; i+1 < len(segs); i++ {
+		if segs[i] == "pods" {
+			return segs[i+1]
+		}
+	}
+	return ""
+}
+
+func lastSegment(path string) string {
+	path = strings.TrimRight(path, "/")
+	return path[strings.LastIndex(path, "/")+1:]
+}
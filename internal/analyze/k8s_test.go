@@ -0,0 +1,69 @@
+package analyze
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestK8sDetectors(t *testing.T) {
+	call := func(user, method, resource, ns, path string, status int) parse.Event {
+		return parse.Event{TS: t0, SrcIP: "203.0.113.9", User: user, Method: method, Resource: resource, Dst: ns, Path: path, Status: status}
+	}
+	// secrets returns GETs of n distinct secrets by user.
+	secrets := func(user string, n int) []parse.Event {
+		rows := repeat(call(user, "GET", "secrets", "prod", "", 200), n, time.Second)
+		for i := range rows {
+			rows[i].Path = "/api/v1/namespaces/prod/secrets/s" + strconv.Itoa(i)
+		}
+		return rows
+	}
+	// execs returns exec calls by dev into n pods.
+	execs := func(n int, step time.Duration) []parse.Event {
+		rows := repeat(call("dev", "CREATE", "pods/exec", "prod", "", 101), n, step)
+		for i := range rows {
+			rows[i].Path = "/api/v1/namespaces/prod/pods/web-" + strconv.Itoa(i) + "/exec"
+		}
+		return rows
+	}
+	forbidden := call("dev", "LIST", "secrets", "kube-system", "/api/v1/namespaces/kube-system/secrets", 403)
+	tests := []struct {
+		name   string
+		detect func([]parse.Event) []AnomalyK8s
+		rows   []parse.Event
+		want   string // the kind expected, or empty when nothing fires
+	}{
+		{"forbidden burst", func(r []parse.Event) []AnomalyK8s { return DetectForbiddenBursts(r, 10, 5*time.Minute) },
+			repeat(forbidden, 12, 10*time.Second), "k8s_forbidden_burst"},
+		{"forbidden spread out", func(r []parse.Event) []AnomalyK8s { return DetectForbiddenBursts(r, 10, 5*time.Minute) },
+			repeat(forbidden, 12, time.Hour), ""},
+		{"exec spike", func(r []parse.Event) []AnomalyK8s { return DetectExecSpikes(r, 5, 10*time.Minute) },
+			execs(6, time.Minute), "k8s_exec_spike"},
+		{"occasional exec", func(r []parse.Event) []AnomalyK8s { return DetectExecSpikes(r, 5, 10*time.Minute) },
+			execs(6, time.Hour), ""},
+		{"secret sweep", func(r []parse.Event) []AnomalyK8s { return DetectSecretsAccess(r, 5) },
+			secrets("dev", 6), "k8s_secrets_access"},
+		{"cluster-wide secret list", func(r []parse.Event) []AnomalyK8s { return DetectSecretsAccess(r, 5) },
+			[]parse.Event{call("dev", "LIST", "secrets", "", "/api/v1/secrets", 200)}, "k8s_secrets_access"},
+		{"control plane reading secrets", func(r []parse.Event) []AnomalyK8s { return DetectSecretsAccess(r, 5) },
+			secrets("system:kube-controller-manager", 6), ""},
+		{"a few secrets", func(r []parse.Event) []AnomalyK8s { return DetectSecretsAccess(r, 5) },
+			secrets("dev", 2), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.detect(tt.rows)
+			if tt.want == "" {
+				if len(got) > 0 {
+					t.Errorf("got %+v, want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Kind != tt.want || got[0].SrcIP != "203.0.113.9" {
+				t.Errorf("got %+v, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package analyze
+
+import (
+	"sort"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+type AnomalyLatency struct {
+	Kind       string    `json:"kind"`
+	Template   string    `json:"template,omitempty"` // empty for all traffic
+	FirstSeen  time.Time `json:"firstSeen"`
+	LastSeen   time.Time `json:"lastSeen"`
+	Count      int       `json:"count"`
+	P95Ms      float64   `json:"p95Ms"`
+	BaselineMs float64   `json:"baselineMs"`
+	Reason     string    `json:"reason"`
+}
+
+// DetectLatencySpikes compares the p95 duration of each window, for all
+// traffic and for each path template, with the median of that key's window
+// p95s, so a long slowdown does not drag the baseline up with it. A window
+// with at least minSamples timed requests whose p95 is factor times the
+// baseline and at least minDeltaMs above it is flagged; consecutive windows
+// merge. Keys with fewer than three such windows have no usable baseline.
+func DetectLatencySpikes(rows []parse.Event, window time.Duration, minSamples int, factor, minDeltaMs float64) []AnomalyLatency {
+	type series struct {
+		byWindow map[time.Time][]float64
+	}
+	keys := make(map[string]*series)
+	add := func(key string, w time.Time, d float64) {
+		s := keys[key]
+		if s == nil {
+			s = &series{byWindow: make(map[time.Time][]float64)}
+			keys[key] = s
+		}
+		s.byWindow[w] = append(s.byWindow[w], d)
+	}
+
+	for _, ev := range rows {
+		if ev.TS.IsZero() || ev.DurationMs <= 0 {
+			continue
+		}
+		w := ev.TS.UTC().Truncate(window)
+		add("", w, ev.DurationMs)
+		if ev.Path != "" {
+			tmpl, _, _ := PathTemplate(ev.Path)
+			add(tmpl, w, ev.DurationMs)
+		}
+	}
+
+	var out []AnomalyLatency
+	for key, s := range keys {
+		starts := make([]time.Time, 0, len(s.byWindow))
+		p95s := make(map[time.Time]float64)
+		var sample []float64
+		for w, ds := range s.byWindow {
+			starts = append(starts, w)
+			if len(ds) >= minSamples {
+				sort.Float64s(ds)
+				p95s[w] = parse.Percentile(ds, 95)
+				sample = append(sample, p95s[w])
+			}
+		}
+		if len(sample) < 3 {
+			continue
+		}
+		sort.Float64s(sample)
+		base := sample[len(sample)/2]
+
+		sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+
+		var cur *AnomalyLatency
+		flush := func() {
+			if cur == nil {
+				return
+			}
+			what := "all traffic"
+			if key != "" {
+				what = key
+			}
+			cur.Reason = "Latency spike on " + what + " between " + cur.FirstSeen.Format("15:04") + " and " +
+				cur.LastSeen.Format("15:04") + " UTC: p95 " + floatToStr(round2(cur.P95Ms)) + " ms vs a typical " +
+				floatToStr(round2(base)) + " ms (" + intToStr(cur.Count) + " timed requests)."
+			out = append(out, *cur)
+			cur = nil
+		}
+		var prev time.Time
+		for _, w := range starts {
+			ds := s.byWindow[w]
+			p95, ok := p95s[w]
+			if !ok || p95 < factor*base || p95-base < minDeltaMs {
+				flush()
+				continue
+			}
+			if cur != nil && !w.Equal(prev.Add(window)) {
+				flush()
+			}
+			if cur == nil {
+				cur = &AnomalyLatency{Kind: "latency_spike", Template: key, FirstSeen: w, BaselineMs: base}
+			}
+			cur.LastSeen = w.Add(window - time.Second)
+			cur.Count += len(ds)
+			cur.P95Ms = max(cur.P95Ms, p95)
+			prev = w
+		}
+		flush()
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].FirstSeen.Equal(out[j].FirstSeen) {
+			return out[i].FirstSeen.After(out[j].FirstSeen)
+		}
+		return out[i].Template < out[j].Template
+	})
+	return out
+}
@@ -0,0 +1,51 @@
+package analyze
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectLatencySpikes(t *testing.T) {
+	// hour returns an hour of /api/search requests taking base ms, twelve per
+	// five-minute window, with window 6 taking slow ms and holding n requests.
+	hour := func(base, slow float64, n int) []parse.Event {
+		var rows []parse.Event
+		for w := range 12 {
+			d, k := base, 12
+			if w == 6 {
+				d, k = slow, n
+			}
+			start := parse.Event{TS: t0.Add(time.Duration(w) * 5 * time.Minute), SrcIP: "192.0.2.1", Method: "GET", Path: "/api/search", Status: 200, DurationMs: d}
+			rows = append(rows, repeat(start, k, 10*time.Second)...)
+		}
+		return rows
+	}
+	tests := []struct {
+		name          string
+		rows          []parse.Event
+		wantTemplates []string // nil when nothing fires
+	}{
+		{"search slows down", hour(50, 800, 12), []string{"", "/api/search"}},
+		{"steady latency", hour(50, 50, 12), nil},
+		{"too few slow samples", hour(50, 800, 5), nil},
+		{"fast endpoint, small delta", hour(5, 50, 12), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectLatencySpikes(tt.rows, 5*time.Minute, 10, 2, 100)
+			var templates []string
+			for _, a := range got {
+				if a.Kind != "latency_spike" || !a.FirstSeen.Equal(t0.Add(30*time.Minute)) || a.P95Ms != 800 || a.BaselineMs != 50 {
+					t.Errorf("got %+v, want a spike to 800 ms at 13:30", a)
+				}
+				templates = append(templates, a.Template)
+			}
+			if !slices.Equal(templates, tt.wantTemplates) {
+				t.Errorf("spikes on %q, want %q", templates, tt.wantTemplates)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package analyze
+
+import (
+	"sort"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+type AnomalyLowSlow struct {
+	Kind        string    `json:"kind"`
+	SrcIP       string    `json:"srcIp"`
+	FirstSeen   time.Time `json:"firstSeen"`
+	LastSeen    time.Time `json:"lastSeen"`
+	Count       int       `json:"count"`
+	UniquePaths int       `json:"uniquePaths"`
+	PeakPerMin  int       `json:"peakPerMin"`
+	Reason      string    `json:"reason"`
+}
+
+// DetectLowAndSlow flags careful scanners: IPs that touch at least minUnique
+// distinct paths over at least minSpan while never exceeding maxPerMin
+// requests in any minute, i.e. staying under the rate-spike floor.
+func DetectLowAndSlow(rows []parse.Event, minUnique int, minSpan time.Duration, maxPerMin int) []AnomalyLowSlow {
+	type stats struct {
+		first, last time.Time
+		count       int
+		paths       map[string]struct{}
+		perMin      map[time.Time]int
+	}
+	perIP := make(map[string]*stats)
+
+	for _, ev := range rows {
+		if ev.SrcIP == "" || ev.TS.IsZero() || ev.Path == "" {
+			continue
+		}
+		t := ev.TS.UTC()
+		s, ok := perIP[ev.SrcIP]
+		if !ok {
+			s = &stats{first: t, last: t, paths: make(map[string]struct{}), perMin: make(map[time.Time]int)}
+			perIP[ev.SrcIP] = s
+		}
+		if t.Before(s.first) {
+			s.first = t
+		}
+		if t.After(s.last) {
+			s.last = t
+		}
+		s.count++
+		s.paths[ev.Path] = struct{}{}
+		s.perMin[t.Truncate(time.Minute)]++
+	}
+
+	out := make([]AnomalyLowSlow, 0)
+	for ip, s := range perIP {
+		span := s.last.Sub(s.first)
+		if span < minSpan || len(s.paths) < minUnique {
+			continue
+		}
+		peak := 0
+		for _, n := range s.perMin {
+			if n > peak {
+				peak = n
+			}
+		}
+		if peak > maxPerMin {
+			continue
+		}
+
+		// Mostly-unique paths at a low, steady rate is the scanner signature;
+		// repeated polling of the same few paths is not.
+		uniqRatio := float64(len(s.paths)) / float64(s.count)
+		if uniqRatio < 0.5 {
+			continue
+		}
+
+		out = append(out, AnomalyLowSlow{
+			Kind:        "low_and_slow",
+			SrcIP:       ip,
+			FirstSeen:   s.first,
+			LastSeen:    s.last,
+			Count:       s.count,
+			UniquePaths: len(s.paths),
+			PeakPerMin:  peak,
+			Reason: "Low-and-slow scanning from " + ip + ": " + intToStr(len(s.paths)) +
+				" distinct paths in " + intToStr(s.count) + " requests over ~" +
+				floatToStr(round2(span.Hours())) + " hour(s), never more than " +
+				intToStr(peak) + " req/min.",
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+	return out
+}
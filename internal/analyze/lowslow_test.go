@@ -0,0 +1,42 @@
+package analyze
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectLowAndSlow(t *testing.T) {
+	// crawl returns n requests from one source, step apart, cycling through
+	// paths distinct paths.
+	crawl := func(n, paths int, step time.Duration) []parse.Event {
+		rows := repeat(parse.Event{TS: t0, SrcIP: "198.51.100.7", Method: "GET", Status: 404}, n, step)
+		for i := range rows {
+			rows[i].Path = "/p/" + strconv.Itoa(i%paths)
+		}
+		return rows
+	}
+	tests := []struct {
+		name      string
+		rows      []parse.Event
+		wantFires bool
+	}{
+		{"one new path a minute for two hours", crawl(120, 120, time.Minute), true},
+		{"fast sweep", crawl(120, 120, time.Second), false},
+		{"polling a few paths", crawl(120, 5, time.Minute), false},
+		{"too short", crawl(55, 55, 30*time.Second), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectLowAndSlow(tt.rows, 50, time.Hour, 9)
+			if (len(got) > 0) != tt.wantFires {
+				t.Fatalf("got %d anomalies, want fires=%v", len(got), tt.wantFires)
+			}
+			if tt.wantFires && (got[0].Kind != "low_and_slow" || got[0].UniquePaths != 120 || got[0].PeakPerMin != 1) {
+				t.Errorf("got %+v", got[0])
+			}
+		})
+	}
+}
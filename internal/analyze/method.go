@@ -0,0 +1,141 @@
+package analyze
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// Signals reported by DetectRareMethods.
+const (
+	MethodSignalRare = "rare"
+	MethodSignalMix  = "mix"
+)
+
+// RareMethods are HTTP methods ordinary clients do not send: TRACE/TRACK
+// (cross-site tracing), WebDAV verbs, CONNECT (open proxy probes) and DEBUG
+// (IIS/ASP.NET remote debugging).
+var RareMethods = map[string]bool{
+	"TRACE": true, "TRACK": true, "DEBUG": true, "CONNECT": true,
+	"PROPFIND": true, "PROPPATCH": true, "MKCOL": true, "COPY": true,
+	"MOVE": true, "LOCK": true, "UNLOCK": true, "SEARCH": true,
+}
+
+var commonMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true,
+	"DELETE": true, "PATCH": true, "OPTIONS": true,
+}
+
+type AnomalyRareMethod struct {
+	Kind      string    `json:"kind"`
+	SrcIP     string    `json:"srcIp"`
+	Signal    string    `json:"signal"`
+	Methods   []string  `json:"methods"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Count     int       `json:"count"`
+	Total     int       `json:"total"`
+	Reason    string    `json:"reason"`
+}
+
+// DetectRareMethods flags IPs sending any RareMethods, and IPs with at least
+// minRequests HTTP requests of which mixShare or more use a single method
+// other than GET or POST (e.g. almost only OPTIONS). Database, VPN and
+// Kubernetes rows are skipped since their methods are pseudo methods.
+func DetectRareMethods(rows []parse.Event, minRequests int, mixShare float64) []AnomalyRareMethod {
+	type agg struct {
+		first, last time.Time
+		total       int
+		methods     map[string]int
+	}
+	byIP := make(map[string]*agg)
+
+	for _, ev := range rows {
+		if ev.SrcIP == "" || ev.TS.IsZero() || ev.Category != "" || ev.Resource != "" {
+			continue
+		}
+		m := strings.ToUpper(ev.Method)
+		if !RareMethods[m] && !commonMethods[m] {
+			continue
+		}
+		t := ev.TS.UTC()
+		a, ok := byIP[ev.SrcIP]
+		if !ok {
+			a = &agg{first: t, last: t, methods: make(map[string]int)}
+			byIP[ev.SrcIP] = a
+		}
+		if t.Before(a.first) {
+			a.first = t
+		}
+		if t.After(a.last) {
+			a.last = t
+		}
+		a.total++
+		a.methods[m]++
+	}
+
+	out := make([]AnomalyRareMethod, 0)
+	for ip, a := range byIP {
+		var rare []string
+		rareCount := 0
+		for m, n := range a.methods {
+			if RareMethods[m] {
+				rare = append(rare, m)
+				rareCount += n
+			}
+		}
+		if len(rare) > 0 {
+			sort.Strings(rare)
+			labels := make([]string, len(rare))
+			for i, m := range rare {
+				labels[i] = m + "×" + intToStr(a.methods[m])
+			}
+			out = append(out, AnomalyRareMethod{
+				Kind:      "rare_method",
+				SrcIP:     ip,
+				Signal:    MethodSignalRare,
+				Methods:   labels,
+				FirstSeen: a.first,
+				LastSeen:  a.last,
+				Count:     rareCount,
+				Total:     a.total,
+				Reason: "Unusual HTTP methods from " + ip + ": " + strings.Join(labels, ", ") +
+					" among " + intToStr(a.total) + " request(s) between " + a.first.Format("15:04") +
+					" and " + a.last.Format("15:04") + " UTC.",
+			})
+			continue
+		}
+
+		if a.total < minRequests {
+			continue
+		}
+		top, topN := "", 0
+		for m, n := range a.methods {
+			if n > topN || (n == topN && m < top) {
+				top, topN = m, n
+			}
+		}
+		share := float64(topN) / float64(a.total)
+		if top == "GET" || top == "POST" || share < mixShare {
+			continue
+		}
+		out = append(out, AnomalyRareMethod{
+			Kind:      "rare_method",
+			SrcIP:     ip,
+			Signal:    MethodSignalMix,
+			Methods:   []string{top + "×" + intToStr(topN)},
+			FirstSeen: a.first,
+			LastSeen:  a.last,
+			Count:     topN,
+			Total:     a.total,
+			Reason: "Abnormal method mix from " + ip + ": " + intToStr(int(share*100+0.5)) + "% of " +
+				intToStr(a.total) + " request(s) are " + top + " between " + a.first.Format("15:04") +
+				" and " + a.last.Format("15:04") + " UTC.",
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+	return out
+}
@@ -0,0 +1,44 @@
+package analyze
+
+import (
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectRareMethods(t *testing.T) {
+	get := parse.Event{TS: t0, SrcIP: "203.0.113.9", Method: "GET", Path: "/", Status: 200}
+	trace := get
+	trace.Method = "TRACE"
+	options := get
+	options.Method = "OPTIONS"
+	propfind := get
+	propfind.Method, propfind.Category = "PROPFIND", parse.CategoryRead
+	tests := []struct {
+		name       string
+		rows       []parse.Event
+		wantSignal string // "" when nothing fires
+		wantCount  int
+	}{
+		{"cross-site tracing", append(repeat(get, 5, time.Second), repeat(trace, 2, time.Second)...), MethodSignalRare, 2},
+		{"almost only OPTIONS", append(repeat(options, 19, time.Second), get), MethodSignalMix, 19},
+		{"ordinary browsing", repeat(get, 50, time.Second), "", 0},
+		{"OPTIONS below the request floor", repeat(options, 10, time.Second), "", 0},
+		{"database pseudo method", repeat(propfind, 5, time.Second), "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectRareMethods(tt.rows, 20, 0.9)
+			if tt.wantSignal == "" {
+				if len(got) > 0 {
+					t.Errorf("got %+v, want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Kind != "rare_method" || got[0].Signal != tt.wantSignal || got[0].Count != tt.wantCount || got[0].Total != len(tt.rows) {
+				t.Errorf("got %+v, want one %s anomaly over %d request(s)", got, tt.wantSignal, tt.wantCount)
+			}
+		})
+	}
+}
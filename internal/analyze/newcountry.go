@@ -0,0 +1,95 @@
+package analyze
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// CountryLookup resolves an IP to a country code. ok is false when the
+// country is unknown.
+type CountryLookup func(ip string) (country string, ok bool)
+
+type AnomalyNewCountry struct {
+	Kind      string    `json:"kind"`
+	User      string    `json:"user"`
+	SrcIP     string    `json:"srcIp"`
+	Country   string    `json:"country"`
+	Known     []string  `json:"knownCountries"`
+	FirstSeen time.Time `json:"firstSeen"`
+	Reason    string    `json:"reason"`
+}
+
+// DetectNewCountry flags successful logins from a country an account has not
+// used before. The first minHistory logins of each account only establish
+// its known countries; after that each new country is reported once.
+func DetectNewCountry(rows []parse.Event, country CountryLookup, minHistory int) []AnomalyNewCountry {
+	if country == nil {
+		return nil
+	}
+	type login struct {
+		t      time.Time
+		ip, cc string
+	}
+	perUser := make(map[string][]login)
+	cache := make(map[string]string)
+
+	for _, ev := range rows {
+		if ev.User == "" || ev.SrcIP == "" || ev.TS.IsZero() || ev.Status < 200 || ev.Status >= 300 || !IsLoginPath(ev.Path) {
+			continue
+		}
+		cc, ok := cache[ev.SrcIP]
+		if !ok {
+			cc, _ = country(ev.SrcIP)
+			cache[ev.SrcIP] = cc
+		}
+		if cc == "" {
+			continue
+		}
+		perUser[ev.User] = append(perUser[ev.User], login{t: ev.TS.UTC(), ip: ev.SrcIP, cc: cc})
+	}
+
+	out := make([]AnomalyNewCountry, 0)
+	for user, ls := range perUser {
+		if len(ls) <= minHistory {
+			continue
+		}
+		sort.Slice(ls, func(i, j int) bool { return ls[i].t.Before(ls[j].t) })
+
+		known := make(map[string]int)
+		for _, l := range ls[:minHistory] {
+			known[l.cc]++
+		}
+		for _, l := range ls[minHistory:] {
+			if known[l.cc] > 0 {
+				known[l.cc]++
+				continue
+			}
+			prior := make([]string, 0, len(known))
+			total := 0
+			for cc, n := range known {
+				prior = append(prior, cc)
+				total += n
+			}
+			sort.Strings(prior)
+			// A long single-country history makes a new country more telling.
+			out = append(out, AnomalyNewCountry{
+				Kind:      "new_country_login",
+				User:      user,
+				SrcIP:     l.ip,
+				Country:   l.cc,
+				Known:     prior,
+				FirstSeen: l.t,
+				Reason: "Account " + user + " logged in from " + l.cc + " (" + l.ip + ") at " +
+					l.t.Format("15:04") + " UTC after " + intToStr(total) + " login(s) only from " +
+					strings.Join(prior, ", ") + ".",
+			})
+			known[l.cc]++
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].FirstSeen.After(out[j].FirstSeen) })
+	return out
+}
@@ -0,0 +1,45 @@
+package analyze
+
+import (
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectNewCountry(t *testing.T) {
+	countries := map[string]string{"192.0.2.1": "DE", "192.0.2.2": "DE", "198.51.100.1": "BR"}
+	country := func(ip string) (string, bool) {
+		cc, ok := countries[ip]
+		return cc, ok
+	}
+	// logins returns one successful login a day from each IP in turn.
+	logins := func(ips ...string) []parse.Event {
+		rows := make([]parse.Event, len(ips))
+		for i, ip := range ips {
+			rows[i] = parse.Event{TS: t0.Add(time.Duration(i) * 24 * time.Hour), SrcIP: ip, User: "alice", Method: "CONNECT", Path: "/vpn/login", Status: 200}
+		}
+		return rows
+	}
+	tests := []struct {
+		name      string
+		rows      []parse.Event
+		wantFires bool
+	}{
+		{"new country after a history", logins("192.0.2.1", "192.0.2.2", "192.0.2.1", "198.51.100.1"), true},
+		{"new IP in a known country", logins("192.0.2.1", "192.0.2.1", "192.0.2.1", "192.0.2.2"), false},
+		{"new country within the history", logins("192.0.2.1", "198.51.100.1", "192.0.2.1", "198.51.100.1"), false},
+		{"unknown country", logins("192.0.2.1", "192.0.2.1", "192.0.2.1", "233.252.0.1"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectNewCountry(tt.rows, country, 3)
+			if (len(got) > 0) != tt.wantFires {
+				t.Fatalf("got %d anomalies, want fires=%v", len(got), tt.wantFires)
+			}
+			if tt.wantFires && (got[0].Kind != "new_country_login" || got[0].Country != "BR" || len(got[0].Known) != 1 || got[0].Known[0] != "DE") {
+				t.Errorf("got %+v", got[0])
+			}
+		})
+	}
+}
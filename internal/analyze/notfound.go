@@ -0,0 +1,111 @@
+package analyze
+
+import (
+	"sort"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+type AnomalyNotFound struct {
+	Kind        string    `json:"kind"`
+	SrcIP       string    `json:"srcIp"`
+	FirstSeen   time.Time `json:"firstSeen"`
+	LastSeen    time.Time `json:"lastSeen"`
+	Count       int       `json:"count"`
+	UniquePaths int       `json:"uniquePaths"`
+	TopPaths    []string  `json:"topPaths"`
+	Reason      string    `json:"reason"`
+}
+
+// DetectNotFoundScanning flags dirbuster-style forced browsing: IPs that, within
+// some window, produce at least minCount 404s over at least minUnique distinct
+// paths.
+func DetectNotFoundScanning(rows []parse.Event, minCount, minUnique int, window time.Duration) []AnomalyNotFound {
+	type hit struct {
+		t    time.Time
+		path string
+	}
+	perIP := make(map[string][]hit)
+	for _, ev := range rows {
+		if ev.SrcIP == "" || ev.TS.IsZero() || ev.Status != 404 {
+			continue
+		}
+		perIP[ev.SrcIP] = append(perIP[ev.SrcIP], hit{t: ev.TS.UTC(), path: ev.Path})
+	}
+
+	out := make([]AnomalyNotFound, 0)
+	for ip, hits := range perIP {
+		if len(hits) < minCount {
+			continue
+		}
+		sort.Slice(hits, func(i, j int) bool { return hits[i].t.Before(hits[j].t) })
+
+		// Slide a window over the hits, tracking the best (count, unique) span.
+		inWin := make(map[string]int)
+		bestLo, bestHi, bestUniq := -1, -1, 0
+		lo := 0
+		for hi := range hits {
+			inWin[hits[hi].path]++
+			for hits[hi].t.Sub(hits[lo].t) > window {
+				p := hits[lo].path
+				if inWin[p]--; inWin[p] == 0 {
+					delete(inWin, p)
+				}
+				lo++
+			}
+			n := hi - lo + 1
+			if n >= minCount && len(inWin) >= minUnique && len(inWin) > bestUniq {
+				bestLo, bestHi, bestUniq = lo, hi, len(inWin)
+			}
+		}
+		if bestLo < 0 {
+			continue
+		}
+
+		span := hits[bestLo : bestHi+1]
+		pathCounts := make(map[string]int)
+		for _, h := range span {
+			pathCounts[h.path]++
+		}
+		count := len(span)
+
+		out = append(out, AnomalyNotFound{
+			Kind:        "forced_browsing",
+			SrcIP:       ip,
+			FirstSeen:   span[0].t,
+			LastSeen:    span[len(span)-1].t,
+			Count:       count,
+			UniquePaths: bestUniq,
+			TopPaths:    topKeys(pathCounts, 5),
+			Reason:      buildNotFoundReason(ip, count, bestUniq, span[0].t, span[len(span)-1].t),
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+	return out
+}
+
+// topKeys returns up to n keys with the highest counts, ties broken by key.
+func topKeys(counts map[string]int, n int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
+
+func buildNotFoundReason(ip string, count, uniq int, first, last time.Time) string {
+	win := last.Sub(first).Minutes()
+	return "Forced browsing from " + ip + ": " + intToStr(count) + " 404 responses across " +
+		intToStr(uniq) + " distinct paths within ~" + intToStr(int(win)) + " minute(s)."
+}
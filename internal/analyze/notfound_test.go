@@ -0,0 +1,42 @@
+package analyze
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectNotFoundScanning(t *testing.T) {
+	// probes returns n 404s from one source, step apart, cycling through
+	// paths distinct paths.
+	probes := func(n, paths int, step time.Duration) []parse.Event {
+		rows := repeat(parse.Event{TS: t0, SrcIP: "198.51.100.7", Method: "GET", Status: 404}, n, step)
+		for i := range rows {
+			rows[i].Path = "/probe/" + strconv.Itoa(i%paths)
+		}
+		return rows
+	}
+	tests := []struct {
+		name      string
+		rows      []parse.Event
+		wantFires bool
+	}{
+		{"dirbuster sweep", probes(30, 30, time.Second), true},
+		{"one missing path hammered", probes(30, 1, time.Second), false},
+		{"sweep spread over hours", probes(30, 30, 10*time.Minute), false},
+		{"too few probes", probes(10, 10, time.Second), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectNotFoundScanning(tt.rows, 20, 15, 5*time.Minute)
+			if (len(got) > 0) != tt.wantFires {
+				t.Fatalf("got %d anomalies, want fires=%v", len(got), tt.wantFires)
+			}
+			if tt.wantFires && (got[0].Kind != "forced_browsing" || got[0].Count != 30 || got[0].UniquePaths != 30 || len(got[0].TopPaths) != 5) {
+				t.Errorf("got %+v", got[0])
+			}
+		})
+	}
+}
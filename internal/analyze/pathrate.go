@@ -0,0 +1,89 @@
+package analyze
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+type AnomalyPathRate struct {
+	Kind      string    `json:"kind"`
+	Path      string    `json:"path"`
+	Minute    time.Time `json:"minute"`
+	Count     int       `json:"count"`
+	Baseline  float64   `json:"baseline"`
+	Z         float64   `json:"z"`
+	UniqueIPs int       `json:"uniqueIps"`
+	TopIPs    []string  `json:"topIps"`
+	Reason    string    `json:"reason"`
+}
+
+// DetectPathRateSpikes is DetectRateSpikes keyed on (path, minute) instead of
+// (IP, minute), so a single endpoint being hammered is flagged even when the
+// requests come from many IPs. Minutes below minCount are ignored.
+func DetectPathRateSpikes(agg *Aggregates, minCount, keepTop int) []AnomalyPathRate {
+	var out []AnomalyPathRate
+	for path, mins := range agg.PathMinutes {
+		cnt := make([]float64, len(mins))
+		for i, m := range mins {
+			cnt[i] = float64(agg.PathMinute[PathMinute{path, m}])
+		}
+		mean, std := meanStd(cnt)
+
+		for i, m := range mins {
+			c := cnt[i]
+			if int(c) < minCount {
+				continue
+			}
+			var z float64
+			if std > 0 {
+				z = (c - mean) / std
+				if !(z >= 2.0 || c >= math.Ceil(2.5*mean)) {
+					continue
+				}
+			} else {
+				continue
+			}
+
+			ips := agg.PathMinuteIPs[PathMinute{path, m}]
+			top := make([]string, 0, len(ips))
+			for ip := range ips {
+				top = append(top, ip)
+			}
+			sort.Slice(top, func(a, b int) bool {
+				if ips[top[a]] != ips[top[b]] {
+					return ips[top[a]] > ips[top[b]]
+				}
+				return top[a] < top[b]
+			})
+			if len(top) > 5 {
+				top = top[:5]
+			}
+
+			out = append(out, AnomalyPathRate{
+				Kind:      "path_rate_spike",
+				Path:      path,
+				Minute:    m,
+				Count:     int(c),
+				Baseline:  round2(mean),
+				Z:         round2(z),
+				UniqueIPs: len(ips),
+				TopIPs:    top,
+				Reason: "Unusual request burst on " + path + " at " + m.Format("15:04") + " UTC: " +
+					intToStr(int(c)) + " req/min from " + intToStr(len(ips)) + " IP(s) (baseline ≈ " +
+					floatToStr(round2(mean)) + ", z=" + floatToStr(round2(z)) + ").",
+			})
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].Minute.Equal(out[j].Minute) {
+			return out[i].Minute.After(out[j].Minute)
+		}
+		return out[i].Path < out[j].Path
+	})
+	if keepTop > 0 && len(out) > keepTop {
+		out = out[:keepTop]
+	}
+	return out
+}
@@ -0,0 +1,46 @@
+package analyze
+
+import (
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectPathRateSpikes(t *testing.T) {
+	// hits returns per-minute request counts on /api/search, each minute's
+	// requests spread over three sources.
+	hits := func(counts ...int) []parse.Event {
+		var rows []parse.Event
+		ips := []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}
+		for m, n := range counts {
+			for i := range n {
+				rows = append(rows, parse.Event{TS: t0.Add(time.Duration(m)*time.Minute + time.Duration(i)*100*time.Millisecond), SrcIP: ips[i%3], Path: "/api/search", Status: 200})
+			}
+		}
+		return rows
+	}
+	tests := []struct {
+		name       string
+		rows       []parse.Event
+		wantMinute time.Time // zero when nothing fires
+	}{
+		{"burst on one endpoint", hits(10, 11, 9, 10, 12, 10, 9, 11, 10, 10, 100, 10), t0.Add(10 * time.Minute)},
+		{"steady load", hits(30, 30, 30, 30, 30), time.Time{}},
+		{"burst below the floor", hits(2, 1, 2, 1, 2, 15, 1), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectPathRateSpikes(Aggregate(tt.rows), 20, 50)
+			if tt.wantMinute.IsZero() {
+				if len(got) > 0 {
+					t.Errorf("got %+v, want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Kind != "path_rate_spike" || !got[0].Minute.Equal(tt.wantMinute) || got[0].Count != 100 || got[0].UniqueIPs != 3 {
+				t.Errorf("got %+v, want one spike at %s", got, tt.wantMinute)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package analyze
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// PayloadRule names one attack indicator matched against the decoded
+// request target, or the target as logged when Raw is set. Normalize, if
+// set, is applied to the subject before matching.
+type PayloadRule struct {
+	Name      string
+	Re        *regexp.Regexp
+	Raw       bool
+	Normalize func(string) string
+}
+
+func (r PayloadRule) match(s string) bool {
+	if r.Normalize != nil {
+		s = r.Normalize(s)
+	}
+	return r.Re.MatchString(s)
+}
+
+type AnomalyPayload struct {
+	Kind       string    `json:"kind"`
+	SrcIP      string    `json:"srcIp"`
+	FirstSeen  time.Time `json:"firstSeen"`
+	LastSeen   time.Time `json:"lastSeen"`
+	Count      int       `json:"count"`
+	Indicators []string  `json:"indicators"`
+	Samples    []string  `json:"samples"`
+	Succeeded  int       `json:"succeeded"`
+	Reason     string    `json:"reason"`
+}
+
+const maxPayloadSamples = 5
+
+// detectPayloads groups requests whose decoded path or query matches any of
+// rules by source IP; with headers set the User-Agent and Referer are checked
+// too. Each finding carries the matched indicator names and up to
+// maxPayloadSamples offending requests.
+func detectPayloads(rows []parse.Event, kind, what string, rules []PayloadRule, headers bool) []AnomalyPayload {
+	type agg struct {
+		first, last time.Time
+		count       int
+		indicators  map[string]struct{}
+		samples     []string
+		succeeded   int
+	}
+	byIP := make(map[string]*agg)
+
+	for _, ev := range rows {
+		if ev.SrcIP == "" || ev.TS.IsZero() {
+			continue
+		}
+		raw := ev.Path
+		if ev.RawQuery != "" {
+			raw += "?" + ev.RawQuery
+		}
+		target := DecodeTarget(ev.Path, ev.RawQuery)
+		subjects := []payloadSubject{{raw: raw, decoded: target}}
+		if headers {
+			for _, h := range []struct{ name, v string }{{"User-Agent", ev.UA}, {"Referer", ev.Referer}} {
+				if h.v != "" {
+					subjects = append(subjects, payloadSubject{header: h.name, raw: h.v, decoded: DecodeTarget(h.v, "")})
+				}
+			}
+		}
+
+		var hit []string
+		var where payloadSubject
+		for _, r := range rules {
+			for _, sub := range subjects {
+				subject := sub.decoded
+				if r.Raw {
+					subject = sub.raw
+				}
+				if r.match(subject) {
+					if len(hit) == 0 {
+						where = sub
+					}
+					hit = append(hit, r.Name)
+					break
+				}
+			}
+		}
+		if len(hit) == 0 {
+			continue
+		}
+
+		t := ev.TS.UTC()
+		a, ok := byIP[ev.SrcIP]
+		if !ok {
+			a = &agg{first: t, last: t, indicators: make(map[string]struct{})}
+			byIP[ev.SrcIP] = a
+		}
+		if t.Before(a.first) {
+			a.first = t
+		}
+		if t.After(a.last) {
+			a.last = t
+		}
+		a.count++
+		if ev.Status >= 200 && ev.Status < 300 {
+			a.succeeded++
+		}
+		for _, h := range hit {
+			a.indicators[h] = struct{}{}
+		}
+		if len(a.samples) < maxPayloadSamples {
+			a.samples = append(a.samples, payloadSample(ev, target, where))
+		}
+	}
+
+	out := make([]AnomalyPayload, 0)
+	for ip, a := range byIP {
+		inds := make([]string, 0, len(a.indicators))
+		for k := range a.indicators {
+			inds = append(inds, k)
+		}
+		sort.Strings(inds)
+
+		reason := ip + " sent " + intToStr(a.count) + " request(s) containing " + what + " (" +
+			strings.Join(inds, ", ") + ") between " + a.first.Format("15:04") + " and " +
+			a.last.Format("15:04") + " UTC"
+		if a.succeeded > 0 {
+			reason += "; " + intToStr(a.succeeded) + " returned 2xx"
+		}
+		out = append(out, AnomalyPayload{
+			Kind:       kind,
+			SrcIP:      ip,
+			FirstSeen:  a.first,
+			LastSeen:   a.last,
+			Count:      a.count,
+			Indicators: inds,
+			Samples:    a.samples,
+			Succeeded:  a.succeeded,
+			Reason:     reason + ". First sample: " + a.samples[0],
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].SrcIP < out[j].SrcIP
+	})
+	return out
+}
+
+// DecodeTarget returns path?query with percent-encoding (applied up to twice,
+// to catch double encoding) and '+' in the query undone.
+func DecodeTarget(path, rawQuery string) string {
+	target := path
+	if rawQuery != "" {
+		target += "?" + strings.ReplaceAll(rawQuery, "+", " ")
+	}
+	for range 2 {
+		d, err := url.PathUnescape(target)
+		if err != nil || d == target {
+			break
+		}
+		target = d
+	}
+	return target
+}
+
+type payloadSubject struct {
+	header       string // empty for the request target
+	raw, decoded string
+}
+
+func payloadSample(ev parse.Event, target string, where payloadSubject) string {
+	s := ev.TS.UTC().Format("15:04:05") + " " + ev.Method + " " + target
+	if ev.Status != 0 {
+		s += " (" + intToStr(ev.Status) + ")"
+	}
+	if where.header != "" {
+		s += " " + where.header + ": " + where.decoded
+	}
+	const max = 300
+	if len(s) > max {
+		s = s[:max] + "…"
+	}
+	return s
+}
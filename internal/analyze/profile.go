@@ -0,0 +1,98 @@
+package analyze
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Profile summarizes one source IP's behavior over the log. It is the
+// feature vector the clustering and outlier detectors work on.
+type Profile struct {
+	SrcIP       string    `json:"srcIp"`
+	Requests    int       `json:"requests"`
+	RatePerMin  float64   `json:"ratePerMin"`
+	ErrorRatio  float64   `json:"errorRatio"`
+	UniquePaths int       `json:"uniquePaths"`
+	UAEntropy   float64   `json:"uaEntropy"` // bits; 0 for a single User-Agent
+	MeanBytes   float64   `json:"meanBytes"`
+	FirstSeen   time.Time `json:"-"`
+	LastSeen    time.Time `json:"-"`
+}
+
+// profileFeatures names the columns of Profile.features, in order.
+var profileFeatures = []string{"request rate", "error ratio", "unique path count", "User-Agent entropy", "response size"}
+
+// features returns the profile's feature vector. Counts and sizes are
+// log-scaled so a handful of huge values do not dominate the distance.
+func (p Profile) features() []float64 {
+	return []float64{
+		math.Log1p(p.RatePerMin),
+		p.ErrorRatio,
+		math.Log1p(float64(p.UniquePaths)),
+		p.UAEntropy,
+		math.Log1p(p.MeanBytes),
+	}
+}
+
+// Profiles builds a Profile for every IP in agg with at least minRequests
+// timestamped rows, sorted by IP. The rate is requests per minute over the
+// IP's active span, at least one minute.
+func Profiles(agg *Aggregates, minRequests int) []Profile {
+	return profilesOf(agg.IPs, minRequests)
+}
+
+func profilesOf(ips map[string]*IPStats, minRequests int) []Profile {
+	out := make([]Profile, 0, len(ips))
+	for ip, s := range ips {
+		if s.Requests < minRequests {
+			continue
+		}
+		p := Profile{SrcIP: ip, Requests: s.Requests, FirstSeen: s.First, LastSeen: s.Last}
+		mins := max(p.LastSeen.Sub(p.FirstSeen).Minutes(), 1)
+		p.RatePerMin = round2(float64(p.Requests) / mins)
+		if s.Statuses > 0 {
+			p.ErrorRatio = round2(float64(s.Errors) / float64(s.Statuses))
+		}
+		p.UniquePaths = len(s.Paths)
+		p.UAEntropy = round2(entropy(s.UAs, p.Requests))
+		p.MeanBytes = round2(float64(s.Bytes) / float64(p.Requests))
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].SrcIP < out[j].SrcIP })
+	return out
+}
+
+// entropy is the Shannon entropy in bits of the counts, which sum to n.
+func entropy(counts map[string]int, n int) float64 {
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// standardize returns the profiles' feature vectors scaled to zero mean and
+// unit variance per column. Constant columns become zero.
+func standardize(profiles []Profile) [][]float64 {
+	xs := make([][]float64, len(profiles))
+	for i, p := range profiles {
+		xs[i] = p.features()
+	}
+	col := make([]float64, len(xs))
+	for j := range profileFeatures {
+		for i := range xs {
+			col[i] = xs[i][j]
+		}
+		mean, std := meanStd(col)
+		for i := range xs {
+			if std > 0 {
+				xs[i][j] = (xs[i][j] - mean) / std
+			} else {
+				xs[i][j] = 0
+			}
+		}
+	}
+	return xs
+}
@@ -5,95 +5,64 @@ import (
 	"sort"
 	"strconv"
 	"time"
-
-	"github.com/allensuvorov/tenexlog/internal/parse"
 )
 
+// Anomaly is a rate spike, and the general finding returned by registered
+// Detectors, which fill only the fields that apply.
 type Anomaly struct {
-	Kind       string    `json:"kind"`
-	SrcIP      string    `json:"srcIp"`
-	Minute     time.Time `json:"minute"`
-	Count      int       `json:"count"`
-	Baseline   float64   `json:"baseline"`
-	Z          float64   `json:"z"`
-	Confidence float64   `json:"confidence"`
-	Reason     string    `json:"reason"`
+	Kind      string     `json:"kind"`
+	SrcIP     string     `json:"srcIp"`
+	Path      string     `json:"path,omitempty"`
+	Minute    time.Time  `json:"minute"`
+	FirstSeen time.Time  `json:"firstSeen,omitzero"`
+	LastSeen  time.Time  `json:"lastSeen,omitzero"`
+	Count     int        `json:"count"`
+	Baseline  float64    `json:"baseline"`
+	Z         float64    `json:"z"`
+	Evidence  []Evidence `json:"evidence,omitempty"`
+	Reason    string     `json:"reason"`
+	// Group holds the values a custom rule grouped the finding by.
+	Group map[string]string `json:"group,omitempty"`
 }
 
-func DetectRateSpikes(rows []parse.Event, keepTop int) []Anomaly {
-	type key struct {
-		ip string
-		m  time.Time
-	}
-	perMin := make(map[key]int)
-	perIPMinutes := make(map[string][]time.Time)
-
-	for _, ev := range rows {
-		if ev.SrcIP == "" || ev.TS.IsZero() {
-			continue
-		}
-		min := ev.TS.UTC().Truncate(time.Minute)
-		k := key{ip: ev.SrcIP, m: min}
-		perMin[k]++
-		perIPMinutes[ev.SrcIP] = append(perIPMinutes[ev.SrcIP], min)
-	}
-
-	for ip, mins := range perIPMinutes {
-		perIPMinutes[ip] = uniqueSorted(mins)
-	}
+// DetectRateSpikes compares each IP's per-minute request count with the mean
+// and spread of its other active minutes. When the log spans a day or more,
+// minutes are instead compared with the IP's minutes in the same UTC
+// hour-of-day on other days, so daily cycles such as the overnight lull do
+// not make every morning look like a spike; hours seen on only one day fall
+// back to the IP's overall baseline. A minute needs at least floor requests
+// and a z-score of minZ (or 2.5× the baseline) to count as a spike.
+func DetectRateSpikes(agg *Aggregates, keepTop, floor int, minZ float64) []Anomaly {
+	seasonal := agg.Last.Sub(agg.First) >= 24*time.Hour
 
 	var out []Anomaly
-	const absFloor = 10
-
-	for ip, mins := range perIPMinutes {
+	for ip, mins := range agg.IPMinutes {
 		if len(mins) == 0 {
 			continue
 		}
 		cnt := make([]float64, 0, len(mins))
 		for _, m := range mins {
-			cnt = append(cnt, float64(perMin[key{ip: ip, m: m}]))
+			cnt = append(cnt, float64(agg.IPMinute[IPMinute{ip, m}]))
 		}
 		mean, std := meanStd(cnt)
 
-		for _, m := range mins {
-			c := float64(perMin[key{ip: ip, m: m}])
-			if c < absFloor {
-				continue
-			}
+		var hourly map[int]hourBaseline
+		if seasonal {
+			hourly = hourBaselines(mins, cnt)
+		}
 
-			var z float64
-			if std > 0 {
-				z = (c - mean) / std
-				if !(z >= 2.0 || c >= math.Max(math.Ceil(2.5*mean), absFloor)) {
-					continue
+		for i, m := range mins {
+			c := cnt[i]
+			if hb, ok := hourly[m.Hour()]; ok {
+				if a, ok := scoreSpike(ip, m, c, hb.mean, hb.std, floor, minZ); ok {
+					a.Reason = formatHourReason(ip, m, int(c), hb.mean, a.Z)
+					out = append(out, a)
 				}
-			} else {
-				if !(mean > 0 && c >= 2.5*mean && c >= absFloor) {
-					continue
-				}
-				z = 3.0
-			}
-
-			conf := 1 - math.Exp(-z/3.0)
-			if conf > 1 {
-				conf = 1
+				continue
 			}
-			if std == 0 && conf < 0.8 {
-				conf = 0.8
+			if a, ok := scoreSpike(ip, m, c, mean, std, floor, minZ); ok {
+				out = append(out, a)
 			}
-
-			reason := formatReason(ip, m, int(c), mean, z)
-
-			out = append(out, Anomaly{
-				Kind:       "rate_spike",
-				SrcIP:      ip,
-				Minute:     m,
-				Count:      int(c),
-				Baseline:   round2(mean),
-				Z:          round2(z),
-				Confidence: round2(conf),
-				Reason:     reason,
-			})
 		}
 	}
 
@@ -105,20 +74,109 @@ func DetectRateSpikes(rows []parse.Event, keepTop int) []Anomaly {
 	return out
 }
 
-func uniqueSorted(in []time.Time) []time.Time {
-	if len(in) == 0 {
-		return in
+type hourBaseline struct{ mean, std float64 }
+
+// hourBaselines groups an IP's active minutes by UTC hour-of-day, keeping only
+// hours observed on at least two different days.
+func hourBaselines(mins []time.Time, cnt []float64) map[int]hourBaseline {
+	byHour := make(map[int][]float64)
+	days := make(map[int]map[time.Time]bool)
+	for i, m := range mins {
+		h := m.Hour()
+		byHour[h] = append(byHour[h], cnt[i])
+		if days[h] == nil {
+			days[h] = make(map[time.Time]bool)
+		}
+		days[h][m.Truncate(24*time.Hour)] = true
 	}
-	sort.Slice(in, func(i, j int) bool { return in[i].Before(in[j]) })
-	out := in[:1]
-	for i := 1; i < len(in); i++ {
-		if !in[i].Equal(in[i-1]) {
-			out = append(out, in[i])
+	out := make(map[int]hourBaseline)
+	for h, xs := range byHour {
+		if len(days[h]) < 2 {
+			continue
 		}
+		mean, std := meanStd(xs)
+		out[h] = hourBaseline{mean: mean, std: std}
 	}
 	return out
 }
 
+// scoreSpike decides whether c requests in minute m is a spike against a
+// baseline of mean and std requests per minute.
+func scoreSpike(ip string, m time.Time, c, mean, std float64, floor int, minZ float64) (Anomaly, bool) {
+	if c < float64(floor) {
+		return Anomaly{}, false
+	}
+	var z float64
+	if std > 0 {
+		z = (c - mean) / std
+		if !(z >= minZ || c >= math.Max(math.Ceil(2.5*mean), float64(floor))) {
+			return Anomaly{}, false
+		}
+	} else {
+		if !(mean > 0 && c >= 2.5*mean) {
+			return Anomaly{}, false
+		}
+		z = 3.0
+	}
+
+	return Anomaly{
+		Kind:     "rate_spike",
+		SrcIP:    ip,
+		Minute:   m,
+		Count:    int(c),
+		Baseline: round2(mean),
+		Z:        round2(z),
+		Reason:   formatReason(ip, m, int(c), mean, z),
+	}, true
+}
+
+// DetectRateSpikesEWMA is DetectRateSpikes with an exponentially weighted
+// moving baseline: each minute is compared with the IP's decayed mean and
+// variance of the minutes before it, so a slow ramp-up keeps raising the bar
+// gradually and an early burst fades out of the baseline after a few
+// half-lives. Minutes without requests count as zero. An IP's first minute
+// only seeds the baseline.
+func DetectRateSpikesEWMA(agg *Aggregates, keepTop, floor int, minZ float64, halfLife time.Duration) []Anomaly {
+	hl := halfLife.Minutes()
+	if hl <= 0 {
+		hl = 10
+	}
+	alpha := math.Pow(0.5, 1/hl) // weight kept by the old baseline per minute
+	maxGap := int(math.Ceil(hl * 20))
+
+	var out []Anomaly
+	for ip, mins := range agg.IPMinutes {
+		var mean, variance float64
+		for i, m := range mins {
+			c := float64(agg.IPMinute[IPMinute{ip, m}])
+			if i > 0 {
+				gap := int(m.Sub(mins[i-1]).Minutes()) - 1
+				for g := 0; g < min(gap, maxGap); g++ {
+					mean, variance = ewmaStep(mean, variance, 0, alpha)
+				}
+				if a, ok := scoreSpike(ip, m, c, mean, math.Sqrt(variance), floor, minZ); ok {
+					out = append(out, a)
+				}
+				mean, variance = ewmaStep(mean, variance, c, alpha)
+			} else {
+				mean = c
+			}
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Minute.After(out[j].Minute) })
+	if keepTop > 0 && len(out) > keepTop {
+		out = out[:keepTop]
+	}
+	return out
+}
+
+func ewmaStep(mean, variance, x, alpha float64) (float64, float64) {
+	diff := x - mean
+	incr := (1 - alpha) * diff
+	return mean + incr, alpha * (variance + diff*incr)
+}
+
 func meanStd(xs []float64) (mean, std float64) {
 	if len(xs) == 0 {
 		return 0, 0
@@ -148,6 +206,13 @@ func formatReason(ip string, m time.Time, count int, mean, z float64) string {
 		", z=" + floatToStr(round2(z)) + ")."
 }
 
+func formatHourReason(ip string, m time.Time, count int, mean, z float64) string {
+	return "Unusual request burst from " + ip +
+		" at " + m.UTC().Format("15:04") + " UTC: " +
+		intToStr(count) + " req/min (baseline for " + m.UTC().Format("15") + ":00–" + m.UTC().Format("15") + ":59 UTC ≈ " +
+		floatToStr(round2(mean)) + ", z=" + floatToStr(round2(z)) + ")."
+}
+
 func intToStr(n int) string { return strconv.Itoa(n) }
 func floatToStr(f float64) string {
 	return strconv.FormatFloat(f, 'f', -1, 64)
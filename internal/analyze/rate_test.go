@@ -0,0 +1,101 @@
+package analyze
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// perMinute returns counts[i] requests from one IP in minute i after start.
+func perMinute(start time.Time, counts ...int) []parse.Event {
+	var rows []parse.Event
+	for m, n := range counts {
+		for i := range n {
+			rows = append(rows, parse.Event{TS: start.Add(time.Duration(m)*time.Minute + time.Duration(i)*time.Second), SrcIP: "203.0.113.9", Method: "GET", Path: "/", Status: 200})
+		}
+	}
+	return rows
+}
+
+func TestDetectRateSpikesEWMA(t *testing.T) {
+	// steady returns n minutes alternating around 5 requests.
+	steady := func(n int) []int {
+		out := make([]int, n)
+		for i := range out {
+			out[i] = 4 + 2*(i%2)
+		}
+		return out
+	}
+	tests := []struct {
+		name       string
+		counts     []int
+		wantMinute int // -1 when nothing fires
+	}{
+		{"sudden burst", append(steady(30), 40), 30},
+		{"burst in the first minute seeds the baseline", append([]int{40}, steady(30)...), -1},
+		{"burst below the floor", append(steady(30), 9), -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectRateSpikesEWMA(Aggregate(perMinute(t0, tt.counts...)), 50, 10, 2, 10*time.Minute)
+			if tt.wantMinute < 0 {
+				if len(got) > 0 {
+					t.Errorf("got %+v, want nothing", got)
+				}
+				return
+			}
+			want := t0.Add(time.Duration(tt.wantMinute) * time.Minute)
+			if len(got) != 1 || got[0].Kind != "rate_spike" || !got[0].Minute.Equal(want) || got[0].Count != tt.counts[tt.wantMinute] {
+				t.Errorf("got %+v, want one spike at %s", got, want.Format("15:04"))
+			}
+		})
+	}
+}
+
+func TestDetectRateSpikes(t *testing.T) {
+	// day returns one day of an IP's traffic: 50 quiet minutes after 03:00
+	// and a 5-minute busy stretch after 09:00.
+	day := func(d int) []parse.Event {
+		start := time.Date(2024, 5, 1+d, 0, 0, 0, 0, time.UTC)
+		night := make([]int, 50)
+		for i := range night {
+			night[i] = 1 + 2*(i%2)
+		}
+		return append(perMinute(start.Add(3*time.Hour), night...), perMinute(start.Add(9*time.Hour), 29, 31, 30, 29, 31)...)
+	}
+	days := func(n int) []parse.Event {
+		var rows []parse.Event
+		for d := range n {
+			rows = append(rows, day(d)...)
+		}
+		return rows
+	}
+	nightBurst := perMinute(time.Date(2024, 5, 3, 3, 55, 0, 0, time.UTC), 20)
+	afternoon := perMinute(time.Date(2024, 5, 3, 15, 0, 0, 0, time.UTC), 12)
+	tests := []struct {
+		name        string
+		rows        []parse.Event
+		wantMinutes []string // HH:MM of each spike, newest first
+		wantHourly  bool     // spikes are scored against their hour of day
+	}{
+		{"burst against the overnight hour", append(days(3), nightBurst...), []string{"03:55"}, true},
+		{"daily peak on a multi-day log", days(3), nil, false},
+		{"hour seen on one day only", append(days(3), afternoon...), []string{"15:00"}, false},
+		{"daily peak within a single day", day(0), []string{"09:04", "09:03", "09:02", "09:01", "09:00"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectRateSpikes(Aggregate(tt.rows), 50, 10, 2)
+			if len(got) != len(tt.wantMinutes) {
+				t.Fatalf("got %+v, want spikes at %v", got, tt.wantMinutes)
+			}
+			for i, a := range got {
+				if a.Minute.Format("15:04") != tt.wantMinutes[i] || strings.Contains(a.Reason, "baseline for") != tt.wantHourly {
+					t.Errorf("spike %d: %s %q, want %s (hourly baseline %v)", i, a.Minute.Format("15:04"), a.Reason, tt.wantMinutes[i], tt.wantHourly)
+				}
+			}
+		})
+	}
+}
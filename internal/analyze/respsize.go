@@ -0,0 +1,115 @@
+package analyze
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+type AnomalyResponseSize struct {
+	Kind       string    `json:"kind"`
+	SrcIP      string    `json:"srcIp"`
+	Path       string    `json:"path"`
+	FirstSeen  time.Time `json:"firstSeen"`
+	LastSeen   time.Time `json:"lastSeen"`
+	Count      int       `json:"count"`
+	MaxBytes   int64     `json:"maxBytes"`
+	TotalBytes int64     `json:"totalBytes"`
+	Baseline   float64   `json:"baseline"`
+	Reason     string    `json:"reason"`
+}
+
+// DetectResponseSizes baselines the median response size of each path with at
+// least minSamples successful responses and flags, per IP and path, responses
+// of at least minBytes and factor times that median, such as a normally 2 KB
+// endpoint returning 80 MB to one client.
+func DetectResponseSizes(rows []parse.Event, minSamples int, factor float64, minBytes int64) []AnomalyResponseSize {
+	sizes := make(map[string][]float64)
+	for _, ev := range rows {
+		if !sizedResponse(ev) {
+			continue
+		}
+		sizes[ev.Path] = append(sizes[ev.Path], float64(ev.Bytes))
+	}
+	medians := make(map[string]float64)
+	for path, xs := range sizes {
+		if len(xs) < minSamples {
+			continue
+		}
+		sort.Float64s(xs)
+		m := xs[len(xs)/2]
+		if len(xs)%2 == 0 {
+			m = (xs[len(xs)/2-1] + xs[len(xs)/2]) / 2
+		}
+		medians[path] = m
+	}
+
+	type key struct{ ip, path string }
+	found := make(map[key]*AnomalyResponseSize)
+	for _, ev := range rows {
+		if ev.SrcIP == "" || ev.TS.IsZero() || !sizedResponse(ev) {
+			continue
+		}
+		med, ok := medians[ev.Path]
+		if !ok || ev.Bytes < minBytes || float64(ev.Bytes) < factor*med {
+			continue
+		}
+		k := key{ip: ev.SrcIP, path: ev.Path}
+		t := ev.TS.UTC()
+		a, ok := found[k]
+		if !ok {
+			a = &AnomalyResponseSize{Kind: "response_size", SrcIP: ev.SrcIP, Path: ev.Path, FirstSeen: t, LastSeen: t, Baseline: round2(med)}
+			found[k] = a
+		}
+		if t.Before(a.FirstSeen) {
+			a.FirstSeen = t
+		}
+		if t.After(a.LastSeen) {
+			a.LastSeen = t
+		}
+		a.Count++
+		a.TotalBytes += ev.Bytes
+		if ev.Bytes > a.MaxBytes {
+			a.MaxBytes = ev.Bytes
+		}
+	}
+
+	out := make([]AnomalyResponseSize, 0, len(found))
+	for _, a := range found {
+		a.Reason = "Oversized responses on " + a.Path + " to " + a.SrcIP + ": " + intToStr(a.Count) +
+			" response(s) up to " + formatBytes(a.MaxBytes) + " (median for the path " +
+			formatBytes(int64(a.Baseline)) + ") between " + a.FirstSeen.Format("15:04") +
+			" and " + a.LastSeen.Format("15:04") + " UTC."
+		out = append(out, *a)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].MaxBytes != out[j].MaxBytes {
+			return out[i].MaxBytes > out[j].MaxBytes
+		}
+		return out[i].SrcIP < out[j].SrcIP
+	})
+	return out
+}
+
+// sizedResponse reports whether ev is a successful HTTP response with a size;
+// database, VPN and Kubernetes rows are not.
+func sizedResponse(ev parse.Event) bool {
+	return ev.Path != "" && ev.Bytes > 0 && ev.Category == "" && ev.Resource == "" &&
+		(ev.Status == 0 || ev.Status >= 200 && ev.Status < 300)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return strconv.FormatFloat(float64(n)/float64(div), 'f', 1, 64) + " " + string("KMGTPE"[exp]) + "B"
+}
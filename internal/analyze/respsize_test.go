@@ -0,0 +1,42 @@
+package analyze
+
+import (
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectResponseSizes(t *testing.T) {
+	normal := parse.Event{TS: t0, SrcIP: "192.0.2.1", Method: "GET", Path: "/api/orders", Status: 200, Bytes: 2048}
+	dump := parse.Event{TS: t0.Add(time.Hour), SrcIP: "203.0.113.9", Method: "GET", Path: "/api/orders", Status: 200, Bytes: 80 << 20}
+	failed := dump
+	failed.Status = 500
+	small := dump
+	small.Bytes = 512 << 10
+	tests := []struct {
+		name      string
+		rows      []parse.Event
+		wantFires bool
+	}{
+		{"bulk export", append(repeat(normal, 30, time.Minute), dump, dump), true},
+		{"no baseline for the path", append(repeat(normal, 10, time.Minute), dump), false},
+		{"large error page", append(repeat(normal, 30, time.Minute), failed), false},
+		{"under the size floor", append(repeat(normal, 30, time.Minute), small), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectResponseSizes(tt.rows, 20, 20, 1<<20)
+			if !tt.wantFires {
+				if len(got) > 0 {
+					t.Errorf("got %+v, want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Kind != "response_size" || got[0].SrcIP != "203.0.113.9" || got[0].Count != 2 ||
+				got[0].MaxBytes != 80<<20 || got[0].Baseline != 2048 {
+				t.Errorf("got %+v, want one oversized-response anomaly for 203.0.113.9", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package analyze
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// ScannerUAs lists lower-case User-Agent substrings of offensive tooling.
+var ScannerUAs = []string{
+	"sqlmap", "nikto", "nuclei", "masscan", "zgrab", "nmap", "dirbuster", "gobuster",
+	"dirb/", "wfuzz", "ffuf", "feroxbuster", "acunetix", "netsparker", "burpsuite", "burp collaborator",
+	"wpscan", "hydra", "openvas", "nessus", "zmeu", "jaeles", "whatweb", "arachni", "skipfish",
+	"w3af", "httpx", "xsstrike", "commix", "l9explore", "censysinspect",
+}
+
+type AnomalyScannerUA struct {
+	Kind      string    `json:"kind"`
+	SrcIP     string    `json:"srcIp"`
+	Tool      string    `json:"tool"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Count     int       `json:"count"`
+	Reason    string    `json:"reason"`
+}
+
+// DetectScannerUA flags IPs whose User-Agent names a known offensive tool,
+// and IPs sending bursts of at least curlBurst bare curl requests in a minute.
+func DetectScannerUA(rows []parse.Event, curlBurst int) []AnomalyScannerUA {
+	type key struct{ ip, tool string }
+	type agg struct {
+		first, last time.Time
+		count       int
+		perMin      map[time.Time]int
+	}
+	found := make(map[key]*agg)
+
+	for _, ev := range rows {
+		if ev.SrcIP == "" || ev.TS.IsZero() || ev.UA == "" {
+			continue
+		}
+		tool := scannerTool(ev.UA)
+		if tool == "" {
+			continue
+		}
+		k := key{ip: ev.SrcIP, tool: tool}
+		t := ev.TS.UTC()
+		a, ok := found[k]
+		if !ok {
+			a = &agg{first: t, last: t, perMin: make(map[time.Time]int)}
+			found[k] = a
+		}
+		if t.Before(a.first) {
+			a.first = t
+		}
+		if t.After(a.last) {
+			a.last = t
+		}
+		a.count++
+		a.perMin[t.Truncate(time.Minute)]++
+	}
+
+	out := make([]AnomalyScannerUA, 0)
+	for k, a := range found {
+		if k.tool == "curl" {
+			peak := 0
+			for _, n := range a.perMin {
+				if n > peak {
+					peak = n
+				}
+			}
+			if peak < curlBurst {
+				continue
+			}
+		}
+
+		out = append(out, AnomalyScannerUA{
+			Kind:      "scanner_ua",
+			SrcIP:     k.ip,
+			Tool:      k.tool,
+			FirstSeen: a.first,
+			LastSeen:  a.last,
+			Count:     a.count,
+			Reason: "Requests from " + k.ip + " identify as " + k.tool + ": " +
+				intToStr(a.count) + " request(s) between " + a.first.Format("15:04") +
+				" and " + a.last.Format("15:04") + " UTC.",
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+	return out
+}
+
+func scannerTool(ua string) string {
+	l := strings.ToLower(ua)
+	for _, s := range ScannerUAs {
+		if strings.Contains(l, s) {
+			return strings.TrimSuffix(s, "/")
+		}
+	}
+	if strings.HasPrefix(l, "curl/") {
+		return "curl"
+	}
+	return ""
+}
@@ -0,0 +1,39 @@
+package analyze
+
+import (
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectScannerUA(t *testing.T) {
+	req := func(ua string) parse.Event {
+		return parse.Event{TS: t0, SrcIP: "198.51.100.7", Method: "GET", Path: "/", Status: 200, UA: ua}
+	}
+	tests := []struct {
+		name     string
+		rows     []parse.Event
+		wantTool string // empty when nothing fires
+	}{
+		{"sqlmap", []parse.Event{req("sqlmap/1.7.2#stable (https://sqlmap.org)")}, "sqlmap"},
+		{"dirb", []parse.Event{req("Mozilla/5.0 DirB/2.22")}, "dirb"},
+		{"curl burst", repeat(req("curl/8.4.0"), 40, time.Second), "curl"},
+		{"occasional curl", repeat(req("curl/8.4.0"), 40, time.Minute), ""},
+		{"browser", repeat(req("Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0"), 40, time.Second), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectScannerUA(tt.rows, 30)
+			if tt.wantTool == "" {
+				if len(got) > 0 {
+					t.Errorf("got %+v, want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Kind != "scanner_ua" || got[0].Tool != tt.wantTool {
+				t.Errorf("got %+v, want %s", got, tt.wantTool)
+			}
+		})
+	}
+}
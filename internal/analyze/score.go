@@ -0,0 +1,33 @@
+package analyze
+
+import "math"
+
+// Evidence is one measured input to a confidence score: a Value compared with
+// the Threshold at which the signal alone is enough to report, counted with
+// Weight.
+type Evidence struct {
+	Signal    string  `json:"signal"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	Weight    float64 `json:"weight"`
+}
+
+// maxStrength caps how much one signal can contribute, so a single extreme
+// value cannot carry the score on its own.
+const maxStrength = 4
+
+// Calibrate maps evidence onto the confidence scale shared by every detector.
+// Each signal's strength is Value/Threshold, capped at maxStrength; the
+// weighted strengths add up to S and confidence is 1 - 2^-S. One signal at
+// its threshold gives 0.5, twice the threshold 0.75, and each further unit of
+// S halves the remaining doubt. The result is capped at 0.99.
+func Calibrate(ev []Evidence) float64 {
+	var s float64
+	for _, e := range ev {
+		if e.Threshold <= 0 || e.Value <= 0 {
+			continue
+		}
+		s += e.Weight * min(e.Value/e.Threshold, maxStrength)
+	}
+	return round2(min(1-math.Pow(2, -s), 0.99))
+}
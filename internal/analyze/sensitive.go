@@ -1,20 +1,159 @@
 package analyze
 
 import (
-	"math"
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/allensuvorov/tenexlog/internal/parse"
 )
 
+// SensitivityList is the default set of sensitive path prefixes. Use
+// SensitivePaths and SetSensitivePaths to read or replace the active list at
+// runtime.
 var SensitivityList = []string{
 	"/admin", "/login", "/wp-admin", "/wp-login", "/xmlrpc.php",
 	"/.git", "/.env", "/.DS_Store", "/.well-known", "/server-status",
 	"/phpmyadmin", "/manager", "/actuator", "/console",
 }
 
+var sensitivityMu sync.RWMutex
+
+var ErrBadSensitivePath = errors.New("sensitive paths must be a /prefix, a glob such as */wp-login.php or a ^regex")
+
+// pathMatcher matches one SensitivityList entry. Entries starting with ^ are
+// regular expressions, entries containing * ? or [ are globs matched against
+// the whole path without its query string (* also spans /), and anything else
+// is a prefix. Matching is case-insensitive.
+type pathMatcher struct {
+	entry  string
+	prefix string
+	re     *regexp.Regexp
+	glob   bool
+}
+
+func compilePathMatcher(entry string) (pathMatcher, error) {
+	m := pathMatcher{entry: entry}
+	switch {
+	case strings.HasPrefix(entry, "^"):
+		re, err := regexp.Compile("(?i)" + entry)
+		if err != nil {
+			return m, fmt.Errorf("%w: %q: %v", ErrBadSensitivePath, entry, err)
+		}
+		m.re = re
+	case strings.ContainsAny(entry, "*?["):
+		re, err := globToRegexp(entry)
+		if err != nil {
+			return m, fmt.Errorf("%w: %q: %v", ErrBadSensitivePath, entry, err)
+		}
+		m.re, m.glob = re, true
+	case strings.HasPrefix(entry, "/"):
+		m.prefix = strings.ToLower(entry)
+	default:
+		return m, fmt.Errorf("%w: %q", ErrBadSensitivePath, entry)
+	}
+	return m, nil
+}
+
+func (m pathMatcher) match(path string) bool {
+	if m.glob {
+		path, _, _ = strings.Cut(path, "?")
+	}
+	if m.re != nil {
+		return m.re.MatchString(path)
+	}
+	return strings.HasPrefix(strings.ToLower(path), m.prefix)
+}
+
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				return nil, errors.New("unterminated [")
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// SensitivePaths returns a copy of the active sensitive path list.
+func SensitivePaths() []string {
+	sensitivityMu.RLock()
+	defer sensitivityMu.RUnlock()
+	return append([]string(nil), SensitivityList...)
+}
+
+// SetSensitivePaths replaces the active list. Entries are trimmed, blanks
+// dropped and duplicates removed; an invalid entry leaves the list unchanged.
+func SetSensitivePaths(paths []string) error {
+	clean := make([]string, 0, len(paths))
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, err := compilePathMatcher(p); err != nil {
+			return err
+		}
+		if !seen[p] {
+			seen[p] = true
+			clean = append(clean, p)
+		}
+	}
+	sensitivityMu.Lock()
+	SensitivityList = clean
+	sensitivityMu.Unlock()
+	return nil
+}
+
+// LoadSensitivePaths reads one path per line from path, ignoring blank lines
+// and # comments, and makes it the active list.
+func LoadSensitivePaths(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var paths []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return SetSensitivePaths(paths)
+}
+
 type AnomalySensitive struct {
 	Kind       string    `json:"kind"`
 	SrcIP      string    `json:"srcIp"`
@@ -22,30 +161,49 @@ type AnomalySensitive struct {
 	LastSeen   time.Time `json:"lastSeen"`
 	Hits       int       `json:"hits"`
 	UniquePref int       `json:"uniquePref"`
-	Confidence float64   `json:"confidence"`
 	Reason     string    `json:"reason"`
 }
 
+// SensitiveMatcher returns a func reporting whether a path matches the
+// current sensitive path list.
+func SensitiveMatcher() func(path string) bool {
+	var matchers []pathMatcher
+	for _, p := range SensitivePaths() {
+		if m, err := compilePathMatcher(p); err == nil {
+			matchers = append(matchers, m)
+		}
+	}
+	return func(path string) bool {
+		for _, m := range matchers {
+			if m.match(path) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 func DetectSensitivePaths(rows []parse.Event, minHits, minUnique int) []AnomalySensitive {
 	type prefCount map[string]int
 	ipToCounts := make(map[string]prefCount)
 	ipFirst := make(map[string]time.Time)
 	ipLast := make(map[string]time.Time)
 
-	prefixes := make([]string, len(SensitivityList))
-	for i, p := range SensitivityList {
-		prefixes[i] = strings.ToLower(p)
+	var matchers []pathMatcher
+	for _, p := range SensitivePaths() {
+		if m, err := compilePathMatcher(p); err == nil {
+			matchers = append(matchers, m)
+		}
 	}
 
 	for _, ev := range rows {
 		if ev.SrcIP == "" || ev.Path == "" || ev.TS.IsZero() {
 			continue
 		}
-		lpath := strings.ToLower(ev.Path)
 		matched := ""
-		for _, pref := range prefixes {
-			if strings.HasPrefix(lpath, pref) {
-				matched = pref
+		for _, m := range matchers {
+			if m.match(ev.Path) {
+				matched = m.entry
 				break
 			}
 		}
@@ -76,7 +234,6 @@ func DetectSensitivePaths(rows []parse.Event, minHits, minUnique int) []AnomalyS
 			hits += n
 		}
 		if hits >= minHits || uniq >= minUnique {
-			conf := 1 - expNeg(float64(hits)/10.0)
 			reason := buildSensitiveReason(ip, hits, uniq, ipFirst[ip], ipLast[ip])
 			out = append(out, AnomalySensitive{
 				Kind:       "sensitive_paths",
@@ -85,7 +242,6 @@ func DetectSensitivePaths(rows []parse.Event, minHits, minUnique int) []AnomalyS
 				LastSeen:   ipLast[ip],
 				Hits:       hits,
 				UniquePref: uniq,
-				Confidence: round2(conf),
 				Reason:     reason,
 			})
 		}
@@ -95,10 +251,6 @@ func DetectSensitivePaths(rows []parse.Event, minHits, minUnique int) []AnomalyS
 	return out
 }
 
-func expNeg(x float64) float64 {
-	return math.Exp(-x)
-}
-
 func buildSensitiveReason(ip string, hits, uniq int, first, last time.Time) string {
 	win := last.Sub(first).Minutes()
 	if win < 0 {
@@ -0,0 +1,147 @@
+package analyze
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// keepSensitivePaths restores the active sensitive path list when t ends.
+func keepSensitivePaths(t *testing.T) {
+	t.Helper()
+	saved := SensitivePaths()
+	t.Cleanup(func() {
+		if err := SetSensitivePaths(saved); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestSetSensitivePaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		paths   []string
+		want    []string
+		wantErr bool
+	}{
+		{"cleaned", []string{" /admin ", "", "/.env", "/admin"}, []string{"/admin", "/.env"}, false},
+		{"relative entry", []string{"/admin", "admin"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keepSensitivePaths(t)
+			before := SensitivePaths()
+			err := SetSensitivePaths(tt.paths)
+			if tt.wantErr {
+				if !errors.Is(err, ErrBadSensitivePath) {
+					t.Fatalf("err = %v, want ErrBadSensitivePath", err)
+				}
+				if got := SensitivePaths(); !slices.Equal(got, before) {
+					t.Errorf("list changed to %q on error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := SensitivePaths(); !slices.Equal(got, tt.want) {
+				t.Errorf("list %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadSensitivePaths(t *testing.T) {
+	keepSensitivePaths(t)
+	path := filepath.Join(t.TempDir(), "sensitive.txt")
+	if err := os.WriteFile(path, []byte("# admin panels\n/admin\n\n  /grafana  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadSensitivePaths(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := SensitivePaths(), []string{"/admin", "/grafana"}; !slices.Equal(got, want) {
+		t.Errorf("list %q, want %q", got, want)
+	}
+}
+
+func TestCompilePathMatcher(t *testing.T) {
+	tests := []struct {
+		entry   string
+		path    string
+		want    bool
+		wantErr bool
+	}{
+		{entry: "/admin", path: "/ADMIN/users", want: true},
+		{entry: "/admin", path: "/blog/admin", want: false},
+		{entry: "*/wp-login.php", path: "/blog/wp-login.php?redirect_to=/", want: true},
+		{entry: "*/wp-login.php", path: "/wp-login.php.bak", want: false},
+		{entry: "/backup-?.zip", path: "/backup-1.zip", want: true},
+		{entry: "/backup-?.zip", path: "/backup-/.zip", want: false},
+		{entry: "/[!a-m]*.sql", path: "/prod.sql", want: true},
+		{entry: "/[!a-m]*.sql", path: "/dump.sql", want: false},
+		{entry: `^/api/v\d+/debug`, path: "/api/V2/Debug/vars", want: true},
+		{entry: `^/api/v\d+/debug`, path: "/api/latest/debug", want: false},
+		{entry: "^/(", wantErr: true},
+		{entry: "/[abc", wantErr: true},
+		{entry: "admin", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.entry+" "+tt.path, func(t *testing.T) {
+			m, err := compilePathMatcher(tt.entry)
+			if tt.wantErr {
+				if !errors.Is(err, ErrBadSensitivePath) {
+					t.Errorf("err = %v, want ErrBadSensitivePath", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.match(tt.path); got != tt.want {
+				t.Errorf("match(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectSensitivePaths(t *testing.T) {
+	keepSensitivePaths(t)
+	if err := SetSensitivePaths([]string{"/admin", "/.env", "*/wp-login.php", `^/api/v\d+/debug`}); err != nil {
+		t.Fatal(err)
+	}
+	hit := func(path string, n int) []parse.Event {
+		return repeat(parse.Event{TS: t0, SrcIP: "203.0.113.9", Method: "GET", Path: path, Status: 404}, n, time.Second)
+	}
+	tests := []struct {
+		name       string
+		rows       []parse.Event
+		wantHits   int // 0 when nothing fires
+		wantUnique int
+	}{
+		{"many hits on one prefix", hit("/admin/login", 5), 5, 1},
+		{"few hits across entries", append(hit("/.env", 1), hit("/blog/wp-login.php", 1)...), 2, 2},
+		{"regex entry", append(hit("/api/v1/debug", 1), hit("/admin", 1)...), 2, 2},
+		{"one stray hit", hit("/.env", 1), 0, 0},
+		{"ordinary pages", hit("/index.html", 20), 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectSensitivePaths(tt.rows, 5, 2)
+			if tt.wantHits == 0 {
+				if len(got) > 0 {
+					t.Errorf("got %+v, want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Kind != "sensitive_paths" || got[0].Hits != tt.wantHits || got[0].UniquePref != tt.wantUnique {
+				t.Errorf("got %+v, want %d hit(s) on %d entries", got, tt.wantHits, tt.wantUnique)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package analyze
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+type AnomalyServerErrors struct {
+	Kind      string    `json:"kind"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Errors    int       `json:"errors"`
+	Peak      int       `json:"peak"`
+	Baseline  float64   `json:"baseline"`
+	Z         float64   `json:"z"`
+	TopPaths  []string  `json:"topPaths"`
+	Statuses  []string  `json:"statuses"`
+	Reason    string    `json:"reason"`
+}
+
+// DetectServerErrorBursts counts 5xx responses per minute, with quiet minutes
+// between the first and last request counted as zero, and flags minutes with
+// at least minErrors server errors that are at least 3× the mean and z ≥ 3
+// (or any such minute when the log otherwise has none). Consecutive hot
+// minutes merge into one burst, listing the paths and status codes involved.
+func DetectServerErrorBursts(rows []parse.Event, minErrors int) []AnomalyServerErrors {
+	perMin := make(map[time.Time]int)
+	paths := make(map[time.Time]map[string]int)
+	statuses := make(map[time.Time]map[int]int)
+	var first, last time.Time
+
+	for _, ev := range rows {
+		if ev.TS.IsZero() {
+			continue
+		}
+		m := ev.TS.UTC().Truncate(time.Minute)
+		if first.IsZero() || m.Before(first) {
+			first = m
+		}
+		if m.After(last) {
+			last = m
+		}
+		if ev.Status < 500 || ev.Status > 599 {
+			continue
+		}
+		perMin[m]++
+		if paths[m] == nil {
+			paths[m] = make(map[string]int)
+			statuses[m] = make(map[int]int)
+		}
+		paths[m][ev.Path]++
+		statuses[m][ev.Status]++
+	}
+	if len(perMin) == 0 {
+		return nil
+	}
+
+	var series []float64
+	for m := first; !m.After(last); m = m.Add(time.Minute) {
+		series = append(series, float64(perMin[m]))
+	}
+	mean, std := meanStd(series)
+
+	hot := func(c float64) (float64, bool) {
+		if c < float64(minErrors) || c < 3*mean {
+			return 0, false
+		}
+		if std == 0 {
+			return 3, true
+		}
+		z := (c - mean) / std
+		return z, z >= 3
+	}
+
+	var out []AnomalyServerErrors
+	var cur *AnomalyServerErrors
+	burstPaths := make(map[string]int)
+	burstStatus := make(map[int]int)
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		cur.TopPaths = topKeys(burstPaths, 5)
+		codes := make([]int, 0, len(burstStatus))
+		for c := range burstStatus {
+			codes = append(codes, c)
+		}
+		sort.Ints(codes)
+		for _, c := range codes {
+			cur.Statuses = append(cur.Statuses, strconv.Itoa(c)+"×"+intToStr(burstStatus[c]))
+		}
+		cur.Reason = "Server error burst between " + cur.FirstSeen.Format("15:04") + " and " + cur.LastSeen.Format("15:04") +
+			" UTC: " + intToStr(cur.Errors) + " 5xx responses, peaking at " + intToStr(cur.Peak) + "/min (baseline ≈ " +
+			floatToStr(cur.Baseline) + "/min); most on " + cur.TopPaths[0] + "."
+		out = append(out, *cur)
+		cur = nil
+		burstPaths = make(map[string]int)
+		burstStatus = make(map[int]int)
+	}
+
+	for m := first; !m.After(last); m = m.Add(time.Minute) {
+		c := perMin[m]
+		z, ok := hot(float64(c))
+		if !ok {
+			flush()
+			continue
+		}
+		if cur == nil {
+			cur = &AnomalyServerErrors{Kind: "server_error_burst", FirstSeen: m, Baseline: round2(mean)}
+		}
+		cur.LastSeen = m
+		cur.Errors += c
+		if c > cur.Peak {
+			cur.Peak = c
+			cur.Z = round2(z)
+		}
+		for p, n := range paths[m] {
+			burstPaths[p] += n
+		}
+		for s, n := range statuses[m] {
+			burstStatus[s] += n
+		}
+	}
+	flush()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].FirstSeen.After(out[j].FirstSeen) })
+	return out
+}
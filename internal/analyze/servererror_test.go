@@ -0,0 +1,53 @@
+package analyze
+
+import (
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectServerErrorBursts(t *testing.T) {
+	ok := parse.Event{TS: t0, SrcIP: "192.0.2.1", Method: "GET", Path: "/", Status: 200}
+	// hour is an hour of traffic with a stray 500 every ten minutes.
+	hour := func() []parse.Event {
+		rows := repeat(ok, 60, time.Minute)
+		for i := 5; i < len(rows); i += 10 {
+			rows[i].Status = 500
+		}
+		return rows
+	}
+	// errors returns n 502s on /api/pay during minute 30.
+	errors := func(n int) []parse.Event {
+		return repeat(parse.Event{TS: t0.Add(30 * time.Minute), SrcIP: "192.0.2.2", Method: "POST", Path: "/api/pay", Status: 502}, n, time.Second)
+	}
+	steady := make([]parse.Event, 0)
+	for m := range 60 {
+		steady = append(steady, repeat(parse.Event{TS: t0.Add(time.Duration(m) * time.Minute), Path: "/api/pay", Status: 503}, 15, time.Second)...)
+	}
+	tests := []struct {
+		name      string
+		rows      []parse.Event
+		wantFires bool
+	}{
+		{"payment backend down", append(hour(), errors(20)...), true},
+		{"stray errors only", hour(), false},
+		{"burst below the floor", append(hour(), errors(8)...), false},
+		{"chronically failing endpoint", steady, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectServerErrorBursts(tt.rows, 10)
+			if !tt.wantFires {
+				if len(got) > 0 {
+					t.Errorf("got %+v, want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Kind != "server_error_burst" || got[0].Errors != 20 || got[0].Peak != 20 ||
+				!got[0].FirstSeen.Equal(t0.Add(30*time.Minute)) || got[0].TopPaths[0] != "/api/pay" || len(got[0].Statuses) != 1 || got[0].Statuses[0] != "502×20" {
+				t.Errorf("got %+v, want one burst of 20 502s at 13:30", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package analyze
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+type AnomalyConcurrentSessions struct {
+	Kind      string    `json:"kind"`
+	User      string    `json:"user"`
+	SrcIP     string    `json:"srcIp"`
+	IPs       []string  `json:"ips"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Peak      int       `json:"peak"`
+	Reason    string    `json:"reason"`
+}
+
+// DetectConcurrentSessions flags accounts holding sessions from two or more
+// source IPs at the same time. Sessions are built from CONNECT, UPDATE and
+// DISCONNECT events carrying a SessionID; a session without a disconnect is
+// assumed to last maxSession, or until the end of the log if that is sooner,
+// but at least until its last accounting update.
+func DetectConcurrentSessions(rows []parse.Event, maxSession time.Duration) []AnomalyConcurrentSessions {
+	type session struct {
+		user, ip   string
+		start, end time.Time
+		closed     bool
+	}
+	type key struct{ user, id string }
+	sessions := make(map[key]*session)
+	var all []*session
+	var logEnd time.Time
+
+	for _, ev := range rows {
+		if ev.TS.After(logEnd) {
+			logEnd = ev.TS
+		}
+		if ev.User == "" || ev.SessionID == "" || ev.TS.IsZero() {
+			continue
+		}
+		k := key{ev.User, ev.SessionID}
+		t := ev.TS.UTC()
+		s, ok := sessions[k]
+		switch ev.Method {
+		case "CONNECT", "UPDATE":
+			if ok && !s.closed && ev.Method == "UPDATE" {
+				s.end = t
+				continue
+			}
+			if ok && !s.closed && ev.Method == "CONNECT" && s.ip == ev.SrcIP {
+				continue
+			}
+			if ev.SrcIP == "" {
+				continue
+			}
+			s = &session{user: ev.User, ip: ev.SrcIP, start: t, end: t}
+			sessions[k] = s
+			all = append(all, s)
+		case "DISCONNECT":
+			if ok {
+				s.end, s.closed = t, true
+			}
+		}
+	}
+
+	perUser := make(map[string][]*session)
+	for _, s := range all {
+		if !s.closed {
+			end := s.start.Add(maxSession)
+			if logEnd.Before(end) {
+				end = logEnd.UTC()
+			}
+			if end.After(s.end) {
+				s.end = end
+			}
+		}
+		perUser[s.user] = append(perUser[s.user], s)
+	}
+
+	out := make([]AnomalyConcurrentSessions, 0)
+	for user, ss := range perUser {
+		sort.Slice(ss, func(i, j int) bool { return ss[i].start.Before(ss[j].start) })
+
+		var (
+			peak        int
+			first, last time.Time
+			ips         = make(map[string]struct{})
+			newest      string
+		)
+		for i, s := range ss {
+			active := map[string]struct{}{s.ip: {}}
+			for _, o := range ss[:i] {
+				if o.end.After(s.start) && o.ip != s.ip {
+					active[o.ip] = struct{}{}
+				}
+			}
+			if len(active) < 2 {
+				continue
+			}
+			if first.IsZero() {
+				first = s.start
+			}
+			last = s.start
+			newest = s.ip
+			for ip := range active {
+				ips[ip] = struct{}{}
+			}
+			if len(active) > peak {
+				peak = len(active)
+			}
+		}
+		if peak < 2 {
+			continue
+		}
+
+		list := make([]string, 0, len(ips))
+		for ip := range ips {
+			list = append(list, ip)
+		}
+		sort.Strings(list)
+
+		out = append(out, AnomalyConcurrentSessions{
+			Kind:      "concurrent_sessions",
+			User:      user,
+			SrcIP:     newest,
+			IPs:       list,
+			FirstSeen: first,
+			LastSeen:  last,
+			Peak:      peak,
+			Reason: "Account " + user + " held simultaneous sessions from " + intToStr(peak) +
+				" IPs (" + strings.Join(list, ", ") + "), first at " + first.Format("15:04") + " UTC.",
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+	return out
+}
@@ -0,0 +1,61 @@
+package analyze
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectConcurrentSessions(t *testing.T) {
+	ev := func(method, ip, session string, after time.Duration) parse.Event {
+		return parse.Event{TS: t0.Add(after), SrcIP: ip, User: "alice", Method: method, SessionID: session}
+	}
+	tests := []struct {
+		name    string
+		rows    []parse.Event
+		wantIPs []string // nil when nothing fires
+	}{
+		{"overlapping sessions", []parse.Event{
+			ev("CONNECT", "192.0.2.1", "s1", 0),
+			ev("CONNECT", "198.51.100.1", "s2", time.Hour),
+			ev("DISCONNECT", "198.51.100.1", "s2", 2*time.Hour),
+			ev("DISCONNECT", "192.0.2.1", "s1", 3*time.Hour),
+		}, []string{"192.0.2.1", "198.51.100.1"}},
+		{"open session overlapping", []parse.Event{
+			ev("CONNECT", "192.0.2.1", "s1", 0),
+			ev("CONNECT", "198.51.100.1", "s2", time.Hour),
+			ev("UPDATE", "198.51.100.1", "s2", 2*time.Hour),
+		}, []string{"192.0.2.1", "198.51.100.1"}},
+		{"back-to-back sessions", []parse.Event{
+			ev("CONNECT", "192.0.2.1", "s1", 0),
+			ev("DISCONNECT", "192.0.2.1", "s1", time.Hour),
+			ev("CONNECT", "198.51.100.1", "s2", 2*time.Hour),
+			ev("DISCONNECT", "198.51.100.1", "s2", 3*time.Hour),
+		}, nil},
+		{"two sessions from one IP", []parse.Event{
+			ev("CONNECT", "192.0.2.1", "s1", 0),
+			ev("CONNECT", "192.0.2.1", "s2", time.Hour),
+			ev("DISCONNECT", "192.0.2.1", "s2", 2*time.Hour),
+		}, nil},
+		{"stale session outlived", []parse.Event{
+			ev("CONNECT", "192.0.2.1", "s1", 0),
+			ev("CONNECT", "198.51.100.1", "s2", 13*time.Hour),
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectConcurrentSessions(tt.rows, 12*time.Hour)
+			if tt.wantIPs == nil {
+				if len(got) > 0 {
+					t.Errorf("got %+v, want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Kind != "concurrent_sessions" || got[0].Peak != 2 || !slices.Equal(got[0].IPs, tt.wantIPs) {
+				t.Errorf("got %+v, want sessions from %v", got, tt.wantIPs)
+			}
+		})
+	}
+}
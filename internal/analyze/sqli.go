@@ -0,0 +1,24 @@
+package analyze
+
+import (
+	"regexp"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// SQLiRules are the SQL injection indicators checked by DetectSQLi.
+var SQLiRules = []PayloadRule{
+	{Name: "union_select", Re: regexp.MustCompile(`(?i)union(\s|/\*.*?\*/)+(all(\s|/\*.*?\*/)+)?select`)},
+	{Name: "tautology", Re: regexp.MustCompile(`(?i)['"\)]\s*(or|and)\s+['"]?(\d+|'[^']*'|"[^"]*")['"]?\s*(=|like)\s*['"]?(\d+|'[^']*'|"[^"]*")`)},
+	{Name: "time_based", Re: regexp.MustCompile(`(?i)\b(sleep|pg_sleep|benchmark)\s*\(|waitfor\s+delay\s`)},
+	{Name: "information_schema", Re: regexp.MustCompile(`(?i)information_schema|\bsys(objects|columns)\b|\bpg_catalog\b|mysql\.user`)},
+	{Name: "stacked_query", Re: regexp.MustCompile(`(?i);\s*(drop|insert|update|delete|exec|declare|shutdown)\s`)},
+	{Name: "comment_terminator", Re: regexp.MustCompile(`'\s*(--|#|/\*)`)},
+	{Name: "error_based", Re: regexp.MustCompile(`(?i)\b(extractvalue|updatexml|convert|cast)\s*\(.*select`)},
+}
+
+// DetectSQLi flags source IPs sending requests whose path or query string
+// carries SQL injection indicators.
+func DetectSQLi(rows []parse.Event) []AnomalyPayload {
+	return detectPayloads(rows, "sqli", "SQL injection payloads", SQLiRules, false)
+}
@@ -0,0 +1,48 @@
+package analyze
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// request returns a GET of target, split into path and raw query as the
+// parsers do.
+func request(target string, status int) parse.Event {
+	path, query, _ := strings.Cut(target, "?")
+	return parse.Event{TS: t0, SrcIP: "198.51.100.7", Method: "GET", Path: path, RawQuery: query, Status: status}
+}
+
+func TestDetectSQLi(t *testing.T) {
+	tests := []struct {
+		name           string
+		target         string
+		status         int
+		wantIndicators []string // nil when nothing fires
+		wantSucceeded  int
+	}{
+		{"union select", "/items?id=1%20UNION%20ALL%20SELECT%20username,password%20FROM%20users", 200, []string{"union_select"}, 1},
+		{"tautology with comment", "/login?user=admin'%20OR%20'1'='1'--", 403, []string{"comment_terminator", "tautology"}, 0},
+		{"double-encoded sleep", "/search?q=1%2520AND%2520SLEEP(5)", 200, []string{"time_based"}, 1},
+		{"comment in union", "/items?id=1+UNION/**/SELECT+1", 500, []string{"union_select"}, 0},
+		{"schema probe", "/items?id=(select+table_name+from+information_schema.tables)", 500, []string{"information_schema"}, 0},
+		{"plain search", "/search?q=union+station+selection", 200, nil, 0},
+		{"apostrophe in a name", "/users?name=O'Brien", 200, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectSQLi([]parse.Event{request(tt.target, tt.status)})
+			if tt.wantIndicators == nil {
+				if len(got) > 0 {
+					t.Errorf("got %+v, want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Kind != "sqli" || !slices.Equal(got[0].Indicators, tt.wantIndicators) || got[0].Succeeded != tt.wantSucceeded {
+				t.Errorf("got %+v, want indicators %v and %d succeeded", got, tt.wantIndicators, tt.wantSucceeded)
+			}
+		})
+	}
+}
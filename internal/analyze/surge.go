@@ -0,0 +1,160 @@
+package analyze
+
+import (
+	"sort"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+type AnomalySurge struct {
+	Kind      string    `json:"kind"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Count     int       `json:"count"`
+	Peak      int       `json:"peak"`
+	Baseline  float64   `json:"baseline"`
+	Z         float64   `json:"z"`
+	TopIPs    []string  `json:"topIps"`
+	TopShare  float64   `json:"topShare"`
+	Reason    string    `json:"reason"`
+}
+
+// DetectTrafficSurges flags minutes where total traffic across all sources
+// jumps well above the trailing window before it, merging consecutive
+// minutes into one surge. It works from the parse timeline, which covers
+// every scanned line, so floods spread thinly over many IPs are caught even
+// when no single IP spikes. rows, where they cover the surge, are used to
+// name the top sources and the largest single-IP share.
+func DetectTrafficSurges(timeline []parse.Bucket, rows []parse.Event, window, minCount int) []AnomalySurge {
+	if len(timeline) == 0 {
+		return nil
+	}
+	perMin := make(map[time.Time]int, len(timeline))
+	first, last := timeline[0].T, timeline[0].T
+	for _, b := range timeline {
+		perMin[b.T] += b.Count
+		if b.T.Before(first) {
+			first = b.T
+		}
+		if b.T.After(last) {
+			last = b.T
+		}
+	}
+	var series []int
+	for m := first; !m.After(last); m = m.Add(time.Minute) {
+		series = append(series, perMin[m])
+	}
+	if len(series) <= window {
+		return nil
+	}
+
+	type surge struct {
+		from, to    int
+		count, peak int
+		base, std   float64
+		z           float64
+	}
+	var surges []*surge
+	var cur *surge
+	for i := window; i < len(series); i++ {
+		c := float64(series[i])
+		// While a surge is running, keep comparing against the baseline from
+		// before it started rather than a window it has already inflated.
+		var mean, std float64
+		if cur != nil {
+			mean, std = cur.base, cur.std
+		} else {
+			hist := make([]float64, window)
+			for j := range hist {
+				hist[j] = float64(series[i-window+j])
+			}
+			mean, std = meanStd(hist)
+		}
+		z := 0.0
+		if std > 0 {
+			z = (c - mean) / std
+		}
+		hot := series[i] >= minCount && c >= 3*mean && (z >= 3 || std == 0)
+		if !hot {
+			cur = nil
+			continue
+		}
+		if std == 0 {
+			z = 3
+		}
+		if cur == nil {
+			cur = &surge{from: i, base: mean, std: std}
+			surges = append(surges, cur)
+		}
+		cur.to = i
+		cur.count += series[i]
+		if series[i] > cur.peak {
+			cur.peak = series[i]
+		}
+		if z > cur.z {
+			cur.z = z
+		}
+	}
+
+	out := make([]AnomalySurge, 0, len(surges))
+	for _, s := range surges {
+		from := first.Add(time.Duration(s.from) * time.Minute)
+		to := first.Add(time.Duration(s.to) * time.Minute)
+		top, share := topSources(rows, from, to.Add(time.Minute))
+		reason := "Traffic surge across all sources between " + from.Format("15:04") + " and " + to.Format("15:04") +
+			" UTC: peak " + intToStr(s.peak) + " req/min against a trailing baseline of ≈ " + floatToStr(round2(s.base)) + " req/min."
+		if len(top) > 0 {
+			reason += " The busiest source sent " + floatToStr(round2(share*100)) + "% of the surge."
+			if share < 0.2 {
+				reason += " The load is spread across many sources, consistent with a distributed flood."
+			}
+		}
+		out = append(out, AnomalySurge{
+			Kind:      "traffic_surge",
+			FirstSeen: from,
+			LastSeen:  to,
+			Count:     s.count,
+			Peak:      s.peak,
+			Baseline:  round2(s.base),
+			Z:         round2(s.z),
+			TopIPs:    top,
+			TopShare:  round2(share),
+			Reason:    reason,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].FirstSeen.After(out[j].FirstSeen) })
+	return out
+}
+
+// topSources returns up to five IPs with the most rows in [from, to) and the
+// busiest IP's share of those rows.
+func topSources(rows []parse.Event, from, to time.Time) ([]string, float64) {
+	counts := make(map[string]int)
+	total := 0
+	for _, ev := range rows {
+		if ev.SrcIP == "" || ev.TS.Before(from) || !ev.TS.Before(to) {
+			continue
+		}
+		counts[ev.SrcIP]++
+		total++
+	}
+	if total == 0 {
+		return nil, 0
+	}
+	ips := make([]string, 0, len(counts))
+	for ip := range counts {
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(i, j int) bool {
+		if counts[ips[i]] != counts[ips[j]] {
+			return counts[ips[i]] > counts[ips[j]]
+		}
+		return ips[i] < ips[j]
+	})
+	share := float64(counts[ips[0]]) / float64(total)
+	if len(ips) > 5 {
+		ips = ips[:5]
+	}
+	return ips, share
+}
@@ -0,0 +1,62 @@
+package analyze
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectTrafficSurges(t *testing.T) {
+	// timeline returns one bucket a minute with the given counts.
+	timeline := func(counts ...int) []parse.Bucket {
+		out := make([]parse.Bucket, len(counts))
+		for i, c := range counts {
+			out[i] = parse.Bucket{T: t0.Add(time.Duration(i) * time.Minute), Count: c}
+		}
+		return out
+	}
+	// steady returns n minutes alternating around 20 requests.
+	steady := func(n int) []int {
+		out := make([]int, n)
+		for i := range out {
+			out[i] = 18 + 4*(i%2)
+		}
+		return out
+	}
+	// flood spreads 200 requests in minute 40 over 50 sources.
+	var flood []parse.Event
+	for i := range 200 {
+		flood = append(flood, parse.Event{TS: t0.Add(40*time.Minute + time.Duration(i)*250*time.Millisecond), SrcIP: "198.51.100." + strconv.Itoa(i%50), Path: "/"})
+	}
+	tests := []struct {
+		name     string
+		counts   []int
+		rows     []parse.Event
+		wantPeak int // 0 when nothing fires
+	}{
+		{"distributed flood", append(append(steady(40), 200, 220), steady(10)...), flood, 220},
+		{"steady traffic", steady(60), nil, 0},
+		{"surge below the floor", append(append(steady(40), 90), steady(10)...), nil, 0},
+		{"timeline shorter than the window", append(steady(10), 500), nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectTrafficSurges(timeline(tt.counts...), tt.rows, 30, 100)
+			if tt.wantPeak == 0 {
+				if len(got) > 0 {
+					t.Errorf("got %+v, want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Kind != "traffic_surge" || got[0].Peak != tt.wantPeak || got[0].Count != 420 ||
+				!got[0].FirstSeen.Equal(t0.Add(40*time.Minute)) || !got[0].LastSeen.Equal(t0.Add(41*time.Minute)) {
+				t.Fatalf("got %+v, want one surge over minutes 40-41", got)
+			}
+			if len(got[0].TopIPs) != 5 || got[0].TopShare != 0.02 {
+				t.Errorf("top sources %v with share %v, want 5 with 0.02", got[0].TopIPs, got[0].TopShare)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package analyze
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// GeoLookup resolves an IP to coordinates. ok is false when the location is
+// unknown.
+type GeoLookup func(ip string) (lat, lon float64, ok bool)
+
+type AnomalyTravel struct {
+	Kind       string    `json:"kind"`
+	User       string    `json:"user"`
+	SrcIP      string    `json:"srcIp"`
+	FromIP     string    `json:"fromIp"`
+	FirstSeen  time.Time `json:"firstSeen"`
+	LastSeen   time.Time `json:"lastSeen"`
+	DistanceKm float64   `json:"distanceKm"`
+	SpeedKmh   float64   `json:"speedKmh"`
+	Reason     string    `json:"reason"`
+}
+
+// DetectImpossibleTravel flags identities seen from two locations at least
+// minKm apart faster than maxKmh allows. Only the worst hop per identity is
+// reported.
+func DetectImpossibleTravel(rows []parse.Event, geo GeoLookup, minKm, maxKmh float64) []AnomalyTravel {
+	if geo == nil {
+		return nil
+	}
+	type fix struct {
+		t        time.Time
+		ip       string
+		lat, lon float64
+	}
+	perUser := make(map[string][]fix)
+	cache := make(map[string]*fix)
+
+	for _, ev := range rows {
+		if ev.User == "" || ev.SrcIP == "" || ev.TS.IsZero() {
+			continue
+		}
+		loc, ok := cache[ev.SrcIP]
+		if !ok {
+			if lat, lon, found := geo(ev.SrcIP); found {
+				loc = &fix{lat: lat, lon: lon}
+			}
+			cache[ev.SrcIP] = loc
+		}
+		if loc == nil {
+			continue
+		}
+		perUser[ev.User] = append(perUser[ev.User], fix{t: ev.TS.UTC(), ip: ev.SrcIP, lat: loc.lat, lon: loc.lon})
+	}
+
+	out := make([]AnomalyTravel, 0)
+	for user, fixes := range perUser {
+		sort.Slice(fixes, func(i, j int) bool { return fixes[i].t.Before(fixes[j].t) })
+
+		var worst *AnomalyTravel
+		for i := 1; i < len(fixes); i++ {
+			a, b := fixes[i-1], fixes[i]
+			if a.ip == b.ip {
+				continue
+			}
+			km := haversineKm(a.lat, a.lon, b.lat, b.lon)
+			if km < minKm {
+				continue
+			}
+			hours := b.t.Sub(a.t).Hours()
+			speed := math.Inf(1)
+			if hours > 0 {
+				speed = km / hours
+			}
+			if speed <= maxKmh {
+				continue
+			}
+			if worst != nil && speed <= worst.SpeedKmh {
+				continue
+			}
+			shown := speed
+			if math.IsInf(shown, 1) {
+				shown = -1
+			}
+			worst = &AnomalyTravel{
+				Kind:       "impossible_travel",
+				User:       user,
+				SrcIP:      b.ip,
+				FromIP:     a.ip,
+				FirstSeen:  a.t,
+				LastSeen:   b.t,
+				DistanceKm: math.Round(km),
+				SpeedKmh:   math.Round(shown),
+				Reason: "Impossible travel for user " + user + ": seen from " + a.ip + " then " + b.ip +
+					" (~" + intToStr(int(km)) + " km apart) within " + b.t.Sub(a.t).String() + ".",
+			}
+		}
+		if worst != nil {
+			out = append(out, *worst)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+	return out
+}
+
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthKm = 6371.0
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthKm * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,45 @@
+package analyze
+
+import (
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectImpossibleTravel(t *testing.T) {
+	// Berlin, New York and Potsdam, 30 km from Berlin.
+	places := map[string][2]float64{
+		"192.0.2.1":    {52.52, 13.40},
+		"198.51.100.1": {40.71, -74.01},
+		"203.0.113.1":  {52.39, 13.06},
+	}
+	geo := func(ip string) (float64, float64, bool) {
+		p, ok := places[ip]
+		return p[0], p[1], ok
+	}
+	login := func(ip string, after time.Duration) parse.Event {
+		return parse.Event{TS: t0.Add(after), SrcIP: ip, User: "alice", Path: "/login", Status: 200}
+	}
+	tests := []struct {
+		name      string
+		rows      []parse.Event
+		wantFires bool
+	}{
+		{"Berlin then New York an hour later", []parse.Event{login("192.0.2.1", 0), login("198.51.100.1", time.Hour)}, true},
+		{"Berlin then New York a day later", []parse.Event{login("192.0.2.1", 0), login("198.51.100.1", 24*time.Hour)}, false},
+		{"Berlin then Potsdam a minute later", []parse.Event{login("192.0.2.1", 0), login("203.0.113.1", time.Minute)}, false},
+		{"unknown location", []parse.Event{login("192.0.2.1", 0), login("233.252.0.1", time.Minute)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectImpossibleTravel(tt.rows, geo, 500, 1000)
+			if (len(got) > 0) != tt.wantFires {
+				t.Fatalf("got %d anomalies, want fires=%v", len(got), tt.wantFires)
+			}
+			if tt.wantFires && (got[0].Kind != "impossible_travel" || got[0].User != "alice" || got[0].FromIP != "192.0.2.1" || got[0].SrcIP != "198.51.100.1") {
+				t.Errorf("got %+v", got[0])
+			}
+		})
+	}
+}
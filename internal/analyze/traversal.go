@@ -0,0 +1,25 @@
+package analyze
+
+import (
+	"regexp"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// TraversalRules are the path traversal and local file inclusion indicators
+// checked by DetectPathTraversal.
+var TraversalRules = []PayloadRule{
+	{Name: "dot_dot_slash", Re: regexp.MustCompile(`(^|[/\\=])\.\.[/\\]`)},
+	{Name: "encoded_traversal", Re: regexp.MustCompile(`(?i)(%2e|%252e|%c0%ae|%u002e){2}|\.\.(%2f|%252f|%5c|%255c|%c0%af|%c1%9c)|%2e\.|\.%2e`), Raw: true},
+	{Name: "unix_sensitive_file", Re: regexp.MustCompile(`(?i)/etc/(passwd|shadow|group|hosts|issue)\b|/proc/self/(environ|cmdline|fd)|/root/\.ssh|\.bash_history`)},
+	{Name: "windows_sensitive_file", Re: regexp.MustCompile(`(?i)win\.ini|boot\.ini|system32[/\\]|[/\\]windows[/\\]system\.ini`)},
+	{Name: "php_wrapper", Re: regexp.MustCompile(`(?i)\b(php://(filter|input|fd|memory)|data://|expect://|zip://|phar://|glob://)`)},
+	{Name: "file_scheme", Re: regexp.MustCompile(`(?i)(^|[=?&])file:/`)},
+	{Name: "null_byte", Re: regexp.MustCompile(`(?i)%00`), Raw: true},
+}
+
+// DetectPathTraversal flags source IPs sending ../ sequences (plain or
+// encoded), requests for well-known system files and PHP stream wrappers.
+func DetectPathTraversal(rows []parse.Event) []AnomalyPayload {
+	return detectPayloads(rows, "path_traversal", "path traversal / file inclusion payloads", TraversalRules, false)
+}
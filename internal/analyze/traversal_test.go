@@ -0,0 +1,40 @@
+package analyze
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+func TestDetectPathTraversal(t *testing.T) {
+	tests := []struct {
+		name           string
+		target         string
+		wantIndicators []string // nil when nothing fires
+	}{
+		{"dot dot slash to passwd", "/download?file=../../etc/passwd", []string{"dot_dot_slash", "unix_sensitive_file"}},
+		{"encoded traversal", "/static/%2e%2e/%2e%2e/app.conf", []string{"dot_dot_slash", "encoded_traversal"}},
+		{"overlong utf-8", "/static/..%c0%afconfig", []string{"encoded_traversal"}},
+		{"windows file", `/view?page=C:\Windows\win.ini`, []string{"windows_sensitive_file"}},
+		{"php filter", "/index.php?page=php://filter/convert.base64-encode/resource=index", []string{"php_wrapper"}},
+		{"null byte", "/view?page=report.pdf%00.php", []string{"null_byte"}},
+		{"file scheme", "/fetch?url=file:///etc/hosts", []string{"file_scheme", "unix_sensitive_file"}},
+		{"relative link in a path", "/docs/v2..v3/changes", nil},
+		{"ordinary page", "/etc-services/passwords-help", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectPathTraversal([]parse.Event{request(tt.target, 404)})
+			if tt.wantIndicators == nil {
+				if len(got) > 0 {
+					t.Errorf("got %+v, want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Kind != "path_traversal" || !slices.Equal(got[0].Indicators, tt.wantIndicators) {
+				t.Errorf("got %+v, want indicators %v", got, tt.wantIndicators)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/allensuvorov/tenexlog/internal/reqctx"
+)
+
+// ParseAPIKeys reads comma-separated name:key pairs, as in API_KEYS. Names
+// identify the caller in logs and job owners.
+func ParseAPIKeys(s string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, key, ok := strings.Cut(pair, ":")
+		if !ok || name == "" || len(key) < 16 {
+			return nil, errors.New("entries must be name:key with a key of at least 16 characters")
+		}
+		if _, dup := keys[name]; dup {
+			return nil, errors.New("duplicate name " + name)
+		}
+		keys[name] = key
+	}
+	return keys, nil
+}
+
+// APIKeys accepts requests carrying one of keys (name → key) as
+// "Authorization: Bearer <key>" and authenticates them as the key's name.
+// Requests without a bearer token go through fallback.
+func APIKeys(keys map[string]string, fallback func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		other := fallback(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok {
+				other.ServeHTTP(w, r)
+				return
+			}
+			user := ""
+			for name, key := range keys {
+				// Compare against every key so timing does not tell which matched.
+				if subtle.ConstantTimeCompare([]byte(token), []byte(key)) == 1 {
+					user = name
+				}
+			}
+			if user == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="restricted"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			ctx := reqctx.WithPrincipal(r.Context(), reqctx.Principal{User: user})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
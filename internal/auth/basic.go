@@ -6,15 +6,26 @@ import (
 	"net/http"
 	"os"
 	"strings"
+
+	"github.com/allensuvorov/tenexlog/internal/reqctx"
 )
 
+// EnvBasicAuth authenticates with BASIC_USER and BASIC_PASS, and with the API
+// keys in API_KEYS (see ParseAPIKeys) sent as bearer tokens.
 func EnvBasicAuth() func(http.Handler) http.Handler {
 	user := os.Getenv("BASIC_USER")
 	pass := os.Getenv("BASIC_PASS")
 	if user == "" || pass == "" {
 		panic("BASIC_USER/BASIC_PASS must be set")
 	}
-	return BasicAuth(user, pass)
+	keys, err := ParseAPIKeys(os.Getenv("API_KEYS"))
+	if err != nil {
+		panic("API_KEYS: " + err.Error())
+	}
+	if len(keys) == 0 {
+		return BasicAuth(user, pass)
+	}
+	return APIKeys(keys, BasicAuth(user, pass))
 }
 
 func BasicAuth(user, pass string) func(http.Handler) http.Handler {
@@ -53,7 +64,8 @@ func BasicAuth(user, pass string) func(http.Handler) http.Handler {
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			ctx := reqctx.WithPrincipal(r.Context(), reqctx.Principal{User: parts[0]})
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid guest token")
+	ErrExpiredToken = errors.New("guest token expired")
+)
+
+// SignGuestToken returns a token granting read-only access to a single job
+// until exp. The token is self-contained: jobID and expiry are carried in the
+// clear and authenticated with an HMAC over secret.
+func SignGuestToken(secret []byte, jobID string, exp time.Time) string {
+	payload := jobID + "|" + strconv.FormatInt(exp.Unix(), 10)
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(payload)) + "." + enc.EncodeToString(guestMAC(secret, payload))
+}
+
+// VerifyGuestToken checks the signature and expiry and returns the job ID the
+// token is scoped to.
+func VerifyGuestToken(secret []byte, token string, now time.Time) (string, error) {
+	enc := base64.RawURLEncoding
+	p, m, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+	payload, err := enc.DecodeString(p)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	mac, err := enc.DecodeString(m)
+	if err != nil || !hmac.Equal(mac, guestMAC(secret, string(payload))) {
+		return "", ErrInvalidToken
+	}
+
+	jobID, expStr, ok := strings.Cut(string(payload), "|")
+	if !ok || jobID == "" {
+		return "", ErrInvalidToken
+	}
+	exp, err := strconv.ParseInt(expStr, 10, 64)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	if now.Unix() >= exp {
+		return "", ErrExpiredToken
+	}
+	return jobID, nil
+}
+
+func guestMAC(secret []byte, payload string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte("guest|" + payload))
+	return h.Sum(nil)
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyGuestToken(t *testing.T) {
+	secret := []byte("s3cret")
+	now := time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)
+	valid := SignGuestToken(secret, "job1", now.Add(time.Hour))
+	payload, mac, _ := strings.Cut(valid, ".")
+	enc := base64.RawURLEncoding
+
+	tests := []struct {
+		name    string
+		token   string
+		now     time.Time
+		wantID  string
+		wantErr error
+	}{
+		{"valid", valid, now, "job1", nil},
+		{"just before expiry", valid, now.Add(time.Hour - time.Second), "job1", nil},
+		{"at expiry", valid, now.Add(time.Hour), "", ErrExpiredToken},
+		{"other secret", SignGuestToken([]byte("other"), "job1", now.Add(time.Hour)), now, "", ErrInvalidToken},
+		{"other job", enc.EncodeToString([]byte("job2|"+payloadExp(t, payload))) + "." + mac, now, "", ErrInvalidToken},
+		{"later expiry", enc.EncodeToString([]byte("job1|9999999999")) + "." + mac, now, "", ErrInvalidToken},
+		{"no signature", payload, now, "", ErrInvalidToken},
+		{"empty", "", now, "", ErrInvalidToken},
+		{"bad base64", "!!!." + mac, now, "", ErrInvalidToken},
+		{"no job", signed(secret, "|"+payloadExp(t, payload)), now, "", ErrInvalidToken},
+		{"bad expiry", signed(secret, "job1|soon"), now, "", ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := VerifyGuestToken(secret, tt.token, tt.now)
+			if !errors.Is(err, tt.wantErr) || id != tt.wantID {
+				t.Errorf("VerifyGuestToken = %q, %v; want %q, %v", id, err, tt.wantID, tt.wantErr)
+			}
+		})
+	}
+}
+
+// payloadExp returns the expiry carried by an encoded token payload.
+func payloadExp(t *testing.T, payload string) string {
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, exp, _ := strings.Cut(string(b), "|")
+	return exp
+}
+
+// signed builds a correctly signed token around an arbitrary payload.
+func signed(secret []byte, payload string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(payload)) + "." + enc.EncodeToString(guestMAC(secret, payload))
+}
@@ -0,0 +1,125 @@
+package cases
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+)
+
+const (
+	StatusOpen          = "open"
+	StatusInvestigating = "investigating"
+	StatusClosed        = "closed"
+)
+
+var ErrNotFound = errors.New("case not found")
+
+type Note struct {
+	Author string    `json:"author,omitempty"`
+	Text   string    `json:"text"`
+	At     time.Time `json:"at"`
+}
+
+// Item is an anomaly attached to a case. The anomaly itself is kept verbatim
+// as it appeared in its job's results.
+type Item struct {
+	JobID       string          `json:"jobId"`
+	Fingerprint string          `json:"fingerprint,omitempty"`
+	Kind        string          `json:"kind,omitempty"`
+	SrcIP       string          `json:"srcIp,omitempty"`
+	Reason      string          `json:"reason,omitempty"`
+	Confidence  float64         `json:"confidence,omitempty"`
+	Added       time.Time       `json:"added"`
+	Anomaly     json.RawMessage `json:"anomaly"`
+}
+
+type Case struct {
+	ID      string    `json:"id"`
+	Title   string    `json:"title"`
+	Status  string    `json:"status"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+	Notes   []Note    `json:"notes"`
+	Items   []Item    `json:"items"`
+}
+
+func (c Case) JobIDs() []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, it := range c.Items {
+		if !seen[it.JobID] {
+			seen[it.JobID] = true
+			out = append(out, it.JobID)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+type store struct {
+	mu    sync.RWMutex
+	cases map[string]*Case
+}
+
+var cases = &store{cases: make(map[string]*Case)}
+
+func (s *store) create(title string) Case {
+	now := time.Now().UTC()
+	c := &Case{
+		ID:      httputil.NewID(),
+		Title:   title,
+		Status:  StatusOpen,
+		Created: now,
+		Updated: now,
+		Notes:   []Note{},
+		Items:   []Item{},
+	}
+	s.mu.Lock()
+	s.cases[c.ID] = c
+	s.mu.Unlock()
+	return clone(c)
+}
+
+func (s *store) get(id string) (Case, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.cases[id]
+	if !ok {
+		return Case{}, ErrNotFound
+	}
+	return clone(c), nil
+}
+
+func (s *store) list() []Case {
+	s.mu.RLock()
+	out := make([]Case, 0, len(s.cases))
+	for _, c := range s.cases {
+		out = append(out, clone(c))
+	}
+	s.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Updated.After(out[j].Updated) })
+	return out
+}
+
+func (s *store) update(id string, fn func(c *Case)) (Case, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.cases[id]
+	if !ok {
+		return Case{}, ErrNotFound
+	}
+	fn(c)
+	c.Updated = time.Now().UTC()
+	return clone(c), nil
+}
+
+func clone(c *Case) Case {
+	out := *c
+	out.Notes = append([]Note{}, c.Notes...)
+	out.Items = append([]Item{}, c.Items...)
+	return out
+}
@@ -0,0 +1,174 @@
+package cases
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/reqctx"
+)
+
+func Create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Title string `json:"title"`
+	}
+	if err := decode(w, r, &req); err != nil {
+		return
+	}
+	if strings.TrimSpace(req.Title) == "" {
+		http.Error(w, "title is required", http.StatusBadRequest)
+		return
+	}
+	httputil.JSON(w, http.StatusCreated, cases.create(strings.TrimSpace(req.Title)))
+}
+
+func List(w http.ResponseWriter, r *http.Request) {
+	httputil.JSON(w, http.StatusOK, cases.list())
+}
+
+func Get(w http.ResponseWriter, r *http.Request) {
+	c, err := cases.get(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	httputil.JSON(w, http.StatusOK, c)
+}
+
+func Update(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Title  *string `json:"title"`
+		Status *string `json:"status"`
+	}
+	if err := decode(w, r, &req); err != nil {
+		return
+	}
+	if req.Status != nil {
+		switch *req.Status {
+		case StatusOpen, StatusInvestigating, StatusClosed:
+		default:
+			http.Error(w, "status must be open, investigating or closed", http.StatusBadRequest)
+			return
+		}
+	}
+	c, err := cases.update(r.PathValue("id"), func(c *Case) {
+		if req.Title != nil && strings.TrimSpace(*req.Title) != "" {
+			c.Title = strings.TrimSpace(*req.Title)
+		}
+		if req.Status != nil {
+			c.Status = *req.Status
+		}
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	httputil.JSON(w, http.StatusOK, c)
+}
+
+func AddNote(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Text string `json:"text"`
+	}
+	if err := decode(w, r, &req); err != nil {
+		return
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		http.Error(w, "text is required", http.StatusBadRequest)
+		return
+	}
+	author := reqctx.PrincipalFrom(r.Context()).User
+	c, err := cases.update(r.PathValue("id"), func(c *Case) {
+		c.Notes = append(c.Notes, Note{Author: author, Text: req.Text, At: time.Now().UTC()})
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	httputil.JSON(w, http.StatusOK, c)
+}
+
+// AttachAnomalies adds anomalies, as returned in a job's results, to a case.
+// Anomalies already attached (the same anomaly of the same job) are skipped;
+// distinct findings sharing a fingerprint are all kept.
+func AttachAnomalies(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		JobID     string            `json:"jobId"`
+		Anomalies []json.RawMessage `json:"anomalies"`
+	}
+	if err := decode(w, r, &req); err != nil {
+		return
+	}
+	if req.JobID == "" || len(req.Anomalies) == 0 {
+		http.Error(w, "jobId and anomalies are required", http.StatusBadRequest)
+		return
+	}
+
+	items := make([]Item, 0, len(req.Anomalies))
+	now := time.Now().UTC()
+	for _, raw := range req.Anomalies {
+		it := Item{JobID: req.JobID, Added: now, Anomaly: raw}
+		if err := json.Unmarshal(raw, &it); err != nil {
+			http.Error(w, "anomalies must be JSON objects", http.StatusBadRequest)
+			return
+		}
+		it.JobID, it.Added, it.Anomaly = req.JobID, now, raw
+		items = append(items, it)
+	}
+
+	c, err := cases.update(r.PathValue("id"), func(c *Case) {
+		for _, it := range items {
+			if c.has(it) {
+				continue
+			}
+			c.Items = append(c.Items, it)
+		}
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	httputil.JSON(w, http.StatusOK, c)
+}
+
+func (c *Case) has(item Item) bool {
+	key := anomalyKey(item.Anomaly)
+	for _, it := range c.Items {
+		if it.JobID == item.JobID && anomalyKey(it.Anomaly) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// anomalyKey identifies an anomaly by its whole content, independent of key
+// order and spacing in the JSON it was sent as.
+func anomalyKey(raw json.RawMessage) string {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return string(raw)
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func Report(w http.ResponseWriter, r *http.Request) {
+	c, err := cases.get(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="case-`+c.ID+`.md"`)
+	_, _ = w.Write([]byte(renderReport(c)))
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) error {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(v)
+	if err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+package cases
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+func renderReport(c Case) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Case: %s\n\n", c.Title)
+	fmt.Fprintf(&b, "- ID: %s\n", c.ID)
+	fmt.Fprintf(&b, "- Status: %s\n", c.Status)
+	fmt.Fprintf(&b, "- Opened: %s\n", c.Created.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Last updated: %s\n", c.Updated.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Jobs: %s\n", strings.Join(c.JobIDs(), ", "))
+	fmt.Fprintf(&b, "- Anomalies: %d\n\n", len(c.Items))
+
+	b.WriteString("## Anomalies\n\n")
+	if len(c.Items) == 0 {
+		b.WriteString("None attached.\n\n")
+	} else {
+		items := append([]Item{}, c.Items...)
+		sort.SliceStable(items, func(i, j int) bool {
+			if items[i].SrcIP != items[j].SrcIP {
+				return items[i].SrcIP < items[j].SrcIP
+			}
+			return items[i].Kind < items[j].Kind
+		})
+		b.WriteString("| Job | Kind | Source IP | Confidence | Reason |\n")
+		b.WriteString("| --- | --- | --- | --- | --- |\n")
+		for _, it := range items {
+			fmt.Fprintf(&b, "| %s | %s | %s | %.2f | %s |\n",
+				it.JobID, it.Kind, it.SrcIP, it.Confidence, strings.ReplaceAll(it.Reason, "|", "\\|"))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("## Notes\n\n")
+	if len(c.Notes) == 0 {
+		b.WriteString("No notes.\n")
+	}
+	for _, n := range c.Notes {
+		who := n.Author
+		if who == "" {
+			who = "unknown"
+		}
+		fmt.Fprintf(&b, "- %s (%s): %s\n", n.At.Format(time.RFC3339), who, n.Text)
+	}
+	return b.String()
+}
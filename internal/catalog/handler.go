@@ -0,0 +1,58 @@
+package catalog
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/jobs"
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+type formatFields struct {
+	Format string            `json:"format"`
+	Common []parse.FieldSpec `json:"common"`
+	Extras []parse.FieldSpec `json:"extras"`
+}
+
+// Fields serves GET /api/catalog/fields. ?format= or ?jobId= narrows the
+// response to one format; otherwise every known format is listed.
+func Fields(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if id := r.URL.Query().Get("jobId"); id != "" {
+		j, err := jobs.Default.GetJob(id)
+		if errors.Is(err, jobs.ErrNotFound) {
+			http.Error(w, "job not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "could not load job", http.StatusInternalServerError)
+			return
+		}
+		format = j.Format
+	}
+
+	if format == parse.FormatMixed {
+		var extras []parse.FieldSpec
+		for _, f := range parse.Formats() {
+			extras = append(extras, parse.FormatFields[f]...)
+		}
+		httputil.JSON(w, http.StatusOK, formatFields{Format: format, Common: parse.CommonFields, Extras: extras})
+		return
+	}
+	if format != "" {
+		extras, ok := parse.FormatFields[format]
+		if !ok {
+			http.Error(w, "unknown format", http.StatusNotFound)
+			return
+		}
+		httputil.JSON(w, http.StatusOK, formatFields{Format: format, Common: parse.CommonFields, Extras: extras})
+		return
+	}
+
+	all := make([]formatFields, 0, len(parse.FormatFields))
+	for _, f := range parse.Formats() {
+		all = append(all, formatFields{Format: f, Common: parse.CommonFields, Extras: parse.FormatFields[f]})
+	}
+	httputil.JSON(w, http.StatusOK, map[string]any{"formats": all})
+}
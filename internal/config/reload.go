@@ -0,0 +1,51 @@
+package config
+
+import (
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/allensuvorov/tenexlog/internal/analyze"
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/upload"
+)
+
+type reloadResult struct {
+	Reloaded []string          `json:"reloaded"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+// Reload serves POST /api/config/reload: it re-reads SENSITIVE_PATHS_FILE,
+// SUPPRESSIONS_FILE and RULES_FILE, those that are set, so edits made on
+// disk take effect without a restart. A file that fails to load leaves its
+// settings as they were and is reported under errors with a 500; the others
+// are still reloaded. A suppressions file that does not exist yet is skipped,
+// as at startup.
+func Reload(w http.ResponseWriter, r *http.Request) {
+	res := reloadResult{Reloaded: []string{}, Errors: map[string]string{}}
+	load := func(name, path string, fn func(string) error) {
+		if path == "" {
+			return
+		}
+		if err := fn(path); err != nil {
+			if name == "suppressions" && errors.Is(err, os.ErrNotExist) {
+				return
+			}
+			res.Errors[name] = err.Error()
+			return
+		}
+		res.Reloaded = append(res.Reloaded, name)
+	}
+	load("sensitivePaths", SensitivePathsFile, analyze.LoadSensitivePaths)
+	load("suppressions", SuppressionsFile, upload.LoadSuppressions)
+	load("rules", RulesFile, func(p string) error {
+		_, err := LoadRules(p)
+		return err
+	})
+
+	status := http.StatusOK
+	if len(res.Errors) > 0 {
+		status = http.StatusInternalServerError
+	}
+	httputil.JSON(w, status, res)
+}
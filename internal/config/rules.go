@@ -0,0 +1,166 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/allensuvorov/tenexlog/internal/analyze"
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/rules"
+	"github.com/allensuvorov/tenexlog/internal/upload"
+)
+
+// RulesFile, when set, is rewritten whenever rules are added or removed so
+// changes survive a restart. Comments in the file are not kept.
+var RulesFile string
+
+// ErrRuleName is returned for a rule named like a detector or another rule.
+var ErrRuleName = errors.New("rule name is already taken")
+
+// rulesMu serializes changes to the active rules.
+var rulesMu sync.Mutex
+
+// activeRules returns the registered custom rules in the order they run.
+func activeRules() []*rules.Rule {
+	var list []*rules.Rule
+	for _, d := range analyze.Registered() {
+		if rule, ok := d.(*rules.Rule); ok {
+			list = append(list, rule)
+		}
+	}
+	return list
+}
+
+func ruleNames(list []*rules.Rule) []string {
+	names := make([]string, len(list))
+	for i, r := range list {
+		names[i] = r.Name()
+	}
+	return names
+}
+
+func detectors(list []*rules.Rule) []analyze.Detector {
+	ds := make([]analyze.Detector, len(list))
+	for i, r := range list {
+		ds[i] = r
+	}
+	return ds
+}
+
+// checkRuleNames rejects rules in list named like a detector that stays
+// registered: a built-in one, one registered by the program, or, unless
+// replacing, an active rule.
+func checkRuleNames(list []*rules.Rule, replacing bool) error {
+	taken := upload.DetectorKinds()
+	if replacing {
+		active := ruleNames(activeRules())
+		taken = slices.DeleteFunc(taken, func(k string) bool { return slices.Contains(active, k) })
+	}
+	for _, r := range list {
+		if slices.Contains(taken, r.Name()) {
+			return fmt.Errorf("%w: %q", ErrRuleName, r.Name())
+		}
+	}
+	return nil
+}
+
+// LoadRules reads the rules in path and makes them the active custom rules,
+// replacing those loaded before. It returns how many were loaded.
+func LoadRules(path string) (int, error) {
+	list, err := rules.Load(path)
+	if err != nil {
+		return 0, err
+	}
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	if err := checkRuleNames(list, true); err != nil {
+		return 0, err
+	}
+	analyze.Replace(ruleNames(activeRules()), detectors(list)...)
+	return len(list), nil
+}
+
+// persistRules rewrites RulesFile, if set, with the active rules.
+func persistRules() error {
+	if RulesFile == "" {
+		return nil
+	}
+	if err := os.WriteFile(RulesFile, rules.Marshal(activeRules()), 0o644); err != nil {
+		log.Printf("persist rules to %s: %v", RulesFile, err)
+		return err
+	}
+	return nil
+}
+
+type ruleList struct {
+	Rules []rules.Info `json:"rules"`
+}
+
+func infos(list []*rules.Rule) ruleList {
+	out := ruleList{Rules: []rules.Info{}}
+	for _, r := range list {
+		out.Rules = append(out.Rules, r.Info())
+	}
+	return out
+}
+
+// GetRules serves GET /api/config/rules: the active custom rules, in the
+// order they run.
+func GetRules(w http.ResponseWriter, r *http.Request) {
+	httputil.JSON(w, http.StatusOK, infos(activeRules()))
+}
+
+// PostRules serves POST /api/config/rules: the body is a rules document in
+// the RULES_FILE format whose rules are added after the active ones.
+func PostRules(w http.ResponseWriter, r *http.Request) {
+	src, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "could not read body", http.StatusBadRequest)
+		return
+	}
+	list, err := rules.Parse(src)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(list) == 0 {
+		http.Error(w, "no rules in body", http.StatusBadRequest)
+		return
+	}
+
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	if err := checkRuleNames(list, false); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	analyze.Replace(nil, detectors(list)...)
+	if persistRules() != nil {
+		http.Error(w, "updated in memory but could not persist to file", http.StatusInternalServerError)
+		return
+	}
+	httputil.JSON(w, http.StatusCreated, infos(list))
+}
+
+// DeleteRule serves DELETE /api/config/rules/{name}.
+func DeleteRule(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	if !slices.Contains(ruleNames(activeRules()), name) {
+		http.Error(w, "no such rule", http.StatusNotFound)
+		return
+	}
+	analyze.Replace([]string{name})
+	if persistRules() != nil {
+		http.Error(w, "updated in memory but could not persist to file", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
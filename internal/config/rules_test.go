@@ -0,0 +1,129 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/allensuvorov/tenexlog/internal/analyze"
+	"github.com/allensuvorov/tenexlog/internal/rules"
+)
+
+const probeRule = `rules:
+  - name: admin_probe
+    match:
+      path:
+        prefix: /admin
+    threshold: 10
+`
+
+// useRulesFile points RulesFile at a file holding src and drops every rule
+// and setting the test loaded when it ends.
+func useRulesFile(t *testing.T, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(path, []byte(src), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	RulesFile = path
+	t.Cleanup(func() {
+		RulesFile, SensitivePathsFile, SuppressionsFile = "", "", ""
+		analyze.Replace(ruleNames(activeRules()))
+	})
+	return path
+}
+
+// serve sends a request through the config routes as cmd/api mounts them.
+func serve(method, target, body string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/config/rules", PostRules)
+	mux.HandleFunc("DELETE /api/config/rules/{name}", DeleteRule)
+	mux.HandleFunc("POST /api/config/reload", Reload)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+func TestRuleChanges(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantActive []string
+	}{
+		{"add", http.MethodPost, "/api/config/rules", strings.ReplaceAll(probeRule, "admin_probe", "env_probe"), http.StatusCreated, []string{"admin_probe", "env_probe"}},
+		{"add a taken name", http.MethodPost, "/api/config/rules", probeRule, http.StatusConflict, []string{"admin_probe"}},
+		{"add a built-in name", http.MethodPost, "/api/config/rules", strings.ReplaceAll(probeRule, "admin_probe", "sensitive_paths"), http.StatusConflict, []string{"admin_probe"}},
+		{"add an invalid rule", http.MethodPost, "/api/config/rules", "rules:\n  - name: x\n", http.StatusBadRequest, []string{"admin_probe"}},
+		{"remove", http.MethodDelete, "/api/config/rules/admin_probe", "", http.StatusNoContent, nil},
+		{"remove a missing rule", http.MethodDelete, "/api/config/rules/nope", "", http.StatusNotFound, []string{"admin_probe"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := useRulesFile(t, probeRule)
+			if _, err := LoadRules(path); err != nil {
+				t.Fatal(err)
+			}
+			rec := serve(tt.method, tt.target, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d (%s), want %d", rec.Code, strings.TrimSpace(rec.Body.String()), tt.wantStatus)
+			}
+			if got := ruleNames(activeRules()); !slices.Equal(got, tt.wantActive) {
+				t.Errorf("active rules %q, want %q", got, tt.wantActive)
+			}
+			saved, err := rules.Load(path)
+			if err != nil {
+				t.Fatalf("reading back %s: %v", path, err)
+			}
+			if got := ruleNames(saved); !slices.Equal(got, tt.wantActive) {
+				t.Errorf("%s holds %q, want %q", path, got, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := useRulesFile(t, probeRule)
+	if _, err := LoadRules(path); err != nil {
+		t.Fatal(err)
+	}
+	saved := analyze.SensitivePaths()
+	t.Cleanup(func() { analyze.SetSensitivePaths(saved) })
+	SensitivePathsFile = filepath.Join(t.TempDir(), "sensitive.txt")
+	SuppressionsFile = filepath.Join(t.TempDir(), "missing.json")
+
+	// Edit both files on disk, the rules one badly.
+	if err := os.WriteFile(SensitivePathsFile, []byte("/grafana\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("rules:\n  - name: Bad Name\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rec := serve(http.MethodPost, "/api/config/reload", "")
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), `"reloaded":["sensitivePaths"]`) ||
+		!strings.Contains(rec.Body.String(), `"rules":`) {
+		t.Errorf("status %d, body %s; want 500 reloading only the sensitive paths", rec.Code, rec.Body)
+	}
+	if got := analyze.SensitivePaths(); !slices.Equal(got, []string{"/grafana"}) {
+		t.Errorf("sensitive paths %q, want [/grafana]", got)
+	}
+	if got := ruleNames(activeRules()); !slices.Equal(got, []string{"admin_probe"}) {
+		t.Errorf("active rules %q after a failed reload, want the old ones", got)
+	}
+
+	if err := os.WriteFile(path, []byte(strings.ReplaceAll(probeRule, "admin_probe", "env_probe")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if rec := serve(http.MethodPost, "/api/config/reload", ""); rec.Code != http.StatusOK {
+		t.Errorf("status %d, body %s; want 200", rec.Code, rec.Body)
+	}
+	if got := ruleNames(activeRules()); !slices.Equal(got, []string{"env_probe"}) {
+		t.Errorf("active rules %q, want [env_probe]", got)
+	}
+}
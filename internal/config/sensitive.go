@@ -0,0 +1,64 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/allensuvorov/tenexlog/internal/analyze"
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+)
+
+// SensitivePathsFile, when set, is rewritten on every successful PUT so
+// changes survive a restart.
+var SensitivePathsFile string
+
+const maxSensitivePaths = 1000
+
+type sensitivePaths struct {
+	Paths []string `json:"paths"`
+}
+
+func GetSensitivePaths(w http.ResponseWriter, r *http.Request) {
+	httputil.JSON(w, http.StatusOK, sensitivePaths{Paths: analyze.SensitivePaths()})
+}
+
+func PutSensitivePaths(w http.ResponseWriter, r *http.Request) {
+	var body sensitivePaths
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if body.Paths == nil {
+		http.Error(w, "field 'paths' is required", http.StatusBadRequest)
+		return
+	}
+	if len(body.Paths) > maxSensitivePaths {
+		http.Error(w, "too many paths", http.StatusBadRequest)
+		return
+	}
+	if err := analyze.SetSensitivePaths(body.Paths); err != nil {
+		if errors.Is(err, analyze.ErrBadSensitivePath) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "could not update sensitive paths", http.StatusInternalServerError)
+		return
+	}
+
+	paths := analyze.SensitivePaths()
+	if SensitivePathsFile != "" {
+		data := strings.Join(paths, "\n") + "\n"
+		if err := os.WriteFile(SensitivePathsFile, []byte(data), 0o644); err != nil {
+			log.Printf("persist sensitive paths to %s: %v", SensitivePathsFile, err)
+			http.Error(w, "updated in memory but could not persist to file", http.StatusInternalServerError)
+			return
+		}
+	}
+	httputil.JSON(w, http.StatusOK, sensitivePaths{Paths: paths})
+}
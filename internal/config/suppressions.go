@@ -0,0 +1,60 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/upload"
+)
+
+// SuppressionsFile, when set, is rewritten on every successful PUT so
+// changes survive a restart.
+var SuppressionsFile string
+
+type suppressionList struct {
+	Suppressions []upload.Suppression `json:"suppressions"`
+}
+
+func GetSuppressions(w http.ResponseWriter, r *http.Request) {
+	httputil.JSON(w, http.StatusOK, suppressionList{Suppressions: upload.Suppressions()})
+}
+
+func PutSuppressions(w http.ResponseWriter, r *http.Request) {
+	var body suppressionList
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if body.Suppressions == nil {
+		http.Error(w, "field 'suppressions' is required", http.StatusBadRequest)
+		return
+	}
+	if err := upload.SetSuppressions(body.Suppressions); err != nil {
+		if errors.Is(err, upload.ErrBadSuppression) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "could not update suppressions", http.StatusInternalServerError)
+		return
+	}
+
+	list := suppressionList{Suppressions: upload.Suppressions()}
+	if SuppressionsFile != "" {
+		data, err := json.MarshalIndent(list, "", "  ")
+		if err == nil {
+			err = os.WriteFile(SuppressionsFile, append(data, '\n'), 0o644)
+		}
+		if err != nil {
+			log.Printf("persist suppressions to %s: %v", SuppressionsFile, err)
+			http.Error(w, "updated in memory but could not persist to file", http.StatusInternalServerError)
+			return
+		}
+	}
+	httputil.JSON(w, http.StatusOK, list)
+}
@@ -0,0 +1,245 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/integrations"
+)
+
+// Abuse is an AbuseIPDB reputation report.
+type Abuse struct {
+	Score        int        `json:"score"`
+	Reports      int        `json:"reports"`
+	Reporters    int        `json:"reporters,omitempty"`
+	CountryCode  string     `json:"countryCode,omitempty"`
+	UsageType    string     `json:"usageType,omitempty"`
+	ISP          string     `json:"isp,omitempty"`
+	LastReported *time.Time `json:"lastReported,omitempty"`
+}
+
+type AbuseIPDBConfig struct {
+	APIKey     string
+	Endpoint   string
+	MaxAgeDays int
+	PerMinute  int // request budget; lookups beyond it fail with ErrRateLimited
+	CacheTTL   time.Duration
+}
+
+// ErrRateLimited is returned when the AbuseIPDB request budget is spent.
+var ErrRateLimited = errors.New("abuseipdb: rate limit reached")
+
+const abuseIPDBEndpoint = "https://api.abuseipdb.com/api/v2/check"
+
+// AbuseIPDBEnvConfig reads ABUSEIPDB_API_KEY, ABUSEIPDB_URL,
+// ABUSEIPDB_MAX_AGE_DAYS (default 90), ABUSEIPDB_RATE_PER_MIN (default 30)
+// and ABUSEIPDB_CACHE_TTL (default 24h).
+func AbuseIPDBEnvConfig() (AbuseIPDBConfig, error) {
+	c := AbuseIPDBConfig{
+		APIKey:     os.Getenv("ABUSEIPDB_API_KEY"),
+		Endpoint:   os.Getenv("ABUSEIPDB_URL"),
+		MaxAgeDays: 90,
+		PerMinute:  30,
+		CacheTTL:   24 * time.Hour,
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = abuseIPDBEndpoint
+	}
+	if v := os.Getenv("ABUSEIPDB_MAX_AGE_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 365 {
+			return c, errors.New("ABUSEIPDB_MAX_AGE_DAYS must be between 1 and 365")
+		}
+		c.MaxAgeDays = n
+	}
+	if v := os.Getenv("ABUSEIPDB_RATE_PER_MIN"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return c, errors.New("ABUSEIPDB_RATE_PER_MIN must be a positive integer")
+		}
+		c.PerMinute = n
+	}
+	if v := os.Getenv("ABUSEIPDB_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return c, errors.New("ABUSEIPDB_CACHE_TTL must be a duration")
+		}
+		c.CacheTTL = d
+	}
+	return c, nil
+}
+
+type abuseClient struct {
+	cfg    AbuseIPDBConfig
+	cache  *cache
+	health *integrations.Tracker
+	http   *http.Client
+
+	mu      sync.Mutex
+	tokens  float64
+	refill  time.Time
+	blocked time.Time // set from Retry-After on 429
+}
+
+var (
+	abuseMu  sync.RWMutex
+	abuseCli *abuseClient
+)
+
+// ConfigureAbuseIPDB enables AbuseIPDB lookups; an empty APIKey disables them.
+// Lookups share one client built here, so the outbound CA bundle must be
+// configured first.
+func ConfigureAbuseIPDB(c AbuseIPDBConfig) {
+	var cli *abuseClient
+	if c.APIKey != "" {
+		if c.Endpoint == "" {
+			c.Endpoint = abuseIPDBEndpoint
+		}
+		cli = &abuseClient{cfg: c, cache: newCache(c.CacheTTL, 50_000), tokens: float64(c.PerMinute), refill: time.Now()}
+		cli.health = integrations.Track("enrichment", "abuseipdb", httputil.RedactURL(c.Endpoint))
+		cli.http = httputil.OutboundClient(10 * time.Second)
+	} else {
+		integrations.Forget("enrichment")
+	}
+	abuseMu.Lock()
+	abuseCli = cli
+	abuseMu.Unlock()
+}
+
+// AbuseIPDBEnabled reports whether an API key is configured.
+func AbuseIPDBEnabled() bool {
+	abuseMu.RLock()
+	defer abuseMu.RUnlock()
+	return abuseCli != nil
+}
+
+// AbuseOf returns the AbuseIPDB report for addr, or nil for private addresses
+// and when no API key is configured. Reports are cached for CacheTTL.
+func AbuseOf(ctx context.Context, addr netip.Addr) (*Abuse, error) {
+	abuseMu.RLock()
+	c := abuseCli
+	abuseMu.RUnlock()
+	if c == nil || !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return nil, nil
+	}
+	addr = addr.Unmap()
+	if b, ok := c.cache.get(addr.String()); ok {
+		return b.Abuse, nil
+	}
+	if !c.take() {
+		return nil, ErrRateLimited
+	}
+	var a *Abuse
+	err := c.health.Do(func() (err error) {
+		a, err = c.check(ctx, addr)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.cache.put(addr.String(), Bundle{Abuse: a})
+	return a, nil
+}
+
+// take spends one request from a token bucket refilled at PerMinute per minute.
+func (c *abuseClient) take() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if now.Before(c.blocked) {
+		return false
+	}
+	per := float64(c.cfg.PerMinute)
+	c.tokens = min(per, c.tokens+now.Sub(c.refill).Minutes()*per)
+	c.refill = now
+	if c.tokens < 1 {
+		return false
+	}
+	c.tokens--
+	return true
+}
+
+func (c *abuseClient) check(ctx context.Context, addr netip.Addr) (*Abuse, error) {
+	q := url.Values{}
+	q.Set("ipAddress", addr.String())
+	q.Set("maxAgeInDays", strconv.Itoa(c.cfg.MaxAgeDays))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.cfg.Endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Key", c.cfg.APIKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		wait := time.Minute
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+			wait = time.Duration(s) * time.Second
+		}
+		c.mu.Lock()
+		c.blocked = time.Now().Add(wait)
+		c.mu.Unlock()
+		return nil, ErrRateLimited
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("abuseipdb: %s", resp.Status)
+	}
+
+	var body struct {
+		Data struct {
+			Score        int     `json:"abuseConfidenceScore"`
+			Reports      int     `json:"totalReports"`
+			Reporters    int     `json:"numDistinctUsers"`
+			CountryCode  string  `json:"countryCode"`
+			UsageType    string  `json:"usageType"`
+			ISP          string  `json:"isp"`
+			LastReported *string `json:"lastReportedAt"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("abuseipdb: %w", err)
+	}
+	d := body.Data
+	a := &Abuse{
+		Score:       d.Score,
+		Reports:     d.Reports,
+		Reporters:   d.Reporters,
+		CountryCode: d.CountryCode,
+		UsageType:   d.UsageType,
+		ISP:         d.ISP,
+	}
+	if d.LastReported != nil {
+		if t, err := time.Parse(time.RFC3339, *d.LastReported); err == nil {
+			t = t.UTC()
+			a.LastReported = &t
+		}
+	}
+	return a, nil
+}
+
+// abuseEnricher keeps its own long-lived cache, so the pipeline cache is
+// bypassed.
+type abuseEnricher struct{}
+
+func (abuseEnricher) Name() string { return "abuseipdb" }
+func (abuseEnricher) NoCache()     {}
+
+func (abuseEnricher) Enrich(ctx context.Context, addr netip.Addr, b *Bundle) error {
+	a, err := AbuseOf(ctx, addr)
+	b.Abuse = a
+	return err
+}
@@ -0,0 +1,65 @@
+package enrich
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+func init() {
+	Register(geoEnricher{})
+	Register(asnEnricher{})
+	Register(rdnsEnricher{})
+	Register(priorEnricher{})
+	Register(intelEnricher{})
+	Register(anonymizerEnricher{})
+	Register(abuseEnricher{})
+}
+
+type geoEnricher struct{}
+
+func (geoEnricher) Name() string { return "geo" }
+
+func (geoEnricher) Enrich(_ context.Context, addr netip.Addr, b *Bundle) error {
+	b.Geo = GeoOf(addr)
+	return nil
+}
+
+type asnEnricher struct{}
+
+func (asnEnricher) Name() string { return "asn" }
+
+func (asnEnricher) Enrich(_ context.Context, addr netip.Addr, b *Bundle) error {
+	b.ASN = ASNOf(addr)
+	return nil
+}
+
+type rdnsEnricher struct{}
+
+func (rdnsEnricher) Name() string { return "rdns" }
+
+func (rdnsEnricher) Enrich(ctx context.Context, addr netip.Addr, b *Bundle) error {
+	names, err := net.DefaultResolver.LookupAddr(ctx, addr.String())
+	if notFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, n := range names {
+		b.RDNS = append(b.RDNS, strings.TrimSuffix(n, "."))
+	}
+	return nil
+}
+
+// priorEnricher reads the live sightings index, so it is never cached.
+type priorEnricher struct{}
+
+func (priorEnricher) Name() string { return "prior" }
+func (priorEnricher) NoCache()     {}
+
+func (priorEnricher) Enrich(_ context.Context, addr netip.Addr, b *Bundle) error {
+	b.Prior = Sightings.Of(addr.String())
+	return nil
+}
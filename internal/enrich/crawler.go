@@ -0,0 +1,127 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// crawler is a search engine bot that can be verified by forward-confirmed
+// reverse DNS: its addresses reverse-resolve to a host in one of its domains,
+// and that host resolves back to the address.
+type crawler struct {
+	name    string
+	token   string // lower-case User-Agent substring
+	domains []string
+}
+
+var crawlers = []crawler{
+	{"googlebot", "googlebot", []string{"googlebot.com", "google.com", "googleusercontent.com"}},
+	{"bingbot", "bingbot", []string{"search.msn.com"}},
+	{"applebot", "applebot", []string{"applebot.apple.com"}},
+	{"yandexbot", "yandexbot", []string{"yandex.ru", "yandex.net", "yandex.com"}},
+	{"baiduspider", "baiduspider", []string{"baidu.com", "baidu.jp"}},
+}
+
+// ClaimedCrawler returns the name of the crawler ua claims to be, or "".
+func ClaimedCrawler(ua string) string {
+	ua = strings.ToLower(ua)
+	for _, c := range crawlers {
+		if strings.Contains(ua, c.token) {
+			return c.name
+		}
+	}
+	return ""
+}
+
+// CrawlerCacheTTL is how long a verification verdict is reused.
+var CrawlerCacheTTL = 24 * time.Hour
+
+type crawlerVerdict struct {
+	ok      bool
+	expires time.Time
+}
+
+var (
+	crawlerMu    sync.Mutex
+	crawlerCache = make(map[string]crawlerVerdict)
+)
+
+// VerifyCrawler reports whether addr belongs to the named crawler. Lookup
+// failures other than NXDOMAIN are returned and not cached.
+func VerifyCrawler(ctx context.Context, name string, addr netip.Addr) (bool, error) {
+	i := -1
+	for j, c := range crawlers {
+		if c.name == name {
+			i = j
+		}
+	}
+	if i < 0 || !addr.IsValid() {
+		return false, nil
+	}
+	addr = addr.Unmap()
+	key := name + "|" + addr.String()
+
+	crawlerMu.Lock()
+	v, hit := crawlerCache[key]
+	crawlerMu.Unlock()
+	if hit && time.Now().Before(v.expires) {
+		return v.ok, nil
+	}
+
+	ok, err := fcrdns(ctx, addr, crawlers[i].domains)
+	if err != nil {
+		return false, err
+	}
+	crawlerMu.Lock()
+	crawlerCache[key] = crawlerVerdict{ok: ok, expires: time.Now().Add(CrawlerCacheTTL)}
+	crawlerMu.Unlock()
+	return ok, nil
+}
+
+func fcrdns(ctx context.Context, addr netip.Addr, domains []string) (bool, error) {
+	names, err := net.DefaultResolver.LookupAddr(ctx, addr.String())
+	if notFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, n := range names {
+		host := strings.ToLower(strings.TrimSuffix(n, "."))
+		if !inDomains(host, domains) {
+			continue
+		}
+		ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if notFound(err) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		for _, ip := range ips {
+			if ip.Unmap() == addr {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func inDomains(host string, domains []string) bool {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+func notFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
@@ -0,0 +1,143 @@
+package enrich
+
+import (
+	"context"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Geo struct {
+	Country string  `json:"country,omitempty"`
+	City    string  `json:"city,omitempty"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+}
+
+type ASN struct {
+	Number int    `json:"number"`
+	Org    string `json:"org,omitempty"`
+}
+
+type Sighting struct {
+	JobID string    `json:"jobId"`
+	Kind  string    `json:"kind"`
+	Seen  time.Time `json:"seen"`
+}
+
+type Bundle struct {
+	IP         string     `json:"ip"`
+	Valid      bool       `json:"valid"`
+	RDNS       []string   `json:"rdns,omitempty"`
+	Geo        *Geo       `json:"geo,omitempty"`
+	ASN        *ASN       `json:"asn,omitempty"`
+	Intel      []string   `json:"intel,omitempty"`
+	Anonymizer []string   `json:"anonymizer,omitempty"`
+	Abuse      *Abuse     `json:"abuse,omitempty"`
+	Prior      []Sighting `json:"priorAppearances,omitempty"`
+	Errors     []string   `json:"errors,omitempty"`
+}
+
+var (
+	geoDB *rangeDB
+	asnDB *rangeDB
+)
+
+// LoadGeoDB loads a CSV of cidr,country,city,lat,lon rows.
+func LoadGeoDB(path string) error {
+	db, err := loadRangeDB(path)
+	if err != nil {
+		return err
+	}
+	geoDB = db
+	return nil
+}
+
+// LoadASNDB loads a CSV of cidr,asn,org rows.
+func LoadASNDB(path string) error {
+	db, err := loadRangeDB(path)
+	if err != nil {
+		return err
+	}
+	asnDB = db
+	return nil
+}
+
+func GeoOf(addr netip.Addr) *Geo {
+	fs, ok := geoDB.lookup(addr)
+	if !ok {
+		return nil
+	}
+	g := &Geo{Country: field(fs, 0), City: field(fs, 1)}
+	g.Lat, _ = strconv.ParseFloat(field(fs, 2), 64)
+	g.Lon, _ = strconv.ParseFloat(field(fs, 3), 64)
+	return g
+}
+
+func ASNOf(addr netip.Addr) *ASN {
+	fs, ok := asnDB.lookup(addr)
+	if !ok {
+		return nil
+	}
+	n, _ := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(field(fs, 0)), "AS"))
+	return &ASN{Number: n, Org: field(fs, 1)}
+}
+
+// Lookup builds the enrichment bundle for a single IP using the configured
+// pipeline.
+func Lookup(ctx context.Context, ip string) Bundle {
+	return current().Lookup(ctx, ip)
+}
+
+// LookupAll enriches ips concurrently, preserving input order.
+func LookupAll(ctx context.Context, ips []string) []Bundle {
+	const workers = 8
+	out := make([]Bundle, len(ips))
+	idx := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(ips); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range idx {
+				out[i] = Lookup(ctx, ips[i])
+			}
+		}()
+	}
+	for i := range ips {
+		idx <- i
+	}
+	close(idx)
+	wg.Wait()
+	return out
+}
+
+// sightings remembers which jobs flagged an IP, newest first.
+type sightings struct {
+	mu   sync.Mutex
+	byIP map[string][]Sighting
+}
+
+// Sightings is the process-wide record of IPs flagged by previous jobs.
+var Sightings = &sightings{byIP: make(map[string][]Sighting)}
+
+func (s *sightings) Record(ip, jobID, kind string, seen time.Time) {
+	const maxPerIP = 50
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := append(s.byIP[ip], Sighting{JobID: jobID, Kind: kind, Seen: seen.UTC()})
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Seen.After(list[j].Seen) })
+	if len(list) > maxPerIP {
+		list = list[:maxPerIP]
+	}
+	s.byIP[ip] = list
+}
+
+func (s *sightings) Of(ip string) []Sighting {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Sighting(nil), s.byIP[ip]...)
+}
@@ -0,0 +1,40 @@
+package enrich
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+)
+
+const maxBulkIPs = 500
+
+type bulkRequest struct {
+	IPs []string `json:"ips"`
+}
+
+type bulkResponse struct {
+	Results []Bundle `json:"results"`
+}
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	var req bulkRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "body must be JSON: {\"ips\": [...]}", http.StatusBadRequest)
+		return
+	}
+	if len(req.IPs) == 0 {
+		http.Error(w, "ips must not be empty", http.StatusBadRequest)
+		return
+	}
+	if len(req.IPs) > maxBulkIPs {
+		http.Error(w, "too many ips (max 500)", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	httputil.JSON(w, http.StatusOK, bulkResponse{Results: LookupAll(r.Context(), req.IPs)})
+}
+
+func StatsHandler(w http.ResponseWriter, r *http.Request) {
+	httputil.JSON(w, http.StatusOK, Stats())
+}
@@ -0,0 +1,257 @@
+package enrich
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/integrations"
+)
+
+// Feed is one threat-intel IP list: a local file or a URL in plain "one
+// address or CIDR per line" form, which covers Spamhaus DROP/EDROP
+// ("prefix ; SBL..."), FireHOL .netset/.ipset and most other blocklists.
+type Feed struct {
+	Name   string
+	Source string
+}
+
+type feedState struct {
+	Feed
+	set      *feedSet
+	prefixes []netip.Prefix
+	health   *integrations.Tracker
+	client   *http.Client // nil for local files
+}
+
+// feedSet is a group of feeds configured and matched together: threat-intel
+// blocklists, or TOR exit and VPN/proxy ranges.
+type feedSet struct {
+	kind  string // integrations kind
+	mu    sync.RWMutex
+	feeds []*feedState
+}
+
+var (
+	intelFeeds = &feedSet{kind: "feed"}
+	anonFeeds  = &feedSet{kind: "anonymizer"}
+
+	// FeedRefresh is how often URL feeds are fetched again.
+	FeedRefresh = 6 * time.Hour
+)
+
+// ParseFeeds reads name=source pairs separated by commas, e.g.
+// "drop=https://www.spamhaus.org/drop/drop.txt,local=/etc/tenexlog/bad.txt".
+func ParseFeeds(s string) ([]Feed, error) {
+	var out []Feed
+	for _, kv := range strings.Split(s, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		name, src, ok := strings.Cut(kv, "=")
+		if !ok || strings.TrimSpace(name) == "" || strings.TrimSpace(src) == "" {
+			return nil, fmt.Errorf("intel feed %q: want name=source", kv)
+		}
+		out = append(out, Feed{Name: strings.TrimSpace(name), Source: strings.TrimSpace(src)})
+	}
+	return out, nil
+}
+
+// ConfigureFeeds replaces the threat-intel feeds and loads every feed once.
+// Local files must load; URL feeds that fail are logged and retried on the
+// next refresh. URL feeds are fetched with a client built here, so the
+// outbound CA bundle must be configured first.
+func ConfigureFeeds(fs []Feed) error {
+	return intelFeeds.configure(fs)
+}
+
+// ConfigureAnonymizerFeeds replaces the TOR exit and VPN/proxy range feeds,
+// loading them like ConfigureFeeds. A feed's name is reported as the
+// anonymizer, so name them after what they list (tor, vpn, proxy).
+func ConfigureAnonymizerFeeds(fs []Feed) error {
+	return anonFeeds.configure(fs)
+}
+
+func (s *feedSet) configure(fs []Feed) error {
+	states := make([]*feedState, 0, len(fs))
+	names := make([]string, 0, len(fs))
+	for _, f := range fs {
+		target := f.Source
+		if isURL(target) {
+			target = httputil.RedactURL(target)
+		}
+		st := &feedState{Feed: f, set: s, health: integrations.Track(s.kind, f.Name, target)}
+		if isURL(f.Source) {
+			st.client = httputil.OutboundClient(30 * time.Second)
+		}
+		names = append(names, f.Name)
+		if err := st.refresh(context.Background()); err != nil {
+			if !isURL(f.Source) {
+				return fmt.Errorf("%s %s: %w", s.kind, f.Name, err)
+			}
+			log.Printf("%s %s: %v", s.kind, f.Name, err)
+		}
+		states = append(states, st)
+	}
+	s.mu.Lock()
+	s.feeds = states
+	s.mu.Unlock()
+	integrations.Forget(s.kind, names...)
+	return nil
+}
+
+// RunFeedRefresh refetches URL feeds every FeedRefresh until ctx ends.
+func RunFeedRefresh(ctx context.Context) {
+	t := time.NewTicker(FeedRefresh)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		intelFeeds.refreshURLs(ctx)
+		anonFeeds.refreshURLs(ctx)
+	}
+}
+
+func (s *feedSet) refreshURLs(ctx context.Context) {
+	s.mu.RLock()
+	cur := append([]*feedState(nil), s.feeds...)
+	s.mu.RUnlock()
+	for _, st := range cur {
+		if !isURL(st.Source) {
+			continue
+		}
+		if err := st.refresh(ctx); err != nil {
+			log.Printf("%s %s: %v", s.kind, st.Name, err)
+		}
+	}
+}
+
+func (st *feedState) refresh(ctx context.Context) error {
+	var prefixes []netip.Prefix
+	err := st.health.Do(func() (err error) {
+		prefixes, err = st.fetch(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	st.set.mu.Lock()
+	st.prefixes = prefixes
+	st.set.mu.Unlock()
+	st.health.SetDetail(strconv.Itoa(len(prefixes)) + " entries")
+	return nil
+}
+
+func (st *feedState) fetch(ctx context.Context) ([]netip.Prefix, error) {
+	if !isURL(st.Source) {
+		f, err := os.Open(st.Source)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return parseFeed(f)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, st.Source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := st.client.Do(req)
+	if err != nil {
+		return nil, httputil.RedactError(err, false)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: %s", httputil.RedactURL(st.Source), resp.Status)
+	}
+	return parseFeed(io.LimitReader(resp.Body, 64<<20))
+}
+
+func parseFeed(r io.Reader) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+		fs := strings.Fields(line)
+		if len(fs) == 0 {
+			continue
+		}
+		if p, err := netip.ParsePrefix(fs[0]); err == nil {
+			out = append(out, p.Masked())
+		} else if a, err := netip.ParseAddr(fs[0]); err == nil {
+			out = append(out, netip.PrefixFrom(a, a.BitLen()))
+		}
+	}
+	return out, sc.Err()
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// IntelOf returns the names of the threat-intel feeds listing addr.
+func IntelOf(addr netip.Addr) []string {
+	return intelFeeds.match(addr)
+}
+
+// AnonymizerOf returns the names of the anonymizer feeds listing addr.
+func AnonymizerOf(addr netip.Addr) []string {
+	return anonFeeds.match(addr)
+}
+
+// HasAnonymizerFeeds reports whether any anonymizer feed is configured.
+func HasAnonymizerFeeds() bool {
+	anonFeeds.mu.RLock()
+	defer anonFeeds.mu.RUnlock()
+	return len(anonFeeds.feeds) > 0
+}
+
+func (s *feedSet) match(addr netip.Addr) []string {
+	addr = addr.Unmap()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []string
+	for _, st := range s.feeds {
+		for _, p := range st.prefixes {
+			if p.Contains(addr) {
+				out = append(out, st.Name)
+				break
+			}
+		}
+	}
+	return out
+}
+
+type intelEnricher struct{}
+
+func (intelEnricher) Name() string { return "intel" }
+
+func (intelEnricher) Enrich(_ context.Context, addr netip.Addr, b *Bundle) error {
+	b.Intel = IntelOf(addr)
+	return nil
+}
+
+type anonymizerEnricher struct{}
+
+func (anonymizerEnricher) Name() string { return "anonymizer" }
+
+func (anonymizerEnricher) Enrich(_ context.Context, addr netip.Addr, b *Bundle) error {
+	b.Anonymizer = AnonymizerOf(addr)
+	return nil
+}
@@ -0,0 +1,305 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Enricher adds one kind of context about an IP to a Bundle. Implementations
+// should only set their own fields; the pipeline merges the results.
+type Enricher interface {
+	Name() string
+	Enrich(ctx context.Context, addr netip.Addr, b *Bundle) error
+}
+
+// An Enricher that also implements NoCache is always called fresh.
+type uncached interface {
+	NoCache()
+}
+
+var (
+	regMu    sync.RWMutex
+	registry = make(map[string]Enricher)
+	regOrder []string
+)
+
+// Register makes an enricher available by name for pipeline configuration.
+func Register(e Enricher) {
+	regMu.Lock()
+	defer regMu.Unlock()
+	if _, ok := registry[e.Name()]; !ok {
+		regOrder = append(regOrder, e.Name())
+	}
+	registry[e.Name()] = e
+}
+
+type Config struct {
+	Order    []string                 // enricher names to run, in order; empty means all registered
+	Timeouts map[string]time.Duration // per-enricher timeout; missing uses DefaultTimeout
+	CacheTTL time.Duration
+	CacheMax int
+}
+
+const DefaultTimeout = 2 * time.Second
+
+// EnvConfig reads ENRICHERS (comma-separated names), ENRICH_TIMEOUTS
+// (name=duration pairs), ENRICH_CACHE_TTL and ENRICH_CACHE_SIZE. A malformed
+// value is an error rather than falling back to the default.
+func EnvConfig() (Config, error) {
+	c := Config{
+		Timeouts: make(map[string]time.Duration),
+		CacheTTL: 10 * time.Minute,
+		CacheMax: 10_000,
+	}
+	for _, n := range strings.Split(os.Getenv("ENRICHERS"), ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			c.Order = append(c.Order, n)
+		}
+	}
+	for _, kv := range strings.Split(os.Getenv("ENRICH_TIMEOUTS"), ",") {
+		if strings.TrimSpace(kv) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(kv, "=")
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if !ok || strings.TrimSpace(k) == "" || err != nil || d <= 0 {
+			return c, fmt.Errorf("ENRICH_TIMEOUTS: %q must be name=duration, e.g. rdns=500ms", strings.TrimSpace(kv))
+		}
+		c.Timeouts[strings.TrimSpace(k)] = d
+	}
+	if v := os.Getenv("ENRICH_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return c, errors.New("ENRICH_CACHE_TTL must be a duration")
+		}
+		c.CacheTTL = d
+	}
+	if v := os.Getenv("ENRICH_CACHE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return c, errors.New("ENRICH_CACHE_SIZE must be a non-negative integer")
+		}
+		c.CacheMax = n
+	}
+	return c, nil
+}
+
+type Metrics struct {
+	Calls     int64   `json:"calls"`
+	CacheHits int64   `json:"cacheHits"`
+	Errors    int64   `json:"errors"`
+	Timeouts  int64   `json:"timeouts"`
+	AvgMs     float64 `json:"avgMs"`
+
+	totalNs int64
+}
+
+type stage struct {
+	e       Enricher
+	timeout time.Duration
+	cached  bool
+	m       Metrics
+}
+
+type Pipeline struct {
+	stages []*stage
+	cache  *cache
+}
+
+// NewPipeline builds a pipeline from registered enrichers. Unknown names in
+// c.Order are skipped.
+func NewPipeline(c Config) *Pipeline {
+	regMu.RLock()
+	defer regMu.RUnlock()
+
+	names := c.Order
+	if len(names) == 0 {
+		names = regOrder
+	}
+	p := &Pipeline{cache: newCache(c.CacheTTL, c.CacheMax)}
+	seen := make(map[string]bool)
+	for _, n := range names {
+		e, ok := registry[n]
+		if !ok || seen[n] {
+			continue
+		}
+		seen[n] = true
+		to := DefaultTimeout
+		if d, ok := c.Timeouts[n]; ok {
+			to = d
+		}
+		_, noCache := e.(uncached)
+		p.stages = append(p.stages, &stage{e: e, timeout: to, cached: !noCache})
+	}
+	return p
+}
+
+func (p *Pipeline) Lookup(ctx context.Context, ip string) Bundle {
+	b := Bundle{IP: ip}
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		b.Errors = append(b.Errors, "invalid IP address")
+		return b
+	}
+	b.Valid = true
+
+	for _, s := range p.stages {
+		key := s.e.Name() + "|" + addr.String()
+		if s.cached {
+			if frag, ok := p.cache.get(key); ok {
+				atomic.AddInt64(&s.m.CacheHits, 1)
+				b.merge(frag)
+				continue
+			}
+		}
+
+		var frag Bundle
+		sctx, cancel := context.WithTimeout(ctx, s.timeout)
+		start := time.Now()
+		err := s.e.Enrich(sctx, addr, &frag)
+		atomic.AddInt64(&s.m.totalNs, int64(time.Since(start)))
+		atomic.AddInt64(&s.m.Calls, 1)
+		timedOut := sctx.Err() == context.DeadlineExceeded
+		cancel()
+
+		if err != nil {
+			atomic.AddInt64(&s.m.Errors, 1)
+			if timedOut {
+				atomic.AddInt64(&s.m.Timeouts, 1)
+			}
+			b.Errors = append(b.Errors, s.e.Name()+": "+err.Error())
+			continue
+		}
+		if s.cached {
+			p.cache.put(key, frag)
+		}
+		b.merge(frag)
+	}
+	return b
+}
+
+// Stats returns a metrics snapshot keyed by enricher name.
+func (p *Pipeline) Stats() map[string]Metrics {
+	out := make(map[string]Metrics, len(p.stages))
+	for _, s := range p.stages {
+		m := Metrics{
+			Calls:     atomic.LoadInt64(&s.m.Calls),
+			CacheHits: atomic.LoadInt64(&s.m.CacheHits),
+			Errors:    atomic.LoadInt64(&s.m.Errors),
+			Timeouts:  atomic.LoadInt64(&s.m.Timeouts),
+		}
+		if m.Calls > 0 {
+			m.AvgMs = float64(atomic.LoadInt64(&s.m.totalNs)) / float64(m.Calls) / 1e6
+		}
+		out[s.e.Name()] = m
+	}
+	return out
+}
+
+func (b *Bundle) merge(o Bundle) {
+	if o.Geo != nil {
+		b.Geo = o.Geo
+	}
+	if o.ASN != nil {
+		b.ASN = o.ASN
+	}
+	if o.Abuse != nil {
+		b.Abuse = o.Abuse
+	}
+	b.RDNS = append(b.RDNS, o.RDNS...)
+	b.Intel = append(b.Intel, o.Intel...)
+	b.Anonymizer = append(b.Anonymizer, o.Anonymizer...)
+	b.Prior = append(b.Prior, o.Prior...)
+	b.Errors = append(b.Errors, o.Errors...)
+}
+
+var (
+	pipeMu   sync.RWMutex
+	pipeline *Pipeline
+)
+
+// Configure replaces the pipeline used by Lookup and LookupAll.
+func Configure(c Config) {
+	p := NewPipeline(c)
+	pipeMu.Lock()
+	pipeline = p
+	pipeMu.Unlock()
+}
+
+func current() *Pipeline {
+	pipeMu.RLock()
+	p := pipeline
+	pipeMu.RUnlock()
+	if p == nil {
+		Configure(Config{CacheTTL: 10 * time.Minute, CacheMax: 10_000})
+		return current()
+	}
+	return p
+}
+
+// Stats reports metrics for the configured pipeline.
+func Stats() map[string]Metrics {
+	return current().Stats()
+}
+
+type cacheEntry struct {
+	frag    Bundle
+	expires time.Time
+}
+
+// cache is a TTL map shared by all stages of a pipeline. When full, expired
+// entries are dropped first and then arbitrary ones.
+type cache struct {
+	mu  sync.Mutex
+	ttl time.Duration
+	max int
+	m   map[string]cacheEntry
+}
+
+func newCache(ttl time.Duration, max int) *cache {
+	return &cache{ttl: ttl, max: max, m: make(map[string]cacheEntry)}
+}
+
+func (c *cache) get(key string) (Bundle, bool) {
+	if c.ttl <= 0 || c.max <= 0 {
+		return Bundle{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.m[key]
+	if !ok || time.Now().After(e.expires) {
+		return Bundle{}, false
+	}
+	return e.frag, true
+}
+
+func (c *cache) put(key string, frag Bundle) {
+	if c.ttl <= 0 || c.max <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.m) >= c.max {
+		now := time.Now()
+		for k, e := range c.m {
+			if now.After(e.expires) {
+				delete(c.m, k)
+			}
+		}
+		for k := range c.m {
+			if len(c.m) < c.max {
+				break
+			}
+			delete(c.m, k)
+		}
+	}
+	c.m[key] = cacheEntry{frag: frag, expires: time.Now().Add(c.ttl)}
+}
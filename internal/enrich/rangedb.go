@@ -0,0 +1,81 @@
+package enrich
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+// rangeDB maps CIDR prefixes to CSV records. Lookups return the most specific
+// matching prefix.
+type rangeDB struct {
+	entries []rangeEntry
+}
+
+type rangeEntry struct {
+	prefix netip.Prefix
+	fields []string
+}
+
+// loadRangeDB reads a CSV file whose first column is a CIDR prefix. Blank
+// lines and lines starting with '#' are ignored.
+func loadRangeDB(path string) (*rangeDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	db := &rangeDB{}
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		p, err := netip.ParsePrefix(strings.TrimSpace(rec[0]))
+		if err != nil {
+			// Tolerate a header row.
+			if len(db.entries) == 0 {
+				continue
+			}
+			return nil, err
+		}
+		db.entries = append(db.entries, rangeEntry{prefix: p.Masked(), fields: rec[1:]})
+	}
+	sort.SliceStable(db.entries, func(i, j int) bool {
+		return db.entries[i].prefix.Bits() > db.entries[j].prefix.Bits()
+	})
+	return db, nil
+}
+
+func (db *rangeDB) lookup(addr netip.Addr) ([]string, bool) {
+	if db == nil {
+		return nil, false
+	}
+	addr = addr.Unmap()
+	for _, e := range db.entries {
+		if e.prefix.Contains(addr) {
+			return e.fields, true
+		}
+	}
+	return nil, false
+}
+
+func field(fs []string, i int) string {
+	if i < len(fs) {
+		return strings.TrimSpace(fs[i])
+	}
+	return ""
+}
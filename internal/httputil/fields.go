@@ -0,0 +1,42 @@
+package httputil
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Fields returns the names listed in a comma-separated ?fields= parameter.
+func Fields(r *http.Request) []string {
+	var out []string
+	for _, v := range r.URL.Query()["fields"] {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				out = append(out, f)
+			}
+		}
+	}
+	return out
+}
+
+// Project encodes v as a JSON object and keeps only the keys in keep. A nil
+// keep set keeps every key.
+func Project(v any, keep map[string]bool) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if keep == nil {
+		return m, nil
+	}
+	for k := range m {
+		if !keep[k] {
+			delete(m, k)
+		}
+	}
+	return m, nil
+}
@@ -10,3 +10,9 @@ func NewID() string {
 	_, _ = rand.Read(b[:])
 	return hex.EncodeToString(b[:])
 }
+
+// IsID reports whether s has the form of an ID returned by NewID.
+func IsID(s string) bool {
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) == 16 && s == hex.EncodeToString(b)
+}
@@ -0,0 +1,147 @@
+package httputil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	outboundMu   sync.RWMutex
+	outboundRoot *x509.CertPool
+)
+
+// ConfigureOutbound adds the PEM certificates in caFile to the system roots
+// trusted by every outbound integration (webhooks, SMTP STARTTLS, ...), for
+// egress through an intercepting proxy or to internal services. An empty
+// caFile restores the system roots.
+func ConfigureOutbound(caFile string) error {
+	if caFile == "" {
+		outboundMu.Lock()
+		outboundRoot = nil
+		outboundMu.Unlock()
+		return nil
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return errors.New("no PEM certificates found in " + caFile)
+	}
+	outboundMu.Lock()
+	outboundRoot = pool
+	outboundMu.Unlock()
+	return nil
+}
+
+// OutboundTLS returns the TLS configuration for connecting to serverName.
+func OutboundTLS(serverName string) *tls.Config {
+	outboundMu.RLock()
+	defer outboundMu.RUnlock()
+	return &tls.Config{ServerName: serverName, RootCAs: outboundRoot, MinVersion: tls.VersionTLS12}
+}
+
+// OutboundClient returns an HTTP client for calls to third parties. It honours
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY and trusts the CA bundle set with
+// ConfigureOutbound.
+func OutboundClient(timeout time.Duration) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	t.TLSClientConfig = OutboundTLS("")
+	return &http.Client{Timeout: timeout, Transport: t}
+}
+
+// ErrNonPublicAddress is returned by PublicClient for a target that is, or
+// resolves to, an address that is not on the public internet.
+var ErrNonPublicAddress = errors.New("target is not a public address")
+
+// cgnat is the shared address space of carrier-grade NAT (RFC 6598).
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// IsPublicAddr reports whether ip is a public unicast address: not loopback,
+// private, link-local, carrier-grade NAT, unspecified or multicast.
+func IsPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !cgnat.Contains(ip)
+}
+
+// PublicClient is OutboundClient for URLs that users supply, such as upload
+// callbacks: it only connects to public addresses, so those URLs cannot reach
+// the host or the internal network. The check runs on the address actually
+// dialed, after resolution and on every redirect, so a name that resolves
+// differently the second time does not get past it. When a proxy does the
+// dialing the target's addresses are resolved and checked before the request.
+func PublicClient(timeout time.Duration) *http.Client {
+	c := OutboundClient(timeout)
+	t := c.Transport.(*http.Transport)
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: dialPublic}
+	t.DialContext = d.DialContext
+	c.Transport = publicTransport{t}
+	return c
+}
+
+func dialPublic(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !IsPublicAddr(ip) {
+		return ErrNonPublicAddress
+	}
+	return nil
+}
+
+type publicTransport struct{ *http.Transport }
+
+func (t publicTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if proxy, err := t.Proxy(req); err == nil && proxy != nil {
+		ips, err := net.DefaultResolver.LookupNetIP(req.Context(), "ip", req.URL.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			if !IsPublicAddr(ip) {
+				return nil, ErrNonPublicAddress
+			}
+		}
+	}
+	return t.Transport.RoundTrip(req)
+}
+
+// RedactURL keeps only the scheme, host and path of u, since feed and
+// reputation URLs often carry API keys in the query.
+func RedactURL(u string) string {
+	p, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	return p.Scheme + "://" + p.Host + p.Path
+}
+
+// RedactError strips the query from the URL in a client error, or replaces
+// the URL entirely when the whole of it is secret (webhooks).
+func RedactError(err error, whole bool) error {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		if whole {
+			ue.URL = "<redacted>"
+		} else {
+			ue.URL = RedactURL(ue.URL)
+		}
+	}
+	return err
+}
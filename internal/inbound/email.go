@@ -0,0 +1,362 @@
+package inbound
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/integrations"
+	"github.com/allensuvorov/tenexlog/internal/jobs"
+	"github.com/allensuvorov/tenexlog/internal/reqctx"
+	"github.com/allensuvorov/tenexlog/internal/upload"
+)
+
+const (
+	maxMessageBytes = 50 << 20
+	linkTTL         = 7 * 24 * time.Hour
+)
+
+// Config controls the email gateway. Address, when set, restricts accepted
+// mail to messages addressed to it. Replies are sent only when SMTPAddr is set.
+type Config struct {
+	Address  string
+	SMTPAddr string
+	SMTPUser string
+	SMTPPass string
+	From     string
+	BaseURL  string // prefix for report links in replies, e.g. https://tenexlog.example.com
+}
+
+var cfg Config
+
+func Configure(c Config) {
+	cfg = c
+	if c.SMTPAddr != "" {
+		smtpHealth()
+	}
+}
+
+func smtpHealth() *integrations.Tracker {
+	return integrations.Track("smtp", cfg.SMTPAddr, cfg.SMTPAddr)
+}
+
+type jobLink struct {
+	JobID     string `json:"jobId"`
+	Filename  string `json:"filename"`
+	Anomalies int    `json:"anomalies"`
+	Link      string `json:"link"`
+	Error     string `json:"error,omitempty"`
+}
+
+type emailResponse struct {
+	Jobs    []jobLink `json:"jobs"`
+	Replied bool      `json:"replied"`
+}
+
+// EmailHandler accepts an inbound email, either as a raw RFC 822 message or
+// wrapped in an SES-to-SNS notification, and creates one job per attachment.
+func EmailHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageBytes))
+	if err != nil {
+		http.Error(w, "message too large or unreadable", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	raw, confirm, err := unwrapSNS(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if confirm != "" {
+		log.Println("inbound email: SNS subscription confirmation requested; visit", confirm)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		http.Error(w, "invalid email message", http.StatusBadRequest)
+		return
+	}
+	if cfg.Address != "" && !addressedTo(msg.Header, cfg.Address) {
+		http.Error(w, "message is not addressed to the configured inbound address", http.StatusForbidden)
+		return
+	}
+
+	atts, err := attachments(msg)
+	if err != nil {
+		http.Error(w, "could not read attachments: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(atts) == 0 {
+		http.Error(w, "message has no attachments", http.StatusBadRequest)
+		return
+	}
+
+	resp := emailResponse{Jobs: make([]jobLink, 0, len(atts))}
+	for _, a := range atts {
+		res, err := upload.Ingest(r.Context(), a.name, bytes.NewReader(a.data))
+		if err != nil {
+			resp.Jobs = append(resp.Jobs, jobLink{Filename: a.name, Error: err.Error()})
+			continue
+		}
+		link := jobs.GuestLink(res.JobID, linkTTL)
+		resp.Jobs = append(resp.Jobs, jobLink{
+			JobID:     res.JobID,
+			Filename:  a.name,
+			Anomalies: len(res.Anomalies),
+			Link:      strings.TrimSuffix(cfg.BaseURL, "/") + link.URL,
+		})
+	}
+
+	if cfg.SMTPAddr != "" {
+		if err := reply(msg.Header, resp.Jobs); err != nil {
+			reqctx.Logger(r.Context()).Println("inbound email: reply failed:", err)
+		} else {
+			resp.Replied = true
+		}
+	}
+	httputil.JSON(w, http.StatusOK, resp)
+}
+
+// unwrapSNS returns the raw MIME message from an SNS notification carrying an
+// SES receipt, or body unchanged if it is not JSON. For subscription
+// confirmations it returns the SubscribeURL instead.
+func unwrapSNS(body []byte) (raw []byte, confirmURL string, err error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return body, "", nil
+	}
+	var env struct {
+		Type         string `json:"Type"`
+		Message      string `json:"Message"`
+		SubscribeURL string `json:"SubscribeURL"`
+	}
+	if err := json.Unmarshal(trimmed, &env); err != nil {
+		return nil, "", errors.New("invalid SNS envelope")
+	}
+	if env.Type == "SubscriptionConfirmation" {
+		return nil, env.SubscribeURL, nil
+	}
+	var ses struct {
+		Content string `json:"content"`
+		Receipt struct {
+			Action struct {
+				Encoding string `json:"encoding"`
+			} `json:"action"`
+		} `json:"receipt"`
+	}
+	if err := json.Unmarshal([]byte(env.Message), &ses); err != nil || ses.Content == "" {
+		return nil, "", errors.New("SNS notification has no SES message content")
+	}
+	if strings.EqualFold(ses.Receipt.Action.Encoding, "BASE64") {
+		dec, err := base64.StdEncoding.DecodeString(ses.Content)
+		if err != nil {
+			return nil, "", errors.New("invalid base64 SES content")
+		}
+		return dec, "", nil
+	}
+	return []byte(ses.Content), "", nil
+}
+
+func addressedTo(h mail.Header, want string) bool {
+	for _, field := range []string{"To", "Cc", "Delivered-To", "X-Original-To"} {
+		list, err := h.AddressList(field)
+		if err != nil {
+			if strings.Contains(strings.ToLower(h.Get(field)), strings.ToLower(want)) {
+				return true
+			}
+			continue
+		}
+		for _, a := range list {
+			if strings.EqualFold(a.Address, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type attachment struct {
+	name string
+	data []byte
+}
+
+func attachments(msg *mail.Message) ([]attachment, error) {
+	var out []attachment
+	err := walkPart(msg.Header, msg.Body, &out, 0)
+	return out, err
+}
+
+type header interface {
+	Get(string) string
+}
+
+func walkPart(h header, body io.Reader, out *[]attachment, depth int) error {
+	if depth > 5 {
+		return nil
+	}
+	mediaType, params, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextRawPart()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := walkPart(p.Header, p, out, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, dparams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+	name := dparams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+	if name == "" {
+		return nil
+	}
+	name = decodeWord(name)
+
+	var r io.Reader = body
+	switch strings.ToLower(strings.TrimSpace(h.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, newlineStripper{body})
+	case "quoted-printable":
+		r = quotedprintable.NewReader(body)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	*out = append(*out, attachment{name: name, data: data})
+	return nil
+}
+
+func decodeWord(s string) string {
+	if d, err := new(mime.WordDecoder).DecodeHeader(s); err == nil {
+		return d
+	}
+	return s
+}
+
+// newlineStripper drops CR and LF so line-wrapped base64 decodes cleanly.
+type newlineStripper struct{ r io.Reader }
+
+func (n newlineStripper) Read(p []byte) (int, error) {
+	for {
+		k, err := n.r.Read(p)
+		j := 0
+		for _, b := range p[:k] {
+			if b != '\r' && b != '\n' {
+				p[j] = b
+				j++
+			}
+		}
+		if j > 0 || err != nil {
+			return j, err
+		}
+	}
+}
+
+func reply(h mail.Header, results []jobLink) error {
+	to := h.Get("Reply-To")
+	if to == "" {
+		to = h.Get("From")
+	}
+	addr, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("no usable reply address: %w", err)
+	}
+	from := cfg.From
+	if from == "" {
+		from = cfg.Address
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", addr.String())
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Re: "+h.Get("Subject")))
+	if id := h.Get("Message-Id"); id != "" {
+		fmt.Fprintf(&b, "In-Reply-To: %s\r\n", id)
+	}
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString("Your logs have been analyzed by TenexLog.\r\n\r\n")
+	for _, j := range results {
+		if j.Error != "" {
+			fmt.Fprintf(&b, "- %s: failed (%s)\r\n", j.Filename, j.Error)
+			continue
+		}
+		fmt.Fprintf(&b, "- %s: %d anomalies\r\n  %s\r\n", j.Filename, j.Anomalies, j.Link)
+	}
+	fmt.Fprintf(&b, "\r\nLinks expire in %d days.\r\n", int(linkTTL.Hours()/24))
+
+	return sendMail(addrOnly(from), addr.Address, []byte(b.String()))
+}
+
+// sendMail is smtp.SendMail with STARTTLS verified against the outbound CA
+// bundle rather than only the system roots.
+func sendMail(from, to string, msg []byte) error {
+	return smtpHealth().Do(func() error { return dialAndSend(from, to, msg) })
+}
+
+func dialAndSend(from, to string, msg []byte) error {
+	host, _, _ := strings.Cut(cfg.SMTPAddr, ":")
+	c, err := smtp.Dial(cfg.SMTPAddr)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(httputil.OutboundTLS(host)); err != nil {
+			return err
+		}
+	}
+	if cfg.SMTPUser != "" {
+		if err := c.Auth(smtp.PlainAuth("", cfg.SMTPUser, cfg.SMTPPass, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func addrOnly(s string) string {
+	if a, err := mail.ParseAddress(s); err == nil {
+		return a.Address
+	}
+	return s
+}
@@ -0,0 +1,228 @@
+// Package integrations tracks the health of outbound dependencies
+// (webhooks, SMTP, intel feeds, reputation APIs) and guards each with a
+// circuit breaker so a failing one is skipped instead of stalling callers.
+package integrations
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+)
+
+// Status is a snapshot of one integration.
+type Status struct {
+	Kind        string     `json:"kind"`
+	Name        string     `json:"name"`
+	Target      string     `json:"target,omitempty"`
+	State       string     `json:"state"` // ok, failing or unknown (never called)
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	LastFailure *time.Time `json:"lastFailure,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+	Failures    int        `json:"consecutiveFailures"`
+	Breaker     string     `json:"breaker"` // closed, open or half-open
+	RetryAt     *time.Time `json:"retryAt,omitempty"`
+	Detail      string     `json:"detail,omitempty"`
+}
+
+// Breaker settings shared by every tracker: after FailureThreshold
+// consecutive failures calls are refused for Cooldown, then one trial call is
+// let through; each failed trial doubles the wait up to MaxCooldown.
+var (
+	FailureThreshold = 5
+	Cooldown         = 30 * time.Second
+	MaxCooldown      = 10 * time.Minute
+)
+
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// Tracker records call outcomes for one integration and acts as its circuit
+// breaker.
+type Tracker struct {
+	mu       sync.Mutex
+	s        Status
+	openedAt time.Time
+	wait     time.Duration
+	trial    bool // a half-open trial call is in flight
+}
+
+var (
+	mu       sync.Mutex
+	trackers = make(map[string]*Tracker)
+)
+
+// Track returns the tracker for kind/name, creating it on first use so that
+// configured integrations are listed before they are ever called. target is
+// shown to operators and must not carry credentials.
+func Track(kind, name, target string) *Tracker {
+	key := kind + "|" + name
+	mu.Lock()
+	defer mu.Unlock()
+	t, ok := trackers[key]
+	if !ok {
+		t = &Tracker{s: Status{Kind: kind, Name: name}}
+		trackers[key] = t
+	}
+	if target != "" {
+		t.mu.Lock()
+		t.s.Target = target
+		t.mu.Unlock()
+	}
+	return t
+}
+
+// Forget drops trackers of kind that are not in keep, for integrations that
+// were reconfigured away.
+func Forget(kind string, keep ...string) {
+	names := make(map[string]bool, len(keep))
+	for _, n := range keep {
+		names[n] = true
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for key, t := range trackers {
+		if t.s.Kind == kind && !names[t.s.Name] {
+			delete(trackers, key)
+		}
+	}
+}
+
+// Allow reports whether a call may be made now. While the breaker is open it
+// returns false; once the cooldown has passed it admits a single trial call
+// whose Record decides whether the breaker closes again.
+func (t *Tracker) Allow() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.openedAt.IsZero() {
+		return true
+	}
+	if t.trial || time.Since(t.openedAt) < t.wait {
+		return false
+	}
+	t.trial = true
+	return true
+}
+
+// ErrOpen is returned by Do while the breaker refuses calls.
+var ErrOpen = errors.New("circuit breaker open")
+
+// Do runs fn if the breaker allows it and records the outcome.
+func (t *Tracker) Do(fn func() error) error {
+	if !t.Allow() {
+		return ErrOpen
+	}
+	err := fn()
+	t.Record(err)
+	return err
+}
+
+// RetryAt is when an open breaker next admits a trial call; zero when closed.
+func (t *Tracker) RetryAt() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.openedAt.IsZero() {
+		return time.Time{}
+	}
+	return t.openedAt.Add(t.wait)
+}
+
+// Record notes the outcome of one call; a nil err is a success.
+func (t *Tracker) Record(err error) {
+	now := time.Now().UTC()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	trial := t.trial
+	t.trial = false
+	if err == nil {
+		t.s.LastSuccess = &now
+		t.s.Failures = 0
+		t.openedAt, t.wait = time.Time{}, 0
+		return
+	}
+	t.s.LastFailure = &now
+	t.s.LastError = err.Error()
+	t.s.Failures++
+	switch {
+	case trial:
+		t.openedAt, t.wait = now, min(t.wait*2, MaxCooldown)
+	case t.openedAt.IsZero() && FailureThreshold > 0 && t.s.Failures >= FailureThreshold:
+		t.openedAt, t.wait = now, Cooldown
+	}
+}
+
+// SetDetail sets a short free-form note such as a feed's entry count.
+func (t *Tracker) SetDetail(d string) {
+	t.mu.Lock()
+	t.s.Detail = d
+	t.mu.Unlock()
+}
+
+func (t *Tracker) Status() Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.s
+	switch {
+	case t.openedAt.IsZero():
+		s.Breaker = BreakerClosed
+	case t.trial || !time.Now().Before(t.openedAt.Add(t.wait)):
+		s.Breaker = BreakerHalfOpen
+	default:
+		s.Breaker = BreakerOpen
+		retry := t.openedAt.Add(t.wait)
+		s.RetryAt = &retry
+	}
+	switch {
+	case s.Failures > 0:
+		s.State = "failing"
+	case s.LastSuccess != nil:
+		s.State = "ok"
+	default:
+		s.State = "unknown"
+	}
+	return s
+}
+
+// Statuses lists every tracked integration ordered by kind and name.
+func Statuses() []Status {
+	mu.Lock()
+	ts := make([]*Tracker, 0, len(trackers))
+	for _, t := range trackers {
+		ts = append(ts, t)
+	}
+	mu.Unlock()
+
+	out := make([]Status, 0, len(ts))
+	for _, t := range ts {
+		out = append(out, t.Status())
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Kind != out[j].Kind {
+			return out[i].Kind < out[j].Kind
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+type statusResponse struct {
+	Integrations []Status `json:"integrations"`
+	Failing      int      `json:"failing"`
+}
+
+// StatusHandler serves GET /api/integrations/status.
+func StatusHandler(w http.ResponseWriter, r *http.Request) {
+	resp := statusResponse{Integrations: Statuses()}
+	for _, s := range resp.Integrations {
+		if s.State == "failing" {
+			resp.Failing++
+		}
+	}
+	httputil.JSON(w, http.StatusOK, resp)
+}
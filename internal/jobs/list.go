@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+type jobList struct {
+	Jobs   []Job `json:"jobs"`
+	Total  int   `json:"total"`
+	Limit  int   `json:"limit"`
+	Offset int   `json:"offset"`
+}
+
+// List serves GET /api/jobs: stored jobs, newest first, without their
+// results. ?from= and ?to= (RFC 3339) bound the received time and ?source=
+// keeps the jobs of one source; ?limit= (default 50, max 500) and ?offset=
+// page through the matches, and total counts them all.
+func List(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, offset := defaultListLimit, 0
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxListLimit {
+			http.Error(w, "limit must be an integer between 1 and 500", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+	var from, to time.Time
+	for _, b := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		if v := q.Get(b.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, b.name+" must be an RFC 3339 time such as 2024-05-01T13:00:00Z", http.StatusBadRequest)
+				return
+			}
+			*b.t = t
+		}
+	}
+
+	all, err := Default.ListJobs()
+	if err != nil {
+		http.Error(w, "could not list jobs", http.StatusInternalServerError)
+		return
+	}
+	matched := make([]Job, 0, len(all))
+	for _, j := range all {
+		if (!from.IsZero() && j.Received.Before(from)) || (!to.IsZero() && j.Received.After(to)) {
+			continue
+		}
+		if source := q.Get("source"); source != "" && j.Source != source {
+			continue
+		}
+		matched = append(matched, j)
+	}
+	start := min(offset, len(matched))
+	page := matched[start : start+min(limit, len(matched)-start)]
+	httputil.JSON(w, http.StatusOK, jobList{Jobs: page, Total: len(matched), Limit: limit, Offset: offset})
+}
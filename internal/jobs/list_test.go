@@ -0,0 +1,73 @@
+package jobs
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestList(t *testing.T) {
+	old := Default
+	t.Cleanup(func() { Default = old })
+	s := NewMemStore(0)
+	base := time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		j := Job{ID: strconv.Itoa(i), Received: base.Add(time.Duration(i) * time.Hour), Status: StatusDone}
+		if i%2 == 1 {
+			j.Source = "edge"
+		}
+		if _, err := s.SaveJob(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	Default = s
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIDs    []string
+		wantTotal  int
+	}{
+		{name: "all", wantStatus: http.StatusOK, wantIDs: []string{"4", "3", "2", "1", "0"}, wantTotal: 5},
+		{name: "page", query: "limit=2&offset=1", wantStatus: http.StatusOK, wantIDs: []string{"3", "2"}, wantTotal: 5},
+		{name: "last partial page", query: "limit=2&offset=4", wantStatus: http.StatusOK, wantIDs: []string{"0"}, wantTotal: 5},
+		{name: "offset past the end", query: "offset=9", wantStatus: http.StatusOK, wantIDs: []string{}, wantTotal: 5},
+		{name: "largest offset", query: "limit=500&offset=" + strconv.Itoa(math.MaxInt), wantStatus: http.StatusOK, wantIDs: []string{}, wantTotal: 5},
+		{name: "time range", query: "from=2024-05-01T14:00:00Z&to=2024-05-01T15:00:00Z", wantStatus: http.StatusOK, wantIDs: []string{"2", "1"}, wantTotal: 2},
+		{name: "source", query: "source=edge", wantStatus: http.StatusOK, wantIDs: []string{"3", "1"}, wantTotal: 2},
+		{name: "source and time range", query: "source=edge&from=2024-05-01T15:00:00Z", wantStatus: http.StatusOK, wantIDs: []string{"3"}, wantTotal: 1},
+		{name: "unknown source", query: "source=db", wantStatus: http.StatusOK, wantIDs: []string{}, wantTotal: 0},
+		{name: "limit too large", query: "limit=501", wantStatus: http.StatusBadRequest},
+		{name: "negative offset", query: "offset=-1", wantStatus: http.StatusBadRequest},
+		{name: "bad time", query: "from=yesterday", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			List(w, httptest.NewRequest("GET", "/api/jobs?"+tt.query, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var got jobList
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			ids := []string{}
+			for _, j := range got.Jobs {
+				ids = append(ids, j.ID)
+			}
+			if got.Total != tt.wantTotal || !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("jobs %v (total %d), want %v (total %d)", ids, got.Total, tt.wantIDs, tt.wantTotal)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/auth"
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/reqctx"
+)
+
+const (
+	defaultShareTTL = 24 * time.Hour
+	maxShareTTL     = 30 * 24 * time.Hour
+)
+
+// ShareSecret signs guest links and must be set before serving. If left
+// unset a random secret is generated on first use, so links stop working when
+// the process restarts.
+var ShareSecret []byte
+
+var shareOnce sync.Once
+
+func shareSecret() []byte {
+	shareOnce.Do(func() {
+		if len(ShareSecret) == 0 {
+			secret := make([]byte, 32)
+			_, _ = rand.Read(secret)
+			ShareSecret = secret
+		}
+	})
+	return ShareSecret
+}
+
+// GuestViewer is implemented by results holding fields that guests must not
+// see, such as where the upload is kept on the server.
+type GuestViewer interface {
+	GuestView() any
+}
+
+// guestView returns a job's results as a guest may see them.
+func guestView(result any) any {
+	switch v := result.(type) {
+	case GuestViewer:
+		return v.GuestView()
+	case json.RawMessage:
+		var m map[string]any
+		if err := json.Unmarshal(v, &m); err != nil {
+			return nil
+		}
+		delete(m, "savedTo")
+		return m
+	}
+	return result
+}
+
+type ShareLink struct {
+	Token   string    `json:"token"`
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+// Share issues a read-only guest link for one job. The optional JSON body
+// {"ttl": "72h"} sets the lifetime (default 24h, max 30 days).
+func Share(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := Default.GetJob(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	ttl := defaultShareTTL
+	var req struct {
+		TTL string `json:"ttl"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 || d > maxShareTTL {
+			http.Error(w, "ttl must be a positive duration up to 720h", http.StatusBadRequest)
+			return
+		}
+		ttl = d
+	}
+
+	httputil.JSON(w, http.StatusCreated, GuestLink(id, ttl))
+}
+
+// GuestLink signs a read-only link to job id valid for ttl.
+func GuestLink(id string, ttl time.Duration) ShareLink {
+	exp := time.Now().Add(ttl).UTC().Truncate(time.Second)
+	tok := auth.SignGuestToken(shareSecret(), id, exp)
+	return ShareLink{
+		Token:   tok,
+		URL:     "/api/shared/" + tok,
+		Expires: exp,
+	}
+}
+
+// Shared serves a job's stored results to holders of a valid guest token,
+// without Basic Auth. It is read-only and cannot reach any other job.
+func Shared(w http.ResponseWriter, r *http.Request) {
+	id, err := auth.VerifyGuestToken(shareSecret(), r.PathValue("token"), time.Now())
+	if err != nil {
+		status := http.StatusUnauthorized
+		if errors.Is(err, auth.ErrExpiredToken) {
+			status = http.StatusGone
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	ctx := reqctx.WithPrincipal(r.Context(), reqctx.Principal{User: "guest:" + id, Guest: true})
+	j, err := Default.GetJob(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if j.Status != StatusDone {
+		http.Error(w, "job is "+j.Status, http.StatusConflict)
+		return
+	}
+	reqctx.Logger(ctx).Printf("shared job %s viewed", id)
+	w.Header().Set("Cache-Control", "private, no-store")
+	httputil.JSON(w, http.StatusOK, guestView(j.Result))
+}
@@ -0,0 +1,216 @@
+package jobs
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dialects understood by SQLStore, named after the database/sql drivers they
+// expect: modernc.org/sqlite registers "sqlite" and pgx's stdlib package
+// "pgx". Neither needs cgo.
+const (
+	DialectSQLite   = "sqlite"
+	DialectPostgres = "pgx"
+)
+
+const sqlSchema = `CREATE TABLE IF NOT EXISTS jobs (
+	id            TEXT PRIMARY KEY,
+	filename      TEXT NOT NULL,
+	size_bytes    BIGINT NOT NULL,
+	saved_to      TEXT NOT NULL,
+	received      BIGINT NOT NULL,
+	format        TEXT NOT NULL,
+	anomaly_count INTEGER NOT NULL,
+	owner         TEXT NOT NULL,
+	request_id    TEXT NOT NULL,
+	status        TEXT NOT NULL,
+	progress      INTEGER NOT NULL,
+	error         TEXT NOT NULL,
+	result        TEXT,
+	source        TEXT NOT NULL DEFAULT ''
+)`
+
+// sqlAddSource adds the source column to tables created before it existed.
+const sqlAddSource = `ALTER TABLE jobs ADD COLUMN source TEXT NOT NULL DEFAULT ''`
+
+const (
+	sqlMetaColumns = `id, filename, size_bytes, saved_to, received, format, anomaly_count, owner, request_id, status, progress, error, source`
+	sqlColumns     = sqlMetaColumns + `, result`
+)
+
+// SQLStore keeps jobs in a SQLite or Postgres table, so several instances
+// can share one results database. Results are stored as JSON and turned back
+// into values by DecodeResult; without it they are returned as
+// json.RawMessage.
+type SQLStore struct {
+	db           *sql.DB
+	dialect      string
+	DecodeResult func(json.RawMessage) (any, error)
+}
+
+// OpenSQL opens the database and creates the jobs table if needed. The driver
+// for dialect must be linked in; see the sqlite and postgres build tags of
+// cmd/api.
+func OpenSQL(dialect, dsn string) (*SQLStore, error) {
+	if dialect != DialectSQLite && dialect != DialectPostgres {
+		return nil, fmt.Errorf("unknown SQL dialect %q", dialect)
+	}
+	if !slices.Contains(sql.Drivers(), dialect) {
+		tag := "sqlite"
+		if dialect == DialectPostgres {
+			tag = "postgres"
+		}
+		return nil, fmt.Errorf("SQL driver %q is not built in; build with -tags %s", dialect, tag)
+	}
+	db, err := sql.Open(dialect, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if dialect == DialectSQLite {
+		// One writer at a time avoids SQLITE_BUSY under concurrent saves.
+		db.SetMaxOpenConns(1)
+	}
+	if _, err := db.Exec(sqlSchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("create jobs table: %w", err)
+	}
+	if _, err := db.Exec(`SELECT source FROM jobs WHERE 1 = 0`); err != nil {
+		if _, err := db.Exec(sqlAddSource); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("add source column: %w", err)
+		}
+	}
+	return &SQLStore{db: db, dialect: dialect}, nil
+}
+
+// Open returns the store described by spec: empty or "memory" for a
+// MemStore, "sqlite:<path>" for SQLite, or a postgres:// or postgresql://
+// URL for Postgres.
+func Open(spec string) (Store, error) {
+	switch {
+	case spec == "" || spec == "memory":
+		return NewMemStore(200), nil
+	case strings.HasPrefix(spec, "sqlite:"):
+		return OpenSQL(DialectSQLite, strings.TrimPrefix(spec, "sqlite:"))
+	case strings.HasPrefix(spec, "postgres://"), strings.HasPrefix(spec, "postgresql://"):
+		return OpenSQL(DialectPostgres, spec)
+	}
+	return nil, errors.New("job store must be memory, sqlite:<path> or a postgres:// URL")
+}
+
+func (s *SQLStore) Close() error { return s.db.Close() }
+
+// rebind turns ? placeholders into $n for Postgres.
+func (s *SQLStore) rebind(q string) string {
+	if s.dialect != DialectPostgres {
+		return q
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range q {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// SaveJob never evicts: jobs stay until they are deleted.
+func (s *SQLStore) SaveJob(j Job) ([]Job, error) {
+	var result sql.NullString
+	if j.Result != nil {
+		data, err := json.Marshal(j.Result)
+		if err != nil {
+			return nil, err
+		}
+		result = sql.NullString{String: string(data), Valid: true}
+	}
+	_, err := s.db.Exec(s.rebind(`INSERT INTO jobs (`+sqlColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			filename = excluded.filename, size_bytes = excluded.size_bytes,
+			saved_to = excluded.saved_to, received = excluded.received,
+			format = excluded.format, anomaly_count = excluded.anomaly_count,
+			owner = excluded.owner, request_id = excluded.request_id,
+			status = excluded.status, progress = excluded.progress,
+			error = excluded.error, source = excluded.source,
+			result = excluded.result`),
+		j.ID, j.Filename, j.SizeBytes, j.SavedTo, j.Received.UnixNano(), j.Format, j.AnomalyCount,
+		j.Owner, j.RequestID, j.Status, j.Progress, j.Error, j.Source, result)
+	return nil, err
+}
+
+func (s *SQLStore) GetJob(id string) (Job, error) {
+	row := s.db.QueryRow(s.rebind(`SELECT `+sqlColumns+` FROM jobs WHERE id = ?`), id)
+	j, err := s.scan(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Job{}, ErrNotFound
+	}
+	return j, err
+}
+
+// ListJobs returns all jobs without their results, newest first.
+func (s *SQLStore) ListJobs() ([]Job, error) {
+	rows, err := s.db.Query(`SELECT ` + sqlMetaColumns + ` FROM jobs ORDER BY received DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Job
+	for rows.Next() {
+		var j Job
+		var received int64
+		err := rows.Scan(&j.ID, &j.Filename, &j.SizeBytes, &j.SavedTo, &received, &j.Format, &j.AnomalyCount,
+			&j.Owner, &j.RequestID, &j.Status, &j.Progress, &j.Error, &j.Source)
+		if err != nil {
+			return nil, err
+		}
+		j.Received = time.Unix(0, received).UTC()
+		out = append(out, j)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLStore) DeleteJob(id string) error {
+	res, err := s.db.Exec(s.rebind(`DELETE FROM jobs WHERE id = ?`), id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLStore) scan(row interface{ Scan(...any) error }) (Job, error) {
+	var (
+		j        Job
+		received int64
+		result   sql.NullString
+	)
+	err := row.Scan(&j.ID, &j.Filename, &j.SizeBytes, &j.SavedTo, &received, &j.Format, &j.AnomalyCount,
+		&j.Owner, &j.RequestID, &j.Status, &j.Progress, &j.Error, &j.Source, &result)
+	if err != nil {
+		return Job{}, err
+	}
+	j.Received = time.Unix(0, received).UTC()
+	if result.Valid {
+		raw := json.RawMessage(result.String)
+		j.Result = raw
+		if s.DecodeResult != nil {
+			if j.Result, err = s.DecodeResult(raw); err != nil {
+				return Job{}, fmt.Errorf("job %s: decode result: %w", j.ID, err)
+			}
+		}
+	}
+	return j, nil
+}
@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrNotFound = errors.New("job not found")
+
+// Job states. Uploads start queued; a job only has a Result once done.
+const (
+	StatusQueued   = "queued"
+	StatusRunning  = "running"
+	StatusDone     = "done"
+	StatusFailed   = "failed"
+	StatusCanceled = "canceled"
+)
+
+// Job is the stored record of one analysis. Result holds the payload exactly
+// as it was returned to the uploader.
+type Job struct {
+	ID           string    `json:"id"`
+	Filename     string    `json:"filename"`
+	SizeBytes    int64     `json:"sizeBytes"`
+	SavedTo      string    `json:"savedTo,omitempty"`
+	Received     time.Time `json:"received"`
+	Format       string    `json:"format,omitempty"`
+	AnomalyCount int       `json:"anomalyCount"`
+	Owner        string    `json:"owner,omitempty"`
+	RequestID    string    `json:"requestId,omitempty"`
+	Source       string    `json:"source,omitempty"` // the stream the upload belongs to, if named
+	Status       string    `json:"status"`
+	Progress     int       `json:"progress"` // percent
+	Error        string    `json:"error,omitempty"`
+	Result       any       `json:"-"`
+}
+
+// Store persists jobs. MemStore keeps them in process; SQLStore shares them
+// between instances through a database. SaveJob returns the jobs it dropped
+// to make room, whose uploads are the caller's to remove.
+type Store interface {
+	SaveJob(j Job) (evicted []Job, err error)
+	GetJob(id string) (Job, error)
+	ListJobs() ([]Job, error) // without Result; use GetJob for it
+	DeleteJob(id string) error
+}
+
+// MemStore keeps the most recent jobs in memory. Once more than max are held
+// it drops the oldest finished job, failed or canceled ones included; queued
+// and running jobs are never dropped.
+type MemStore struct {
+	mu   sync.RWMutex
+	max  int
+	jobs map[string]Job
+}
+
+func NewMemStore(max int) *MemStore {
+	return &MemStore{max: max, jobs: make(map[string]Job)}
+}
+
+// Default is the process-wide job store.
+var Default Store = NewMemStore(200)
+
+func (s *MemStore) SaveJob(j Job) ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[j.ID] = j
+	if s.max <= 0 || len(s.jobs) <= s.max {
+		return nil, nil
+	}
+	var evicted Job
+	for _, o := range s.jobs {
+		if o.Status == StatusQueued || o.Status == StatusRunning {
+			continue
+		}
+		if evicted.ID == "" || o.Received.Before(evicted.Received) {
+			evicted = o
+		}
+	}
+	if evicted.ID == "" {
+		return nil, nil
+	}
+	delete(s.jobs, evicted.ID)
+	evicted.Result = nil
+	return []Job{evicted}, nil
+}
+
+func (s *MemStore) GetJob(id string) (Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return j, nil
+}
+
+// ListJobs returns all jobs without their results, newest first.
+func (s *MemStore) ListJobs() ([]Job, error) {
+	s.mu.RLock()
+	out := make([]Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		j.Result = nil
+		out = append(out, j)
+	}
+	s.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Received.After(out[j].Received) })
+	return out, nil
+}
+
+func (s *MemStore) DeleteJob(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.jobs, id)
+	return nil
+}
@@ -0,0 +1,170 @@
+package jobs
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store { return NewMemStore(0) },
+		"sqlite": func(t *testing.T) Store {
+			s, err := Open("sqlite:" + filepath.Join(t.TempDir(), "jobs.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { _ = s.(*SQLStore).Close() })
+			return s
+		},
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) { testStore(t, open(t)) })
+	}
+}
+
+func testStore(t *testing.T, s Store) {
+	base := time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)
+	older := Job{ID: "a", Filename: "a.log", SizeBytes: 10, SavedTo: "/tmp/a.log", Received: base, Status: StatusQueued}
+	newer := Job{ID: "b", Filename: "b.log", Received: base.Add(time.Hour), Status: StatusDone, AnomalyCount: 2,
+		Owner: "alice", Format: "nginx", Progress: 100, Source: "edge", Result: map[string]any{"jobId": "b"}}
+	for _, j := range []Job{older, newer} {
+		if _, err := s.SaveJob(j); err != nil {
+			t.Fatalf("SaveJob(%s): %v", j.ID, err)
+		}
+	}
+
+	got, err := s.GetJob("b")
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	if got.Owner != "alice" || got.Source != "edge" || got.AnomalyCount != 2 || got.Status != StatusDone || !got.Received.Equal(newer.Received) {
+		t.Errorf("GetJob(b) = %+v", got)
+	}
+	if got.Result == nil {
+		t.Error("GetJob(b) lost its result")
+	}
+	if raw, ok := got.Result.(json.RawMessage); ok && string(raw) != `{"jobId":"b"}` {
+		t.Errorf("GetJob(b) result = %s", raw)
+	}
+
+	// Saving again updates the job in place.
+	older.Status, older.Progress = StatusRunning, 40
+	if _, err := s.SaveJob(older); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetJob("a"); got.Status != StatusRunning || got.Progress != 40 {
+		t.Errorf("after update GetJob(a) = %+v", got)
+	}
+
+	list, err := s.ListJobs()
+	if err != nil {
+		t.Fatalf("ListJobs: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != "b" || list[1].ID != "a" {
+		t.Fatalf("ListJobs order = %v, want b, a", list)
+	}
+	if list[0].Result != nil {
+		t.Error("ListJobs returned results")
+	}
+	if list[0].Source != "edge" || list[1].Source != "" {
+		t.Errorf("ListJobs sources = %q, %q; want edge and none", list[0].Source, list[1].Source)
+	}
+	if list[1].SavedTo != "/tmp/a.log" || list[1].SizeBytes != 10 {
+		t.Errorf("ListJobs()[1] = %+v", list[1])
+	}
+
+	if err := s.DeleteJob("a"); err != nil {
+		t.Fatalf("DeleteJob: %v", err)
+	}
+	if _, err := s.GetJob("a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetJob after delete: %v, want ErrNotFound", err)
+	}
+	if err := s.DeleteJob("a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second DeleteJob: %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemStoreEvictsFinishedJobs(t *testing.T) {
+	base := time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)
+	s := NewMemStore(2)
+	var evicted []Job
+	for _, j := range []Job{
+		{ID: "running", Received: base, Status: StatusRunning},
+		{ID: "done", Received: base.Add(time.Minute), Status: StatusDone, SavedTo: "/tmp/done.log", Result: "x"},
+		{ID: "queued", Received: base.Add(2 * time.Minute), Status: StatusQueued},
+	} {
+		ev, err := s.SaveJob(j)
+		if err != nil {
+			t.Fatal(err)
+		}
+		evicted = append(evicted, ev...)
+	}
+	if _, err := s.GetJob("done"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("finished job kept: %v", err)
+	}
+	if len(evicted) != 1 || evicted[0].ID != "done" || evicted[0].SavedTo != "/tmp/done.log" || evicted[0].Result != nil {
+		t.Errorf("SaveJob evicted %+v, want the done job without its result", evicted)
+	}
+	for _, id := range []string{"running", "queued"} {
+		if _, err := s.GetJob(id); err != nil {
+			t.Errorf("active job %s evicted", id)
+		}
+	}
+
+	// With only active jobs left the store grows past max.
+	if _, err := s.SaveJob(Job{ID: "queued2", Received: base.Add(3 * time.Minute), Status: StatusQueued}); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := s.ListJobs(); len(list) != 3 {
+		t.Errorf("ListJobs holds %d jobs, want 3", len(list))
+	}
+}
+
+// TestOpenSQLAddsSource opens a database made before jobs had a source.
+func TestOpenSQLAddsSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE jobs (
+	id TEXT PRIMARY KEY, filename TEXT NOT NULL, size_bytes INTEGER NOT NULL, saved_to TEXT NOT NULL,
+	received INTEGER NOT NULL, format TEXT NOT NULL, anomaly_count INTEGER NOT NULL, owner TEXT NOT NULL,
+	request_id TEXT NOT NULL, status TEXT NOT NULL, progress INTEGER NOT NULL, error TEXT NOT NULL, result TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO jobs VALUES ('old', 'old.log', 1, '', 0, '', 0, '', '', 'done', 100, '', NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+
+	s, err := Open("sqlite:" + path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = s.(*SQLStore).Close() })
+	if got, err := s.GetJob("old"); err != nil || got.Source != "" {
+		t.Errorf("GetJob(old) = %+v, %v", got, err)
+	}
+	if _, err := s.SaveJob(Job{ID: "new", Source: "edge", Status: StatusQueued}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetJob("new"); got.Source != "edge" {
+		t.Errorf("GetJob(new).Source = %q, want edge", got.Source)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	if _, err := Open("mysql://x"); err == nil {
+		t.Error("Open accepted an unknown store")
+	}
+	if s, err := Open(""); err != nil || s == nil {
+		t.Errorf("Open(\"\") = %v, %v", s, err)
+	}
+}
@@ -0,0 +1,113 @@
+// Package live pushes server events (job state changes, new anomalies) to
+// WebSocket clients for live dashboards.
+package live
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	clientBuffer = 64
+	pingEvery    = 30 * time.Second
+)
+
+// Message is one event sent to every connected client.
+type Message struct {
+	Type    string    `json:"type"` // job, anomaly or resolved
+	JobID   string    `json:"jobId"`
+	Sent    time.Time `json:"sent"`
+	Job     any       `json:"job,omitempty"`
+	Anomaly any       `json:"anomaly,omitempty"`
+}
+
+type client struct {
+	send chan []byte
+}
+
+var (
+	mu      sync.Mutex
+	clients = make(map[*client]bool)
+)
+
+// Publish sends m to every connected client. A client whose buffer is full is
+// disconnected rather than allowed to hold up the others.
+func Publish(m Message) {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(clients) == 0 {
+		return
+	}
+	if m.Sent.IsZero() {
+		m.Sent = time.Now().UTC()
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		log.Printf("live: encode %s message: %v", m.Type, err)
+		return
+	}
+	for c := range clients {
+		select {
+		case c.send <- data:
+		default:
+			delete(clients, c)
+			close(c.send)
+		}
+	}
+}
+
+// Handler serves the /ws endpoint. Browsers send an Origin header with the
+// upgrade; it must match allowedOrigin, since the CORS middleware does not
+// apply to WebSockets.
+func Handler(allowedOrigin string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o := r.Header.Get("Origin"); o != "" && o != allowedOrigin {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		ws, err := accept(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer ws.Close()
+
+		c := &client{send: make(chan []byte, clientBuffer)}
+		mu.Lock()
+		clients[c] = true
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			if clients[c] {
+				delete(clients, c)
+				close(c.send)
+			}
+			mu.Unlock()
+		}()
+
+		done := make(chan struct{})
+		go func() {
+			ws.readLoop()
+			close(done)
+		}()
+		ping := time.NewTicker(pingEvery)
+		defer ping.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case data, ok := <-c.send:
+				if !ok || ws.write(opText, data) != nil {
+					return
+				}
+			case <-ping.C:
+				if ws.write(opPing, nil) != nil {
+					return
+				}
+			}
+		}
+	}
+}
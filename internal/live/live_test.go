@@ -0,0 +1,84 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// waitClients waits until n clients are connected.
+func waitClients(t *testing.T, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		mu.Lock()
+		got := len(clients)
+		mu.Unlock()
+		if got == n {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %d client(s)", n)
+}
+
+// TestHandlerInterop talks to the hand-written server side with an
+// independent client implementation.
+func TestHandlerInterop(t *testing.T) {
+	srv := httptest.NewServer(Handler("http://app.example"))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ws, _, err := websocket.Dial(ctx, url, &websocket.DialOptions{HTTPHeader: http.Header{"Origin": {"http://app.example"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.CloseNow()
+	ws.SetReadLimit(1 << 20)
+	waitClients(t, 1)
+
+	// Payloads needing 7-bit, 16-bit and 64-bit frame lengths.
+	sizes := []int{10, 300, 70000}
+	for _, n := range sizes {
+		Publish(Message{Type: "job", JobID: strings.Repeat("j", n)})
+	}
+	for _, n := range sizes {
+		typ, data, err := ws.Read(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m Message
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatal(err)
+		}
+		if typ != websocket.MessageText || m.Type != "job" || len(m.JobID) != n || m.Sent.IsZero() {
+			t.Errorf("got a %v message of type %q with a %d-byte job ID, want a text job message with %d bytes", typ, m.Type, len(m.JobID), n)
+		}
+	}
+
+	ctx = ws.CloseRead(ctx)
+	if err := ws.Ping(ctx); err != nil {
+		t.Errorf("ping: %v", err)
+	}
+	if err := ws.Close(websocket.StatusNormalClosure, ""); err != nil {
+		t.Errorf("close: %v", err)
+	}
+	waitClients(t, 0)
+}
+
+func TestHandlerRejectsOrigin(t *testing.T) {
+	srv := httptest.NewServer(Handler("http://app.example"))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, resp, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"), &websocket.DialOptions{HTTPHeader: http.Header{"Origin": {"http://evil.example"}}})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("dial from another origin: %v, %v; want 403", resp, err)
+	}
+}
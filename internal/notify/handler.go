@@ -0,0 +1,37 @@
+package notify
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+)
+
+// Deliveries serves GET /api/notify/deliveries?status=pending|dead.
+func Deliveries(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" && status != StatusPending && status != StatusDead {
+		http.Error(w, "status must be pending or dead", http.StatusBadRequest)
+		return
+	}
+	httputil.JSON(w, http.StatusOK, map[string]any{"deliveries": Default.List(status)})
+}
+
+// Redeliver serves POST /api/notify/deliveries/{id}/redeliver.
+func Redeliver(w http.ResponseWriter, r *http.Request) {
+	d, err := Default.Redeliver(r.PathValue("id"))
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	httputil.JSON(w, http.StatusAccepted, d)
+}
+
+// Discard serves DELETE /api/notify/deliveries/{id}.
+func Discard(w http.ResponseWriter, r *http.Request) {
+	if err := Default.Discard(r.PathValue("id")); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
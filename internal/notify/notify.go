@@ -0,0 +1,154 @@
+package notify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/integrations"
+)
+
+// Message is one alert fanned out to every configured webhook.
+type Message struct {
+	Event string    `json:"event"`
+	JobID string    `json:"jobId,omitempty"`
+	Title string    `json:"title"`
+	Text  string    `json:"text"`
+	URL   string    `json:"url,omitempty"`
+	Sent  time.Time `json:"sent"`
+	Data  any       `json:"data,omitempty"`
+}
+
+type Config struct {
+	Webhooks      []string
+	MinConfidence float64 // anomalies below this do not trigger an alert
+	QueueFile     string
+	BaseURL       string        // external API base used for report links
+	LinkTTL       time.Duration // lifetime of report links; 0 sends the job ID only
+	Secret        []byte        // signs every delivery when set
+}
+
+var (
+	config  = Config{MinConfidence: 0.8}
+	Default = NewQueue()
+)
+
+// EnvConfig reads NOTIFY_WEBHOOKS (comma-separated URLs),
+// NOTIFY_MIN_CONFIDENCE (default 0.8), NOTIFY_QUEUE_FILE, NOTIFY_LINK_TTL
+// (off by default, at most 720h) and WEBHOOK_SECRET.
+func EnvConfig() (Config, error) {
+	c := Config{MinConfidence: 0.8, QueueFile: os.Getenv("NOTIFY_QUEUE_FILE"), Secret: []byte(os.Getenv("WEBHOOK_SECRET"))}
+	for _, u := range strings.Split(os.Getenv("NOTIFY_WEBHOOKS"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			if _, err := url.ParseRequestURI(u); err != nil {
+				return c, err
+			}
+			c.Webhooks = append(c.Webhooks, u)
+		}
+	}
+	if v := os.Getenv("NOTIFY_MIN_CONFIDENCE"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return c, err
+		}
+		c.MinConfidence = f
+	}
+	if v := os.Getenv("NOTIFY_LINK_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 || d > maxLinkTTL {
+			return c, fmt.Errorf("NOTIFY_LINK_TTL must be a duration up to 720h, got %q", v)
+		}
+		c.LinkTTL = d
+	}
+	return c, nil
+}
+
+// Configure applies c and restores any persisted deliveries.
+func Configure(c Config) error {
+	config = c
+	names := make([]string, 0, len(c.Webhooks))
+	for _, w := range c.Webhooks {
+		names = append(names, webhookHealth(w).Status().Name)
+	}
+	integrations.Forget("webhook", names...)
+	Default.File = c.QueueFile
+	Default.Secret = c.Secret
+	return Default.Load()
+}
+
+func MinConfidence() float64 { return config.MinConfidence }
+func BaseURL() string        { return config.BaseURL }
+
+// maxLinkTTL matches the longest guest link POST /api/jobs/{id}/share issues.
+const maxLinkTTL = 30 * 24 * time.Hour
+
+// LinkTTL is how long report links in alerts and callbacks stay valid. Links
+// are left out unless both it and BaseURL are set.
+func LinkTTL() time.Duration { return config.LinkTTL }
+
+// Enabled reports whether any webhook is configured.
+func Enabled() bool { return len(config.Webhooks) > 0 }
+
+// Signing reports whether deliveries carry a signature.
+func Signing() bool { return len(config.Secret) > 0 }
+
+// Publish queues m for every configured webhook.
+func Publish(m Message) {
+	if m.Sent.IsZero() {
+		m.Sent = time.Now().UTC()
+	}
+	for _, target := range config.Webhooks {
+		payload, err := encodeFor(target, m)
+		if err != nil {
+			log.Printf("notify: encode message for %s: %v", target, err)
+			continue
+		}
+		Default.Enqueue(target, payload)
+	}
+}
+
+// PublishTo queues m for an upload's callback URL. The URL comes from the
+// uploader, so it is only delivered to public addresses.
+func PublishTo(target string, m Message) {
+	if m.Sent.IsZero() {
+		m.Sent = time.Now().UTC()
+	}
+	payload, err := encodeFor(target, m)
+	if err != nil {
+		log.Printf("notify: encode message: %v", err)
+		return
+	}
+	Default.EnqueueCallback(target, payload)
+}
+
+// webhookHealth returns the status tracker for target. Webhook URLs are
+// secrets (Slack's path is the credential), so targets are shown by host with
+// a short hash to tell several hooks on one host apart.
+func webhookHealth(target string) *integrations.Tracker {
+	sum := sha256.Sum256([]byte(target))
+	host := target
+	if u, err := url.Parse(target); err == nil {
+		host = u.Host
+	}
+	return integrations.Track("webhook", host+"#"+hex.EncodeToString(sum[:4]), host)
+}
+
+// encodeFor shapes m for the target: Slack incoming webhooks get a text
+// message, anything else the Message as JSON.
+func encodeFor(target string, m Message) ([]byte, error) {
+	if u, err := url.Parse(target); err == nil && u.Host == "hooks.slack.com" {
+		text := "*" + m.Title + "*\n" + m.Text
+		if m.URL != "" {
+			text += "\n<" + m.URL + "|Open report>"
+		}
+		return json.Marshal(map[string]string{"text": text})
+	}
+	return json.Marshal(m)
+}
@@ -0,0 +1,314 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/integrations"
+)
+
+const (
+	StatusPending = "pending"
+	StatusDead    = "dead"
+)
+
+var ErrNotFound = errors.New("delivery not found")
+
+// Delivery is one message to one target, retried until it succeeds or runs
+// out of attempts and moves to the dead-letter list.
+type Delivery struct {
+	ID          string          `json:"id"`
+	Target      string          `json:"target"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	Created     time.Time       `json:"created"`
+	NextAttempt time.Time       `json:"nextAttempt,omitempty"`
+	LastError   string          `json:"lastError,omitempty"`
+	// Callback marks a target supplied with an upload rather than
+	// configured by the operator; it is sent with CallbackClient.
+	Callback bool `json:"callback,omitempty"`
+}
+
+// Queue holds pending and dead deliveries. When File is set the queue is
+// written there after every change and reloaded by Load, so deliveries survive
+// restarts.
+type Queue struct {
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	File        string
+	Client      *http.Client
+	// CallbackClient sends Callback deliveries. Their targets come from
+	// users, so it should only reach public addresses.
+	CallbackClient *http.Client
+	// Secret, when set, signs each payload: X-Tenexlog-Signature carries
+	// sha256= and the hex HMAC-SHA256 of the body.
+	Secret []byte
+
+	mu         sync.Mutex
+	deliveries map[string]*Delivery
+	wake       chan struct{}
+}
+
+func NewQueue() *Queue {
+	return &Queue{
+		MaxAttempts:    8,
+		BaseBackoff:    2 * time.Second,
+		MaxBackoff:     10 * time.Minute,
+		Client:         httputil.OutboundClient(10 * time.Second),
+		CallbackClient: httputil.PublicClient(10 * time.Second),
+		deliveries:     make(map[string]*Delivery),
+		wake:           make(chan struct{}, 1),
+	}
+}
+
+// Load restores deliveries saved in q.File. A missing file is not an error.
+func (q *Queue) Load() error {
+	if q.File == "" {
+		return nil
+	}
+	data, err := os.ReadFile(q.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var ds []*Delivery
+	if err := json.Unmarshal(data, &ds); err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, d := range ds {
+		q.deliveries[d.ID] = d
+	}
+	return nil
+}
+
+// saveLocked persists the queue; q.mu must be held.
+func (q *Queue) saveLocked() {
+	if q.File == "" {
+		return
+	}
+	ds := make([]*Delivery, 0, len(q.deliveries))
+	for _, d := range q.deliveries {
+		ds = append(ds, d)
+	}
+	data, err := json.Marshal(ds)
+	if err != nil {
+		log.Printf("notify: encode queue: %v", err)
+		return
+	}
+	tmp := q.File + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Printf("notify: save queue: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, q.File); err != nil {
+		log.Printf("notify: save queue: %v", err)
+	}
+}
+
+func (q *Queue) Enqueue(target string, payload []byte) Delivery {
+	return q.enqueue(target, payload, false)
+}
+
+// EnqueueCallback is Enqueue for a target supplied by a user.
+func (q *Queue) EnqueueCallback(target string, payload []byte) Delivery {
+	return q.enqueue(target, payload, true)
+}
+
+func (q *Queue) enqueue(target string, payload []byte, callback bool) Delivery {
+	now := time.Now().UTC()
+	d := &Delivery{
+		ID:          httputil.NewID(),
+		Target:      target,
+		Payload:     payload,
+		Status:      StatusPending,
+		Created:     now,
+		NextAttempt: now,
+		Callback:    callback,
+	}
+	q.mu.Lock()
+	q.deliveries[d.ID] = d
+	q.saveLocked()
+	cp := *d
+	q.mu.Unlock()
+	q.poke()
+	return cp
+}
+
+func (q *Queue) poke() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers due messages until ctx is cancelled.
+func (q *Queue) Run(ctx context.Context) {
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		q.deliverDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		case <-q.wake:
+		}
+	}
+}
+
+func (q *Queue) deliverDue(ctx context.Context) {
+	now := time.Now()
+	q.mu.Lock()
+	var due []Delivery
+	for _, d := range q.deliveries {
+		if d.Status == StatusPending && !d.NextAttempt.After(now) {
+			due = append(due, *d)
+		}
+	}
+	q.mu.Unlock()
+	sort.Slice(due, func(i, j int) bool { return due[i].Created.Before(due[j].Created) })
+
+	for _, d := range due {
+		health := webhookHealth(d.Target)
+		err := health.Do(func() error { return q.send(ctx, d) })
+		if errors.Is(err, integrations.ErrOpen) {
+			q.hold(d.ID, health.RetryAt())
+			continue
+		}
+		q.mu.Lock()
+		cur, ok := q.deliveries[d.ID]
+		if !ok {
+			q.mu.Unlock()
+			continue
+		}
+		cur.Attempts++
+		switch {
+		case err == nil:
+			delete(q.deliveries, d.ID)
+		case cur.Attempts >= q.MaxAttempts:
+			cur.Status, cur.LastError, cur.NextAttempt = StatusDead, err.Error(), time.Time{}
+			log.Printf("notify: delivery %s to %s dead after %d attempts: %v", cur.ID, cur.Target, cur.Attempts, err)
+		default:
+			cur.LastError = err.Error()
+			cur.NextAttempt = time.Now().UTC().Add(q.backoff(cur.Attempts))
+		}
+		q.saveLocked()
+		q.mu.Unlock()
+	}
+}
+
+// hold postpones a delivery whose target's breaker is open without spending
+// an attempt.
+func (q *Queue) hold(id string, until time.Time) {
+	if until.IsZero() {
+		until = time.Now().Add(time.Second)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if cur, ok := q.deliveries[id]; ok && cur.Status == StatusPending {
+		cur.NextAttempt = until.UTC()
+		q.saveLocked()
+	}
+}
+
+// backoff doubles from BaseBackoff per attempt, capped at MaxBackoff, with up
+// to 20% jitter so retries to a recovering endpoint spread out.
+func (q *Queue) backoff(attempts int) time.Duration {
+	d := q.BaseBackoff << min(attempts-1, 20)
+	if d <= 0 || d > q.MaxBackoff {
+		d = q.MaxBackoff
+	}
+	return d + time.Duration(rand.Int64N(int64(d)/5+1))
+}
+
+func (q *Queue) send(ctx context.Context, d Delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Target, bytes.NewReader(d.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(q.Secret) > 0 {
+		mac := hmac.New(sha256.New, q.Secret)
+		mac.Write(d.Payload)
+		req.Header.Set("X-Tenexlog-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	client := q.Client
+	if d.Callback {
+		client = q.CallbackClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return httputil.RedactError(err, true)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("target returned %s", resp.Status)
+	}
+	return nil
+}
+
+// List returns deliveries with the given status (all when empty), oldest
+// first.
+func (q *Queue) List(status string) []Delivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]Delivery, 0)
+	for _, d := range q.deliveries {
+		if status == "" || d.Status == status {
+			out = append(out, *d)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Created.Before(out[j].Created) })
+	return out
+}
+
+// Redeliver moves a dead delivery back to pending with a fresh attempt
+// budget.
+func (q *Queue) Redeliver(id string) (Delivery, error) {
+	q.mu.Lock()
+	d, ok := q.deliveries[id]
+	if !ok {
+		q.mu.Unlock()
+		return Delivery{}, ErrNotFound
+	}
+	d.Status, d.Attempts, d.NextAttempt = StatusPending, 0, time.Now().UTC()
+	q.saveLocked()
+	cp := *d
+	q.mu.Unlock()
+	q.poke()
+	return cp, nil
+}
+
+// Discard drops a delivery.
+func (q *Queue) Discard(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.deliveries[id]; !ok {
+		return ErrNotFound
+	}
+	delete(q.deliveries, id)
+	q.saveLocked()
+	return nil
+}
@@ -0,0 +1,362 @@
+// Package parquet writes flat Apache Parquet files: optional columns of a few
+// primitive kinds, PLAIN-encoded and uncompressed, one data page per column
+// chunk. It covers what the export needs and nothing more.
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Kind is a column's value type.
+type Kind int
+
+const (
+	String    Kind = iota // string, stored as UTF-8 BYTE_ARRAY
+	Int32                 // int
+	Int64                 // int64
+	Double                // float64
+	Bool                  // bool
+	Timestamp             // time.Time, stored as INT64 microseconds since the epoch (UTC)
+)
+
+// Column names one column of a file. Every column is optional: a nil value
+// is written as null.
+type Column struct {
+	Name string
+	Kind Kind
+}
+
+// DefaultRowGroupRows is the number of rows buffered per row group when
+// NewWriter is given 0.
+const DefaultRowGroupRows = 50_000
+
+// Physical types, converted types, encodings and page types from
+// parquet.thrift.
+const (
+	typeBoolean   = 0
+	typeInt32     = 1
+	typeInt64     = 2
+	typeDouble    = 5
+	typeByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+
+	repetitionOptional = 1
+	encodingPlain      = 0
+	encodingRLE        = 3
+	codecUncompressed  = 0
+	pageData           = 0
+)
+
+var magic = []byte("PAR1")
+
+var errClosed = errors.New("parquet: writer is closed")
+
+// Writer writes rows to an underlying io.Writer, flushing a row group every
+// RowGroupRows rows. Close writes the last row group and the footer; the
+// output is not a valid file until then.
+type Writer struct {
+	w         io.Writer
+	cols      []Column
+	groupRows int
+
+	off    int64
+	rows   int64
+	groups []rowGroup
+	buf    []columnBuf
+	n      int // rows buffered in buf
+	closed bool
+	err    error
+}
+
+type columnBuf struct {
+	defined []bool
+	values  []byte
+	bools   []bool
+}
+
+type rowGroup struct {
+	rows   int64
+	size   int64
+	chunks []chunk
+}
+
+type chunk struct {
+	offset int64
+	size   int64
+	values int64
+}
+
+// NewWriter returns a Writer for cols that flushes a row group every
+// groupRows rows (DefaultRowGroupRows when 0).
+func NewWriter(w io.Writer, cols []Column, groupRows int) *Writer {
+	if groupRows <= 0 {
+		groupRows = DefaultRowGroupRows
+	}
+	return &Writer{w: w, cols: cols, groupRows: groupRows, buf: make([]columnBuf, len(cols))}
+}
+
+// Write appends one row with a value, or nil, per column in order.
+func (w *Writer) Write(row []any) error {
+	if w.closed {
+		return errClosed
+	}
+	if w.err != nil {
+		return w.err
+	}
+	if len(row) != len(w.cols) {
+		return fmt.Errorf("parquet: row has %d values for %d columns", len(row), len(w.cols))
+	}
+	for i, v := range row {
+		if err := w.buf[i].add(w.cols[i], v); err != nil {
+			return err
+		}
+	}
+	if w.n++; w.n >= w.groupRows {
+		w.err = w.flush()
+	}
+	return w.err
+}
+
+// Close flushes buffered rows and writes the footer. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+	if w.err != nil {
+		return w.err
+	}
+	if w.off == 0 {
+		if w.err = w.write(magic); w.err != nil {
+			return w.err
+		}
+	}
+	if w.n > 0 {
+		if w.err = w.flush(); w.err != nil {
+			return w.err
+		}
+	}
+	footer := w.footer()
+	var tail [4]byte
+	binary.LittleEndian.PutUint32(tail[:], uint32(len(footer)))
+	for _, b := range [][]byte{footer, tail[:], magic} {
+		if w.err = w.write(b); w.err != nil {
+			break
+		}
+	}
+	return w.err
+}
+
+func (w *Writer) write(b []byte) error {
+	n, err := w.w.Write(b)
+	w.off += int64(n)
+	return err
+}
+
+func (c *columnBuf) add(col Column, v any) error {
+	if v == nil {
+		c.defined = append(c.defined, false)
+		return nil
+	}
+	switch col.Kind {
+	case String:
+		s, ok := v.(string)
+		if !ok {
+			return typeError(col, v)
+		}
+		c.values = binary.LittleEndian.AppendUint32(c.values, uint32(len(s)))
+		c.values = append(c.values, s...)
+	case Int32:
+		n, ok := v.(int)
+		if !ok {
+			return typeError(col, v)
+		}
+		c.values = binary.LittleEndian.AppendUint32(c.values, uint32(int32(n)))
+	case Int64:
+		n, ok := v.(int64)
+		if !ok {
+			return typeError(col, v)
+		}
+		c.values = binary.LittleEndian.AppendUint64(c.values, uint64(n))
+	case Double:
+		f, ok := v.(float64)
+		if !ok {
+			return typeError(col, v)
+		}
+		c.values = binary.LittleEndian.AppendUint64(c.values, math.Float64bits(f))
+	case Bool:
+		b, ok := v.(bool)
+		if !ok {
+			return typeError(col, v)
+		}
+		c.bools = append(c.bools, b)
+	case Timestamp:
+		t, ok := v.(time.Time)
+		if !ok {
+			return typeError(col, v)
+		}
+		c.values = binary.LittleEndian.AppendUint64(c.values, uint64(t.UnixMicro()))
+	}
+	c.defined = append(c.defined, true)
+	return nil
+}
+
+func typeError(col Column, v any) error {
+	return fmt.Errorf("parquet: column %s: unexpected %T", col.Name, v)
+}
+
+// flush writes the buffered rows as one row group: a single data page per
+// column, definition levels first (RLE, bit width 1) and then the PLAIN
+// values of the non-null entries.
+func (w *Writer) flush() error {
+	if w.off == 0 {
+		if err := w.write(magic); err != nil {
+			return err
+		}
+	}
+	g := rowGroup{rows: int64(w.n)}
+	for i, col := range w.cols {
+		c := &w.buf[i]
+		levels := rleLevels(c.defined)
+		data := binary.LittleEndian.AppendUint32(nil, uint32(len(levels)))
+		data = append(data, levels...)
+		if col.Kind == Bool {
+			data = append(data, packBools(c.bools)...)
+		} else {
+			data = append(data, c.values...)
+		}
+
+		var h thrift
+		h.i32(1, pageData)
+		h.i32(2, int32(len(data)))
+		h.i32(3, int32(len(data)))
+		h.begin(5)
+		h.i32(1, int32(len(c.defined)))
+		h.i32(2, encodingPlain)
+		h.i32(3, encodingRLE)
+		h.i32(4, encodingRLE)
+		h.end()
+		h.stop()
+
+		ch := chunk{offset: w.off, size: int64(len(h.b) + len(data)), values: int64(len(c.defined))}
+		if err := w.write(h.b); err != nil {
+			return err
+		}
+		if err := w.write(data); err != nil {
+			return err
+		}
+		g.chunks = append(g.chunks, ch)
+		g.size += ch.size
+		*c = columnBuf{defined: c.defined[:0], values: c.values[:0], bools: c.bools[:0]}
+	}
+	w.groups = append(w.groups, g)
+	w.rows += g.rows
+	w.n = 0
+	return nil
+}
+
+// footer encodes the FileMetaData.
+func (w *Writer) footer() []byte {
+	var t thrift
+	t.i32(1, 1)
+	t.list(2, typeStruct, len(w.cols)+1)
+	t.item()
+	t.str(4, "schema")
+	t.i32(5, int32(len(w.cols)))
+	t.end()
+	for _, col := range w.cols {
+		t.item()
+		t.i32(1, physicalType(col.Kind))
+		t.i32(3, repetitionOptional)
+		t.str(4, col.Name)
+		switch col.Kind {
+		case String:
+			t.i32(6, convertedUTF8)
+		case Timestamp:
+			t.i32(6, convertedTimestampMicros)
+		}
+		t.end()
+	}
+	t.i64(3, w.rows)
+	t.list(4, typeStruct, len(w.groups))
+	for _, g := range w.groups {
+		t.item()
+		t.list(1, typeStruct, len(g.chunks))
+		for i, ch := range g.chunks {
+			t.item()
+			t.i64(2, ch.offset)
+			t.begin(3)
+			t.i32(1, physicalType(w.cols[i].Kind))
+			t.list(2, typeI32, 2)
+			t.varint(encodingPlain)
+			t.varint(encodingRLE)
+			t.list(3, typeBinary, 1)
+			t.bytes(w.cols[i].Name)
+			t.i32(4, codecUncompressed)
+			t.i64(5, ch.values)
+			t.i64(6, ch.size)
+			t.i64(7, ch.size)
+			t.i64(9, ch.offset)
+			t.end()
+			t.end()
+		}
+		t.i64(2, g.size)
+		t.i64(3, g.rows)
+		t.end()
+	}
+	t.str(6, "tenexlog")
+	t.stop()
+	return t.b
+}
+
+func physicalType(k Kind) int32 {
+	switch k {
+	case Int32:
+		return typeInt32
+	case Int64, Timestamp:
+		return typeInt64
+	case Double:
+		return typeDouble
+	case Bool:
+		return typeBoolean
+	}
+	return typeByteArray
+}
+
+// rleLevels encodes definition levels of bit width 1 as RLE runs.
+func rleLevels(defined []bool) []byte {
+	var out []byte
+	for i := 0; i < len(defined); {
+		j := i + 1
+		for j < len(defined) && defined[j] == defined[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		if defined[i] {
+			out = append(out, 1)
+		} else {
+			out = append(out, 0)
+		}
+		i = j
+	}
+	return out
+}
+
+// packBools bit-packs PLAIN booleans, least significant bit first.
+func packBools(bs []bool) []byte {
+	out := make([]byte, (len(bs)+7)/8)
+	for i, b := range bs {
+		if b {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+	return out
+}
@@ -0,0 +1,143 @@
+package parquet
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	ref "github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/deprecated"
+)
+
+var testColumns = []Column{
+	{"path", String},
+	{"status", Int32},
+	{"bytes", Int64},
+	{"ratio", Double},
+	{"bot", Bool},
+	{"ts", Timestamp},
+}
+
+// readBack opens a written file with an independent Parquet implementation
+// and returns its rows, with nulls as nil and timestamps as microseconds.
+func readBack(t *testing.T, b []byte) (*ref.File, [][]any) {
+	t.Helper()
+	f, err := ref.OpenFile(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("reference reader: %v", err)
+	}
+	r := ref.NewReader(f)
+	defer r.Close()
+	var rows [][]any
+	buf := make([]ref.Row, 3)
+	for {
+		n, err := r.ReadRows(buf)
+		for _, row := range buf[:n] {
+			vals := make([]any, len(row))
+			for i, v := range row {
+				switch {
+				case v.IsNull():
+				case v.Kind() == ref.ByteArray:
+					vals[i] = string(v.ByteArray())
+				case v.Kind() == ref.Int32:
+					vals[i] = v.Int32()
+				case v.Kind() == ref.Int64:
+					vals[i] = v.Int64()
+				case v.Kind() == ref.Double:
+					vals[i] = v.Double()
+				case v.Kind() == ref.Boolean:
+					vals[i] = v.Boolean()
+				default:
+					t.Fatalf("column %d: unexpected kind %v", i, v.Kind())
+				}
+			}
+			rows = append(rows, vals)
+		}
+		if errors.Is(err, io.EOF) {
+			return f, rows
+		}
+		if err != nil {
+			t.Fatalf("reference reader: %v", err)
+		}
+	}
+}
+
+func TestReferenceReader(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 13, 0, 0, 123456000, time.UTC)
+	rows := [][]any{
+		{"/", 200, int64(512), 0.5, false, ts},
+		{nil, nil, nil, nil, nil, nil},
+		{"/ünïcode?q=1", -1, int64(1) << 40, -2.25, true, ts.Add(time.Hour)},
+		{"", 0, int64(0), 0.0, true, nil},
+		{"/last", 404, nil, nil, false, ts.Add(-time.Hour)},
+	}
+	want := [][]any{
+		{"/", int32(200), int64(512), 0.5, false, ts.UnixMicro()},
+		{nil, nil, nil, nil, nil, nil},
+		{"/ünïcode?q=1", int32(-1), int64(1) << 40, -2.25, true, ts.Add(time.Hour).UnixMicro()},
+		{"", int32(0), int64(0), 0.0, true, nil},
+		{"/last", int32(404), nil, nil, false, ts.Add(-time.Hour).UnixMicro()},
+	}
+
+	tests := []struct {
+		name       string
+		groupRows  int
+		rows, want [][]any
+		wantGroups int
+	}{
+		{"one row group", 0, rows, want, 1},
+		{"a row group every two rows", 2, rows, want, 3},
+		{"no rows", 0, nil, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf, testColumns, tt.groupRows)
+			for _, row := range tt.rows {
+				if err := w.Write(row); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			f, got := readBack(t, buf.Bytes())
+			if f.NumRows() != int64(len(tt.rows)) || len(f.RowGroups()) != tt.wantGroups {
+				t.Errorf("file has %d rows in %d row groups, want %d in %d", f.NumRows(), len(f.RowGroups()), len(tt.rows), tt.wantGroups)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReferenceReaderSchema(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewWriter(&buf, testColumns, 0).Close(); err != nil {
+		t.Fatal(err)
+	}
+	f, _ := readBack(t, buf.Bytes())
+
+	wantTypes := []string{"BYTE_ARRAY", "INT32", "INT64", "DOUBLE", "BOOLEAN", "INT64"}
+	fields := f.Schema().Fields()
+	if len(fields) != len(testColumns) {
+		t.Fatalf("schema has %d fields, want %d", len(fields), len(testColumns))
+	}
+	for i, col := range testColumns {
+		fd := fields[i]
+		if fd.Name() != col.Name || !fd.Optional() || fd.Type().Kind().String() != wantTypes[i] {
+			t.Errorf("field %d = %s %v optional=%v, want optional %s %s", i, fd.Name(), fd.Type().Kind(), fd.Optional(), wantTypes[i], col.Name)
+		}
+	}
+	if ct := fields[0].Type().ConvertedType(); ct == nil || *ct != deprecated.UTF8 {
+		t.Errorf("path converted type = %v, want UTF8", ct)
+	}
+	if ct := fields[5].Type().ConvertedType(); ct == nil || *ct != deprecated.TimestampMicros {
+		t.Errorf("ts converted type = %v, want TIMESTAMP_MICROS", ct)
+	}
+}
@@ -0,0 +1,90 @@
+package parquet
+
+import "encoding/binary"
+
+// Thrift compact protocol type codes.
+const (
+	typeI32    = 5
+	typeI64    = 6
+	typeBinary = 8
+	typeList   = 9
+	typeStruct = 12
+)
+
+// thrift encodes the few Thrift compact-protocol shapes that page headers
+// and the file footer need. Fields must be written in increasing id order
+// within a struct.
+type thrift struct {
+	b    []byte
+	last int16   // id of the previous field in the current struct
+	up   []int16 // last of each enclosing struct
+}
+
+func (t *thrift) field(id int16, typ byte) {
+	if d := id - t.last; d > 0 && d <= 15 {
+		t.b = append(t.b, byte(d)<<4|typ)
+	} else {
+		t.b = append(t.b, typ)
+		t.b = binary.AppendVarint(t.b, int64(id))
+	}
+	t.last = id
+}
+
+func (t *thrift) i32(id int16, v int32) {
+	t.field(id, typeI32)
+	t.varint(v)
+}
+
+func (t *thrift) i64(id int16, v int64) {
+	t.field(id, typeI64)
+	t.b = binary.AppendVarint(t.b, v)
+}
+
+func (t *thrift) str(id int16, s string) {
+	t.field(id, typeBinary)
+	t.bytes(s)
+}
+
+// varint writes a bare zigzag-encoded i32, as in a list of i32.
+func (t *thrift) varint(v int32) {
+	t.b = binary.AppendVarint(t.b, int64(v))
+}
+
+// bytes writes a bare length-prefixed string, as in a list of strings.
+func (t *thrift) bytes(s string) {
+	t.b = binary.AppendUvarint(t.b, uint64(len(s)))
+	t.b = append(t.b, s...)
+}
+
+func (t *thrift) list(id int16, elem byte, n int) {
+	t.field(id, typeList)
+	if n < 15 {
+		t.b = append(t.b, byte(n)<<4|elem)
+		return
+	}
+	t.b = append(t.b, 0xF0|elem)
+	t.b = binary.AppendUvarint(t.b, uint64(n))
+}
+
+// begin opens a struct-valued field; item opens a struct element of a list.
+// Both are closed by end.
+func (t *thrift) begin(id int16) {
+	t.field(id, typeStruct)
+	t.item()
+}
+
+func (t *thrift) item() {
+	t.up = append(t.up, t.last)
+	t.last = 0
+}
+
+func (t *thrift) end() {
+	t.stop()
+	t.last = t.up[len(t.up)-1]
+	t.up = t.up[:len(t.up)-1]
+}
+
+// stop ends the outermost struct.
+func (t *thrift) stop() {
+	t.b = append(t.b, 0)
+}
@@ -0,0 +1,59 @@
+package parse
+
+import (
+	"sort"
+	"time"
+)
+
+// accumulator builds a Summary and per-minute timeline from already parsed
+// events, for formats that are not read line by line by ParseTSV.
+type accumulator struct {
+	lines   int
+	sum     Summary
+	ips     map[string]struct{}
+	minutes map[time.Time]int
+	durs    []float64
+	top     *tally
+}
+
+func newAccumulator() *accumulator {
+	return &accumulator{ips: make(map[string]struct{}), minutes: make(map[time.Time]int), top: newTally()}
+}
+
+func (a *accumulator) add(ev Event) {
+	if ev.TS.IsZero() {
+		return
+	}
+	if a.sum.Start.IsZero() || ev.TS.Before(a.sum.Start) {
+		a.sum.Start = ev.TS
+	}
+	if a.sum.End.IsZero() || ev.TS.After(a.sum.End) {
+		a.sum.End = ev.TS
+	}
+	if ev.SrcIP != "" {
+		a.ips[ev.SrcIP] = struct{}{}
+	}
+	a.minutes[ev.TS.Truncate(time.Minute)]++
+	if ev.DurationMs > 0 {
+		a.durs = append(a.durs, ev.DurationMs)
+	}
+	a.top.add(ev.SrcIP, ev.Path, ev.UA, ev.Status)
+}
+
+func (a *accumulator) finish() (Summary, []Bucket) {
+	sum := a.sum
+	sum.Lines = a.lines
+	sum.UniqueIPs = len(a.ips)
+	sum.Latency = latencyOf(a.durs)
+	sum.Top = a.top.tops()
+
+	if len(a.minutes) == 0 {
+		return sum, nil
+	}
+	timeline := make([]Bucket, 0, len(a.minutes))
+	for t, c := range a.minutes {
+		timeline = append(timeline, Bucket{T: t, Count: c})
+	}
+	sort.Slice(timeline, func(i, j int) bool { return timeline[i].T.Before(timeline[j].T) })
+	return sum, timeline
+}
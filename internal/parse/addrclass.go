@@ -0,0 +1,46 @@
+package parse
+
+import "net/netip"
+
+// Address classes set in Event.SrcClass. Every class but AddrPublic is
+// internal.
+const (
+	AddrPublic    = "public"
+	AddrPrivate   = "private"    // RFC 1918 and IPv6 unique local
+	AddrLoopback  = "loopback"   // 127.0.0.0/8, ::1
+	AddrLinkLocal = "link_local" // 169.254.0.0/16, fe80::/10
+	AddrCGNAT     = "cgnat"      // 100.64.0.0/10 (RFC 6598)
+)
+
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// AddrClass classifies ip, or returns "" when it is not an address.
+func AddrClass(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	switch {
+	case addr.IsLoopback():
+		return AddrLoopback
+	case addr.IsPrivate():
+		return AddrPrivate
+	case addr.IsLinkLocalUnicast():
+		return AddrLinkLocal
+	case cgnat.Contains(addr):
+		return AddrCGNAT
+	}
+	return AddrPublic
+}
+
+// IsInternal reports whether class is one of the internal address classes.
+func IsInternal(class string) bool {
+	return class != "" && class != AddrPublic
+}
+
+func classifyRows(rows []Event) {
+	for i := range rows {
+		rows[i].SrcClass = AddrClass(rows[i].SrcIP)
+	}
+}
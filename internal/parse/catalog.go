@@ -0,0 +1,101 @@
+package parse
+
+import (
+	"sort"
+	"strings"
+)
+
+// FieldSpec describes one named row field for rule authors and the UI.
+type FieldSpec struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"` // string, number, bool, time
+	Description string `json:"description"`
+}
+
+// CommonFields are the Event fields shared by every format. Which of them a
+// format fills in varies; see FormatFields for format-specific extras.
+var CommonFields = []FieldSpec{
+	{"ts", "time", "Event time (UTC)."},
+	{"srcIp", "string", "Client address."},
+	{"srcClass", "string", "Client address class: public, private, loopback, link_local or cgnat."},
+	{"anonymizer", "string", "Name of the anonymizer feed (e.g. tor, vpn, proxy) listing the client address."},
+	{"dst", "string", "Destination host, server or namespace."},
+	{"method", "string", "HTTP method, SQL verb, API verb or pseudo method such as LOGON or CONNECT."},
+	{"path", "string", "Request path or pseudo path such as /logon or /vpn/login."},
+	{"status", "number", "Response status or HTTP-like outcome code."},
+	{"bytes", "number", "Bytes transferred."},
+	{"ua", "string", "User-Agent."},
+	{"crawler", "string", "Search engine crawler the User-Agent claims to be (googlebot, bingbot, applebot, yandexbot, baiduspider)."},
+	{"crawlerVerified", "bool", "Whether the claimed crawler was confirmed by forward-confirmed reverse DNS."},
+	{"referer", "string", "Referer."},
+	{"rawQuery", "string", "Query string as logged."},
+	{"durationMs", "number", "Request or session duration in milliseconds."},
+	{"user", "string", "Authenticated or targeted account."},
+	{"workstation", "string", "Client workstation name (Windows)."},
+	{"category", "string", "Statement or event category (read, write, ddl, privilege, session, auth, other)."},
+	{"statement", "string", "SQL statement, passwords redacted."},
+	{"sessionId", "string", "Session identifier (VPN, RADIUS)."},
+	{"resource", "string", "Kubernetes resource[/subresource]."},
+}
+
+// IsField reports whether name addresses a row field: one of CommonFields or
+// extras.<key>.
+func IsField(name string) bool {
+	if key, ok := strings.CutPrefix(name, "extras."); ok {
+		return key != ""
+	}
+	for _, f := range CommonFields {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatFields lists the extras each format may attach to rows, addressed as
+// extras.<name>.
+var FormatFields = map[string][]FieldSpec{
+	FormatTSV: {
+		{"*", "string|number|bool", "Any trailing key=value column; numbers and true/false are typed automatically (e.g. cache, tls, upstream_ms, country)."},
+	},
+	FormatWindowsXML: {
+		{"eventId", "string", "Windows event ID (4624, 4625, 4771, 4776)."},
+		{"logonType", "number", "Logon type (2 interactive, 3 network, 10 remote interactive, ...)."},
+		{"authPackage", "string", "Authentication package (NTLM, Kerberos, Negotiate)."},
+		{"logonProcess", "string", "Logon process name."},
+		{"failureStatus", "string", "NTSTATUS sub-status of a failed logon."},
+	},
+	FormatK8sAudit: {
+		{"object", "string", "Name of the object acted on."},
+		{"apiGroup", "string", "API group of the resource."},
+		{"auditLevel", "string", "Audit level the event was recorded at."},
+		{"impersonatedBy", "string", "Real user when the request used impersonation."},
+	},
+	FormatPostgres: {
+		{"pid", "number", "Backend process ID."},
+		{"level", "string", "Log severity (LOG, ERROR, FATAL, ...)."},
+	},
+	FormatMySQL: {
+		{"thread", "number", "Connection thread ID."},
+	},
+	FormatVPN: {
+		{"framedIp", "string", "Tunnel address assigned to the client."},
+		{"nasPort", "string", "NAS port of the session."},
+		{"terminateCause", "string", "RADIUS Acct-Terminate-Cause of a stopped session."},
+	},
+}
+
+// RegisterFields adds extras for a format, e.g. from a new parser.
+func RegisterFields(format string, specs ...FieldSpec) {
+	FormatFields[format] = append(FormatFields[format], specs...)
+}
+
+// Formats returns the formats with registered fields, sorted.
+func Formats() []string {
+	out := make([]string, 0, len(FormatFields))
+	for f := range FormatFields {
+		out = append(out, f)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,324 @@
+package parse
+
+import (
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatPostgres = "postgres"
+	FormatMySQL    = "mysql-general"
+)
+
+// Statement categories assigned to database events.
+const (
+	CategoryRead      = "read"
+	CategoryWrite     = "write"
+	CategoryDDL       = "ddl"
+	CategoryPrivilege = "privilege"
+	CategorySession   = "session"
+	CategoryAuth      = "auth"
+	CategoryOther     = "other"
+)
+
+var (
+	// 2025-08-28 10:00:00.123 UTC [1234] alice@appdb 10.0.0.5(51234) LOG:  statement: SELECT 1
+	pgLine      = regexp.MustCompile(`^(\d{4}-\d\d-\d\d \d\d:\d\d:\d\d(?:\.\d+)?)(?: ([A-Z]{2,5}|[+-]\d\d(?::?\d\d)?))? \[(\d+)\][:\-\d]*\s*(.*?)\s*(LOG|ERROR|FATAL|PANIC|WARNING|NOTICE|INFO|DEBUG\d?|DETAIL|HINT|STATEMENT):\s+(.*)$`)
+	pgAuthFail  = regexp.MustCompile(`(?:password|ident|peer|md5|scram-sha-256|ldap|cert) authentication failed for user "([^"]*)"|no pg_hba\.conf entry for host "([^"]*)", user "([^"]*)"|role "([^"]*)" does not exist`)
+	pgConnAuth  = regexp.MustCompile(`connection authorized: user=(\S+)(?: database=(\S+))?`)
+	pgConnRecv  = regexp.MustCompile(`connection received: host=(\S+)`)
+	pgStatement = regexp.MustCompile(`^(?:statement|execute [^:]*|duration: [\d.]+ ms\s+statement): (.*)$`)
+
+	// 2025-08-28T10:00:00.123456Z	   12 Query	SELECT 1
+	myLine      = regexp.MustCompile(`^(\d{4}-\d\d-\d\d[T ]\d\d:\d\d:\d\d(?:\.\d+)?Z?)\s+(\d+)\s+(Connect|Query|Quit|Init DB|Execute|Prepare|Close stmt|Field List|Change user|Connect Out|Statistics|Ping|Shutdown|Kill|Refresh|Reset stmt|Set option|Long Data)\t?(.*)$`)
+	myConnect   = regexp.MustCompile(`^(\S+?)@(\S+) on (\S*)`)
+	myDenied    = regexp.MustCompile(`Access denied for user '([^']*)'@'([^']*)'`)
+	sqlPassword = regexp.MustCompile(`(?i)((?:PASSWORD|IDENTIFIED\s+(?:WITH\s+\S+\s+)?BY)\s*(?:=\s*)?)'(?:[^'\\]|\\.|'')*'`)
+	sqlVerbRe   = regexp.MustCompile(`^\s*(?:/\*.*?\*/\s*)*([A-Za-z]+)(?:\s+([A-Za-z]+))?`)
+)
+
+// isPostgresLine and isMySQLLine are used by DetectFormat.
+func isPostgresLine(s string) bool { return pgLine.MatchString(s) }
+func isMySQLLine(s string) bool    { return myLine.MatchString(s) }
+
+// ClassifySQL returns the upper-case leading verb of a statement and its
+// category.
+func ClassifySQL(stmt string) (verb, category string) {
+	m := sqlVerbRe.FindStringSubmatch(stmt)
+	if m == nil {
+		return "", CategoryOther
+	}
+	verb = strings.ToUpper(m[1])
+	second := strings.ToUpper(m[2])
+	switch verb {
+	case "SELECT", "SHOW", "DESCRIBE", "DESC", "EXPLAIN", "COPY", "WITH", "TABLE", "VALUES":
+		if verb == "COPY" && strings.Contains(strings.ToUpper(stmt), " FROM ") {
+			return verb, CategoryWrite
+		}
+		return verb, CategoryRead
+	case "INSERT", "UPDATE", "DELETE", "MERGE", "REPLACE", "UPSERT", "TRUNCATE", "LOAD":
+		return verb, CategoryWrite
+	case "GRANT", "REVOKE":
+		return verb, CategoryPrivilege
+	case "CREATE", "ALTER", "DROP", "RENAME":
+		switch second {
+		case "USER", "ROLE", "GROUP":
+			return verb + " " + second, CategoryPrivilege
+		}
+		return verb, CategoryDDL
+	case "SET":
+		if second == "ROLE" || second == "SESSION" || second == "PASSWORD" {
+			return verb + " " + second, CategoryPrivilege
+		}
+		return verb, CategorySession
+	case "BEGIN", "COMMIT", "ROLLBACK", "START", "SAVEPOINT", "RELEASE", "USE", "DISCARD", "RESET":
+		return verb, CategorySession
+	}
+	return verb, CategoryOther
+}
+
+// ParsePostgresLine parses one line of a Postgres server log written with a
+// log_line_prefix containing at least %m and %p, and optionally %u@%d and %h.
+// ok is false for lines that are not log entries (e.g. continuation lines).
+func ParsePostgresLine(line string) (ev Event, ok bool) {
+	m := pgLine.FindStringSubmatch(line)
+	if m == nil {
+		return ev, false
+	}
+	ev.TS = parseDBTime(m[1], m[2])
+	ev.Dst = "postgres"
+	ev.Extras.SetParsed("pid", m[3])
+	ev.Path = "/db"
+
+	for _, tok := range strings.Fields(m[4]) {
+		if u, db, ok := strings.Cut(tok, "@"); ok {
+			if u != "" && u != "[unknown]" {
+				ev.User = u
+			}
+			if db != "" && db != "[unknown]" {
+				ev.Path = "/db/" + db
+			}
+			continue
+		}
+		host, _, _ := strings.Cut(tok, "(")
+		if looksLikeHost(host) {
+			ev.SrcIP = host
+		}
+	}
+
+	level, msg := m[5], m[6]
+	ev.Extras.SetString("level", level)
+	ev.Status = 200
+	if level == "ERROR" {
+		ev.Status = 500
+	}
+
+	switch {
+	case pgAuthFail.MatchString(msg):
+		am := pgAuthFail.FindStringSubmatch(msg)
+		for _, u := range []string{am[1], am[3], am[4]} {
+			if u != "" {
+				ev.User = u
+			}
+		}
+		if am[2] != "" && ev.SrcIP == "" {
+			ev.SrcIP = am[2]
+		}
+		ev.Method, ev.Category, ev.Status, ev.Path = "AUTH", CategoryAuth, 401, "/db/login"
+	case pgConnAuth.MatchString(msg):
+		am := pgConnAuth.FindStringSubmatch(msg)
+		ev.User = am[1]
+		if am[2] != "" {
+			ev.Path = "/db/" + am[2]
+		}
+		ev.Method, ev.Category, ev.Path = "CONNECT", CategoryAuth, "/db/login"
+	case pgConnRecv.MatchString(msg):
+		if ev.SrcIP == "" {
+			ev.SrcIP = pgConnRecv.FindStringSubmatch(msg)[1]
+		}
+		ev.Method, ev.Category = "CONNECT", CategorySession
+	case pgStatement.MatchString(msg):
+		stmt := pgStatement.FindStringSubmatch(msg)[1]
+		ev.Method, ev.Category = ClassifySQL(stmt)
+		ev.Statement = cleanStmt(stmt)
+		if d, ok := pgDuration(msg); ok {
+			ev.DurationMs = d
+		}
+	default:
+		if d, ok := pgDuration(msg); ok {
+			ev.DurationMs = d
+		}
+		ev.Method, ev.Category = level, CategoryOther
+	}
+	return ev, true
+}
+
+func pgDuration(msg string) (float64, bool) {
+	rest, ok := strings.CutPrefix(msg, "duration: ")
+	if !ok {
+		return 0, false
+	}
+	num, _, _ := strings.Cut(rest, " ")
+	f, err := strconv.ParseFloat(num, 64)
+	return f, err == nil
+}
+
+// mysqlConn remembers who a general-log thread id belongs to, since only the
+// Connect entry names the user and host.
+type mysqlConn struct {
+	user, host, db string
+}
+
+type mysqlParser struct {
+	conns map[string]mysqlConn
+	lastT time.Time
+}
+
+func newMySQLParser() *mysqlParser {
+	return &mysqlParser{conns: make(map[string]mysqlConn)}
+}
+
+// parse handles one line of a MySQL general query log. Continuation lines of
+// multi-line statements are not log entries and return ok=false.
+func (p *mysqlParser) parse(line string) (ev Event, ok bool) {
+	m := myLine.FindStringSubmatch(line)
+	if m == nil {
+		return ev, false
+	}
+	ev.TS = parseDBTime(m[1], "")
+	id, cmd, arg := m[2], m[3], strings.TrimSpace(m[4])
+	ev.Dst = "mysql"
+	ev.Extras.SetParsed("thread", id)
+	ev.Path = "/db"
+	ev.Status = 200
+
+	switch cmd {
+	case "Connect":
+		if dm := myDenied.FindStringSubmatch(arg); dm != nil {
+			ev.User, ev.SrcIP = dm[1], dm[2]
+			ev.Method, ev.Category, ev.Status, ev.Path = "AUTH", CategoryAuth, 401, "/db/login"
+			return ev, true
+		}
+		c := mysqlConn{}
+		if cm := myConnect.FindStringSubmatch(arg); cm != nil {
+			c = mysqlConn{user: cm[1], host: cm[2], db: cm[3]}
+		}
+		p.conns[id] = c
+		ev.Method, ev.Category, ev.Path = "CONNECT", CategoryAuth, "/db/login"
+	case "Quit":
+		ev.Method, ev.Category = "QUIT", CategorySession
+		defer delete(p.conns, id)
+	case "Init DB":
+		c := p.conns[id]
+		c.db = arg
+		p.conns[id] = c
+		ev.Method, ev.Category = "USE", CategorySession
+	case "Query", "Execute", "Prepare":
+		ev.Method, ev.Category = ClassifySQL(arg)
+		ev.Statement = cleanStmt(arg)
+	default:
+		ev.Method, ev.Category = strings.ToUpper(cmd), CategoryOther
+	}
+
+	c := p.conns[id]
+	if ev.User == "" {
+		ev.User = c.user
+	}
+	if ev.SrcIP == "" {
+		ev.SrcIP = c.host
+	}
+	if c.db != "" && ev.Path == "/db" {
+		ev.Path = "/db/" + c.db
+	}
+	return ev, true
+}
+
+func parseDBTime(ts, zone string) time.Time {
+	ts = strings.Replace(ts, " ", "T", 1)
+	if !strings.HasSuffix(ts, "Z") {
+		switch {
+		case zone == "" || zone == "UTC" || zone == "GMT":
+			ts += "Z"
+		case zone[0] == '+' || zone[0] == '-':
+			if len(zone) == 3 {
+				zone += ":00"
+			} else if !strings.Contains(zone, ":") {
+				zone = zone[:3] + ":" + zone[3:]
+			}
+			ts += zone
+		default:
+			// Named zones other than UTC are ambiguous; treat them as UTC.
+			ts += "Z"
+		}
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}
+
+func looksLikeHost(s string) bool {
+	if s == "localhost" {
+		return true
+	}
+	_, err := netip.ParseAddr(s)
+	return err == nil
+}
+
+// cleanStmt redacts password literals and bounds the statement kept on the event.
+func cleanStmt(s string) string {
+	const max = 512
+	s = sqlPassword.ReplaceAllString(strings.TrimSpace(s), "$1'***'")
+	if len(s) > max {
+		return s[:max] + "…"
+	}
+	return s
+}
+
+// parseDBLog parses a Postgres or MySQL general log (format as returned by
+// DetectFormat) into events.
+func (ps pass) parseDBLog(path, format string, maxRows, keepRows int) (Summary, []Bucket, []Event, error) {
+	f, err := ps.open(path)
+	if err != nil {
+		return Summary{}, nil, nil, err
+	}
+	defer f.Close()
+
+	my := newMySQLParser()
+	parseLine := func(s string) (Event, bool) { return ParsePostgresLine(s) }
+	if format == FormatMySQL {
+		parseLine = my.parse
+	}
+
+	acc := newAccumulator()
+	rows := make([]Event, 0, min(keepRows, 4096))
+	each := ps.each
+	sc, ls := newScanner(f)
+	for sc.Scan() {
+		acc.lines++
+		if maxRows > 0 && acc.lines > maxRows {
+			acc.lines--
+			break
+		}
+		ev, ok := parseLine(sc.Text())
+		if !ok {
+			continue
+		}
+		acc.add(ev)
+		each(ev)
+		if keepRows <= 0 || len(rows) < keepRows {
+			rows = append(rows, ev)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return Summary{}, nil, nil, err
+	}
+
+	sum, timeline := acc.finish()
+	sum.TruncatedLines = ls.truncated
+	return sum, timeline, rows, nil
+}
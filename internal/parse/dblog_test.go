@@ -0,0 +1,106 @@
+package parse
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePostgresLine(t *testing.T) {
+	at := func(sec int, nsec int) time.Time { return time.Date(2025, 8, 28, 10, 0, sec, nsec, time.UTC) }
+	tests := []struct {
+		name   string
+		line   string
+		want   Event
+		wantOK bool
+	}{
+		{
+			name: "statement",
+			line: `2025-08-28 10:00:00.123 UTC [1234] alice@appdb 10.0.0.5(51234) LOG:  statement: SELECT * FROM users`,
+			want: Event{TS: at(0, 123e6), SrcIP: "10.0.0.5", Dst: "postgres", Method: "SELECT", Path: "/db/appdb", Status: 200,
+				User: "alice", Category: CategoryRead, Statement: "SELECT * FROM users", Extras: Extras{"pid": 1234.0, "level": "LOG"}},
+			wantOK: true,
+		},
+		{
+			name: "password is redacted",
+			line: `2025-08-28 10:00:01 +02 [1234] alice@appdb 10.0.0.5(51234) LOG:  statement: ALTER ROLE bob PASSWORD 'hunter2'`,
+			want: Event{TS: at(1, 0).Add(-2 * time.Hour), SrcIP: "10.0.0.5", Dst: "postgres", Method: "ALTER ROLE", Path: "/db/appdb", Status: 200,
+				User: "alice", Category: CategoryPrivilege, Statement: "ALTER ROLE bob PASSWORD '***'", Extras: Extras{"pid": 1234.0, "level": "LOG"}},
+			wantOK: true,
+		},
+		{
+			name: "failed password",
+			line: `2025-08-28 10:00:02 UTC [88] [unknown]@[unknown] 203.0.113.9(40000) FATAL:  password authentication failed for user "postgres"`,
+			want: Event{TS: at(2, 0), SrcIP: "203.0.113.9", Dst: "postgres", Method: "AUTH", Path: "/db/login", Status: 401,
+				User: "postgres", Category: CategoryAuth, Extras: Extras{"pid": 88.0, "level": "FATAL"}},
+			wantOK: true,
+		},
+		{
+			name: "duration",
+			line: `2025-08-28 10:00:03 UTC [1234] alice@appdb 10.0.0.5(51234) LOG:  duration: 12.5 ms  statement: DELETE FROM t`,
+			want: Event{TS: at(3, 0), SrcIP: "10.0.0.5", Dst: "postgres", Method: "DELETE", Path: "/db/appdb", Status: 200, DurationMs: 12.5,
+				User: "alice", Category: CategoryWrite, Statement: "DELETE FROM t", Extras: Extras{"pid": 1234.0, "level": "LOG"}},
+			wantOK: true,
+		},
+		{name: "continuation line", line: `	WHERE id = 1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParsePostgresLine(tt.line)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok {
+				checkEvents(t, []Event{got}, []Event{tt.want})
+			}
+		})
+	}
+}
+
+func TestMySQLParser(t *testing.T) {
+	lines := []string{
+		"2025-08-28T10:00:00.000000Z\t   12 Connect\tbob@10.0.0.7 on shop using TCP/IP",
+		"2025-08-28T10:00:01.000000Z\t   12 Query\tGRANT ALL ON *.* TO 'eve'@'%'",
+		"2025-08-28T10:00:02.000000Z\t   12 Quit\t",
+		"2025-08-28T10:00:03.000000Z\t   13 Connect\tAccess denied for user 'root'@'203.0.113.9' (using password: YES)",
+		"    AND deleted = 0",
+	}
+	at := func(sec int) time.Time { return time.Date(2025, 8, 28, 10, 0, sec, 0, time.UTC) }
+	want := []Event{
+		{TS: at(0), SrcIP: "10.0.0.7", Dst: "mysql", Method: "CONNECT", Path: "/db/login", Status: 200, User: "bob", Category: CategoryAuth, Extras: Extras{"thread": 12.0}},
+		{TS: at(1), SrcIP: "10.0.0.7", Dst: "mysql", Method: "GRANT", Path: "/db/shop", Status: 200, User: "bob", Category: CategoryPrivilege,
+			Statement: "GRANT ALL ON *.* TO 'eve'@'%'", Extras: Extras{"thread": 12.0}},
+		{TS: at(2), SrcIP: "10.0.0.7", Dst: "mysql", Method: "QUIT", Path: "/db/shop", Status: 200, User: "bob", Category: CategorySession, Extras: Extras{"thread": 12.0}},
+		{TS: at(3), SrcIP: "203.0.113.9", Dst: "mysql", Method: "AUTH", Path: "/db/login", Status: 401, User: "root", Category: CategoryAuth, Extras: Extras{"thread": 13.0}},
+	}
+	p := newMySQLParser()
+	var got []Event
+	for _, l := range lines {
+		if ev, ok := p.parse(l); ok {
+			got = append(got, ev)
+		}
+	}
+	checkEvents(t, got, want)
+}
+
+func TestClassifySQL(t *testing.T) {
+	tests := []struct {
+		stmt, verb, category string
+	}{
+		{"select 1", "SELECT", CategoryRead},
+		{"/* app */ UPDATE t SET a = 1", "UPDATE", CategoryWrite},
+		{"COPY t FROM '/tmp/x.csv'", "COPY", CategoryWrite},
+		{"COPY t TO STDOUT", "COPY", CategoryRead},
+		{"CREATE USER eve", "CREATE USER", CategoryPrivilege},
+		{"CREATE TABLE t (a int)", "CREATE", CategoryDDL},
+		{"SET ROLE admin", "SET ROLE", CategoryPrivilege},
+		{"SET search_path = public", "SET", CategorySession},
+		{"VACUUM", "VACUUM", CategoryOther},
+		{"", "", CategoryOther},
+	}
+	for _, tt := range tests {
+		verb, category := ClassifySQL(tt.stmt)
+		if verb != tt.verb || category != tt.category {
+			t.Errorf("ClassifySQL(%q) = %q, %q; want %q, %q", tt.stmt, verb, category, tt.verb, tt.category)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package parse
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseDuration converts a request duration column to milliseconds. It accepts
+// Go-style durations ("120ms", "1.5s"), bare integers as milliseconds
+// (HAProxy Tt) and bare decimals as seconds (nginx $request_time, ALB
+// target_processing_time). Negative values such as ALB's -1 are rejected.
+func ParseDuration(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "-" {
+		return 0, false
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		if d < 0 {
+			return 0, false
+		}
+		return float64(d) / float64(time.Millisecond), true
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n < 0 {
+			return 0, false
+		}
+		return float64(n), true
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f * 1000, true
+}
+
+type Latency struct {
+	Samples int     `json:"samples"`
+	P50     float64 `json:"p50Ms"`
+	P95     float64 `json:"p95Ms"`
+	P99     float64 `json:"p99Ms"`
+}
+
+func latencyOf(durations []float64) *Latency {
+	if len(durations) == 0 {
+		return nil
+	}
+	sort.Float64s(durations)
+	return &Latency{
+		Samples: len(durations),
+		P50:     Percentile(durations, 50),
+		P95:     Percentile(durations, 95),
+		P99:     Percentile(durations, 99),
+	}
+}
+
+// Percentile returns the nearest-rank percentile of an ascending slice.
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return math.Round(sorted[rank-1]*1000) / 1000
+}
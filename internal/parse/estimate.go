@@ -0,0 +1,92 @@
+package parse
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"os"
+)
+
+// Estimate is an approximate figure with a 95% confidence interval.
+type Estimate struct {
+	Value float64 `json:"value"`
+	Low   float64 `json:"low"`
+	High  float64 `json:"high"`
+	Level float64 `json:"level"`
+}
+
+// estimateTotals is called when a parse stopped after scanned lines. It
+// reports whether the file really continues and, for files read without
+// transcoding, estimates its total line count from the scanned lines' mean
+// length and the remaining bytes. The interval treats line lengths as
+// independent draws, so it is optimistic for files whose lines grow or shrink
+// over time.
+func estimateTotals(path string, scanned int) (more bool, est map[string]Estimate) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, nil
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return false, nil
+	}
+
+	br := bufio.NewReaderSize(f, 64*1024)
+	head, _ := br.Peek(2)
+	transcoded := bytes.HasPrefix(head, []byte{0xFF, 0xFE}) || bytes.HasPrefix(head, []byte{0xFE, 0xFF})
+
+	var consumed, sum, ssq float64
+	n := 0
+	for n < scanned {
+		k, err := lineLen(br)
+		if k > 0 {
+			n++
+			consumed += float64(k)
+			sum += float64(k)
+			ssq += float64(k) * float64(k)
+		}
+		if err != nil {
+			break
+		}
+	}
+	if _, err := br.Peek(1); err != nil {
+		return false, nil
+	}
+	if transcoded || n == 0 {
+		return true, nil
+	}
+
+	mean := sum / float64(n)
+	std := math.Sqrt(max(ssq/float64(n)-mean*mean, 0))
+	remaining := float64(st.Size()) - consumed
+	rest := remaining / mean
+	se := rest * (std / mean) / math.Sqrt(float64(n))
+	return true, map[string]Estimate{
+		"lines": {
+			Value: math.Round(float64(n) + rest),
+			Low:   math.Round(float64(n) + max(rest-1.96*se, 1)),
+			High:  math.Round(float64(n) + rest + 1.96*se),
+			Level: 0.95,
+		},
+	}
+}
+
+// lineLen consumes one line including its terminator and returns its length
+// in bytes.
+func lineLen(br *bufio.Reader) (int, error) {
+	n := 0
+	for {
+		chunk, err := br.ReadSlice('\n')
+		n += len(chunk)
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if errors.Is(err, io.EOF) && n > 0 {
+			return n, nil
+		}
+		return n, err
+	}
+}
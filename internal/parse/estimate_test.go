@@ -0,0 +1,63 @@
+package parse
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTotals(t *testing.T) {
+	// lines returns n lines; varied ones differ in length.
+	lines := func(n int, varied bool) string {
+		var b strings.Builder
+		for i := range n {
+			pad := 0
+			if varied {
+				pad = i % 7 * 5
+			}
+			fmt.Fprintf(&b, "2024-05-01T13:00:00Z\t203.0.113.9\tweb1\tGET\t/page/%04d%s\t200\t512\n", i, strings.Repeat("x", pad))
+		}
+		return b.String()
+	}
+	tests := []struct {
+		name     string
+		data     []byte
+		scanned  int
+		wantMore bool
+		want     *Estimate // exact estimate, if any
+		bracket  int       // line count the interval must contain, if any
+	}{
+		{"uniform lines", []byte(lines(1000, false)), 100, true, &Estimate{Value: 1000, Low: 1000, High: 1000, Level: 0.95}, 0},
+		{"varied lines", []byte(lines(1000, true)), 140, true, nil, 1000},
+		{"file read to the end", []byte(lines(100, false)), 100, false, nil, 0},
+		{"UTF-16 file", utf16Bytes(lines(100, false), binary.LittleEndian, []byte{0xFF, 0xFE}), 10, true, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sample.log")
+			if err := os.WriteFile(path, tt.data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			more, est := estimateTotals(path, tt.scanned)
+			if more != tt.wantMore {
+				t.Errorf("more = %v, want %v", more, tt.wantMore)
+			}
+			got, ok := est["lines"]
+			switch {
+			case tt.want != nil:
+				if got != *tt.want {
+					t.Errorf("lines estimate %+v, want %+v", got, *tt.want)
+				}
+			case tt.bracket > 0:
+				if !ok || got.Low >= got.High || got.Low > float64(tt.bracket) || got.High < float64(tt.bracket) {
+					t.Errorf("lines estimate %+v, want an interval around %d", got, tt.bracket)
+				}
+			case ok:
+				t.Errorf("lines estimate %+v, want none", got)
+			}
+		})
+	}
+}
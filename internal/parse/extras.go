@@ -0,0 +1,135 @@
+package parse
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Extras holds format-specific attributes that have no dedicated Event field
+// (cache status, TLS version, upstream time, ...). Values are always string,
+// float64 or bool.
+type Extras map[string]any
+
+func (x *Extras) set(k string, v any) {
+	if *x == nil {
+		*x = make(Extras)
+	}
+	(*x)[k] = v
+}
+
+// SetString stores v under k; empty and "-" values are skipped.
+func (x *Extras) SetString(k, v string) {
+	if v = strings.TrimSpace(v); v != "" && v != "-" {
+		x.set(k, v)
+	}
+}
+
+func (x *Extras) SetNumber(k string, v float64) { x.set(k, v) }
+func (x *Extras) SetBool(k string, v bool)      { x.set(k, v) }
+
+// SetParsed stores v as a bool or number when it reads as one, else as a
+// string.
+func (x *Extras) SetParsed(k, v string) {
+	v = strings.TrimSpace(v)
+	switch strings.ToLower(v) {
+	case "true", "yes", "on":
+		x.SetBool(k, true)
+		return
+	case "false", "no", "off":
+		x.SetBool(k, false)
+		return
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		x.SetNumber(k, f)
+		return
+	}
+	x.SetString(k, v)
+}
+
+func (x Extras) String(k string) (string, bool) {
+	v, ok := x[k].(string)
+	return v, ok
+}
+
+func (x Extras) Number(k string) (float64, bool) {
+	v, ok := x[k].(float64)
+	return v, ok
+}
+
+func (x Extras) Bool(k string) (bool, bool) {
+	v, ok := x[k].(bool)
+	return v, ok
+}
+
+// Lookup returns the value of a row field by its JSON name, or of an extra
+// as "extras.<key>" (a bare key not naming a built-in field also falls back
+// to extras). It is the single place filters and rules resolve field names.
+func (ev Event) Lookup(field string) (any, bool) {
+	switch field {
+	case "ts":
+		return ev.TS, !ev.TS.IsZero()
+	case "srcIp":
+		return ev.SrcIP, ev.SrcIP != ""
+	case "srcClass":
+		return ev.SrcClass, ev.SrcClass != ""
+	case "anonymizer":
+		return ev.Anonymizer, ev.Anonymizer != ""
+	case "dst":
+		return ev.Dst, ev.Dst != ""
+	case "method":
+		return ev.Method, ev.Method != ""
+	case "path":
+		return ev.Path, ev.Path != ""
+	case "status":
+		return float64(ev.Status), ev.Status != 0
+	case "bytes":
+		return float64(ev.Bytes), true
+	case "ua":
+		return ev.UA, ev.UA != ""
+	case "crawler":
+		return ev.Crawler, ev.Crawler != ""
+	case "crawlerVerified":
+		return ev.CrawlerVerified, ev.Crawler != ""
+	case "referer":
+		return ev.Referer, ev.Referer != ""
+	case "rawQuery":
+		return ev.RawQuery, ev.RawQuery != ""
+	case "durationMs":
+		return ev.DurationMs, ev.DurationMs != 0
+	case "user":
+		return ev.User, ev.User != ""
+	case "workstation":
+		return ev.Workstation, ev.Workstation != ""
+	case "category":
+		return ev.Category, ev.Category != ""
+	case "statement":
+		return ev.Statement, ev.Statement != ""
+	case "sessionId":
+		return ev.SessionID, ev.SessionID != ""
+	case "resource":
+		return ev.Resource, ev.Resource != ""
+	}
+	k := strings.TrimPrefix(field, "extras.")
+	v, ok := ev.Extras[k]
+	return v, ok
+}
+
+// Matches reports whether field equals want. Numbers compare numerically and
+// bools by their parsed value; strings compare case-insensitively.
+func (ev Event) Matches(field, want string) bool {
+	v, ok := ev.Lookup(field)
+	if !ok {
+		return false
+	}
+	switch v := v.(type) {
+	case float64:
+		f, err := strconv.ParseFloat(want, 64)
+		return err == nil && f == v
+	case bool:
+		b, err := strconv.ParseBool(want)
+		return err == nil && b == v
+	case string:
+		return strings.EqualFold(v, want)
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package parse
+
+import "time"
+
+type Gap struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Minutes int       `json:"minutes"`
+}
+
+// FindGaps reports stretches of at least minGap between consecutive timeline
+// buckets, i.e. periods where the source delivered no events at all.
+func FindGaps(timeline []Bucket, minGap time.Duration) []Gap {
+	var out []Gap
+	for i := 1; i < len(timeline); i++ {
+		prev, cur := timeline[i-1].T, timeline[i].T
+		silent := cur.Sub(prev) - time.Minute
+		if silent < minGap {
+			continue
+		}
+		out = append(out, Gap{
+			From:    prev.Add(time.Minute),
+			To:      cur,
+			Minutes: int(silent / time.Minute),
+		})
+	}
+	return out
+}
@@ -0,0 +1,125 @@
+package parse
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const FormatK8sAudit = "k8s-audit"
+
+type k8sAuditEvent struct {
+	Kind       string `json:"kind"`
+	Level      string `json:"level"`
+	Stage      string `json:"stage"`
+	RequestURI string `json:"requestURI"`
+	Verb       string `json:"verb"`
+	User       struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	ImpersonatedUser *struct {
+		Username string `json:"username"`
+	} `json:"impersonatedUser"`
+	SourceIPs []string `json:"sourceIPs"`
+	UserAgent string   `json:"userAgent"`
+	ObjectRef *struct {
+		Resource    string `json:"resource"`
+		APIGroup    string `json:"apiGroup"`
+		Namespace   string `json:"namespace"`
+		Name        string `json:"name"`
+		Subresource string `json:"subresource"`
+	} `json:"objectRef"`
+	ResponseStatus *struct {
+		Code int `json:"code"`
+	} `json:"responseStatus"`
+	RequestReceivedTimestamp time.Time `json:"requestReceivedTimestamp"`
+	StageTimestamp           time.Time `json:"stageTimestamp"`
+}
+
+// parseK8sAuditLine decodes one JSON line of a Kubernetes API server audit
+// log. Only the final stage of each request is kept (ResponseComplete or
+// Panic) so requests are not counted once per stage.
+func parseK8sAuditLine(line string) (Event, bool) {
+	var a k8sAuditEvent
+	if err := json.Unmarshal([]byte(line), &a); err != nil || a.Kind != "Event" {
+		return Event{}, false
+	}
+	if a.Stage != "" && a.Stage != "ResponseComplete" && a.Stage != "Panic" {
+		return Event{}, false
+	}
+
+	ev := Event{
+		TS:     a.RequestReceivedTimestamp.UTC(),
+		Method: strings.ToUpper(a.Verb),
+		UA:     a.UserAgent,
+		User:   a.User.Username,
+	}
+	if a.ImpersonatedUser != nil && a.ImpersonatedUser.Username != "" {
+		ev.Extras.SetString("impersonatedBy", ev.User)
+		ev.User = a.ImpersonatedUser.Username
+	}
+	ev.Extras.SetString("auditLevel", a.Level)
+	if len(a.SourceIPs) > 0 {
+		ev.SrcIP = a.SourceIPs[0]
+	}
+	if a.ResponseStatus != nil {
+		ev.Status = a.ResponseStatus.Code
+	}
+	if !a.StageTimestamp.IsZero() && !a.RequestReceivedTimestamp.IsZero() {
+		ev.DurationMs = float64(a.StageTimestamp.Sub(a.RequestReceivedTimestamp).Microseconds()) / 1000
+	}
+	ev.Path, ev.RawQuery, ev.Query = splitTarget(a.RequestURI)
+	if a.ObjectRef != nil {
+		ev.Resource = a.ObjectRef.Resource
+		if a.ObjectRef.Subresource != "" {
+			ev.Resource += "/" + a.ObjectRef.Subresource
+		}
+		ev.Dst = a.ObjectRef.Namespace
+		ev.Extras.SetString("object", a.ObjectRef.Name)
+		ev.Extras.SetString("apiGroup", a.ObjectRef.APIGroup)
+	}
+	if ev.UA != "" {
+		c := ParseUserAgent(ev.UA)
+		ev.Client = &c
+	}
+	return ev, true
+}
+
+// parseK8sAudit parses a Kubernetes audit log in JSON lines form. Events map
+// verb to Method, requestURI to Path, the first source IP to SrcIP, the
+// namespace to Dst and resource[/subresource] to Resource.
+func (ps pass) parseK8sAudit(path string, maxRows, keepRows int) (Summary, []Bucket, []Event, error) {
+	f, err := ps.open(path)
+	if err != nil {
+		return Summary{}, nil, nil, err
+	}
+	defer f.Close()
+
+	acc := newAccumulator()
+	rows := make([]Event, 0, min(keepRows, 4096))
+	each := ps.each
+	sc, ls := newScanner(f)
+	for sc.Scan() {
+		acc.lines++
+		if maxRows > 0 && acc.lines > maxRows {
+			acc.lines--
+			break
+		}
+		ev, ok := parseK8sAuditLine(sc.Text())
+		if !ok {
+			continue
+		}
+		acc.add(ev)
+		each(ev)
+		if keepRows <= 0 || len(rows) < keepRows {
+			rows = append(rows, ev)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return Summary{}, nil, nil, err
+	}
+
+	sum, timeline := acc.finish()
+	sum.TruncatedLines = ls.truncated
+	return sum, timeline, rows, nil
+}
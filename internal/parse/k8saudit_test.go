@@ -0,0 +1,57 @@
+package parse
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseK8sAuditLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   Event
+		wantOK bool
+	}{
+		{
+			name: "exec into a pod",
+			line: `{"kind":"Event","level":"Metadata","stage":"ResponseComplete","requestURI":"/api/v1/namespaces/prod/pods/web-1/exec?command=sh&stdin=true","verb":"create",` +
+				`"user":{"username":"dev@example.com"},"sourceIPs":["203.0.113.9","10.0.0.1"],"userAgent":"kubectl/v1.30.0 (linux/amd64) kubernetes/abc",` +
+				`"objectRef":{"resource":"pods","namespace":"prod","name":"web-1","subresource":"exec"},"responseStatus":{"code":101},` +
+				`"requestReceivedTimestamp":"2024-05-01T13:00:00.000000Z","stageTimestamp":"2024-05-01T13:00:00.250000Z"}`,
+			want: Event{
+				TS: time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC), SrcIP: "203.0.113.9", Dst: "prod", Method: "CREATE",
+				Path: "/api/v1/namespaces/prod/pods/web-1/exec", RawQuery: "command=sh&stdin=true", Query: url.Values{"command": {"sh"}, "stdin": {"true"}},
+				Status: 101, DurationMs: 250, UA: "kubectl/v1.30.0 (linux/amd64) kubernetes/abc", Client: &Client{OS: "Linux", Device: "desktop"},
+				User: "dev@example.com", Resource: "pods/exec", Extras: Extras{"auditLevel": "Metadata", "object": "web-1"},
+			},
+			wantOK: true,
+		},
+		{
+			name: "impersonated secret read",
+			line: `{"kind":"Event","level":"Request","stage":"ResponseComplete","requestURI":"/api/v1/namespaces/prod/secrets/db","verb":"get",` +
+				`"user":{"username":"admin"},"impersonatedUser":{"username":"system:serviceaccount:prod:app"},"sourceIPs":["10.0.0.5"],` +
+				`"objectRef":{"resource":"secrets","namespace":"prod","name":"db"},"responseStatus":{"code":403},"requestReceivedTimestamp":"2024-05-01T13:00:01Z"}`,
+			want: Event{
+				TS: time.Date(2024, 5, 1, 13, 0, 1, 0, time.UTC), SrcIP: "10.0.0.5", Dst: "prod", Method: "GET",
+				Path: "/api/v1/namespaces/prod/secrets/db", Status: 403, User: "system:serviceaccount:prod:app", Resource: "secrets",
+				Extras: Extras{"impersonatedBy": "admin", "auditLevel": "Request", "object": "db"},
+			},
+			wantOK: true,
+		},
+		{name: "earlier stage", line: `{"kind":"Event","stage":"RequestReceived","verb":"get","requestURI":"/api/v1/pods"}`},
+		{name: "not an audit event", line: `{"kind":"EventList","items":[]}`},
+		{name: "not JSON", line: `2024-05-01T13:00:00Z GET /`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseK8sAuditLine(tt.line)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok {
+				checkEvents(t, []Event{got}, []Event{tt.want})
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package parse
+
+import (
+	"strings"
+	"time"
+)
+
+// FormatMixed is reported for files that interleave several line-based
+// formats, e.g. a combined dump of several services.
+const FormatMixed = "mixed"
+
+// FormatUnknown counts lines of a mixed file that no parser recognised.
+const FormatUnknown = "unknown"
+
+// sniffLines bounds how many lines DetectFormat reads looking for a second
+// format.
+const sniffLines = 500
+
+// lineFormat classifies a single line against the line-based parsers, or
+// returns "" when none recognises it. MySQL lines also look like TSV, so TSV
+// is tried last.
+func lineFormat(line string) string {
+	switch {
+	case strings.HasPrefix(line, "{") && strings.Contains(line, "audit.k8s.io"):
+		return FormatK8sAudit
+	case isMySQLLine(line):
+		return FormatMySQL
+	case isPostgresLine(line):
+		return FormatPostgres
+	case isVPNLine(line):
+		return FormatVPN
+	case isTSVLine(line):
+		return FormatTSV
+	}
+	return ""
+}
+
+func isTSVLine(line string) bool {
+	ts, rest, ok := strings.Cut(line, "\t")
+	if !ok || rest == "" {
+		return false
+	}
+	_, err := time.Parse(time.RFC3339, ts)
+	return err == nil
+}
+
+// parseMixed parses a file line by line, routing each line to the parser for
+// its own format. Summary.Formats reports how many lines went to each format,
+// with unrecognised lines under FormatUnknown.
+func (ps pass) parseMixed(path string, maxRows, keepRows int) (Summary, []Bucket, []Event, error) {
+	f, err := ps.open(path)
+	if err != nil {
+		return Summary{}, nil, nil, err
+	}
+	defer f.Close()
+
+	my := newMySQLParser()
+	var vpn vpnParser
+	acc := newAccumulator()
+	counts := make(map[string]int)
+	rows := make([]Event, 0, min(keepRows, 4096))
+	each := ps.each
+	keep := func(evs ...Event) {
+		for _, ev := range evs {
+			acc.add(ev)
+			each(ev)
+			if keepRows <= 0 || len(rows) < keepRows {
+				rows = append(rows, ev)
+			}
+		}
+	}
+
+	sc, ls := newScanner(f)
+	for sc.Scan() {
+		acc.lines++
+		if maxRows > 0 && acc.lines > maxRows {
+			acc.lines--
+			break
+		}
+		line := sc.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		format := lineFormat(line)
+		if format == "" && continuesVPN(&vpn, line) {
+			format = FormatVPN
+		}
+		if format != FormatVPN {
+			keep(vpn.flush()...)
+		}
+		if format == "" {
+			counts[FormatUnknown]++
+			continue
+		}
+		counts[format]++
+
+		switch format {
+		case FormatK8sAudit:
+			if ev, ok := parseK8sAuditLine(line); ok {
+				keep(ev)
+			}
+		case FormatMySQL:
+			if ev, ok := my.parse(line); ok {
+				keep(ev)
+			}
+		case FormatPostgres:
+			if ev, ok := ParsePostgresLine(line); ok {
+				keep(ev)
+			}
+		case FormatVPN:
+			keep(vpn.parse(line)...)
+		case FormatTSV:
+			keep(parseTSVLine(line))
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return Summary{}, nil, nil, err
+	}
+	keep(vpn.flush()...)
+
+	sum, timeline := acc.finish()
+	sum.TruncatedLines = ls.truncated
+	sum.Formats = counts
+	return sum, timeline, rows, nil
+}
+
+// continuesVPN reports whether line belongs to a RADIUS detail record: the
+// bare timestamp that opens one, or an attribute of the record already open.
+func continuesVPN(p *vpnParser, line string) bool {
+	if p.block != nil && radiusAttr.MatchString(line) {
+		return true
+	}
+	_, msg, _, ok := splitLogTime(line)
+	return ok && strings.TrimSpace(msg) == ""
+}
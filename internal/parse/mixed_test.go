@@ -0,0 +1,61 @@
+package parse
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestParseMixed(t *testing.T) {
+	const (
+		tsv      = "2024-05-01T13:00:00Z\t203.0.113.9\tweb1\tGET\t/index.html\t200\t512"
+		postgres = `2024-05-01 13:00:01 UTC [88] [unknown]@[unknown] 203.0.113.10(40000) FATAL:  password authentication failed for user "postgres"`
+		openvpn  = "2024-05-01 13:00:02 alice/203.0.113.11:51234 [alice] Peer Connection Initiated with [AF_INET]203.0.113.11:51234"
+		noise    = "-- dump of web1, db1 and vpn-gw --"
+	)
+	radius := []string{
+		"Wed May  1 13:00:03 2024",
+		"\tAcct-Status-Type = Stop",
+		"\tUser-Name = \"carol\"",
+		"\tCalling-Station-Id = \"203.0.113.12\"",
+	}
+	tests := []struct {
+		name        string
+		lines       []string
+		wantFormat  string
+		wantFormats map[string]int
+		wantIPs     []string
+	}{
+		{
+			name:        "interleaved services",
+			lines:       append([]string{noise, tsv, postgres, openvpn}, radius...),
+			wantFormat:  FormatMixed,
+			wantFormats: map[string]int{FormatUnknown: 1, FormatTSV: 1, FormatPostgres: 1, FormatVPN: 5},
+			wantIPs:     []string{"203.0.113.9", "203.0.113.10", "203.0.113.11", "203.0.113.12"},
+		},
+		{
+			name:       "one format is not mixed",
+			lines:      []string{tsv, tsv},
+			wantFormat: FormatTSV,
+			wantIPs:    []string{"203.0.113.9", "203.0.113.9"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sum, rows := parseSample(t, []byte(strings.Join(tt.lines, "\n")+"\n"))
+			if sum.Format != tt.wantFormat {
+				t.Errorf("format %q, want %q", sum.Format, tt.wantFormat)
+			}
+			if !maps.Equal(sum.Formats, tt.wantFormats) {
+				t.Errorf("formats %v, want %v", sum.Formats, tt.wantFormats)
+			}
+			var ips []string
+			for _, ev := range rows {
+				ips = append(ips, ev.SrcIP)
+			}
+			if strings.Join(ips, " ") != strings.Join(tt.wantIPs, " ") {
+				t.Errorf("sources %v, want %v", ips, tt.wantIPs)
+			}
+		})
+	}
+}
@@ -1,41 +1,54 @@
 package parse
 
 import (
-	"bufio"
-	"os"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 )
 
 type Event struct {
-	TS     time.Time `json:"ts"`
-	SrcIP  string    `json:"srcIp,omitempty"`
-	Dst    string    `json:"dst,omitempty"`
-	Method string    `json:"method,omitempty"`
-	Path   string    `json:"path,omitempty"`
-	Status int       `json:"status,omitempty"`
-	Bytes  int64     `json:"bytes,omitempty"`
-	UA     string    `json:"ua,omitempty"`
+	TS              time.Time  `json:"ts"`
+	SrcIP           string     `json:"srcIp,omitempty"`
+	SrcClass        string     `json:"srcClass,omitempty"`
+	Anonymizer      string     `json:"anonymizer,omitempty"` // tor, vpn, proxy: the anonymizer feed listing SrcIP
+	Dst             string     `json:"dst,omitempty"`
+	Method          string     `json:"method,omitempty"`
+	Path            string     `json:"path,omitempty"`
+	Status          int        `json:"status,omitempty"`
+	Bytes           int64      `json:"bytes,omitempty"`
+	UA              string     `json:"ua,omitempty"`
+	Crawler         string     `json:"crawler,omitempty"`
+	CrawlerVerified bool       `json:"crawlerVerified,omitempty"` // forward-confirmed reverse DNS passed
+	Client          *Client    `json:"client,omitempty"`
+	Referer         string     `json:"referer,omitempty"`
+	RawQuery        string     `json:"rawQuery,omitempty"`
+	Query           url.Values `json:"query,omitempty"`
+	DurationMs      float64    `json:"durationMs,omitempty"`
+	User            string     `json:"user,omitempty"`
+	Workstation     string     `json:"workstation,omitempty"`
+	Category        string     `json:"category,omitempty"`
+	Statement       string     `json:"statement,omitempty"`
+	SessionID       string     `json:"sessionId,omitempty"`
+	Resource        string     `json:"resource,omitempty"`
+	Extras          Extras     `json:"extras,omitempty"`
 }
 
-func ParseTSVRows(path string, maxRows, keepRows int) (Summary, []Bucket, []Event, error) {
-	sum, timeline, err := ParseTSV(path, maxRows)
+func (ps pass) parseTSVRows(path string, maxRows, keepRows int) (Summary, []Bucket, []Event, error) {
+	sum, timeline, err := ps.parseTSV(path, maxRows)
 	if err != nil {
 		return Summary{}, nil, nil, err
 	}
 
-	f, err := os.Open(path)
+	f, err := ps.open(path)
 	if err != nil {
 		return Summary{}, nil, nil, err
 	}
 	defer f.Close()
 
 	rows := make([]Event, 0, min(keepRows, 4096))
-	sc := bufio.NewScanner(f)
-	const maxLine = 1024 * 1024
-	buf := make([]byte, 0, 64*1024)
-	sc.Buffer(buf, maxLine)
+	each := ps.each
+	sc, _ := newScanner(f)
 
 	seen := 0
 	for sc.Scan() {
@@ -44,49 +57,93 @@ func ParseTSVRows(path string, maxRows, keepRows int) (Summary, []Bucket, []Even
 			break
 		}
 
-		parts := strings.Split(sc.Text(), "\t")
-		var ev Event
+		ev := parseTSVLine(sc.Text())
+		each(ev)
 
-		if len(parts) > 0 {
-			if ts, err := time.Parse(time.RFC3339, parts[0]); err == nil {
-				ev.TS = ts.UTC()
-			}
-		}
-		if len(parts) > 1 {
-			ev.SrcIP = parts[1]
+		if keepRows <= 0 || len(rows) < keepRows {
+			rows = append(rows, ev)
 		}
-		if len(parts) > 2 {
-			ev.Dst = parts[2]
+	}
+	if err := sc.Err(); err != nil {
+		return Summary{}, nil, nil, err
+	}
+
+	return sum, timeline, rows, nil
+}
+
+// parseTSVLine maps the columns
+// ts, srcIP, dst, method, path[?query], status, bytes, ua, referer, duration, user
+// onto an Event. Missing or malformed columns are left zero. Any further
+// key=value columns (e.g. cache=HIT, tls=TLSv1.3, upstream_ms=12) become
+// Extras.
+func parseTSVLine(line string) Event {
+	parts := strings.Split(line, "\t")
+	var ev Event
+
+	if len(parts) > 0 {
+		if ts, err := time.Parse(time.RFC3339, parts[0]); err == nil {
+			ev.TS = ts.UTC()
 		}
-		if len(parts) > 3 {
-			ev.Method = parts[3]
+	}
+	if len(parts) > 1 {
+		ev.SrcIP = parts[1]
+	}
+	if len(parts) > 2 {
+		ev.Dst = parts[2]
+	}
+	if len(parts) > 3 {
+		ev.Method = parts[3]
+	}
+	if len(parts) > 4 {
+		ev.Path, ev.RawQuery, ev.Query = splitTarget(parts[4])
+	}
+	if len(parts) > 5 {
+		if n, err := strconv.Atoi(parts[5]); err == nil {
+			ev.Status = n
 		}
-		if len(parts) > 4 {
-			ev.Path = parts[4]
+	}
+	if len(parts) > 6 {
+		if n, err := strconv.ParseInt(parts[6], 10, 64); err == nil {
+			ev.Bytes = n
 		}
-		if len(parts) > 5 {
-			if n, err := strconv.Atoi(parts[5]); err == nil {
-				ev.Status = n
-			}
+	}
+	if len(parts) > 7 {
+		ev.UA = parts[7]
+		if c := ParseUserAgent(ev.UA); c != (Client{}) {
+			ev.Client = &c
 		}
-		if len(parts) > 6 {
-			if n, err := strconv.ParseInt(parts[6], 10, 64); err == nil {
-				ev.Bytes = n
-			}
+	}
+	if len(parts) > 8 && parts[8] != "-" {
+		ev.Referer = parts[8]
+	}
+	if len(parts) > 9 {
+		if d, ok := ParseDuration(parts[9]); ok {
+			ev.DurationMs = d
 		}
-		if len(parts) > 7 {
-			ev.UA = parts[7]
+	}
+	if len(parts) > 10 && parts[10] != "-" {
+		ev.User = parts[10]
+	}
+	for _, kv := range parts[min(len(parts), 11):] {
+		if k, v, ok := strings.Cut(kv, "="); ok && k != "" {
+			ev.Extras.SetParsed(strings.TrimSpace(k), v)
 		}
+	}
+	return ev
+}
 
-		if keepRows <= 0 || len(rows) < keepRows {
-			rows = append(rows, ev)
-		}
+// splitTarget separates a request target into its path and query string.
+// Malformed query strings still yield whatever pairs could be decoded.
+func splitTarget(target string) (path, rawQuery string, query url.Values) {
+	path, rawQuery, ok := strings.Cut(target, "?")
+	if !ok || rawQuery == "" {
+		return path, "", nil
 	}
-	if err := sc.Err(); err != nil {
-		return Summary{}, nil, nil, err
+	query, _ = url.ParseQuery(rawQuery)
+	if len(query) == 0 {
+		query = nil
 	}
-
-	return sum, timeline, rows, nil
+	return path, rawQuery, query
 }
 
 func min(a, b int) int {
@@ -0,0 +1,272 @@
+package parse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type logFile struct {
+	io.Reader
+	f *os.File
+}
+
+func (l *logFile) Close() error { return l.f.Close() }
+
+// Hooks are optional callbacks into one parse.
+type Hooks struct {
+	// Progress is called with the lines and bytes read so far in the current
+	// pass. It runs on the parsing goroutine after every read, so it should
+	// be cheap.
+	Progress func(lines int, bytes int64)
+	// Each is called with every event in file order as it is read.
+	Each func(Event)
+}
+
+// pass carries the context and hooks of one parse to the format parsers.
+type pass struct {
+	ctx context.Context
+	Hooks
+}
+
+func (ps pass) each(ev Event) {
+	if ps.Each != nil {
+		ps.Each(ev)
+	}
+}
+
+// open is openLog reading through the parse's context and progress hook.
+func (ps pass) open(path string) (io.ReadCloser, error) {
+	return openLogWith(ps.ctx, path, ps.Progress)
+}
+
+// ParseFileContext is ParseFile that stops with ctx.Err() once ctx is done
+// and calls the hooks in h as it reads.
+func ParseFileContext(ctx context.Context, path string, maxRows, keepRows int, h Hooks) (Summary, []Bucket, []Event, error) {
+	sum, timeline, rows, err := pass{ctx: ctx, Hooks: h}.parseFile(path, maxRows, keepRows)
+	if ctx.Err() != nil {
+		return Summary{}, nil, nil, ctx.Err()
+	}
+	return sum, timeline, rows, err
+}
+
+// EachEvent parses all of path, with no line cap, and calls fn with every
+// event in file order as it is read, instead of keeping them. It stops with
+// ctx.Err() once ctx is done.
+func EachEvent(ctx context.Context, path string, fn func(Event)) (Summary, error) {
+	sum, _, _, err := ParseFileContext(ctx, path, 0, 1, Hooks{Each: func(ev Event) {
+		ev.SrcClass = AddrClass(ev.SrcIP)
+		fn(ev)
+	}})
+	return sum, err
+}
+
+// progressReader counts the bytes and newlines read through it, and fails
+// once ctx is done.
+type progressReader struct {
+	r     io.Reader
+	ctx   context.Context
+	fn    func(lines int, bytes int64)
+	lines int
+	bytes int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	if p.ctx != nil && p.ctx.Err() != nil {
+		return 0, p.ctx.Err()
+	}
+	n, err := p.r.Read(b)
+	if n > 0 && p.fn != nil {
+		p.lines += bytes.Count(b[:n], []byte{'\n'})
+		p.bytes += int64(n)
+		p.fn(p.lines, p.bytes)
+	}
+	return n, err
+}
+
+// openLog opens path and returns a reader that yields UTF-8 regardless of
+// whether the file starts with a UTF-8, UTF-16LE or UTF-16BE byte order mark.
+func openLog(path string) (io.ReadCloser, error) {
+	return openLogWith(nil, path, nil)
+}
+
+// openLogWith is openLog that fails once ctx is done and reports progress to
+// fn; either may be nil.
+func openLogWith(ctx context.Context, path string, fn func(lines int, bytes int64)) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	var src io.Reader = f
+	if fn != nil || ctx != nil {
+		src = &progressReader{r: f, ctx: ctx, fn: fn}
+	}
+	br := bufio.NewReader(src)
+	head, _ := br.Peek(3)
+
+	var r io.Reader = br
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		_, _ = br.Discard(3)
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		_, _ = br.Discard(2)
+		r = &utf16Reader{src: br, order: binary.LittleEndian}
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		_, _ = br.Discard(2)
+		r = &utf16Reader{src: br, order: binary.BigEndian}
+	}
+	return &logFile{Reader: r, f: f}, nil
+}
+
+// utf16Reader transcodes a UTF-16 byte stream to UTF-8.
+type utf16Reader struct {
+	src   io.Reader
+	order binary.ByteOrder
+	in    []byte
+	out   []byte
+	err   error
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.out) == 0 {
+		if u.err != nil {
+			return 0, u.err
+		}
+		u.fill()
+	}
+	n := copy(p, u.out)
+	u.out = u.out[n:]
+	return n, nil
+}
+
+func (u *utf16Reader) fill() {
+	buf := make([]byte, 32*1024)
+	n, err := u.src.Read(buf)
+	u.in = append(u.in, buf[:n]...)
+	if err != nil {
+		u.err = err
+	}
+
+	units := make([]uint16, 0, len(u.in)/2)
+	i := 0
+	for ; i+1 < len(u.in); i += 2 {
+		units = append(units, u.order.Uint16(u.in[i:]))
+	}
+	// Hold back a trailing high surrogate until its pair arrives.
+	if u.err == nil && len(units) > 0 && utf16.IsSurrogate(rune(units[len(units)-1])) &&
+		units[len(units)-1] < 0xDC00 {
+		units = units[:len(units)-1]
+		i -= 2
+	}
+	u.in = append(u.in[:0], u.in[i:]...)
+
+	for _, r := range utf16.Decode(units) {
+		u.out = utf8.AppendRune(u.out, r)
+	}
+}
+
+var (
+	// MaxLineBytes caps the length of a single log line.
+	MaxLineBytes = 1024 * 1024
+	// TruncateLongLines keeps the first MaxLineBytes of an over-long line and
+	// moves on, instead of failing the whole parse with bufio.ErrTooLong.
+	TruncateLongLines bool
+)
+
+func newScanner(r io.Reader) (*bufio.Scanner, *lineSplitter) {
+	limit := MaxLineBytes
+	if limit <= 0 {
+		limit = 1024 * 1024
+	}
+	ls := &lineSplitter{limit: limit, truncate: TruncateLongLines}
+
+	sc := bufio.NewScanner(r)
+	buf := make([]byte, 0, min(64*1024, limit+2))
+	sc.Buffer(buf, limit+2)
+	sc.Split(ls.split)
+	return sc, ls
+}
+
+type lineSplitter struct {
+	limit     int
+	truncate  bool
+	skipping  bool
+	truncated int
+}
+
+func (s *lineSplitter) split(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if s.skipping {
+		i := bytes.IndexAny(data, "\r\n")
+		if i < 0 {
+			return len(data), nil, nil
+		}
+		if data[i] == '\r' && i+1 == len(data) && !atEOF {
+			return i, nil, nil
+		}
+		adv := i + 1
+		if data[i] == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+			adv++
+		}
+		s.skipping = false
+		return adv, nil, nil
+	}
+
+	adv, tok, err := scanLines(data, atEOF)
+	if err != nil {
+		return adv, tok, err
+	}
+	if adv > 0 {
+		if len(tok) > s.limit {
+			if !s.truncate {
+				return 0, nil, bufio.ErrTooLong
+			}
+			s.truncated++
+			tok = tok[:s.limit]
+		}
+		return adv, tok, nil
+	}
+	if len(data) >= s.limit {
+		if !s.truncate {
+			return 0, nil, bufio.ErrTooLong
+		}
+		s.truncated++
+		s.skipping = true
+		return s.limit, data[:s.limit], nil
+	}
+	return 0, nil, nil
+}
+
+// scanLines is bufio.ScanLines extended to accept lone CR line endings.
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+		return 0, nil, nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
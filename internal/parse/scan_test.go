@@ -0,0 +1,80 @@
+package parse
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+// parseSample parses data as a whole log file.
+func parseSample(t *testing.T, data []byte) (Summary, []Event) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "sample.log")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sum, _, rows, err := ParseFile(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sum, rows
+}
+
+// checkEvents compares rows with want field by field, so a failure names the
+// field that differs.
+func checkEvents(t *testing.T, rows, want []Event) {
+	t.Helper()
+	if len(rows) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(rows), len(want), rows)
+	}
+	for i := range want {
+		got, exp := reflect.ValueOf(rows[i]), reflect.ValueOf(want[i])
+		for f := range got.NumField() {
+			if !reflect.DeepEqual(got.Field(f).Interface(), exp.Field(f).Interface()) {
+				t.Errorf("event %d: %s = %#v, want %#v", i, got.Type().Field(f).Name, got.Field(f).Interface(), exp.Field(f).Interface())
+			}
+		}
+	}
+}
+
+// utf16Bytes encodes s as UTF-16 in order, after bom.
+func utf16Bytes(s string, order binary.AppendByteOrder, bom []byte) []byte {
+	out := append([]byte(nil), bom...)
+	for _, u := range utf16.Encode([]rune(s)) {
+		out = order.AppendUint16(out, u)
+	}
+	return out
+}
+
+func TestOpenLogEncodings(t *testing.T) {
+	const line1 = "2024-05-01T13:00:00Z\t203.0.113.9\tweb1\tGET\t/café\t200\t512"
+	const line2 = "2024-05-01T13:00:05Z\t203.0.113.9\tweb1\tPOST\t/login\t401\t0"
+	want := []Event{
+		{TS: time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC), SrcIP: "203.0.113.9", SrcClass: "public", Dst: "web1", Method: "GET", Path: "/café", Status: 200, Bytes: 512},
+		{TS: time.Date(2024, 5, 1, 13, 0, 5, 0, time.UTC), SrcIP: "203.0.113.9", SrcClass: "public", Dst: "web1", Method: "POST", Path: "/login", Status: 401},
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"UTF-8 with LF", []byte(line1 + "\n" + line2 + "\n")},
+		{"UTF-8 BOM with CRLF", []byte("\xEF\xBB\xBF" + line1 + "\r\n" + line2 + "\r\n")},
+		{"lone CR", []byte(line1 + "\r" + line2 + "\r")},
+		{"no final newline", []byte(line1 + "\n" + line2)},
+		{"UTF-16LE", utf16Bytes(line1+"\r\n"+line2+"\r\n", binary.LittleEndian, []byte{0xFF, 0xFE})},
+		{"UTF-16BE", utf16Bytes(line1+"\n"+line2+"\n", binary.BigEndian, []byte{0xFE, 0xFF})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sum, rows := parseSample(t, tt.data)
+			if sum.Lines != 2 {
+				t.Errorf("%d lines, want 2", sum.Lines)
+			}
+			checkEvents(t, rows, want)
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package parse
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"time"
+)
+
+// TailOptions selects the part of a file to analyze: its end, a time range,
+// or both. When several are set the smallest slice wins.
+type TailOptions struct {
+	Bytes  int64         // keep at most the last Bytes bytes
+	Window time.Duration // keep lines within Window of the newest timestamp
+	From   time.Time     // keep lines at or after From
+	To     time.Time     // keep lines at or before To
+}
+
+func (o TailOptions) Enabled() bool {
+	return o.Bytes > 0 || o.Window > 0 || !o.From.IsZero() || !o.To.IsZero()
+}
+
+// TailInfo describes the slice of a file that Tail kept.
+type TailInfo struct {
+	Offset int64      `json:"offset"`
+	Bytes  int64      `json:"bytes"`
+	Since  *time.Time `json:"since,omitempty"`
+	Until  *time.Time `json:"until,omitempty"`
+}
+
+var ErrTailUnsupported = errors.New("tail mode needs a line-based UTF-8 log")
+
+var jsonTime = regexp.MustCompile(`"(?:stageTimestamp|requestReceivedTimestamp|timestamp|time|ts)"\s*:\s*"([^"]+)"`)
+
+// lineTime extracts a leading timestamp (TSV, ISO, ctime or syslog prefix) or
+// a JSON timestamp field from line.
+func lineTime(line string) (time.Time, bool) {
+	if t, _, _, ok := splitLogTime(line); ok {
+		return t, true
+	}
+	if m := jsonTime.FindStringSubmatch(line); m != nil {
+		if t, err := time.Parse(time.RFC3339Nano, m[1]); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Tail copies the selected part of path to dest, on line boundaries, so huge
+// files can be triaged without scanning all of them. Time bounds are found by
+// binary search over byte offsets and assume the file is in roughly
+// chronological order.
+func Tail(path, dest string, opt TailOptions) (TailInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return TailInfo{}, err
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return TailInfo{}, err
+	}
+	size := st.Size()
+
+	head := make([]byte, 512)
+	n, _ := f.ReadAt(head, 0)
+	head = bytes.TrimSpace(head[:n])
+	if bytes.HasPrefix(head, []byte{0xFF, 0xFE}) || bytes.HasPrefix(head, []byte{0xFE, 0xFF}) || bytes.HasPrefix(head, []byte("<")) {
+		return TailInfo{}, ErrTailUnsupported
+	}
+
+	var info TailInfo
+	if opt.Bytes > 0 && opt.Bytes < size {
+		if info.Offset, err = nextLine(f, size-opt.Bytes); err != nil {
+			return TailInfo{}, err
+		}
+	}
+	if opt.Window > 0 {
+		newest, ok := lastTime(f, size)
+		if ok {
+			since := newest.Add(-opt.Window)
+			off, err := firstOffsetSince(f, size, since)
+			if err != nil {
+				return TailInfo{}, err
+			}
+			if off > info.Offset {
+				info.Offset = off
+			}
+			info.Since = &since
+		}
+	}
+	if !opt.From.IsZero() {
+		off, err := firstOffsetSince(f, size, opt.From)
+		if err != nil {
+			return TailInfo{}, err
+		}
+		info.Offset = max(info.Offset, off)
+		if info.Since == nil || opt.From.After(*info.Since) {
+			from := opt.From
+			info.Since = &from
+		}
+	}
+	end := size
+	if !opt.To.IsZero() {
+		// The first line after To ends the slice.
+		if end, err = firstOffsetSince(f, size, opt.To.Add(time.Nanosecond)); err != nil {
+			return TailInfo{}, err
+		}
+		end = max(end, info.Offset)
+		to := opt.To
+		info.Until = &to
+	}
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return TailInfo{}, err
+	}
+	copied, copyErr := io.Copy(out, io.NewSectionReader(f, info.Offset, end-info.Offset))
+	closeErr := out.Close()
+	if err := errors.Join(copyErr, closeErr); err != nil {
+		_ = os.Remove(dest)
+		return TailInfo{}, err
+	}
+	info.Bytes = copied
+	return info, nil
+}
+
+// nextLine returns the offset of the first line starting at or after off.
+func nextLine(f *os.File, off int64) (int64, error) {
+	if off <= 0 {
+		return 0, nil
+	}
+	prev := make([]byte, 1)
+	if _, err := f.ReadAt(prev, off-1); err != nil {
+		return 0, err
+	}
+	if prev[0] == '\n' {
+		return off, nil
+	}
+	br := bufio.NewReader(io.NewSectionReader(f, off, 1<<62))
+	skipped, err := br.ReadSlice('\n')
+	for errors.Is(err, bufio.ErrBufferFull) {
+		off += int64(len(skipped))
+		skipped, err = br.ReadSlice('\n')
+	}
+	if errors.Is(err, io.EOF) {
+		return off + int64(len(skipped)), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return off + int64(len(skipped)), nil
+}
+
+// firstTimeAt returns the first timestamp on a line starting at or after off,
+// looking at a bounded number of lines.
+func firstTimeAt(f *os.File, size, off int64) (time.Time, bool) {
+	start, err := nextLine(f, off)
+	if err != nil || start >= size {
+		return time.Time{}, false
+	}
+	sc, _ := newScanner(io.NewSectionReader(f, start, size-start))
+	for i := 0; i < 64 && sc.Scan(); i++ {
+		if t, ok := lineTime(sc.Text()); ok {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// lastTime finds the newest timestamp in the final lines of the file.
+func lastTime(f *os.File, size int64) (time.Time, bool) {
+	for back := int64(64 << 10); ; back *= 4 {
+		start := max(size-back, 0)
+		from, err := nextLine(f, start)
+		if err != nil {
+			return time.Time{}, false
+		}
+		var newest time.Time
+		sc, _ := newScanner(io.NewSectionReader(f, from, size-from))
+		for sc.Scan() {
+			if t, ok := lineTime(sc.Text()); ok && t.After(newest) {
+				newest = t
+			}
+		}
+		if !newest.IsZero() {
+			return newest, true
+		}
+		if start == 0 || back > 64<<20 {
+			return time.Time{}, false
+		}
+	}
+}
+
+// firstOffsetSince binary-searches for the earliest line boundary whose next
+// timestamp is not before since.
+func firstOffsetSince(f *os.File, size int64, since time.Time) (int64, error) {
+	lo, hi := int64(0), size
+	for hi-lo > 4096 {
+		mid := lo + (hi-lo)/2
+		t, ok := firstTimeAt(f, size, mid)
+		if ok && t.Before(since) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	// Finish with a linear scan over the last small range.
+	from, err := nextLine(f, lo)
+	if err != nil {
+		return 0, err
+	}
+	r := bufio.NewReader(io.NewSectionReader(f, from, size-from))
+	off := from
+	for {
+		line, err := r.ReadString('\n')
+		if len(line) == 0 && err != nil {
+			return size, nil
+		}
+		if t, ok := lineTime(line); ok && !t.Before(since) {
+			return off, nil
+		}
+		off += int64(len(line))
+		if err != nil {
+			return size, nil
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTail(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	// One line a minute for two days, enough for the binary search to run.
+	var b strings.Builder
+	for i := range 2880 {
+		fmt.Fprintf(&b, "%s\t203.0.113.9\tweb1\tGET\t/page/%04d\t200\t512\n", start.Add(time.Duration(i)*time.Minute).Format(time.RFC3339), i)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	lineLen := int64(len(b.String()) / 2880) // every line has the same length
+
+	tests := []struct {
+		name        string
+		opt         TailOptions
+		first, last time.Time
+	}{
+		{"last bytes", TailOptions{Bytes: 10*lineLen + 3}, start.Add(2870 * time.Minute), start.Add(2879 * time.Minute)},
+		{"last hour", TailOptions{Window: time.Hour}, start.Add(2819 * time.Minute), start.Add(2879 * time.Minute)},
+		{"from", TailOptions{From: start.Add(47 * time.Hour)}, start.Add(47 * time.Hour), start.Add(2879 * time.Minute)},
+		{"range", TailOptions{From: start.Add(10 * time.Hour), To: start.Add(10*time.Hour + 20*time.Minute)}, start.Add(10 * time.Hour), start.Add(10*time.Hour + 20*time.Minute)},
+		{"range and bytes", TailOptions{Bytes: 5*lineLen + 3, From: start.Add(47 * time.Hour)}, start.Add(2875 * time.Minute), start.Add(2879 * time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "-"))
+			info, err := Tail(path, dest, tt.opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _, rows, err := ParseFile(dest, 0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) == 0 || !rows[0].TS.Equal(tt.first) || !rows[len(rows)-1].TS.Equal(tt.last) {
+				t.Fatalf("kept %d events, want %s to %s", len(rows), tt.first, tt.last)
+			}
+			if want := int64(len(rows)) * lineLen; info.Bytes != want {
+				t.Errorf("Bytes = %d, want %d", info.Bytes, want)
+			}
+		})
+	}
+
+	for name, data := range map[string][]byte{
+		"UTF-16": {0xFF, 0xFE, '2', 0},
+		"XML":    []byte(`<Events><Event/></Events>`),
+	} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Tail(p, p+".tail", TailOptions{Bytes: 1}); !errors.Is(err, ErrTailUnsupported) {
+			t.Errorf("%s: err = %v, want ErrTailUnsupported", name, err)
+		}
+	}
+}
+
+func TestLineTime(t *testing.T) {
+	tests := []struct {
+		line string
+		want time.Time
+	}{
+		{"2024-05-01T13:00:00Z\t203.0.113.9\tweb1", time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)},
+		{"2024-05-01 15:00:00+02:00 alice/203.0.113.9:1 Peer Connection Initiated", time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)},
+		{"Wed May  1 13:00:00 2024 198.51.100.7:40000 TLS Error", time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)},
+		{`{"kind":"Event","requestReceivedTimestamp":"2024-05-01T13:00:00.5Z"}`, time.Date(2024, 5, 1, 13, 0, 0, 5e8, time.UTC)},
+		{"no timestamp here", time.Time{}},
+	}
+	for _, tt := range tests {
+		got, ok := lineTime(tt.line)
+		if ok != !tt.want.IsZero() || !got.Equal(tt.want) {
+			t.Errorf("lineTime(%q) = %s, %v; want %s", tt.line, got, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package parse
+
+import (
+	"sort"
+	"strconv"
+)
+
+// topN is how many entries each top list keeps.
+const topN = 10
+
+// Count is one entry of a top list or breakdown.
+type Count struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// Tops are the busiest source IPs, paths and user agents over the scanned
+// lines, each with at most topN entries, and the count of every status code.
+type Tops struct {
+	SrcIPs      []Count `json:"topSrcIPs"`
+	Paths       []Count `json:"topPaths"`
+	UserAgents  []Count `json:"topUserAgents"`
+	StatusCodes []Count `json:"statusCodes"`
+}
+
+type tally struct {
+	ips, paths, uas, statuses map[string]int
+}
+
+func newTally() *tally {
+	return &tally{
+		ips:      make(map[string]int),
+		paths:    make(map[string]int),
+		uas:      make(map[string]int),
+		statuses: make(map[string]int),
+	}
+}
+
+func (t *tally) add(ip, path, ua string, status int) {
+	inc(t.ips, ip)
+	inc(t.paths, path)
+	inc(t.uas, ua)
+	if status > 0 {
+		t.statuses[strconv.Itoa(status)]++
+	}
+}
+
+func inc(m map[string]int, k string) {
+	if k != "" && k != "-" {
+		m[k]++
+	}
+}
+
+func (t *tally) tops() Tops {
+	return Tops{
+		SrcIPs:      ranked(t.ips, topN),
+		Paths:       ranked(t.paths, topN),
+		UserAgents:  ranked(t.uas, topN),
+		StatusCodes: ranked(t.statuses, 0),
+	}
+}
+
+// ranked sorts counts by count, then key, keeping at most n (all when n is 0).
+func ranked(counts map[string]int, n int) []Count {
+	out := make([]Count, 0, len(counts))
+	for k, c := range counts {
+		out = append(out, Count{Key: k, Count: c})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Key < out[j].Key
+	})
+	if n > 0 && len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
@@ -1,18 +1,29 @@
 package parse
 
 import (
-	"bufio"
-	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
 type Summary struct {
-	Lines     int       `json:"lines"`
-	UniqueIPs int       `json:"uniqueIPs"`
-	Start     time.Time `json:"start"`
-	End       time.Time `json:"end"`
+	Lines          int       `json:"lines"`
+	UniqueIPs      int       `json:"uniqueIPs"`
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	Latency        *Latency  `json:"latency,omitempty"`
+	TruncatedLines int       `json:"truncatedLines,omitempty"`
+	Format         string    `json:"format,omitempty"`
+	// Formats holds per-format line counts for mixed files.
+	Formats map[string]int `json:"formats,omitempty"`
+	// Exact is false when the scan stopped at the row cap: every other
+	// figure then covers only the scanned lines, and Estimates gives
+	// approximate whole-file values with confidence intervals.
+	Exact     bool                `json:"exact"`
+	Estimates map[string]Estimate `json:"estimates,omitempty"`
+	// Top is reported at the top level of the results, not in the summary.
+	Top Tops `json:"-"`
 }
 
 type Bucket struct {
@@ -20,26 +31,26 @@ type Bucket struct {
 	Count int       `json:"count"`
 }
 
-func ParseTSV(path string, maxRows int) (Summary, []Bucket, error) {
+func (ps pass) parseTSV(path string, maxRows int) (Summary, []Bucket, error) {
 	var sum Summary
 	seenIPs := make(map[string]struct{})
 	minuteCounts := make(map[time.Time]int)
+	var durations []float64
+	top := newTally()
 
-	f, err := os.Open(path)
+	f, err := ps.open(path)
 	if err != nil {
 		return Summary{}, nil, err
 	}
 	defer f.Close()
 
-	sc := bufio.NewScanner(f)
-	const maxLine = 1024 * 1024
-	buf := make([]byte, 0, 64*1024)
-	sc.Buffer(buf, maxLine)
+	sc, ls := newScanner(f)
 
 	for sc.Scan() {
 		line := sc.Text()
 		sum.Lines++
 		if maxRows > 0 && sum.Lines > maxRows {
+			sum.Lines--
 			break
 		}
 
@@ -68,12 +79,34 @@ func ParseTSV(path string, maxRows int) (Summary, []Bucket, error) {
 
 		min := ts.Truncate(time.Minute)
 		minuteCounts[min]++
+
+		if len(parts) > 9 {
+			if d, ok := ParseDuration(parts[9]); ok {
+				durations = append(durations, d)
+			}
+		}
+
+		var path, ua string
+		var status int
+		if len(parts) > 4 {
+			path, _, _ = strings.Cut(parts[4], "?")
+		}
+		if len(parts) > 5 {
+			status, _ = strconv.Atoi(parts[5])
+		}
+		if len(parts) > 7 {
+			ua = parts[7]
+		}
+		top.add(src, path, ua, status)
 	}
 	if err := sc.Err(); err != nil {
 		return Summary{}, nil, err
 	}
 
 	sum.UniqueIPs = len(seenIPs)
+	sum.TruncatedLines = ls.truncated
+	sum.Latency = latencyOf(durations)
+	sum.Top = top.tops()
 
 	if len(minuteCounts) == 0 {
 		return sum, nil, nil
@@ -0,0 +1,110 @@
+package parse
+
+import "strings"
+
+type Client struct {
+	Browser string `json:"browser,omitempty"`
+	OS      string `json:"os,omitempty"`
+	Device  string `json:"device,omitempty"`
+	Bot     bool   `json:"bot,omitempty"`
+}
+
+var botMarkers = []string{
+	"bot", "crawl", "spider", "slurp", "curl/", "wget/", "python-requests", "python-urllib",
+	"go-http-client", "java/", "libwww", "httpclient", "okhttp", "headless", "scrapy",
+	"sqlmap", "nikto", "nuclei", "masscan", "zgrab", "nmap",
+}
+
+var browserMarkers = []struct{ marker, name string }{
+	{"edg/", "Edge"},
+	{"edge/", "Edge"},
+	{"opr/", "Opera"},
+	{"opera", "Opera"},
+	{"samsungbrowser/", "Samsung Internet"},
+	{"firefox/", "Firefox"},
+	{"fxios/", "Firefox"},
+	{"crios/", "Chrome"},
+	{"chromium/", "Chromium"},
+	{"chrome/", "Chrome"},
+	{"msie ", "Internet Explorer"},
+	{"trident/", "Internet Explorer"},
+	{"version/", "Safari"},
+	{"curl/", "curl"},
+	{"wget/", "Wget"},
+	{"python-requests", "python-requests"},
+	{"go-http-client", "Go http client"},
+}
+
+var osMarkers = []struct{ marker, name string }{
+	{"windows phone", "Windows Phone"},
+	{"windows", "Windows"},
+	{"android", "Android"},
+	{"iphone", "iOS"},
+	{"ipad", "iOS"},
+	{"ipod", "iOS"},
+	{"cros", "ChromeOS"},
+	{"mac os x", "macOS"},
+	{"macintosh", "macOS"},
+	{"linux", "Linux"},
+	{"freebsd", "FreeBSD"},
+}
+
+func ParseUserAgent(ua string) Client {
+	var c Client
+	l := strings.ToLower(strings.TrimSpace(ua))
+	if l == "" {
+		return c
+	}
+
+	for _, m := range botMarkers {
+		if strings.Contains(l, m) {
+			c.Bot = true
+			break
+		}
+	}
+	for _, m := range browserMarkers {
+		if strings.Contains(l, m.marker) {
+			c.Browser = m.name
+			break
+		}
+	}
+	for _, m := range osMarkers {
+		if containsToken(l, m.marker) {
+			c.OS = m.name
+			break
+		}
+	}
+
+	switch {
+	case c.Bot:
+		c.Device = "bot"
+	case strings.Contains(l, "ipad") || strings.Contains(l, "tablet") ||
+		(strings.Contains(l, "android") && !strings.Contains(l, "mobile")):
+		c.Device = "tablet"
+	case strings.Contains(l, "mobile") || strings.Contains(l, "iphone") || strings.Contains(l, "ipod"):
+		c.Device = "mobile"
+	case c.OS != "":
+		c.Device = "desktop"
+	}
+	return c
+}
+
+// containsToken reports whether token appears in s as whole words, so that
+// "cros" matches "X11; CrOS x86_64" but not "Microsoft".
+func containsToken(s, token string) bool {
+	for i := 0; ; {
+		j := strings.Index(s[i:], token)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(token)
+		if (start == 0 || !isAlnum(s[start-1])) && (end == len(s) || !isAlnum(s[end])) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
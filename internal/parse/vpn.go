@@ -0,0 +1,265 @@
+package parse
+
+import (
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const FormatVPN = "vpn"
+
+// VPN events use these pseudo paths: connection attempts land on a login path
+// so brute-force, impossible-travel and new-country detection apply, and
+// disconnects or accounting updates on the session path.
+const (
+	VPNLoginPath   = "/vpn/login"
+	VPNSessionPath = "/vpn/tunnel"
+)
+
+var (
+	isoPrefix    = regexp.MustCompile(`^(\d{4}-\d\d-\d\d[ T]\d\d:\d\d:\d\d)(?:\.\d+)?(Z|[+-]\d\d:?\d\d)?\s+`)
+	ctimePrefix  = regexp.MustCompile(`^([A-Z][a-z]{2} [A-Z][a-z]{2} [ \d]\d \d\d:\d\d:\d\d \d{4})(?:\s+:)?\s*`)
+	syslogPrefix = regexp.MustCompile(`^([A-Z][a-z]{2} [ \d]\d \d\d:\d\d:\d\d) (\S+) ([\w.\-/]+)(?:\[\d+\])?:\s+`)
+
+	ovpnPeer     = regexp.MustCompile(`^(?:([^/\s]+)/)?\[?([0-9A-Fa-f.:]+?)\]?:(\d+) (.*)$`)
+	ovpnInit     = regexp.MustCompile(`\[([^\]]+)\] Peer Connection Initiated`)
+	ovpnFail     = regexp.MustCompile(`(?i)verification failed|auth failed|authentication failed|TLS handshake failed|TLS Error: TLS key negotiation failed`)
+	ovpnFailUser = regexp.MustCompile(`(?i)user(?:name)?\s*[=:]?\s*'([^']+)'|\[([^\]]+)\]`)
+	ovpnExit     = regexp.MustCompile(`client-instance exiting|Inactivity timeout|SIGTERM\[|SIGUSR1\[soft,connection-reset\]|connection-reset`)
+
+	wgResponse = regexp.MustCompile(`wireguard: (\S+): Sending handshake response to peer (\d+) \(([^)]+)\)`)
+	wgInvalid  = regexp.MustCompile(`wireguard: (\S+): Invalid handshake initiation from (\S+)`)
+
+	radiusAuth = regexp.MustCompile(`(Login OK|Login incorrect)[^\[]*\[([^\]/]*)(?:/[^\]]*)?\] \(from client (\S+) port \S+(?: cli ([^\s)]+))?`)
+	radiusAttr = regexp.MustCompile(`^\s+([A-Za-z0-9\-]+) = (.*)$`)
+)
+
+func isVPNLine(s string) bool {
+	if m := radiusAttr.FindStringSubmatch(s); m != nil {
+		return m[1] == "Acct-Status-Type"
+	}
+	t, msg, host, ok := splitLogTime(s)
+	if !ok {
+		return false
+	}
+	if strings.HasPrefix(msg, "OpenVPN ") {
+		return true
+	}
+	_, ok = parseVPNMessage(t, host, msg)
+	return ok
+}
+
+// splitLogTime strips an ISO, ctime or syslog timestamp prefix and returns the
+// time, the remaining message and (for syslog) the host.
+func splitLogTime(line string) (t time.Time, msg, host string, ok bool) {
+	if m := isoPrefix.FindStringSubmatch(line); m != nil {
+		ts := strings.Replace(m[1], " ", "T", 1)
+		zone := m[2]
+		if zone == "" {
+			zone = "Z"
+		} else if zone != "Z" && !strings.Contains(zone, ":") {
+			zone = zone[:3] + ":" + zone[3:]
+		}
+		t, err := time.Parse(time.RFC3339, ts+zone)
+		return t.UTC(), line[len(m[0]):], "", err == nil
+	}
+	if m := ctimePrefix.FindStringSubmatch(line); m != nil {
+		t, err := time.Parse(time.ANSIC, m[1])
+		return t.UTC(), line[len(m[0]):], "", err == nil
+	}
+	if m := syslogPrefix.FindStringSubmatch(line); m != nil {
+		t, err := time.Parse(time.Stamp, m[1])
+		if err != nil {
+			return t, "", "", false
+		}
+		// Syslog omits the year; assume the most recent occurrence.
+		now := time.Now().UTC()
+		t = t.AddDate(now.Year(), 0, 0)
+		if t.After(now.Add(24 * time.Hour)) {
+			t = t.AddDate(-1, 0, 0)
+		}
+		return t, line[len(m[0]):], m[2], true
+	}
+	return time.Time{}, "", "", false
+}
+
+type vpnParser struct {
+	block     map[string]string
+	blockTime time.Time
+}
+
+// parse handles one line and returns any events it completes. RADIUS detail
+// records span several lines and are emitted when the next record starts or
+// at flush.
+func (p *vpnParser) parse(line string) []Event {
+	if p.block != nil {
+		if m := radiusAttr.FindStringSubmatch(line); m != nil {
+			p.block[m[1]] = strings.Trim(strings.TrimSpace(m[2]), `"`)
+			return nil
+		}
+	}
+	out := p.flush()
+
+	t, msg, host, ok := splitLogTime(line)
+	if !ok {
+		return out
+	}
+	if strings.TrimSpace(msg) == "" {
+		// A bare timestamp opens a RADIUS detail record.
+		p.block, p.blockTime = make(map[string]string), t
+		return out
+	}
+	if ev, ok := parseVPNMessage(t, host, msg); ok {
+		out = append(out, ev)
+	}
+	return out
+}
+
+func (p *vpnParser) flush() []Event {
+	if p.block == nil {
+		return nil
+	}
+	b, t := p.block, p.blockTime
+	p.block = nil
+
+	ev := Event{TS: t, User: b["User-Name"], SessionID: b["Acct-Session-Id"], Status: 200}
+	if ip := b["Calling-Station-Id"]; isIP(ip) {
+		ev.SrcIP = ip
+	} else if ip := b["Tunnel-Client-Endpoint"]; isIP(ip) {
+		ev.SrcIP = ip
+	}
+	ev.Dst = b["NAS-Identifier"]
+	if ev.Dst == "" {
+		ev.Dst = b["NAS-IP-Address"]
+	}
+	in, _ := strconv.ParseInt(b["Acct-Input-Octets"], 10, 64)
+	outB, _ := strconv.ParseInt(b["Acct-Output-Octets"], 10, 64)
+	ev.Bytes = in + outB
+	if s, err := strconv.ParseFloat(b["Acct-Session-Time"], 64); err == nil {
+		ev.DurationMs = s * 1000
+	}
+	ev.Extras.SetString("framedIp", b["Framed-IP-Address"])
+	ev.Extras.SetString("nasPort", b["NAS-Port"])
+	ev.Extras.SetString("terminateCause", b["Acct-Terminate-Cause"])
+
+	switch b["Acct-Status-Type"] {
+	case "Start":
+		ev.Method, ev.Path, ev.Category = "CONNECT", VPNLoginPath, CategoryAuth
+	case "Stop":
+		ev.Method, ev.Path, ev.Category = "DISCONNECT", VPNSessionPath, CategorySession
+	case "Interim-Update", "Alive":
+		ev.Method, ev.Path, ev.Category = "UPDATE", VPNSessionPath, CategorySession
+	default:
+		return nil
+	}
+	return []Event{ev}
+}
+
+func parseVPNMessage(t time.Time, host, msg string) (Event, bool) {
+	ev := Event{TS: t, Dst: host}
+
+	if m := radiusAuth.FindStringSubmatch(msg); m != nil {
+		ev.User, ev.Dst = m[2], m[3]
+		if isIP(m[4]) {
+			ev.SrcIP = m[4]
+		}
+		ev.Method, ev.Path, ev.Category, ev.Status = "CONNECT", VPNLoginPath, CategoryAuth, 200
+		if m[1] == "Login incorrect" {
+			ev.Method, ev.Status = "AUTH", 401
+		}
+		return ev, true
+	}
+	if m := wgResponse.FindStringSubmatch(msg); m != nil {
+		ip, _, _ := strings.Cut(m[3], ":")
+		ev.Dst, ev.User, ev.SrcIP, ev.SessionID = m[1], "peer "+m[2], ip, m[1]+"/"+m[2]
+		ev.Method, ev.Path, ev.Category, ev.Status = "CONNECT", VPNLoginPath, CategoryAuth, 200
+		return ev, true
+	}
+	if m := wgInvalid.FindStringSubmatch(msg); m != nil {
+		ip := m[2]
+		if ap, err := netip.ParseAddrPort(ip); err == nil {
+			ip = ap.Addr().String()
+		}
+		ev.Dst, ev.SrcIP = m[1], ip
+		ev.Method, ev.Path, ev.Category, ev.Status = "AUTH", VPNLoginPath, CategoryAuth, 401
+		return ev, true
+	}
+
+	// OpenVPN server messages are prefixed by [user/]ip:port of the peer.
+	m := ovpnPeer.FindStringSubmatch(msg)
+	if m == nil || !isIP(m[2]) {
+		return ev, false
+	}
+	ev.User, ev.SrcIP, ev.SessionID = m[1], m[2], m[2]+":"+m[3]
+	rest := m[4]
+	switch {
+	case ovpnInit.MatchString(rest):
+		if ev.User == "" {
+			ev.User = ovpnInit.FindStringSubmatch(rest)[1]
+		}
+		ev.Method, ev.Path, ev.Category, ev.Status = "CONNECT", VPNLoginPath, CategoryAuth, 200
+	case ovpnFail.MatchString(rest):
+		if ev.User == "" {
+			if um := ovpnFailUser.FindStringSubmatch(rest); um != nil {
+				ev.User = um[1] + um[2]
+			}
+		}
+		ev.Method, ev.Path, ev.Category, ev.Status = "AUTH", VPNLoginPath, CategoryAuth, 401
+	case ovpnExit.MatchString(rest):
+		ev.Method, ev.Path, ev.Category, ev.Status = "DISCONNECT", VPNSessionPath, CategorySession, 200
+	default:
+		return ev, false
+	}
+	return ev, true
+}
+
+func isIP(s string) bool {
+	_, err := netip.ParseAddr(s)
+	return err == nil
+}
+
+// parseVPNLog parses OpenVPN server logs, WireGuard kernel messages, FreeRADIUS
+// radius.log authentication lines and RADIUS accounting detail files into
+// connect, disconnect and failed-authentication events. Other lines are
+// counted but produce no events.
+func (ps pass) parseVPNLog(path string, maxRows, keepRows int) (Summary, []Bucket, []Event, error) {
+	f, err := ps.open(path)
+	if err != nil {
+		return Summary{}, nil, nil, err
+	}
+	defer f.Close()
+
+	var p vpnParser
+	acc := newAccumulator()
+	rows := make([]Event, 0, min(keepRows, 4096))
+	each := ps.each
+	keep := func(evs []Event) {
+		for _, ev := range evs {
+			acc.add(ev)
+			each(ev)
+			if keepRows <= 0 || len(rows) < keepRows {
+				rows = append(rows, ev)
+			}
+		}
+	}
+
+	sc, ls := newScanner(f)
+	for sc.Scan() {
+		acc.lines++
+		if maxRows > 0 && acc.lines > maxRows {
+			acc.lines--
+			break
+		}
+		keep(p.parse(sc.Text()))
+	}
+	if err := sc.Err(); err != nil {
+		return Summary{}, nil, nil, err
+	}
+	keep(p.flush())
+
+	sum, timeline := acc.finish()
+	sum.TruncatedLines = ls.truncated
+	return sum, timeline, rows, nil
+}
@@ -0,0 +1,45 @@
+package parse
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVPNParser(t *testing.T) {
+	lines := []string{
+		"2024-05-01 13:00:00 alice/203.0.113.9:51234 [alice] Peer Connection Initiated with [AF_INET]203.0.113.9:51234",
+		"Wed May  1 13:00:05 2024 198.51.100.7:40000 TLS Error: TLS key negotiation failed to occur within 60 seconds",
+		"2024-05-01T13:00:10Z kernel: wireguard: wg0: Invalid handshake initiation from 198.51.100.8:51820",
+		"2024-05-01 13:00:15 : Auth: (12) Login incorrect (pap: Cleartext password does not match): [bob/wrong] (from client vpn-gw port 0 cli 203.0.113.20)",
+		"2024-05-01 13:00:20 MULTI: multi_create_instance called",
+		"Wed May  1 13:01:00 2024",
+		"\tAcct-Status-Type = Stop",
+		"\tUser-Name = \"carol\"",
+		"\tAcct-Session-Id = \"abc123\"",
+		"\tCalling-Station-Id = \"203.0.113.30\"",
+		"\tNAS-Identifier = \"vpn-gw\"",
+		"\tAcct-Input-Octets = 1000",
+		"\tAcct-Output-Octets = 2000",
+		"\tAcct-Session-Time = 60",
+		"\tFramed-IP-Address = 10.8.0.6",
+		"\tAcct-Terminate-Cause = User-Request",
+		"2024-05-01 13:02:00 alice/203.0.113.9:51234 SIGUSR1[soft,connection-reset] received, client-instance restarting",
+	}
+	at := func(min, sec int) time.Time { return time.Date(2024, 5, 1, 13, min, sec, 0, time.UTC) }
+	want := []Event{
+		{TS: at(0, 0), SrcIP: "203.0.113.9", Method: "CONNECT", Path: VPNLoginPath, Status: 200, User: "alice", Category: CategoryAuth, SessionID: "203.0.113.9:51234"},
+		{TS: at(0, 5), SrcIP: "198.51.100.7", Method: "AUTH", Path: VPNLoginPath, Status: 401, Category: CategoryAuth, SessionID: "198.51.100.7:40000"},
+		{TS: at(0, 10), SrcIP: "198.51.100.8", Dst: "wg0", Method: "AUTH", Path: VPNLoginPath, Status: 401, Category: CategoryAuth},
+		{TS: at(0, 15), SrcIP: "203.0.113.20", Dst: "vpn-gw", Method: "AUTH", Path: VPNLoginPath, Status: 401, User: "bob", Category: CategoryAuth},
+		{TS: at(1, 0), SrcIP: "203.0.113.30", Dst: "vpn-gw", Method: "DISCONNECT", Path: VPNSessionPath, Status: 200, Bytes: 3000, DurationMs: 60000,
+			User: "carol", Category: CategorySession, SessionID: "abc123", Extras: Extras{"framedIp": "10.8.0.6", "terminateCause": "User-Request"}},
+		{TS: at(2, 0), SrcIP: "203.0.113.9", Method: "DISCONNECT", Path: VPNSessionPath, Status: 200, User: "alice", Category: CategorySession, SessionID: "203.0.113.9:51234"},
+	}
+	var p vpnParser
+	var got []Event
+	for _, l := range lines {
+		got = append(got, p.parse(l)...)
+	}
+	got = append(got, p.flush()...)
+	checkEvents(t, got, want)
+}
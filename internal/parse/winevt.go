@@ -0,0 +1,252 @@
+package parse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatTSV        = "tsv"
+	FormatWindowsXML = "windows-xml"
+)
+
+// DetectFormat sniffs the start of a log file to choose a parser. Line-based
+// files whose first lines match more than one format are FormatMixed.
+func DetectFormat(path string) (string, error) {
+	f, err := openLog(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, 4096)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	head = bytes.TrimSpace(head[:n])
+	if bytes.HasPrefix(head, []byte("<")) && bytes.Contains(head, []byte("<Event")) {
+		return FormatWindowsXML, nil
+	}
+	return sniffLineFormat(path)
+}
+
+// sniffLineFormat classifies the first sniffLines lines. TSV is the fallback
+// when none is recognised.
+func sniffLineFormat(path string) (string, error) {
+	f, err := openLog(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool)
+	first := ""
+	sc, _ := newScanner(f)
+	for n := 0; n < sniffLines && sc.Scan(); n++ {
+		format := lineFormat(sc.Text())
+		if format == "" {
+			continue
+		}
+		if first == "" {
+			first = format
+		}
+		seen[format] = true
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	switch {
+	case len(seen) > 1:
+		return FormatMixed, nil
+	case first == "":
+		return FormatTSV, nil
+	}
+	return first, nil
+}
+
+// ParseFile detects the file's format and parses it accordingly. The
+// detected format is reported in Summary.Format.
+func ParseFile(path string, maxRows, keepRows int) (Summary, []Bucket, []Event, error) {
+	return ParseFileContext(context.Background(), path, maxRows, keepRows, Hooks{})
+}
+
+func (ps pass) parseFile(path string, maxRows, keepRows int) (Summary, []Bucket, []Event, error) {
+	format, err := DetectFormat(path)
+	if err != nil {
+		return Summary{}, nil, nil, err
+	}
+	var (
+		sum      Summary
+		timeline []Bucket
+		rows     []Event
+	)
+	switch format {
+	case FormatWindowsXML:
+		sum, timeline, rows, err = ps.parseWindowsXML(path, maxRows, keepRows)
+	case FormatK8sAudit:
+		sum, timeline, rows, err = ps.parseK8sAudit(path, maxRows, keepRows)
+	case FormatPostgres, FormatMySQL:
+		sum, timeline, rows, err = ps.parseDBLog(path, format, maxRows, keepRows)
+	case FormatVPN:
+		sum, timeline, rows, err = ps.parseVPNLog(path, maxRows, keepRows)
+	case FormatMixed:
+		sum, timeline, rows, err = ps.parseMixed(path, maxRows, keepRows)
+	default:
+		sum, timeline, rows, err = ps.parseTSVRows(path, maxRows, keepRows)
+	}
+	classifyRows(rows)
+	sum.Format = format
+	sum.Exact = true
+	if err == nil && maxRows > 0 && sum.Lines >= maxRows {
+		if format == FormatWindowsXML {
+			sum.Exact = false
+		} else {
+			more, est := estimateTotals(path, sum.Lines)
+			sum.Exact, sum.Estimates = !more, est
+		}
+	}
+	return sum, timeline, rows, err
+}
+
+type winEvent struct {
+	System struct {
+		EventID     int `xml:"EventID"`
+		TimeCreated struct {
+			SystemTime string `xml:"SystemTime,attr"`
+		} `xml:"TimeCreated"`
+		Computer string `xml:"Computer"`
+	} `xml:"System"`
+	Data []struct {
+		Name  string `xml:"Name,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"EventData>Data"`
+}
+
+func (w winEvent) data(name string) string {
+	for _, d := range w.Data {
+		if d.Name == name {
+			v := strings.TrimSpace(d.Value)
+			if v == "-" {
+				return ""
+			}
+			return v
+		}
+	}
+	return ""
+}
+
+// windowsLogonStatus maps logon event IDs to a pseudo response status so the HTTP
+// oriented detectors (brute force, impossible travel) apply unchanged.
+var windowsLogonStatus = map[int]int{
+	4624: 200, // successful logon
+	4625: 401, // failed logon
+	4771: 401, // Kerberos pre-authentication failed
+	4776: 0,   // NTLM credential validation; status depends on the error code
+}
+
+// parseWindowsXML reads an exported Windows Security log (wevtutil /f:xml or
+// Get-WinEvent ... ToXml()) and maps logon events onto Events: the client
+// address becomes SrcIP, the target account User, the computer Dst, and the
+// logon outcome an HTTP-like status on the pseudo path /logon.
+func (ps pass) parseWindowsXML(path string, maxRows, keepRows int) (Summary, []Bucket, []Event, error) {
+	f, err := ps.open(path)
+	if err != nil {
+		return Summary{}, nil, nil, err
+	}
+	defer f.Close()
+
+	dec := xml.NewDecoder(bufio.NewReader(f))
+	dec.Strict = false
+	// openLog has already transcoded to UTF-8 whatever the declaration says.
+	dec.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+
+	acc := newAccumulator()
+	rows := make([]Event, 0, min(keepRows, 4096))
+	each := ps.each
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Summary{}, nil, nil, err
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "Event" {
+			continue
+		}
+		var we winEvent
+		if err := dec.DecodeElement(&we, &se); err != nil {
+			return Summary{}, nil, nil, err
+		}
+
+		status, known := windowsLogonStatus[we.System.EventID]
+		if !known {
+			continue
+		}
+		acc.lines++
+		if maxRows > 0 && acc.lines > maxRows {
+			acc.lines--
+			break
+		}
+		ev := winToEvent(we, status)
+		acc.add(ev)
+		each(ev)
+		if keepRows <= 0 || len(rows) < keepRows {
+			rows = append(rows, ev)
+		}
+	}
+
+	sum, timeline := acc.finish()
+	return sum, timeline, rows, nil
+}
+
+func winToEvent(we winEvent, status int) Event {
+	var ev Event
+	if ts, err := time.Parse(time.RFC3339Nano, we.System.TimeCreated.SystemTime); err == nil {
+		ev.TS = ts.UTC()
+	}
+	ev.SrcIP = we.data("IpAddress")
+	if ev.SrcIP == "::1" || ev.SrcIP == "127.0.0.1" {
+		ev.SrcIP = ""
+	}
+	ev.Dst = we.System.Computer
+	ev.Method = "LOGON"
+	ev.Path = "/logon"
+
+	user := we.data("TargetUserName")
+	if dom := we.data("TargetDomainName"); dom != "" && user != "" {
+		user = dom + `\` + user
+	}
+	ev.User = user
+	ev.Workstation = we.data("WorkstationName")
+	if ev.Workstation == "" {
+		ev.Workstation = we.data("Workstation")
+	}
+
+	if status == 0 {
+		// 4776 carries the NTLM result in Status; 0x0 means success.
+		status = 401
+		if s := strings.ToLower(we.data("Status")); s == "0x0" || s == "0" {
+			status = 200
+		}
+	}
+	ev.Status = status
+
+	ev.Extras.SetString("eventId", strconv.Itoa(we.System.EventID))
+	if lt, err := strconv.Atoi(we.data("LogonType")); err == nil {
+		ev.Extras.SetNumber("logonType", float64(lt))
+	}
+	ev.Extras.SetString("authPackage", we.data("AuthenticationPackageName"))
+	ev.Extras.SetString("logonProcess", strings.TrimSpace(we.data("LogonProcessName")))
+	ev.Extras.SetString("failureStatus", we.data("SubStatus"))
+	return ev
+}
@@ -0,0 +1,57 @@
+package parse
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWindowsXML(t *testing.T) {
+	const sample = `<?xml version="1.0" encoding="utf-16"?>
+<Events>
+<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event">
+  <System><EventID>4625</EventID><TimeCreated SystemTime="2024-05-01T13:00:00.1234567Z"/><Computer>DC01.corp.example</Computer></System>
+  <EventData>
+    <Data Name="TargetUserName">alice</Data><Data Name="TargetDomainName">CORP</Data>
+    <Data Name="LogonType">3</Data><Data Name="AuthenticationPackageName">NTLM</Data>
+    <Data Name="LogonProcessName">NtLmSsp </Data><Data Name="SubStatus">0xc000006a</Data>
+    <Data Name="WorkstationName">KALI</Data><Data Name="IpAddress">203.0.113.9</Data>
+  </EventData>
+</Event>
+<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event">
+  <System><EventID>4624</EventID><TimeCreated SystemTime="2024-05-01T13:01:00Z"/><Computer>DC01.corp.example</Computer></System>
+  <EventData>
+    <Data Name="TargetUserName">bob</Data><Data Name="TargetDomainName">-</Data>
+    <Data Name="LogonType">10</Data><Data Name="IpAddress">::1</Data>
+  </EventData>
+</Event>
+<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event">
+  <System><EventID>4776</EventID><TimeCreated SystemTime="2024-05-01T13:02:00Z"/><Computer>DC01.corp.example</Computer></System>
+  <EventData><Data Name="TargetUserName">carol</Data><Data Name="Workstation">WS7</Data><Data Name="Status">0x0</Data></EventData>
+</Event>
+<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event">
+  <System><EventID>4634</EventID><TimeCreated SystemTime="2024-05-01T13:03:00Z"/><Computer>DC01.corp.example</Computer></System>
+</Event>
+</Events>`
+	want := []Event{
+		{
+			TS: time.Date(2024, 5, 1, 13, 0, 0, 123456700, time.UTC), SrcIP: "203.0.113.9", SrcClass: "public", Dst: "DC01.corp.example",
+			Method: "LOGON", Path: "/logon", Status: 401, User: `CORP\alice`, Workstation: "KALI",
+			Extras: Extras{"eventId": "4625", "logonType": 3.0, "authPackage": "NTLM", "logonProcess": "NtLmSsp", "failureStatus": "0xc000006a"},
+		},
+		{
+			TS: time.Date(2024, 5, 1, 13, 1, 0, 0, time.UTC), Dst: "DC01.corp.example",
+			Method: "LOGON", Path: "/logon", Status: 200, User: "bob",
+			Extras: Extras{"eventId": "4624", "logonType": 10.0},
+		},
+		{
+			TS: time.Date(2024, 5, 1, 13, 2, 0, 0, time.UTC), Dst: "DC01.corp.example",
+			Method: "LOGON", Path: "/logon", Status: 200, User: "carol", Workstation: "WS7",
+			Extras: Extras{"eventId": "4776"},
+		},
+	}
+	sum, rows := parseSample(t, []byte(sample))
+	if sum.Format != FormatWindowsXML || sum.Lines != 3 {
+		t.Errorf("format %q with %d events, want %q with 3", sum.Format, sum.Lines, FormatWindowsXML)
+	}
+	checkEvents(t, rows, want)
+}
@@ -0,0 +1,119 @@
+// Package pdf writes simple text-only PDF documents: A4 pages of lines set
+// in the standard Helvetica fonts, which every viewer has built in, so no
+// font is embedded. Text outside Latin-1 is replaced with '?'.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// A4 page size and margin, in points.
+const (
+	PageWidth  = 595.0
+	PageHeight = 842.0
+	Margin     = 56.0
+)
+
+// Doc is a PDF document under construction.
+type Doc struct {
+	Title   string
+	Created time.Time
+	pages   []*Page
+}
+
+// Page is one page of a Doc.
+type Page struct {
+	content bytes.Buffer
+}
+
+// AddPage appends a blank page.
+func (d *Doc) AddPage() *Page {
+	p := &Page{}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// Text draws s with its baseline starting at (x, y), measured in points from
+// the bottom-left corner of the page.
+func (p *Page) Text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&p.content, "BT /%s %.1f Tf %.1f %.1f Td %s Tj ET\n", font, size, x, y, literal(s))
+}
+
+// Line draws a thin horizontal rule from x1 to x2 at height y.
+func (p *Page) Line(x1, x2, y float64) {
+	fmt.Fprintf(&p.content, "0.5 w %.1f %.1f m %.1f %.1f l S\n", x1, y, x2, y)
+}
+
+// WriteTo writes the document. A document without pages gets one blank page.
+func (d *Doc) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	var b bytes.Buffer
+	var offsets []int
+	obj := func(body string) {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// Objects 1-5 are the catalog, page tree, fonts and info; each page then
+	// takes two: the page and its content stream.
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	created := d.Created
+	if created.IsZero() {
+		created = time.Now()
+	}
+	obj(fmt.Sprintf("<< /Title %s /Producer (tenexlog) /CreationDate (D:%s) >>", literal(d.Title), created.UTC().Format("20060102150405Z")))
+	for i, p := range d.pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			PageWidth, PageHeight, 7+2*i))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.content.Len(), p.content.Bytes()))
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return b.WriteTo(w)
+}
+
+// literal encodes s as a PDF literal string in WinAnsiEncoding.
+func literal(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			b.WriteByte(' ')
+		case r < 0x80:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
@@ -0,0 +1,108 @@
+package pdf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	ref "github.com/ledongthuc/pdf"
+)
+
+// textLine is one run of text as an independent PDF reader sees it.
+type textLine struct {
+	font string
+	size float64
+	x, y float64
+	s    string
+}
+
+// readBack parses a written document with an independent PDF reader and
+// returns each page's text runs, glyphs joined per baseline.
+func readBack(t *testing.T, b []byte) (*ref.Reader, [][]textLine) {
+	t.Helper()
+	r, err := ref.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("reference reader: %v", err)
+	}
+	var pages [][]textLine
+	for i := 1; i <= r.NumPage(); i++ {
+		var lines []textLine
+		for _, g := range r.Page(i).Content().Text {
+			if n := len(lines); n > 0 && lines[n-1].y == g.Y && lines[n-1].font == g.Font {
+				lines[n-1].s += g.S
+				continue
+			}
+			lines = append(lines, textLine{font: g.Font, size: g.FontSize, x: g.X, y: g.Y, s: g.S})
+		}
+		pages = append(pages, lines)
+	}
+	return r, pages
+}
+
+func TestReferenceReader(t *testing.T) {
+	d := &Doc{Title: "Report (draft) \\ café", Created: time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)}
+	p := d.AddPage()
+	p.Text(Margin, 780, 24, true, "Incident report")
+	p.Line(Margin, PageWidth-Margin, 770)
+	p.Text(Margin, 750, 10, false, "GET /a(b)\\c from 203.0.113.9: café")
+	p.Text(Margin+12, 736, 10, false, "日本\tdone")
+	d.AddPage().Text(Margin, 780, 11, false, "Page two")
+
+	var buf bytes.Buffer
+	if _, err := d.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	r, pages := readBack(t, buf.Bytes())
+
+	want := [][]textLine{
+		{
+			{"Helvetica-Bold", 24, Margin, 780, "Incident report"},
+			{"Helvetica", 10, Margin, 750, "GET /a(b)\\c from 203.0.113.9: café"},
+			{"Helvetica", 10, Margin + 12, 736, "?? done"},
+		},
+		{
+			{"Helvetica", 11, Margin, 780, "Page two"},
+		},
+	}
+	if len(pages) != len(want) {
+		t.Fatalf("reader sees %d pages, want %d", len(pages), len(want))
+	}
+	for i := range want {
+		if len(pages[i]) != len(want[i]) {
+			t.Errorf("page %d text = %+v, want %+v", i+1, pages[i], want[i])
+			continue
+		}
+		for j, w := range want[i] {
+			if got := pages[i][j]; got != w {
+				t.Errorf("page %d line %d = %+v, want %+v", i+1, j+1, got, w)
+			}
+		}
+	}
+
+	box := r.Page(1).V.Key("MediaBox")
+	if box.Index(2).Float64() != PageWidth || box.Index(3).Float64() != PageHeight {
+		t.Errorf("MediaBox = %v, want A4", box)
+	}
+	info := r.Trailer().Key("Info")
+	if got := info.Key("Title").Text(); got != d.Title {
+		t.Errorf("Title = %q, want %q", got, d.Title)
+	}
+	if got := info.Key("CreationDate").Text(); got != "D:20240501130000Z" {
+		t.Errorf("CreationDate = %q", got)
+	}
+}
+
+func TestReferenceReaderEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := (&Doc{}).WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	_, pages := readBack(t, buf.Bytes())
+	if len(pages) != 1 || len(pages[0]) != 0 {
+		t.Errorf("pages = %+v, want one blank page", pages)
+	}
+	if !strings.HasPrefix(buf.String(), "%PDF-1.4\n") {
+		t.Errorf("header = %q", buf.String()[:10])
+	}
+}
@@ -0,0 +1,90 @@
+// Package reqctx carries per-request identity through a context: the request
+// ID, the authenticated principal and a logger that prefixes both, so log
+// lines, job records and notes attribute work to the right caller.
+package reqctx
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+)
+
+// Principal is whoever a request acts for. Org is set by authenticators that
+// know it.
+type Principal struct {
+	User  string `json:"user,omitempty"`
+	Org   string `json:"org,omitempty"`
+	Guest bool   `json:"guest,omitempty"`
+}
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	principalKey
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+func PrincipalFrom(ctx context.Context) Principal {
+	p, _ := ctx.Value(principalKey).(Principal)
+	return p
+}
+
+// Logger returns a logger whose lines are prefixed with the request ID and
+// principal found in ctx.
+func Logger(ctx context.Context) *log.Logger {
+	var b strings.Builder
+	if id := RequestID(ctx); id != "" {
+		b.WriteString("req=" + id + " ")
+	}
+	p := PrincipalFrom(ctx)
+	if p.User != "" {
+		b.WriteString("user=" + p.User + " ")
+	}
+	if p.Org != "" {
+		b.WriteString("org=" + p.Org + " ")
+	}
+	return log.New(log.Writer(), b.String(), log.Flags()|log.Lmsgprefix)
+}
+
+// Middleware assigns each request an ID, reusing a well-formed incoming
+// X-Request-ID from a trusted proxy, and echoes it in the response.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validID(id) {
+			id = httputil.NewID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+	})
+}
+
+func validID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,415 @@
+// Package rpc serves the gRPC API described in tenexlog.proto over the
+// standard library's HTTP/2 support: requests are framed and encoded by hand,
+// so no generated code or gRPC runtime is needed.
+package rpc
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/upload"
+)
+
+// gRPC status codes.
+const (
+	codeOK                 = 0
+	codeCanceled           = 1
+	codeInvalidArgument    = 3
+	codeNotFound           = 5
+	codeResourceExhausted  = 8
+	codeFailedPrecondition = 9
+	codeUnimplemented      = 12
+	codeInternal           = 13
+	codeUnavailable        = 14
+)
+
+// maxMessage caps one received message, as gRPC does by default.
+const maxMessage = 4 << 20
+
+const servicePrefix = "/tenexlog.v1.Tenexlog/"
+
+// Status is an RPC error with its gRPC status code.
+type Status struct {
+	Code    int
+	Message string
+}
+
+func (s *Status) Error() string {
+	return fmt.Sprintf("rpc error: code = %d desc = %s", s.Code, s.Message)
+}
+
+func errorf(code int, format string, args ...any) *Status {
+	return &Status{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Handler serves the Tenexlog service with s. It must be reached over
+// HTTP/2; see Server.
+func Handler(s *upload.Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		var (
+			resp []byte
+			err  error
+		)
+		switch strings.TrimPrefix(r.URL.Path, servicePrefix) {
+		case "Upload":
+			resp, err = uploadRPC(r.Context(), s, r.Body)
+		case "GetJob":
+			resp, err = unary(r.Body, func(req []byte) ([]byte, error) { return getJob(s, req) })
+		case "ListAnomalies":
+			resp, err = unary(r.Body, func(req []byte) ([]byte, error) { return listAnomalies(s, req) })
+		default:
+			err = errorf(codeUnimplemented, "unknown method %s", r.URL.Path)
+		}
+		if err != nil {
+			st, ok := err.(*Status)
+			if !ok {
+				st = errorf(codeInternal, "%v", err)
+			}
+			// A trailers-only response: the status goes in the headers.
+			w.Header().Set("Grpc-Status", strconv.Itoa(st.Code))
+			w.Header().Set("Grpc-Message", url.PathEscape(st.Message))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_ = writeMsg(w, resp)
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(codeOK))
+	})
+}
+
+// Server returns an HTTP server for h on addr that speaks HTTP/2 without TLS
+// (prior knowledge), as gRPC clients using insecure credentials expect.
+func Server(addr string, h http.Handler) *http.Server {
+	var p http.Protocols
+	p.SetUnencryptedHTTP2(true)
+	return &http.Server{Addr: addr, Handler: h, Protocols: &p}
+}
+
+// readMsg reads one length-prefixed gRPC message. It returns io.EOF when the
+// stream ends cleanly between messages.
+func readMsg(r io.Reader) ([]byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errorf(codeInvalidArgument, "truncated message")
+		}
+		return nil, err
+	}
+	if hdr[0] != 0 {
+		return nil, errorf(codeUnimplemented, "compressed messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(hdr[1:])
+	if n > maxMessage {
+		return nil, errorf(codeResourceExhausted, "message of %d bytes exceeds %d", n, maxMessage)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, errorf(codeInvalidArgument, "truncated message")
+	}
+	return msg, nil
+}
+
+func writeMsg(w io.Writer, msg []byte) error {
+	hdr := [5]byte{}
+	binary.BigEndian.PutUint32(hdr[1:], uint32(len(msg)))
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(msg)
+	return err
+}
+
+// unary reads exactly one request message and answers it with fn.
+func unary(body io.Reader, fn func(req []byte) ([]byte, error)) ([]byte, error) {
+	req, err := readMsg(body)
+	if errors.Is(err, io.EOF) {
+		return nil, errorf(codeInvalidArgument, "missing request message")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fn(req)
+}
+
+// uploadRequest is the UploadRequest message.
+type uploadRequest struct {
+	filename string
+	data     []byte
+	query    url.Values // the options, named as for POST /api/upload
+}
+
+func decodeUploadRequest(b []byte) (uploadRequest, error) {
+	req := uploadRequest{query: url.Values{}}
+	err := decode(b, func(field, wire int, v uint64, data []byte) {
+		if wire == wireVarint {
+			switch field {
+			case 5:
+				req.query.Set("excludeInternal", strconv.FormatBool(v != 0))
+			case 6:
+				if v != 0 {
+					req.query.Set("aggregate", "subnet")
+				}
+			}
+			return
+		}
+		if wire != wireBytes {
+			return
+		}
+		switch field {
+		case 1:
+			req.filename = string(data)
+		case 2:
+			req.data = data
+		case 3:
+			req.query.Add("detectors", string(data))
+		case 4:
+			req.query.Add("skipDetectors", string(data))
+		case 7:
+			req.query.Set("callbackUrl", string(data))
+		case 8:
+			req.query.Set("from", string(data))
+		case 9:
+			req.query.Set("to", string(data))
+		case 10:
+			req.query.Set("source", string(data))
+		}
+	})
+	// Repeated detector names are joined the way the query parameter lists them.
+	for _, k := range []string{"detectors", "skipDetectors"} {
+		if v := req.query[k]; len(v) > 1 {
+			req.query.Set(k, strings.Join(v, ","))
+		}
+	}
+	return req, err
+}
+
+// uploadStream reads the data of the remaining UploadRequest messages as one
+// stream, starting with what the first message carried. err keeps the
+// status of a bad message, which storage would otherwise report as a save
+// failure.
+type uploadStream struct {
+	body io.Reader
+	buf  []byte
+	err  *Status
+}
+
+func (u *uploadStream) Read(p []byte) (int, error) {
+	for len(u.buf) == 0 {
+		msg, err := readMsg(u.body)
+		if err != nil {
+			errors.As(err, &u.err)
+			return 0, err
+		}
+		req, err := decodeUploadRequest(msg)
+		if err != nil {
+			u.err = errorf(codeInvalidArgument, "%v", err)
+			return 0, u.err
+		}
+		u.buf = req.data
+	}
+	n := copy(p, u.buf)
+	u.buf = u.buf[n:]
+	return n, nil
+}
+
+func uploadRPC(ctx context.Context, s *upload.Service, body io.Reader) ([]byte, error) {
+	first, err := readMsg(body)
+	if errors.Is(err, io.EOF) {
+		return nil, errorf(codeInvalidArgument, "upload stream is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	req, err := decodeUploadRequest(first)
+	if err != nil {
+		return nil, errorf(codeInvalidArgument, "%v", err)
+	}
+	if req.filename == "" {
+		return nil, errorf(codeInvalidArgument, "the first message must set filename")
+	}
+	opts, err := upload.OptionsFromQuery(req.query)
+	if err != nil {
+		return nil, errorf(codeInvalidArgument, "%v", err)
+	}
+
+	src := &uploadStream{body: body, buf: req.data}
+	j, err := s.Enqueue(ctx, req.filename, upload.LimitUpload(src), opts)
+	if err != nil {
+		switch {
+		case src.err != nil:
+			return nil, src.err
+		case errors.Is(err, upload.ErrQueueFull):
+			return nil, errorf(codeUnavailable, "%v", err)
+		case ctx.Err() != nil:
+			return nil, errorf(codeCanceled, "%v", ctx.Err())
+		}
+		status, msg := upload.ErrorStatus(err)
+		return nil, errorf(httpCode(status), "%s", msg)
+	}
+
+	var e encoder
+	e.str(1, j.ID)
+	e.str(2, j.Status)
+	e.str(3, "/api/jobs/"+j.ID+"/status")
+	return e.b, nil
+}
+
+// httpCode maps an HTTP error status to the nearest gRPC code.
+func httpCode(status int) int {
+	switch status {
+	case http.StatusBadRequest:
+		return codeInvalidArgument
+	case http.StatusNotFound:
+		return codeNotFound
+	case http.StatusConflict:
+		return codeFailedPrecondition
+	case http.StatusServiceUnavailable:
+		return codeUnavailable
+	case http.StatusRequestEntityTooLarge:
+		return codeResourceExhausted
+	}
+	return codeInternal
+}
+
+func jobID(req []byte) (string, error) {
+	var id string
+	err := decode(req, func(field, wire int, _ uint64, data []byte) {
+		if field == 1 && wire == wireBytes {
+			id = string(data)
+		}
+	})
+	if err != nil {
+		return "", errorf(codeInvalidArgument, "%v", err)
+	}
+	if id == "" {
+		return "", errorf(codeInvalidArgument, "job_id is required")
+	}
+	return id, nil
+}
+
+func getJob(s *upload.Service, req []byte) ([]byte, error) {
+	id, err := jobID(req)
+	if err != nil {
+		return nil, err
+	}
+	j, err := s.Jobs.GetJob(id)
+	if err != nil {
+		return nil, errorf(codeNotFound, "%v", err)
+	}
+
+	var e encoder
+	e.str(1, j.ID)
+	e.str(2, j.Status)
+	e.int(3, int64(j.Progress))
+	e.str(4, j.Error)
+	if res, ok := j.Result.(upload.Results); ok {
+		var sum encoder
+		sum.str(1, res.Filename)
+		sum.int(2, res.SizeBytes)
+		sum.int(3, int64(res.Summary.Lines))
+		sum.int(4, int64(res.Summary.UniqueIPs))
+		sum.str(5, rfc3339(res.Summary.Start))
+		sum.str(6, rfc3339(res.Summary.End))
+		sum.str(7, res.Summary.Format)
+		sum.bool(8, res.Summary.Exact)
+		e.message(5, sum)
+		e.int(6, int64(len(res.Anomalies)))
+		e.str(7, res.Executive)
+		// Up to 5000 kept rows would push the message past the 4 MiB
+		// gRPC clients accept; rowsTotal still counts them and
+		// GET /api/jobs/{id}/rows pages through them.
+		res.RowsTotal, res.Rows, res.RowsCursor = len(res.Rows), nil, ""
+		raw, err := json.Marshal(res)
+		if err != nil {
+			return nil, errorf(codeInternal, "could not encode results")
+		}
+		e.bytes(8, raw)
+	}
+	return e.b, nil
+}
+
+func listAnomalies(s *upload.Service, req []byte) ([]byte, error) {
+	var id, minSeverity string
+	err := decode(req, func(field, wire int, _ uint64, data []byte) {
+		if wire != wireBytes {
+			return
+		}
+		switch field {
+		case 1:
+			id = string(data)
+		case 2:
+			minSeverity = string(data)
+		}
+	})
+	if err != nil {
+		return nil, errorf(codeInvalidArgument, "%v", err)
+	}
+	if id == "" {
+		return nil, errorf(codeInvalidArgument, "job_id is required")
+	}
+	j, err := s.Jobs.GetJob(id)
+	if err != nil {
+		return nil, errorf(codeNotFound, "%v", err)
+	}
+	res, ok := j.Result.(upload.Results)
+	if !ok {
+		return nil, errorf(codeFailedPrecondition, "job %s is %s", j.ID, j.Status)
+	}
+	anoms := res.Anomalies
+	if minSeverity != "" {
+		if anoms, ok = upload.FilterSeverity(anoms, minSeverity); !ok {
+			return nil, errorf(codeInvalidArgument, "min_severity must be one of low, medium, high, critical")
+		}
+	}
+
+	var e encoder
+	for _, a := range anoms {
+		var m encoder
+		m.str(1, a.Kind)
+		m.str(2, a.Fingerprint)
+		m.str(3, a.SrcIP)
+		m.str(4, a.Subnet)
+		m.str(5, a.User)
+		m.str(6, a.Path)
+		m.str(7, a.Severity)
+		m.double(8, a.Confidence)
+		m.str(9, a.Reason)
+		if a.FirstSeen != nil {
+			m.str(10, rfc3339(*a.FirstSeen))
+		}
+		if a.LastSeen != nil {
+			m.str(11, rfc3339(*a.LastSeen))
+		}
+		if a.Count != nil {
+			m.int(12, int64(*a.Count))
+		}
+		m.str(13, a.Stage)
+		for _, act := range a.Actions {
+			m.str(14, act)
+		}
+		m.str(15, a.State)
+		e.message(1, m)
+	}
+	return e.b, nil
+}
+
+func rfc3339(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,249 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/allensuvorov/tenexlog/internal/jobs"
+	"github.com/allensuvorov/tenexlog/internal/upload"
+)
+
+// interopClient talks to Handler with grpc-go, using messages built at run
+// time from tenexlog.proto so that the hand-written wire code is checked
+// against the published schema rather than against itself.
+type interopClient struct {
+	conn    *grpc.ClientConn
+	service protoreflect.ServiceDescriptor
+}
+
+func newInteropClient(t *testing.T) *interopClient {
+	t.Helper()
+	c := protocompile.Compiler{Resolver: &protocompile.SourceResolver{}}
+	files, err := c.Compile(context.Background(), "tenexlog.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := upload.NewService(upload.DiskStorage{Dir: t.TempDir()}, upload.FileParser{}, upload.BuiltinDetectors{}, jobs.NewMemStore(0))
+	ctx, cancel := context.WithCancel(context.Background())
+	go s.Run(ctx, 1)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := Server("", Handler(s))
+	go srv.Serve(ln)
+
+	conn, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Close()
+		cancel()
+	})
+	return &interopClient{conn: conn, service: files[0].Services().ByName("Tenexlog")}
+}
+
+func (c *interopClient) method(name string) protoreflect.MethodDescriptor {
+	return c.service.Methods().ByName(protoreflect.Name(name))
+}
+
+// message builds a request for method from field values: strings, []byte,
+// bools or []string for repeated fields.
+func message(md protoreflect.MessageDescriptor, fields map[string]any) *dynamicpb.Message {
+	m := dynamicpb.NewMessage(md)
+	for name, v := range fields {
+		fd := md.Fields().ByName(protoreflect.Name(name))
+		if list, ok := v.([]string); ok {
+			l := m.Mutable(fd).List()
+			for _, s := range list {
+				l.Append(protoreflect.ValueOfString(s))
+			}
+			continue
+		}
+		m.Set(fd, protoreflect.ValueOf(v))
+	}
+	return m
+}
+
+func (c *interopClient) call(ctx context.Context, name string, fields map[string]any) (*dynamicpb.Message, error) {
+	md := c.method(name)
+	resp := dynamicpb.NewMessage(md.Output())
+	err := c.conn.Invoke(ctx, servicePrefix+name, message(md.Input(), fields), resp)
+	return resp, err
+}
+
+func (c *interopClient) upload(ctx context.Context, reqs ...map[string]any) (*dynamicpb.Message, error) {
+	md := c.method("Upload")
+	cs, err := c.conn.NewStream(ctx, &grpc.StreamDesc{StreamName: "Upload", ClientStreams: true}, servicePrefix+"Upload")
+	if err != nil {
+		return nil, err
+	}
+	for _, fields := range reqs {
+		if err := cs.SendMsg(message(md.Input(), fields)); err != nil {
+			return nil, err
+		}
+	}
+	if err := cs.CloseSend(); err != nil {
+		return nil, err
+	}
+	resp := dynamicpb.NewMessage(md.Output())
+	return resp, cs.RecvMsg(resp)
+}
+
+func str(m protoreflect.Message, name string) string {
+	return m.Get(m.Descriptor().Fields().ByName(protoreflect.Name(name))).String()
+}
+
+func num(m protoreflect.Message, name string) int64 {
+	return m.Get(m.Descriptor().Fields().ByName(protoreflect.Name(name))).Int()
+}
+
+// probeLog is a log in which one address probes sensitive paths between
+// ordinary requests.
+func probeLog() string {
+	var b strings.Builder
+	paths := []string{"/.env", "/.git/config", "/wp-admin/", "/server-status", "/.DS_Store", "/wp-login.php"}
+	for i := range 40 {
+		ts := time.Date(2024, 5, 1, 13, 0, i, 0, time.UTC).Format(time.RFC3339)
+		ip, path := fmt.Sprintf("198.51.100.%d", i%5+1), fmt.Sprintf("/products/%d", i)
+		if i%4 == 0 {
+			ip, path = "203.0.113.9", paths[i/4%len(paths)]
+		}
+		fmt.Fprintf(&b, "%s\t%s\texample.com\tGET\t%s\t404\t12\tMozilla/5.0\n", ts, ip, path)
+	}
+	return b.String()
+}
+
+func TestGRPCInterop(t *testing.T) {
+	c := newInteropClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	log := probeLog()
+	half := len(log) / 2
+	resp, err := c.upload(ctx,
+		map[string]any{"filename": "access.log", "data": []byte(log[:half]), "detectors": []string{"sensitive_paths", "scanner_ua"}, "source": "edge"},
+		map[string]any{"data": []byte(log[half:])},
+	)
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	id := str(resp, "job_id")
+	if id == "" || str(resp, "status") != jobs.StatusQueued || str(resp, "status_url") != "/api/jobs/"+id+"/status" {
+		t.Fatalf("Upload = %v, want a queued job with its status URL", resp)
+	}
+
+	var job *dynamicpb.Message
+	for {
+		job, err = c.call(ctx, "GetJob", map[string]any{"job_id": id})
+		if err != nil {
+			t.Fatalf("GetJob: %v", err)
+		}
+		if s := str(job, "status"); s != jobs.StatusQueued && s != jobs.StatusRunning {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s := str(job, "status"); s != jobs.StatusDone {
+		t.Fatalf("job status = %s (%s), want done", s, str(job, "error"))
+	}
+	sum := job.Get(job.Descriptor().Fields().ByName("summary")).Message()
+	if str(sum, "filename") != "access.log" || num(sum, "lines") != 40 || num(sum, "unique_ips") != 6 ||
+		num(sum, "size_bytes") != int64(len(log)) || str(sum, "start") != "2024-05-01T13:00:00Z" {
+		t.Errorf("summary = %v", sum)
+	}
+	var result map[string]any
+	if err := json.Unmarshal(job.Get(job.Descriptor().Fields().ByName("result_json")).Bytes(), &result); err != nil {
+		t.Errorf("result_json: %v", err)
+	}
+	if result["rows"] != nil || result["rowsTotal"] != 40.0 {
+		t.Errorf("result_json rows = %v, rowsTotal = %v; want no rows and a total of 40", result["rows"], result["rowsTotal"])
+	}
+
+	list, err := c.call(ctx, "ListAnomalies", map[string]any{"job_id": id})
+	if err != nil {
+		t.Fatalf("ListAnomalies: %v", err)
+	}
+	anoms := list.Get(list.Descriptor().Fields().ByName("anomalies")).List()
+	if int64(anoms.Len()) != num(job, "anomaly_count") || anoms.Len() == 0 {
+		t.Fatalf("ListAnomalies returned %d anomalies, GetJob counted %d", anoms.Len(), num(job, "anomaly_count"))
+	}
+	found := false
+	for i := range anoms.Len() {
+		a := anoms.Get(i).Message()
+		if k := str(a, "kind"); k != "sensitive_paths" && k != "scanner_ua" {
+			t.Errorf("anomaly kind %s was not requested", k)
+		}
+		if s := str(a, "state"); s != "open" {
+			t.Errorf("anomaly state = %q, want open for the source's first job", s)
+		}
+		if str(a, "kind") == "sensitive_paths" && str(a, "src_ip") == "203.0.113.9" {
+			found = true
+			if conf := a.Get(a.Descriptor().Fields().ByName("confidence")).Float(); conf <= 0 || conf > 1 {
+				t.Errorf("confidence = %v", conf)
+			}
+		}
+	}
+	if !found {
+		t.Error("no sensitive_paths anomaly for 203.0.113.9")
+	}
+
+	if _, err := c.call(ctx, "ListAnomalies", map[string]any{"job_id": id, "min_severity": "severe"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ListAnomalies with min_severity severe: %v, want InvalidArgument", err)
+	}
+}
+
+func TestGRPCInteropErrors(t *testing.T) {
+	c := newInteropClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"unknown job", func() error {
+			_, err := c.call(ctx, "GetJob", map[string]any{"job_id": "nope"})
+			return err
+		}, codes.NotFound},
+		{"missing job ID", func() error {
+			_, err := c.call(ctx, "GetJob", nil)
+			return err
+		}, codes.InvalidArgument},
+		{"upload without filename", func() error {
+			_, err := c.upload(ctx, map[string]any{"data": []byte("x\n")})
+			return err
+		}, codes.InvalidArgument},
+		{"unknown detector", func() error {
+			_, err := c.upload(ctx, map[string]any{"filename": "a.log", "detectors": []string{"nope"}})
+			return err
+		}, codes.InvalidArgument},
+		{"unknown method", func() error {
+			return c.conn.Invoke(ctx, servicePrefix+"Nope", message(c.method("GetJob").Input(), nil), dynamicpb.NewMessage(c.method("GetJob").Output()))
+		}, codes.Unimplemented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(tt.call()); got != tt.want {
+				t.Errorf("code = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
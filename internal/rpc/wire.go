@@ -0,0 +1,114 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Protocol Buffers wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errMalformed = errors.New("malformed protobuf message")
+
+// encoder appends proto3 fields. Zero values are left out, as proto3 does.
+type encoder struct{ b []byte }
+
+func (e *encoder) tag(field, wire int) {
+	e.b = binary.AppendUvarint(e.b, uint64(field)<<3|uint64(wire))
+}
+
+func (e *encoder) str(field int, s string) {
+	if s == "" {
+		return
+	}
+	e.tag(field, wireBytes)
+	e.b = binary.AppendUvarint(e.b, uint64(len(s)))
+	e.b = append(e.b, s...)
+}
+
+func (e *encoder) bytes(field int, b []byte) { e.str(field, string(b)) }
+
+func (e *encoder) int(field int, n int64) {
+	if n == 0 {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.b = binary.AppendUvarint(e.b, uint64(n))
+}
+
+func (e *encoder) bool(field int, v bool) {
+	if v {
+		e.int(field, 1)
+	}
+}
+
+func (e *encoder) double(field int, f float64) {
+	if f == 0 {
+		return
+	}
+	e.tag(field, wireFixed64)
+	e.b = binary.LittleEndian.AppendUint64(e.b, math.Float64bits(f))
+}
+
+// message writes a nested message; unlike scalars it is written even when
+// empty, so that its presence is kept.
+func (e *encoder) message(field int, m encoder) {
+	e.tag(field, wireBytes)
+	e.b = binary.AppendUvarint(e.b, uint64(len(m.b)))
+	e.b = append(e.b, m.b...)
+}
+
+// decode calls fn with every field of a message: v holds varint and fixed
+// values and data the contents of length-delimited ones. Unknown fields are
+// skipped by fn simply ignoring them.
+func decode(b []byte, fn func(field, wire int, v uint64, data []byte)) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errMalformed
+		}
+		b = b[n:]
+		field, wire := int(key>>3), int(key&7)
+		var (
+			v    uint64
+			data []byte
+		)
+		switch wire {
+		case wireVarint:
+			v, n = binary.Uvarint(b)
+			if n <= 0 {
+				return errMalformed
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return errMalformed
+			}
+			v, b = binary.LittleEndian.Uint64(b), b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return errMalformed
+			}
+			v, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return errMalformed
+			}
+			data, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			return errMalformed
+		}
+		if field == 0 {
+			return errMalformed
+		}
+		fn(field, wire, v, data)
+	}
+	return nil
+}
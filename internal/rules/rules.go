@@ -0,0 +1,433 @@
+// Package rules loads custom detections from a YAML file. Each rule selects
+// events with field matchers, groups them by aggregation keys and reports a
+// group once enough matches fall inside the rule's time window. Rules
+// implement analyze.Detector and are registered like any other detector.
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/analyze"
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// Rule is one custom detection. Its name is also the anomaly kind.
+type Rule struct {
+	name        string
+	description string
+	conds       []condition
+	groupBy     []string
+	threshold   int
+	window      time.Duration
+	doc         map[string]any // the rule as decoded, for Marshal
+}
+
+type condition struct {
+	field    string
+	anyOf    []string
+	not      []string
+	prefix   string
+	contains string
+	regex    *regexp.Regexp
+	gte, lte *float64
+	exists   *bool
+}
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Load reads the rules in path.
+func Load(path string) ([]*Rule, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(src)
+}
+
+// Parse reads a document of the form
+//
+//	rules:
+//	  - name: admin_probe
+//	    description: Repeated denied requests to the admin console
+//	    match:
+//	      path:
+//	        prefix: /admin     # also regex, contains, gte, lte, not, exists
+//	      status: [401, 403]   # a value or a list of values
+//	    groupBy: [srcIp]       # default srcIp
+//	    threshold: 10          # matches per group, default 1
+//	    window: 5m             # default: the whole log
+func Parse(src []byte) ([]*Rule, error) {
+	doc, err := decodeYAML(src)
+	if err != nil {
+		return nil, err
+	}
+	top, ok := doc.(map[string]any)
+	if !ok {
+		return nil, errors.New("rules file must be a mapping with a 'rules' list")
+	}
+	list, ok := top["rules"].([]any)
+	if !ok {
+		return nil, errors.New("rules file must have a 'rules' list")
+	}
+	var out []*Rule
+	seen := make(map[string]bool)
+	for i, item := range list {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("rule %d: must be a mapping", i+1)
+		}
+		r, err := parseRule(m)
+		if err != nil {
+			if name, _ := m["name"].(string); name != "" {
+				return nil, fmt.Errorf("rule %q: %w", name, err)
+			}
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		if seen[r.name] {
+			return nil, fmt.Errorf("rule %q: duplicate name", r.name)
+		}
+		seen[r.name] = true
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// Marshal returns a rules document that Parse reads back as list. Comments
+// and layout of the file the rules came from are not kept.
+func Marshal(list []*Rule) []byte {
+	items := make([]any, len(list))
+	for i, r := range list {
+		items[i] = r.doc
+	}
+	return encodeYAML(map[string]any{"rules": items})
+}
+
+func parseRule(m map[string]any) (*Rule, error) {
+	r := &Rule{groupBy: []string{"srcIp"}, threshold: 1, doc: m}
+	for k, v := range m {
+		switch k {
+		case "name":
+			s, ok := v.(string)
+			if !ok || !validName.MatchString(s) {
+				return nil, errors.New("name must be lowercase letters, digits, '_' or '-'")
+			}
+			r.name = s
+		case "description":
+			r.description = toString(v)
+		case "match":
+			mm, ok := v.(map[string]any)
+			if !ok || len(mm) == 0 {
+				return nil, errors.New("match must be a non-empty mapping of field: matcher")
+			}
+			for field, spec := range mm {
+				c, err := parseCondition(field, spec)
+				if err != nil {
+					return nil, err
+				}
+				r.conds = append(r.conds, c)
+			}
+			sort.Slice(r.conds, func(i, j int) bool { return r.conds[i].field < r.conds[j].field })
+		case "groupBy":
+			keys, err := stringList(v)
+			if err != nil || len(keys) == 0 {
+				return nil, errors.New("groupBy must be a field or a list of fields")
+			}
+			for _, k := range keys {
+				if !parse.IsField(k) {
+					return nil, unknownField("groupBy", k)
+				}
+			}
+			r.groupBy = keys
+		case "threshold":
+			f, ok := v.(float64)
+			if !ok || f < 1 || f != float64(int(f)) {
+				return nil, errors.New("threshold must be a positive integer")
+			}
+			r.threshold = int(f)
+		case "window":
+			d, err := time.ParseDuration(toString(v))
+			if err != nil || d <= 0 {
+				return nil, errors.New("window must be a positive duration such as 5m")
+			}
+			r.window = d
+		default:
+			return nil, fmt.Errorf("unknown key %q", k)
+		}
+	}
+	if r.name == "" {
+		return nil, errors.New("name is required")
+	}
+	if len(r.conds) == 0 {
+		return nil, errors.New("match is required")
+	}
+	return r, nil
+}
+
+func parseCondition(field string, spec any) (condition, error) {
+	c := condition{field: field}
+	if !parse.IsField(field) {
+		return c, unknownField("match", field)
+	}
+	switch v := spec.(type) {
+	case []any:
+		list, err := stringList(v)
+		if err != nil {
+			return c, fmt.Errorf("match.%s: %w", field, err)
+		}
+		c.anyOf = list
+	case map[string]any:
+		for op, arg := range v {
+			var err error
+			switch op {
+			case "equals", "in":
+				c.anyOf, err = stringList(arg)
+			case "not":
+				c.not, err = stringList(arg)
+			case "prefix":
+				c.prefix = toString(arg)
+			case "contains":
+				c.contains = toString(arg)
+			case "regex":
+				c.regex, err = regexp.Compile(toString(arg))
+			case "gte", "lte":
+				f, ok := arg.(float64)
+				if !ok {
+					err = errors.New(op + " needs a number")
+				} else if op == "gte" {
+					c.gte = &f
+				} else {
+					c.lte = &f
+				}
+			case "exists":
+				b, ok := arg.(bool)
+				if !ok {
+					err = errors.New("exists needs true or false")
+				}
+				c.exists = &b
+			default:
+				err = fmt.Errorf("unknown matcher %q", op)
+			}
+			if err != nil {
+				return c, fmt.Errorf("match.%s: %w", field, err)
+			}
+		}
+	case nil:
+		return c, fmt.Errorf("match.%s: missing matcher", field)
+	default:
+		c.anyOf = []string{toString(v)}
+	}
+	return c, nil
+}
+
+func unknownField(key, field string) error {
+	return fmt.Errorf("%s: unknown field %q; use a row field such as srcIp or path, or extras.<key>", key, field)
+}
+
+func stringList(v any) ([]string, error) {
+	switch v := v.(type) {
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, x := range v {
+			if x == nil {
+				return nil, errors.New("list items must be values")
+			}
+			out = append(out, toString(x))
+		}
+		return out, nil
+	case nil:
+		return nil, errors.New("missing value")
+	}
+	return []string{toString(v)}, nil
+}
+
+func toString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func toFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func (c condition) matches(ev parse.Event) bool {
+	v, ok := ev.Lookup(c.field)
+	if c.exists != nil && ok != *c.exists {
+		return false
+	}
+	if !ok {
+		return c.exists != nil && len(c.anyOf) == 0 && c.prefix == "" && c.contains == "" &&
+			c.regex == nil && c.gte == nil && c.lte == nil
+	}
+	if len(c.anyOf) > 0 && !slices.ContainsFunc(c.anyOf, func(w string) bool { return ev.Matches(c.field, w) }) {
+		return false
+	}
+	if slices.ContainsFunc(c.not, func(w string) bool { return ev.Matches(c.field, w) }) {
+		return false
+	}
+	s := toString(v)
+	if c.prefix != "" && !strings.HasPrefix(s, c.prefix) {
+		return false
+	}
+	if c.contains != "" && !strings.Contains(strings.ToLower(s), strings.ToLower(c.contains)) {
+		return false
+	}
+	if c.regex != nil && !c.regex.MatchString(s) {
+		return false
+	}
+	if c.gte != nil || c.lte != nil {
+		f, ok := toFloat(v)
+		if !ok || (c.gte != nil && f < *c.gte) || (c.lte != nil && f > *c.lte) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Rule) Name() string { return r.name }
+
+// Info describes a loaded rule for operators.
+type Info struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Match       []string `json:"match"`
+	GroupBy     []string `json:"groupBy"`
+	Threshold   int      `json:"threshold"`
+	Window      string   `json:"window,omitempty"`
+}
+
+func (r *Rule) Info() Info {
+	in := Info{Name: r.name, Description: r.description, GroupBy: r.groupBy, Threshold: r.threshold}
+	for _, c := range r.conds {
+		in.Match = append(in.Match, c.field)
+	}
+	if r.window > 0 {
+		in.Window = r.window.String()
+	}
+	return in
+}
+
+// Analyze reports each group whose matches reach the threshold, within the
+// densest window when the rule has one.
+func (r *Rule) Analyze(events []parse.Event) []analyze.Anomaly {
+	type group struct {
+		vals  []string
+		times []time.Time
+	}
+	groups := make(map[string]*group)
+next:
+	for _, ev := range events {
+		for _, c := range r.conds {
+			if !c.matches(ev) {
+				continue next
+			}
+		}
+		if r.window > 0 && ev.TS.IsZero() {
+			continue
+		}
+		vals := make([]string, len(r.groupBy))
+		for i, f := range r.groupBy {
+			v, ok := ev.Lookup(f)
+			if !ok {
+				continue next
+			}
+			vals[i] = toString(v)
+		}
+		key := strings.Join(vals, "\x00")
+		g := groups[key]
+		if g == nil {
+			g = &group{vals: vals}
+			groups[key] = g
+		}
+		g.times = append(g.times, ev.TS.UTC())
+	}
+
+	out := make([]analyze.Anomaly, 0)
+	for _, g := range groups {
+		slices.SortFunc(g.times, func(a, b time.Time) int { return a.Compare(b) })
+		count, lo, hi := len(g.times), 0, len(g.times)-1
+		if r.window > 0 {
+			count = 0
+			for i, j := 0, 0; j < len(g.times); j++ {
+				for g.times[j].Sub(g.times[i]) >= r.window {
+					i++
+				}
+				if j-i+1 > count {
+					count, lo, hi = j-i+1, i, j
+				}
+			}
+		}
+		if count < r.threshold {
+			continue
+		}
+		a := analyze.Anomaly{
+			Kind:      r.name,
+			FirstSeen: g.times[lo],
+			LastSeen:  g.times[hi],
+			Count:     count,
+			Evidence:  []analyze.Evidence{{Signal: "matches", Value: float64(count), Threshold: float64(r.threshold), Weight: 1}},
+			Reason:    r.reason(g.vals, count),
+			Group:     make(map[string]string, len(r.groupBy)),
+		}
+		for i, f := range r.groupBy {
+			a.Group[f] = g.vals[i]
+			switch f {
+			case "srcIp":
+				a.SrcIP = g.vals[i]
+			case "path":
+				a.Path = g.vals[i]
+			}
+		}
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Reason < out[j].Reason
+	})
+	return out
+}
+
+func (r *Rule) reason(vals []string, count int) string {
+	parts := make([]string, len(r.groupBy))
+	for i, f := range r.groupBy {
+		parts[i] = f + "=" + vals[i]
+	}
+	s := "Rule " + r.name + " matched " + strconv.Itoa(count) + " event(s) for " + strings.Join(parts, ", ")
+	if r.window > 0 {
+		s += " within " + r.window.String()
+	}
+	s += " (threshold " + strconv.Itoa(r.threshold) + ")"
+	if r.description != "" {
+		s += ": " + r.description
+	}
+	return s + "."
+}
@@ -0,0 +1,430 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The rules file is read with a small YAML subset decoder, so the server does
+// not link a YAML library; the tests check it against gopkg.in/yaml.v3. It
+// handles block mappings and sequences, flow sequences ([a, b]), quoted and
+// plain scalars and # comments; anchors, multi-document streams, flow
+// mappings and block scalars (| and >) are rejected or read as plain text.
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+type yamlDecoder struct {
+	lines []yamlLine
+	pos   int
+}
+
+// decodeYAML returns the document as nested map[string]any, []any, string,
+// float64, bool and nil values.
+func decodeYAML(src []byte) (any, error) {
+	d := &yamlDecoder{}
+	for i, raw := range strings.Split(string(src), "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		lead := raw[:len(raw)-len(strings.TrimLeft(raw, " \t"))]
+		if strings.Contains(lead, "\t") && strings.TrimSpace(raw) != "" {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		text := strings.TrimRight(stripComment(raw), " ")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		d.lines = append(d.lines, yamlLine{num: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if len(d.lines) == 0 {
+		return nil, nil
+	}
+	v, err := d.block(d.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos < len(d.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", d.lines[d.pos].num)
+	}
+	return v, nil
+}
+
+// stripComment removes a # comment that starts the line or follows a space,
+// outside quotes. A quote only opens a quoted scalar where a scalar starts,
+// so the apostrophe in "reason: don't # why" is plain text.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"':
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				quote = 0
+			}
+		case quote == '\'':
+			if c == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+				i++ // '' is an escaped quote
+			} else if c == '\'' {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && startsScalar(s[:i]):
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' '):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// startsScalar reports whether a scalar starts right after before: at the
+// start of the line, after a list item's "-", or after "key:", "[" or ","
+// and optional spaces.
+func startsScalar(before string) bool {
+	t := strings.TrimRight(before, " ")
+	if t == "" {
+		return true
+	}
+	if len(t) == len(before) && !strings.HasSuffix(t, "[") && !strings.HasSuffix(t, ",") {
+		return false
+	}
+	switch t[len(t)-1] {
+	case ':', '[', ',':
+		return true
+	case '-':
+		return strings.TrimLeft(t, " -") == ""
+	}
+	return false
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (d *yamlDecoder) block(indent int) (any, error) {
+	if isSeqItem(d.lines[d.pos].text) {
+		return d.sequence(indent)
+	}
+	return d.mapping(indent)
+}
+
+func (d *yamlDecoder) sequence(indent int) ([]any, error) {
+	var out []any
+	for d.pos < len(d.lines) {
+		l := d.lines[d.pos]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent || !isSeqItem(l.text) {
+			return nil, fmt.Errorf("line %d: expected a list item", l.num)
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		switch {
+		case rest == "":
+			d.pos++
+			if d.pos < len(d.lines) && d.lines[d.pos].indent > indent {
+				v, err := d.block(d.lines[d.pos].indent)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, v)
+			} else {
+				out = append(out, nil)
+			}
+		case isMappingEntry(rest):
+			// "- key: value" opens a mapping indented to where key starts.
+			inner := l.indent + len(l.text) - len(rest)
+			d.lines[d.pos] = yamlLine{num: l.num, indent: inner, text: rest}
+			v, err := d.mapping(inner)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		default:
+			v, err := scalar(rest, l.num)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+			d.pos++
+		}
+	}
+	return out, nil
+}
+
+func (d *yamlDecoder) mapping(indent int) (map[string]any, error) {
+	out := make(map[string]any)
+	for d.pos < len(d.lines) {
+		l := d.lines[d.pos]
+		if l.indent < indent || (l.indent == indent && isSeqItem(l.text)) {
+			break
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+		}
+		key, rest, ok := splitEntry(l.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value", l.num)
+		}
+		if _, dup := out[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", l.num, key)
+		}
+		d.pos++
+		if rest != "" {
+			v, err := scalar(rest, l.num)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = v
+			continue
+		}
+		var v any
+		if d.pos < len(d.lines) {
+			next := d.lines[d.pos]
+			switch {
+			case next.indent > indent:
+				var err error
+				if v, err = d.block(next.indent); err != nil {
+					return nil, err
+				}
+			case next.indent == indent && isSeqItem(next.text):
+				var err error
+				if v, err = d.sequence(indent); err != nil {
+					return nil, err
+				}
+			}
+		}
+		out[key] = v
+	}
+	return out, nil
+}
+
+func isMappingEntry(s string) bool {
+	if s[0] == '"' || s[0] == '\'' || s[0] == '[' || s[0] == '{' {
+		return false
+	}
+	_, _, ok := splitEntry(s)
+	return ok
+}
+
+// splitEntry splits "key: value" at the first colon followed by a space or
+// the end of the line.
+func splitEntry(s string) (key, rest string, ok bool) {
+	for i := 0; i < len(s); i++ {
+		if s[i] == ':' && (i+1 == len(s) || s[i+1] == ' ') {
+			key = strings.TrimSpace(s[:i])
+			if k, err := unquote(key); err == nil {
+				key = k
+			}
+			return key, strings.TrimSpace(s[i+1:]), key != ""
+		}
+	}
+	return "", "", false
+}
+
+func scalar(s string, line int) (any, error) {
+	switch {
+	case s[0] == '[':
+		return flowSeq(s, line)
+	case s[0] == '{':
+		return nil, fmt.Errorf("line %d: flow mappings are not supported", line)
+	case s[0] == '|' || s[0] == '>':
+		return nil, fmt.Errorf("line %d: block scalars are not supported", line)
+	case s[0] == '&' || s[0] == '*':
+		return nil, fmt.Errorf("line %d: anchors and aliases are not supported", line)
+	case isSeqItem(s):
+		return nil, fmt.Errorf("line %d: nested lists must start on their own line", line)
+	case s[0] == '"' || s[0] == '\'':
+		v, err := unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		return v, nil
+	}
+	switch s {
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	case "null", "Null", "NULL", "~":
+		return nil, nil
+	}
+	if yamlNumber.MatchString(s) {
+		f, _ := strconv.ParseFloat(s, 64)
+		return f, nil
+	}
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'o') {
+		if n, err := strconv.ParseInt(s, 0, 64); err == nil {
+			return float64(n), nil
+		}
+	}
+	return s, nil
+}
+
+// yamlNumber matches the decimal numbers of the YAML 1.2 core schema. Words
+// strconv.ParseFloat also takes, such as inf and nan, stay strings.
+var yamlNumber = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+
+func unquote(s string) (string, error) {
+	if len(s) < 2 {
+		return s, errors.New("not quoted")
+	}
+	switch {
+	case s[0] == '"' && s[len(s)-1] == '"':
+		return strconv.Unquote(s)
+	case s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	if s[0] == '"' || s[0] == '\'' {
+		return "", errors.New("unterminated quoted string")
+	}
+	return s, errors.New("not quoted")
+}
+
+// flowSeq parses a single-line [a, "b", 3] list of scalars.
+func flowSeq(s string, line int) ([]any, error) {
+	if s[len(s)-1] != ']' {
+		return nil, fmt.Errorf("line %d: unterminated list", line)
+	}
+	body := strings.TrimSpace(s[1 : len(s)-1])
+	out := []any{}
+	if body == "" {
+		return out, nil
+	}
+	var (
+		quote byte
+		start int
+	)
+	for i := 0; i <= len(body); i++ {
+		if i < len(body) {
+			c := body[i]
+			if quote != 0 {
+				if c == '\\' && quote == '"' {
+					i++
+				} else if c == quote {
+					quote = 0
+				}
+				continue
+			}
+			if c == '"' || c == '\'' {
+				quote = c
+				continue
+			}
+			if c == '[' || c == '{' {
+				return nil, fmt.Errorf("line %d: nested lists are not supported", line)
+			}
+			if c != ',' {
+				continue
+			}
+		}
+		item := strings.TrimSpace(body[start:i])
+		if item == "" {
+			return nil, fmt.Errorf("line %d: empty list item", line)
+		}
+		v, err := scalar(item, line)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+		start = i + 1
+	}
+	return out, nil
+}
+
+// encodeYAML writes v, a value as decodeYAML returns it, as a block-style
+// document that decodeYAML reads back unchanged. Strings are double-quoted,
+// mapping keys sorted with "name" first, and nested values indented by two.
+func encodeYAML(v any) []byte {
+	var b strings.Builder
+	encodeBlock(&b, v, 0)
+	return []byte(b.String())
+}
+
+func encodeBlock(b *strings.Builder, v any, indent int) {
+	pad := strings.Repeat(" ", indent)
+	switch v := v.(type) {
+	case map[string]any:
+		for _, k := range sortedKeys(v) {
+			b.WriteString(pad + encodeKey(k) + ":")
+			encodeValue(b, v[k], indent)
+		}
+	case []any:
+		for _, e := range v {
+			if m, ok := e.(map[string]any); ok {
+				// "- key: value" with the other keys lined up under key.
+				var item strings.Builder
+				encodeBlock(&item, m, indent+2)
+				b.WriteString(pad + "- " + item.String()[indent+2:])
+				continue
+			}
+			b.WriteString(pad + "-")
+			encodeValue(b, e, indent)
+		}
+	default:
+		b.WriteString(pad + encodeScalar(v) + "\n")
+	}
+}
+
+// encodeValue writes what follows a "key:" or "-": a scalar on the same
+// line, or a nested block on the lines below.
+func encodeValue(b *strings.Builder, v any, indent int) {
+	switch e := v.(type) {
+	case map[string]any:
+		b.WriteString("\n")
+		encodeBlock(b, e, indent+2)
+	case []any:
+		if len(e) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		encodeBlock(b, e, indent+2)
+	default:
+		b.WriteString(" " + encodeScalar(e) + "\n")
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if (keys[i] == "name") != (keys[j] == "name") {
+			return keys[i] == "name"
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+var plainKey = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+
+func encodeKey(k string) string {
+	if plainKey.MatchString(k) {
+		return k
+	}
+	return strconv.Quote(k)
+}
+
+func encodeScalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return strconv.Quote(v)
+	}
+	return strconv.Quote(fmt.Sprint(v))
+}
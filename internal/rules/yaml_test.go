@@ -0,0 +1,219 @@
+package rules
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDecodeYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    any
+		wantErr string
+	}{
+		{name: "empty", src: "# only a comment\n", want: nil},
+		{
+			name: "scalars",
+			src:  "s: plain text\nn: 3.5\nb: true\nz: ~\nq: \"a # b\"\nsq: 'it''s'\n",
+			want: map[string]any{"s": "plain text", "n": 3.5, "b": true, "z": nil, "q": "a # b", "sq": "it's"},
+		},
+		{
+			name: "comments",
+			src:  "reason: don't # why\nurl: http://x/#frag\n",
+			want: map[string]any{"reason": "don't", "url": "http://x/#frag"},
+		},
+		{
+			name: "nested",
+			src:  "rules:\n  - name: a\n    match:\n      status: [401, \"403\"]\n  - name: b\n",
+			want: map[string]any{"rules": []any{
+				map[string]any{"name": "a", "match": map[string]any{"status": []any{401.0, "403"}}},
+				map[string]any{"name": "b"},
+			}},
+		},
+		{
+			name: "sequence at key indent",
+			src:  "groupBy:\n- srcIp\n- user\n",
+			want: map[string]any{"groupBy": []any{"srcIp", "user"}},
+		},
+		{
+			name: "nested list on its own line",
+			src:  "-\n  - a\n  - b\n",
+			want: []any{[]any{"a", "b"}},
+		},
+		{
+			name: "number-like words",
+			src:  "a: inf\nb: nan\nc: 0x1F\nd: .5\n",
+			want: map[string]any{"a": "inf", "b": "nan", "c": 31.0, "d": 0.5},
+		},
+		{name: "empty flow list", src: "a: []\n", want: map[string]any{"a": []any{}}},
+		{name: "tab indent", src: "a:\n\tb: 1\n", wantErr: "tabs are not allowed"},
+		{name: "duplicate key", src: "a: 1\na: 2\n", wantErr: `duplicate key "a"`},
+		{name: "bad indent", src: "a: 1\n  b: 2\n", wantErr: "unexpected indentation"},
+		{name: "not a mapping", src: "a: 1\njust text\n", wantErr: "expected key: value"},
+		{name: "inline nested list", src: "a: - b\n", wantErr: "nested lists must start on their own line"},
+		{name: "inline nested item", src: "- - a\n", wantErr: "nested lists must start on their own line"},
+		{name: "flow mapping", src: "a: {b: 1}\n", wantErr: "flow mappings are not supported"},
+		{name: "block scalar", src: "a: |\n", wantErr: "block scalars are not supported"},
+		{name: "anchor", src: "a: &x 1\n", wantErr: "anchors and aliases are not supported"},
+		{name: "unterminated quote", src: "a: \"b\n", wantErr: "unterminated quoted string"},
+		{name: "unterminated list", src: "a: [b, c\n", wantErr: "unterminated list"},
+		{name: "nested flow list", src: "a: [b, [c]]\n", wantErr: "nested lists are not supported"},
+		{name: "empty list item", src: "a: [b, , c]\n", wantErr: "empty list item"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeYAML([]byte(tt.src))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("decodeYAML error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeYAML: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeYAML = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripComment(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"# all comment", ""},
+		{"a: b # note", "a: b "},
+		{"a: b#c", "a: b#c"},
+		{`a: "b # c" # d`, `a: "b # c" `},
+		{`a: 'b '' # c' # d`, `a: 'b '' # c' `},
+		{"reason: don't # why", "reason: don't "},
+		{`- "x # y"`, `- "x # y"`},
+		{`a: [b, "c # d"] # e`, `a: [b, "c # d"] `},
+		{`a: "b \" # c"`, `a: "b \" # c"`},
+	}
+	for _, tt := range tests {
+		if got := stripComment(tt.in); got != tt.want {
+			t.Errorf("stripComment(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestDecodeYAMLMatchesYAMLv3 checks that every document the subset decoder
+// accepts decodes to the same values with gopkg.in/yaml.v3.
+func TestDecodeYAMLMatchesYAMLv3(t *testing.T) {
+	docs := []string{
+		"s: plain text\nn: 3.5\nb: true\nz: ~\nq: \"a # b\"\nsq: 'it''s'\n",
+		"reason: don't # why\nurl: http://x/#frag\n",
+		"rules:\n  - name: a\n    match:\n      status: [401, \"403\"]\n  - name: b\n",
+		"groupBy:\n- srcIp\n- user\n",
+		"-\n  - a\n  - b\n",
+		"a: []\n",
+		"a: True\nb: FALSE\nc: null\nd: Null\ne: -7\nf: 1e3\n",
+		"name: inf\nother: nan\nhex: 0x1F\nword: infinity\n",
+		"q: \"tab\\tand \\\"quote\\\"\"\nk: 'x: y'\n\"quoted key\": 1\n",
+		"---\nrules:\n  - name: admin_probe\n    description: Repeated denied requests\n    match:\n      path:\n        prefix: /admin\n      status: [401, 403]\n    groupBy: [srcIp]\n    threshold: 10\n    window: 5m\n",
+		"a:\n  b:\n    c: [x, 'y, z', \"w\"]\n  d: .5\n",
+	}
+	for _, src := range docs {
+		got, err := decodeYAML([]byte(src))
+		if err != nil {
+			t.Errorf("decodeYAML(%q): %v", src, err)
+			continue
+		}
+		var want any
+		if err := yaml.Unmarshal([]byte(src), &want); err != nil {
+			t.Fatalf("yaml.v3 rejects %q: %v", src, err)
+		}
+		if want = normalizeYAML(want); !reflect.DeepEqual(got, want) {
+			t.Errorf("decodeYAML(%q) = %#v, yaml.v3 = %#v", src, got, want)
+		}
+	}
+}
+
+// normalizeYAML turns yaml.v3's integers into the float64s decodeYAML
+// returns for every number.
+func normalizeYAML(v any) any {
+	switch v := v.(type) {
+	case int:
+		return float64(v)
+	case map[string]any:
+		for k, e := range v {
+			v[k] = normalizeYAML(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = normalizeYAML(e)
+		}
+	}
+	return v
+}
+
+func TestEncodeYAMLRoundTrip(t *testing.T) {
+	docs := []string{
+		"s: plain text\nn: 3.5\nb: true\nz: ~\nq: \"a # b\"\nsq: 'it''s'\n",
+		"q: \"tab\\tand \\\"quote\\\"\"\nk: 'x: y'\n\"quoted key\": 1\nu: \"caf\\u00e9\"\n",
+		"rules:\n  - name: a\n    match:\n      status: [401, \"403\"]\n      path:\n        regex: '^/api/v\\d+/'\n  - name: b\n    groupBy: []\n",
+		"-\n  - a\n  - b\n- name: x\n  y: 1e6\n",
+	}
+	for _, src := range docs {
+		want, err := decodeYAML([]byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := encodeYAML(want)
+		got, err := decodeYAML(out)
+		if err != nil {
+			t.Errorf("decodeYAML(encodeYAML(%q)) = %v for\n%s", src, err, out)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("round trip of %q = %#v, want %#v; encoded as\n%s", src, got, want, out)
+		}
+		var v3 any
+		if err := yaml.Unmarshal(out, &v3); err != nil {
+			t.Errorf("yaml.v3 rejects\n%s: %v", out, err)
+		} else if v3 = normalizeYAML(v3); !reflect.DeepEqual(v3, want) {
+			t.Errorf("yaml.v3 reads\n%s as %#v, want %#v", out, v3, want)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	const src = `rules:
+  - name: admin_probe   # comments are dropped
+    description: Repeated denied requests
+    match:
+      path:
+        prefix: /admin
+      status: [401, 403]
+    threshold: 10
+    window: 5m
+  - name: exec
+    match:
+      resource: pods/exec
+`
+	list, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := Marshal(list)
+	if !strings.HasPrefix(string(out), "rules:\n  - name: \"admin_probe\"\n") {
+		t.Errorf("Marshal output starts\n%s", out)
+	}
+	again, err := Parse(out)
+	if err != nil {
+		t.Fatalf("Parse(Marshal()) = %v for\n%s", err, out)
+	}
+	if len(again) != len(list) {
+		t.Fatalf("got %d rules back, want %d", len(again), len(list))
+	}
+	for i := range list {
+		if !reflect.DeepEqual(again[i].Info(), list[i].Info()) {
+			t.Errorf("rule %d: %+v, want %+v", i, again[i].Info(), list[i].Info())
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package upload
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// RecommendedActions maps an anomaly kind to the next steps shown alongside it.
+// Entries can be overridden at startup with LoadRecommendedActions.
+var RecommendedActions = map[string][]string{
+	"rate_spike": {
+		"Check whether the source IP belongs to a known client, monitor or partner integration.",
+		"Apply rate limiting or a temporary block to the source IP at the edge or WAF.",
+	},
+	"known_bad_ip": {
+		"Block the source IP at the edge; it is on a threat-intel blocklist.",
+		"Review any requests from it that received 2xx responses.",
+	},
+	"sensitive_paths": {
+		"Block the source IP and review WAF rules covering admin and dotfile paths.",
+		"Confirm that the probed endpoints are not publicly reachable and that no requests succeeded.",
+		"Rotate credentials for any admin interface that returned 2xx to this source.",
+	},
+	"anonymized_probe": {
+		"Block or challenge the source at the edge; it is a TOR exit, VPN or proxy address.",
+		"Consider denying anonymizer traffic to admin and other sensitive endpoints outright.",
+		"Check whether any of the probed paths returned 2xx to this source.",
+	},
+	"auth_bruteforce": {
+		"Block or challenge the source IP (CAPTCHA, step-up auth) on login endpoints.",
+		"Reset credentials and force MFA for any targeted account that later logged in successfully.",
+		"Enable or tighten account lockout and per-IP login rate limits.",
+	},
+	"forced_browsing": {
+		"Block the source IP; directory brute-forcing is rarely legitimate.",
+		"Check the listed top paths for any that returned 2xx elsewhere in the log.",
+	},
+	"latency_spike": {
+		"Correlate the slowdown with deploys, traffic surges and database or upstream latency at the same time.",
+		"Profile the listed endpoint for slow queries or lock contention.",
+	},
+	"server_error_burst": {
+		"Correlate the burst with deploys, config changes and upstream or database incidents around the same time.",
+		"Check application and upstream logs for the listed paths to find the failing dependency.",
+		"If the errors followed a spike in traffic, check whether the service was overloaded.",
+	},
+	"distributed_attack": {
+		"Protect the endpoint as a whole rather than per IP: apply an endpoint-wide rate limit, a challenge (CAPTCHA) or temporarily restrict it.",
+		"Look for a shared trait among the sources (ASN, User-Agent, country) that can be blocked at the edge.",
+		"Review the related per-IP anomalies listed with the campaign.",
+	},
+	"id_enumeration": {
+		"Check that the endpoint enforces object-level authorization, so a client cannot read records it does not own by changing the ID.",
+		"Rate-limit the endpoint per client and consider non-guessable identifiers.",
+		"Review which of the requested records were returned (2xx) to scope possible data exposure.",
+	},
+	"response_size": {
+		"Check what the endpoint returned: an unpaginated export, a database dump or a successful injection can all produce oversized responses.",
+		"Enforce page size and response limits on the endpoint.",
+		"Review the client's other requests around the same time for signs of data exfiltration.",
+	},
+	"rare_method": {
+		"Disable TRACE, TRACK, DEBUG and WebDAV methods at the web server or proxy unless the application needs them.",
+		"Make sure the server does not act as an open proxy for CONNECT requests.",
+		"Treat the source as reconnaissance and watch it for follow-up exploitation attempts.",
+	},
+	"path_rate_spike": {
+		"Check whether the endpoint is a login, search or other expensive route being abused, and apply per-endpoint rate limits.",
+		"Review the top source IPs and consider a challenge (CAPTCHA, proof-of-work) for the endpoint.",
+	},
+	"traffic_surge": {
+		"Check whether the surge matches a release, campaign or known batch job before treating it as an attack.",
+		"If it is a distributed flood, enable edge rate limiting or DDoS protection for the affected endpoints.",
+	},
+	"error_rate": {
+		"Correlate the spike minute with deploys, upstream outages and WAF changes.",
+		"Break the failing requests down by path and source to spot exploitation attempts rotating IPs.",
+	},
+	"low_and_slow": {
+		"Block the source IP; per-minute rate limits will not stop it.",
+		"Review which of the enumerated paths returned 2xx and whether they should be public.",
+	},
+	"impossible_travel": {
+		"Revoke the user's active sessions and require re-authentication with MFA.",
+		"Confirm with the user whether either location is legitimate (VPN, travel) before unlocking.",
+	},
+	"scanner_ua": {
+		"Block the source IP and add a WAF rule rejecting the tool's User-Agent.",
+		"Check whether the scan was an authorized penetration test before escalating.",
+		"Review responses to the scanner for 2xx/5xx hits that suggest a finding.",
+	},
+	"new_country_login": {
+		"Confirm the login with the account owner before treating it as travel.",
+		"Revoke the session and reset credentials if the country is not expected.",
+	},
+	"concurrent_sessions": {
+		"Check whether the account is shared or its credentials or certificate are in use by more than one person.",
+		"Terminate the sessions, rotate the account's credentials and enforce one session per user on the VPN.",
+	},
+	"abnormal_ua": {
+		"Rate limit or challenge the source IP; real browsers send a stable User-Agent.",
+		"Check whether the source is an internal script or health check that should set a descriptive User-Agent.",
+	},
+	"sqli": {
+		"Block the source IP and enable or tighten WAF SQL injection rules for the targeted endpoints.",
+		"Check the samples that returned 2xx or 5xx and review those handlers for unparameterized queries.",
+		"Review database logs for the same time range for unexpected reads of user or schema tables.",
+	},
+	"log4shell": {
+		"Block the source IP and the callback hosts named in the payloads at the egress firewall.",
+		"Confirm every Java service behind this endpoint runs Log4j 2.17.1 or later.",
+		"Search DNS and egress logs for lookups of the callback hosts; a hit means the payload was evaluated.",
+	},
+	"k8s_forbidden_burst": {
+		"Identify where the user's token or kubeconfig is used from and revoke it if the source is unexpected.",
+		"Review the denied verbs and resources for signs of privilege discovery (e.g. auth can-i style probing).",
+	},
+	"k8s_exec_spike": {
+		"Confirm the exec sessions with the user; interactive access to many pods is rarely routine.",
+		"Restrict pods/exec and pods/portforward via RBAC and admission policy to break-glass roles.",
+	},
+	"k8s_secrets_access": {
+		"Rotate the secrets the user read if the access was not expected.",
+		"Remove get/list/watch on secrets from the user's roles unless strictly required.",
+	},
+	"path_traversal": {
+		"Block the source IP and enable WAF rules for directory traversal and file inclusion.",
+		"Check samples that returned 2xx for leaked file contents and review how those handlers build file paths.",
+		"Disable allow_url_include and unused PHP stream wrappers where PHP is in use.",
+	},
+	"db_privilege_burst": {
+		"Confirm the grants and role changes with the account owner or a change ticket.",
+		"Revert unexpected privileges and rotate the credentials of the issuing account.",
+	},
+	"behavior_cluster": {
+		"Review the source's requests: its mix of rate, errors, paths, User-Agents and response sizes matches none of the other clients.",
+		"Check the traits listed in indicators against known automation (monitors, API clients) before blocking it.",
+		"Watch the source for follow-up findings from the rule-based detectors.",
+	},
+	"behavioral_outlier": {
+		"Look at the source's requests in the flagged minute; the indicators name the features that made it stand out.",
+		"Treat this as a lead rather than a verdict: it fires on anything unusual, benign or not, and no rule-based detector matched it.",
+	},
+	"db_bulk_select": {
+		"Check whether the client is a known report, backup or ETL job.",
+		"Review which tables were read and restrict the account to the data it needs.",
+	},
+}
+
+// LoadRecommendedActions reads a JSON object of kind -> []action from path and
+// merges it over the defaults. An empty list removes the actions for a kind.
+func LoadRecommendedActions(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var m map[string][]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	for kind, acts := range m {
+		if len(acts) == 0 {
+			delete(RecommendedActions, kind)
+			continue
+		}
+		RecommendedActions[kind] = acts
+	}
+	return nil
+}
+
+func actionsFor(kind string) []string {
+	return RecommendedActions[kind]
+}
@@ -0,0 +1,139 @@
+package upload
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Actor groups every anomaly raised against one source IP, or one subnet
+// when anomalies were aggregated by subnet.
+type Actor struct {
+	IP        string     `json:"ip"`
+	Score     float64    `json:"score"`
+	Severity  string     `json:"severity"`
+	Stages    []string   `json:"stages,omitempty"` // in kill-chain order
+	Kinds     []string   `json:"kinds"`
+	Anomalies []string   `json:"anomalies"` // fingerprints, by stage then time
+	Count     int        `json:"count"`
+	FirstSeen *time.Time `json:"firstSeen,omitempty"`
+	LastSeen  *time.Time `json:"lastSeen,omitempty"`
+	Recurrent bool       `json:"recurrent,omitempty"`
+	Reason    string     `json:"reason"`
+}
+
+// actors merges anomalies by source IP. The score combines confidences as
+// independent evidence (1 - Π(1 - c)), and the severity is the highest of the
+// actor's anomalies, one step higher when three or more kinds agree. Kinds and
+// anomalies are listed stage by stage along the kill chain, so the reason
+// reads as the story of the attack.
+func actors(anoms []Anomaly) []Actor {
+	type step struct {
+		stage, kind, fp string
+		at              time.Time
+	}
+	byIP := make(map[string]*Actor)
+	miss := make(map[string]float64)
+	steps := make(map[string][]step)
+	var order []string
+
+	for _, a := range anoms {
+		src := a.SrcIP
+		if src == "" {
+			src = a.Subnet
+		}
+		if src == "" {
+			continue
+		}
+		act := byIP[src]
+		if act == nil {
+			act = &Actor{IP: src, Severity: a.Severity}
+			byIP[src] = act
+			miss[src] = 1
+			order = append(order, src)
+		}
+		act.Count++
+		miss[src] *= 1 - a.Confidence
+		if severityRank(a.Severity) > severityRank(act.Severity) {
+			act.Severity = a.Severity
+		}
+		act.Recurrent = act.Recurrent || a.Recurrent
+
+		start, end := a.FirstSeen, a.LastSeen
+		if a.Minute != nil {
+			start, end = a.Minute, a.Minute
+		}
+		st := step{stage: a.Stage, kind: a.Kind, fp: a.Fingerprint}
+		if start != nil {
+			st.at = *start
+		}
+		steps[src] = append(steps[src], st)
+		if start != nil && (act.FirstSeen == nil || start.Before(*act.FirstSeen)) {
+			t := *start
+			act.FirstSeen = &t
+		}
+		if end != nil && (act.LastSeen == nil || end.After(*act.LastSeen)) {
+			t := *end
+			act.LastSeen = &t
+		}
+	}
+
+	out := make([]Actor, 0, len(order))
+	for _, ip := range order {
+		act := byIP[ip]
+		act.Score = float64(int((1-miss[ip])*100+0.5)) / 100
+		ss := steps[ip]
+		sort.SliceStable(ss, func(i, j int) bool {
+			if ri, rj := stageRank(ss[i].stage), stageRank(ss[j].stage); ri != rj {
+				return ri < rj
+			}
+			if !ss[i].at.Equal(ss[j].at) {
+				return ss[i].at.Before(ss[j].at)
+			}
+			return ss[i].kind < ss[j].kind
+		})
+		// One phrase per stage, e.g. "recon (known scanner, sensitive path probe)".
+		var (
+			phrases []string
+			kinds   []string // of the current phrase
+			stage   string
+		)
+		flush := func() {
+			if len(kinds) > 0 {
+				phrases = append(phrases, stageLabel(stage)+" ("+strings.Join(kinds, ", ")+")")
+			}
+		}
+		for _, st := range ss {
+			if st.fp != "" && !slices.Contains(act.Anomalies, st.fp) {
+				act.Anomalies = append(act.Anomalies, st.fp)
+			}
+			if slices.Contains(act.Kinds, st.kind) {
+				continue
+			}
+			act.Kinds = append(act.Kinds, st.kind)
+			if st.stage != "" && !slices.Contains(act.Stages, st.stage) {
+				act.Stages = append(act.Stages, st.stage)
+			}
+			if len(kinds) > 0 && st.stage != stage {
+				flush()
+				kinds = nil
+			}
+			stage = st.stage
+			kinds = append(kinds, kindLabel(st.kind))
+		}
+		flush()
+		if len(act.Kinds) >= 3 {
+			act.Severity = severityLevels[min(severityRank(act.Severity)+1, len(severityLevels)-1)]
+		}
+		act.Reason = ip + " raised " + plural(act.Count, "anomaly") + ": " + strings.Join(phrases, " → ") + "."
+		out = append(out, *act)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return severityRank(out[i].Severity) > severityRank(out[j].Severity)
+	})
+	return out
+}
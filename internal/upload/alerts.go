@@ -0,0 +1,122 @@
+package upload
+
+import (
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/jobs"
+	"github.com/allensuvorov/tenexlog/internal/notify"
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// reportLink returns an absolute guest link to a job's results for alerts and
+// callbacks. It is empty unless NOTIFY_LINK_TTL and PUBLIC_BASE_URL are both
+// set, since anyone the message is forwarded to can open the link.
+func reportLink(id string) string {
+	base, ttl := notify.BaseURL(), notify.LinkTTL()
+	if base == "" || ttl <= 0 {
+		return ""
+	}
+	return strings.TrimSuffix(base, "/") + jobs.GuestLink(id, ttl).URL
+}
+
+// notifyCallback posts a signed summary of a finished, failed or canceled job to the
+// callback URL given with its upload.
+func notifyCallback(target string, j jobs.Job, res *Results) {
+	if target == "" {
+		return
+	}
+	msg := notify.Message{JobID: j.ID}
+	if res == nil {
+		msg.Event = "job." + j.Status
+		msg.Title = "Analysis of " + j.Filename + " " + j.Status
+		msg.Text = j.Error
+		msg.Data = map[string]any{"status": j.Status}
+		notify.PublishTo(target, msg)
+		return
+	}
+	bySeverity := make(map[string]int)
+	byKind := make(map[string]int)
+	top := ""
+	for _, a := range res.Anomalies {
+		bySeverity[a.Severity]++
+		byKind[a.Kind]++
+		if top == "" || severityRank(a.Severity) > severityRank(top) {
+			top = a.Severity
+		}
+	}
+	msg.Event = "job.completed"
+	msg.Title = "Analysis of " + j.Filename + " finished: " + plural(len(res.Anomalies), "anomaly")
+	msg.Text = res.Executive
+	msg.Data = map[string]any{
+		"status":              j.Status,
+		"anomalyCount":        len(res.Anomalies),
+		"anomaliesBySeverity": bySeverity,
+		"anomaliesByKind":     byKind,
+		"topSeverity":         top,
+	}
+	msg.URL = reportLink(j.ID)
+	notify.PublishTo(target, msg)
+}
+
+// notifyJob publishes an alert for a finished job when it has new anomalies
+// at or above the notifier's confidence floor, or new gaps in its timeline.
+// Findings whose fingerprint an earlier alert already carried are left out,
+// so recurring runs over one source only alert on what changed.
+func notifyJob(res Results) {
+	if !notify.Enabled() {
+		return
+	}
+	var (
+		anoms []Anomaly
+		fps   []string
+	)
+	for _, a := range res.Anomalies {
+		if a.Confidence >= notify.MinConfidence() {
+			anoms = append(anoms, a)
+			fps = append(fps, a.Fingerprint)
+		}
+	}
+	for _, g := range res.Gaps {
+		fps = append(fps, "gap|"+g.From.UTC().Format(time.RFC3339)+"|"+g.To.UTC().Format(time.RFC3339))
+	}
+	fresh := seen.markNew(fps, time.Now())
+	byKind := make(map[string]int)
+	n := 0
+	for i, a := range anoms {
+		if fresh[i] {
+			byKind[a.Kind]++
+			n++
+		}
+	}
+	var gaps []parse.Gap
+	for i, g := range res.Gaps {
+		if fresh[len(anoms)+i] {
+			gaps = append(gaps, g)
+		}
+	}
+	if n == 0 && len(gaps) == 0 {
+		return
+	}
+
+	var title string
+	switch {
+	case n > 0 && len(gaps) > 0:
+		title = plural(n, "new high-confidence anomaly") + " and " +
+			plural(len(gaps), "new gap") + " in " + res.Filename
+	case n > 0:
+		title = plural(n, "new high-confidence anomaly") + " in " + res.Filename
+	default:
+		title = plural(len(gaps), "new gap") + " in " + res.Filename
+	}
+
+	msg := notify.Message{
+		Event: "job.alert",
+		JobID: res.JobID,
+		Title: title,
+		Text:  res.Executive,
+		Data:  map[string]any{"anomaliesByKind": byKind, "gaps": gaps},
+	}
+	msg.URL = reportLink(res.JobID)
+	notify.Publish(msg)
+}
@@ -0,0 +1,53 @@
+package upload
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/auth"
+	"github.com/allensuvorov/tenexlog/internal/jobs"
+	"github.com/allensuvorov/tenexlog/internal/notify"
+)
+
+func TestReportLink(t *testing.T) {
+	tests := []struct {
+		name string
+		base string
+		ttl  time.Duration
+		want bool
+	}{
+		{"off by default", "", 0, false},
+		{"base URL alone", "https://logs.example.com", 0, false},
+		{"TTL alone", "", time.Hour, false},
+		{"both set", "https://logs.example.com/", time.Hour, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := notify.Configure(notify.Config{BaseURL: tt.base, LinkTTL: tt.ttl}); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { _ = notify.Configure(notify.Config{MinConfidence: 0.8}) })
+
+			got := reportLink("job-1")
+			if !tt.want {
+				if got != "" {
+					t.Errorf("reportLink = %q, want no link", got)
+				}
+				return
+			}
+			tok, ok := strings.CutPrefix(got, "https://logs.example.com/api/shared/")
+			if !ok {
+				t.Fatalf("reportLink = %q, want a guest link under the base URL", got)
+			}
+			now := time.Now()
+			if id, err := auth.VerifyGuestToken(jobs.ShareSecret, tok, now); err != nil || id != "job-1" {
+				t.Errorf("token verifies as %q, %v; want job-1", id, err)
+			}
+			if _, err := auth.VerifyGuestToken(jobs.ShareSecret, tok, now.Add(tt.ttl+time.Second)); !errors.Is(err, auth.ErrExpiredToken) {
+				t.Errorf("token after %v: err = %v, want it expired", tt.ttl, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package upload
+
+import (
+	"github.com/allensuvorov/tenexlog/internal/jobs"
+	"github.com/allensuvorov/tenexlog/internal/live"
+)
+
+// announce tells live dashboards that a job changed state and, once it is
+// done, about each of its anomalies and each anomaly of its source that it
+// resolved.
+func announce(j jobs.Job, res *Results) {
+	live.Publish(live.Message{Type: "job", JobID: j.ID, Job: jobView(j)})
+	if res == nil {
+		return
+	}
+	for _, a := range res.Anomalies {
+		live.Publish(live.Message{Type: "anomaly", JobID: j.ID, Anomaly: a})
+	}
+	for _, a := range res.Resolved {
+		live.Publish(live.Message{Type: "resolved", JobID: j.ID, Anomaly: a})
+	}
+}
+
+// jobView drops the server path of the upload from a job sent to
+// dashboards, as GuestView does for results.
+func jobView(j jobs.Job) jobs.Job {
+	j.SavedTo = ""
+	return j
+}
@@ -0,0 +1,63 @@
+package upload
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+
+	"github.com/allensuvorov/tenexlog/internal/jobs"
+	"github.com/allensuvorov/tenexlog/internal/live"
+)
+
+func TestAnnounceHidesSavedTo(t *testing.T) {
+	srv := httptest.NewServer(live.Handler(""))
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ws, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.CloseNow()
+
+	// The client counts as connected only once the handler has registered
+	// it, so announce until something arrives.
+	j := jobs.Job{ID: "job-1", Filename: "access.log", SavedTo: "/var/tmp/uploads/job-1.log", Status: jobs.StatusDone}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		tick := time.NewTicker(10 * time.Millisecond)
+		defer tick.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-tick.C:
+				announce(j, nil)
+			}
+		}
+	}()
+
+	_, data, err := ws.Read(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m struct {
+		Type string         `json:"type"`
+		Job  map[string]any `json:"job"`
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Type != "job" || m.Job["id"] != "job-1" || m.Job["filename"] != "access.log" {
+		t.Errorf("got %s, want the job", data)
+	}
+	if _, ok := m.Job["savedTo"]; ok {
+		t.Errorf("job message exposes savedTo: %s", data)
+	}
+}
@@ -0,0 +1,44 @@
+package upload
+
+import (
+	"net/netip"
+
+	"github.com/allensuvorov/tenexlog/internal/enrich"
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// tagAnonymizers sets Anonymizer on rows whose source is listed by an
+// anonymizer feed (TOR exits, VPN and proxy ranges), looking each IP up once.
+func tagAnonymizers(rows []parse.Event) {
+	if !enrich.HasAnonymizerFeeds() {
+		return
+	}
+	names := make(map[string]string)
+	for i := range rows {
+		ip := rows[i].SrcIP
+		if ip == "" {
+			continue
+		}
+		name, ok := names[ip]
+		if !ok {
+			if addr, err := netip.ParseAddr(ip); err == nil {
+				if feeds := enrich.AnonymizerOf(addr); len(feeds) > 0 {
+					name = feeds[0]
+				}
+			}
+			names[ip] = name
+		}
+		rows[i].Anonymizer = name
+	}
+}
+
+// anonymizersOf maps each tagged source IP in rows to its anonymizer name.
+func anonymizersOf(rows []parse.Event) map[string]string {
+	out := make(map[string]string)
+	for _, ev := range rows {
+		if ev.Anonymizer != "" {
+			out[ev.SrcIP] = ev.Anonymizer
+		}
+	}
+	return out
+}
@@ -0,0 +1,252 @@
+package upload
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+)
+
+// maxBatchFiles caps the jobs one batch may create.
+const maxBatchFiles = 100
+
+// batchJob reports one file of a batch: its job, or why it was not queued.
+type batchJob struct {
+	Filename  string `json:"filename"`
+	JobID     string `json:"jobId,omitempty"`
+	Status    string `json:"status,omitempty"`
+	StatusURL string `json:"statusUrl,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type batch struct {
+	s        *Service
+	r        *http.Request
+	opts     Options
+	jobs     []batchJob
+	ids      []string
+	firstErr error
+	queued   int64 // bytes saved for the batch's jobs
+}
+
+// BatchHandler serves POST /api/upload/batch using the Default service.
+func BatchHandler(w http.ResponseWriter, r *http.Request) {
+	Default.BatchHandler(w, r)
+}
+
+// BatchHandler queues several files in one request, one job each: either a
+// multipart form with any number of "file" fields, or a tar archive (plain
+// or gzipped) as the body or as one of those fields, whose regular files
+// each become a job. It answers 202 with every job ID, and per file the job
+// or the reason it was not queued; the query options are those of Handler
+// and apply to every job. Only when no job could be queued does it answer
+// with the error instead.
+func (s *Service) BatchHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := minSeverity(r); !ok {
+		http.Error(w, "minSeverity must be one of low, medium, high, critical", http.StatusBadRequest)
+		return
+	}
+	opts, err := uploadOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !limitUpload(w, r) {
+		return
+	}
+
+	b := &batch{s: s, r: r, opts: opts}
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case ct == "multipart/form-data":
+		err = b.addMultipart()
+	case isTarType(ct):
+		err = b.addTar("upload.tar", r.Body)
+	default:
+		http.Error(w, "send a multipart form of files or a tar archive", http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil && b.firstErr == nil {
+		b.firstErr = err
+	}
+
+	if len(b.ids) == 0 {
+		switch {
+		case b.firstErr == nil:
+			http.Error(w, "the batch holds no files", http.StatusBadRequest)
+		case isTooLarge(b.firstErr):
+			tooLarge(w)
+		case errors.Is(b.firstErr, ErrQueueFull), errors.Is(b.firstErr, ErrStore):
+			w.Header().Set("Retry-After", "30")
+			_, msg := ErrorStatus(b.firstErr)
+			http.Error(w, msg, http.StatusServiceUnavailable)
+		case errors.Is(b.firstErr, ErrSave):
+			status, msg := ErrorStatus(b.firstErr)
+			http.Error(w, msg, status)
+		default:
+			http.Error(w, b.firstErr.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	body := map[string]any{
+		"jobIds": b.ids,
+		"jobs":   b.jobs,
+	}
+	if err != nil {
+		// Files after this point were not read.
+		body["error"] = batchMessage(err)
+	}
+	httputil.JSON(w, http.StatusAccepted, body)
+}
+
+func isTarType(ct string) bool {
+	return ct == "application/x-tar" || ct == "application/tar" || ct == "application/gzip"
+}
+
+func isTarName(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasSuffix(name, ".tar") || strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+}
+
+// addMultipart queues the "file" fields of the form as they stream in.
+func (b *batch) addMultipart() error {
+	mr, err := b.r.MultipartReader()
+	if err != nil {
+		return err
+	}
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			if isTooLarge(err) {
+				return err
+			}
+			return errors.New("malformed multipart form")
+		}
+		name := part.FileName()
+		if part.FormName() != "file" || name == "" {
+			_ = part.Close()
+			continue
+		}
+		ct, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if isTarName(name) || ct == "application/x-tar" {
+			err = b.addTar(name, part)
+		} else {
+			err = b.add(name, part)
+		}
+		_ = part.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// addTar queues every regular file of a tar archive, gunzipping it first
+// when it starts with the gzip magic bytes, whatever it was labelled. A
+// damaged archive is reported against its name and the rest of the batch
+// goes on.
+func (b *batch) addTar(archive string, src io.Reader) error {
+	br := bufio.NewReader(src)
+	src = br
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(src)
+		if err != nil {
+			if isTooLarge(err) {
+				return err
+			}
+			b.fail(archive, errors.New("not a gzipped tar archive"))
+			return nil
+		}
+		defer zr.Close()
+		src = zr
+	}
+	tr := tar.NewReader(src)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			if isTooLarge(err) {
+				return err
+			}
+			b.fail(archive, errors.New("not a valid tar archive"))
+			return nil
+		}
+		name := path.Base(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if err := b.add(name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// add queues one file. It returns an error only when the rest of the batch
+// is not to be read: the batch or the queue is full, it went over the size
+// limit, the job store failed or the client went away. Only queued files
+// count towards the batch's limit.
+//
+// The limit applies to what is saved, not only to the request body, so that
+// a small compressed archive cannot unpack into more than a plain upload.
+func (b *batch) add(filename string, src io.Reader) error {
+	if len(b.ids) >= maxBatchFiles {
+		return errors.New("a batch holds at most " + strconv.Itoa(maxBatchFiles) + " files")
+	}
+	if MaxUploadBytes > 0 {
+		left := MaxUploadBytes - b.queued
+		if left <= 0 {
+			err := &http.MaxBytesError{Limit: MaxUploadBytes}
+			b.fail(filename, err)
+			return err
+		}
+		src = http.MaxBytesReader(nil, io.NopCloser(src), left)
+	}
+	j, err := b.s.Enqueue(b.r.Context(), filename, src, b.opts)
+	if err != nil {
+		b.fail(filename, err)
+		if isTooLarge(err) || errors.Is(err, ErrQueueFull) || errors.Is(err, ErrStore) || b.r.Context().Err() != nil {
+			return err
+		}
+		return nil
+	}
+	b.queued += j.SizeBytes
+	b.ids = append(b.ids, j.ID)
+	b.jobs = append(b.jobs, batchJob{
+		Filename:  filename,
+		JobID:     j.ID,
+		Status:    j.Status,
+		StatusURL: jobStatusURL(b.r, j.ID),
+	})
+	return nil
+}
+
+func (b *batch) fail(filename string, err error) {
+	if b.firstErr == nil {
+		b.firstErr = err
+	}
+	b.jobs = append(b.jobs, batchJob{Filename: filename, Error: batchMessage(err)})
+}
+
+func batchMessage(err error) string {
+	switch {
+	case isTooLarge(err):
+		return tooLargeMessage()
+	case errors.Is(err, ErrSave), errors.Is(err, ErrStore):
+		_, msg := ErrorStatus(err)
+		return msg
+	}
+	return err.Error()
+}
@@ -0,0 +1,185 @@
+package upload
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/allensuvorov/tenexlog/internal/jobs"
+)
+
+// testService returns a service that keeps its uploads and jobs in t's
+// temporary directory.
+func testService(t *testing.T) *Service {
+	t.Helper()
+	t.Setenv("TMPDIR", t.TempDir())
+	return NewService(DiskStorage{Dir: t.TempDir()}, FileParser{}, BuiltinDetectors{}, jobs.NewMemStore(0))
+}
+
+// tarGz packs n files of size bytes each.
+func tarGz(t *testing.T, n, size int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(tarball(t, n, size)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// tarball is tarGz without the gzip.
+func tarball(t *testing.T, n, size int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i := range n {
+		hdr := &tar.Header{Name: "logs/" + strconv.Itoa(i) + ".log", Mode: 0o600, Size: int64(size), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(bytes.Repeat([]byte("x"), size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBatchLimits(t *testing.T) {
+	oldQueue, oldMax := QueueSize, MaxUploadBytes
+	t.Cleanup(func() { QueueSize, MaxUploadBytes = oldQueue, oldMax })
+
+	tests := []struct {
+		name        string
+		files       int
+		size        int
+		maxBytes    int64
+		queue       int  // QueueSize, when set
+		plain       bool // send an uncompressed tar
+		contentType string
+		wantStatus  int
+		wantJobs    int
+		wantEntries int    // per-file reports, when not wantJobs
+		wantError   string // in the 202 body's "error"
+	}{
+		{name: "within limits", files: 3, size: 100, maxBytes: 1 << 20, wantStatus: http.StatusAccepted, wantJobs: 3},
+		{name: "too many files", files: maxBatchFiles + 5, size: 10, maxBytes: 1 << 20, wantStatus: http.StatusAccepted,
+			wantJobs: maxBatchFiles, wantError: "a batch holds at most 100 files"},
+		{name: "unpacked size over the limit", files: 3, size: 3000, maxBytes: 7000, wantStatus: http.StatusAccepted,
+			wantJobs: 2, wantEntries: 3, wantError: "upload exceeds the limit of 7000 bytes"},
+		{name: "first file over the limit", files: 2, size: 3000, maxBytes: 2000, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "empty archive", files: 0, maxBytes: 1 << 20, wantStatus: http.StatusBadRequest},
+		{name: "queue full", files: 5, size: 10, maxBytes: 1 << 20, queue: 2, wantStatus: http.StatusAccepted,
+			wantJobs: 2, wantEntries: 3, wantError: "upload queue is full"},
+		{name: "gzipped tar labelled as tar", files: 2, size: 10, maxBytes: 1 << 20, contentType: "application/x-tar",
+			wantStatus: http.StatusAccepted, wantJobs: 2},
+		{name: "plain tar labelled as gzip", files: 2, size: 10, maxBytes: 1 << 20, plain: true,
+			wantStatus: http.StatusAccepted, wantJobs: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			MaxUploadBytes, QueueSize = tt.maxBytes, 2*maxBatchFiles
+			if tt.queue > 0 {
+				QueueSize = tt.queue
+			}
+			s := testService(t)
+			archive := tarGz(t, tt.files, tt.size)
+			if tt.plain {
+				archive = tarball(t, tt.files, tt.size)
+			}
+			ct := tt.contentType
+			if ct == "" {
+				ct = "application/gzip"
+			}
+			r := httptest.NewRequest("POST", "/api/upload/batch", bytes.NewReader(archive))
+			r.Header.Set("Content-Type", ct)
+			w := httptest.NewRecorder()
+			s.BatchHandler(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != http.StatusAccepted {
+				return
+			}
+			var body struct {
+				JobIDs []string   `json:"jobIds"`
+				Jobs   []batchJob `json:"jobs"`
+				Error  string     `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.JobIDs) != tt.wantJobs {
+				t.Errorf("%d jobs queued, want %d", len(body.JobIDs), tt.wantJobs)
+			}
+			wantEntries := tt.wantEntries
+			if wantEntries == 0 {
+				wantEntries = tt.wantJobs
+			}
+			if len(body.Jobs) != wantEntries {
+				t.Errorf("%d files reported, want %d", len(body.Jobs), wantEntries)
+			}
+			if tt.wantError == "" && body.Error != "" || !strings.Contains(body.Error, tt.wantError) {
+				t.Errorf("error %q, want %q", body.Error, tt.wantError)
+			}
+		})
+	}
+}
+
+// TestBatchCountsQueuedJobs checks that files which could not be queued do
+// not count towards the batch limit.
+func TestBatchCountsQueuedJobs(t *testing.T) {
+	oldQueue := QueueSize
+	QueueSize = 2 * maxBatchFiles
+	t.Cleanup(func() { QueueSize = oldQueue })
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for i := range maxBatchFiles + 2 {
+		name, data := strconv.Itoa(i)+".log", "x"
+		if i < 2 {
+			name, data = strconv.Itoa(i)+".tar", "not a tar archive"
+		}
+		fw, err := mw.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(strings.Repeat(data, 600))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("POST", "/api/upload/batch", &buf)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	testService(t).BatchHandler(w, r)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		JobIDs []string   `json:"jobIds"`
+		Jobs   []batchJob `json:"jobs"`
+		Error  string     `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.JobIDs) != maxBatchFiles || len(body.Jobs) != maxBatchFiles+2 || body.Error != "" {
+		t.Errorf("%d jobs queued, %d files reported, error %q; want %d, %d and none",
+			len(body.JobIDs), len(body.Jobs), body.Error, maxBatchFiles, maxBatchFiles+2)
+	}
+}
@@ -0,0 +1,167 @@
+package upload
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/jobs"
+)
+
+// blockBase is the suggested block duration for each severity level. Every
+// further job that flagged the same IP doubles it, up to blockMax.
+var blockBase = map[string]time.Duration{
+	"low":      time.Hour,
+	"medium":   24 * time.Hour,
+	"high":     7 * 24 * time.Hour,
+	"critical": 30 * 24 * time.Hour,
+}
+
+const blockMax = 90 * 24 * time.Hour
+
+type blockCandidate struct {
+	IP              string    `json:"ip"`
+	Severity        string    `json:"severity"`
+	Jobs            []string  `json:"jobs"`
+	Kinds           []string  `json:"kinds"`
+	FirstSeen       time.Time `json:"firstSeen"`
+	LastSeen        time.Time `json:"lastSeen"`
+	DurationSeconds int64     `json:"durationSeconds"`
+	Expires         time.Time `json:"expires"`
+	Reason          string    `json:"reason"`
+}
+
+// blockCandidates collects public source IPs from the anomalies of list, jobs
+// held by store, at or above minRank, one entry per IP with its highest
+// severity.
+func blockCandidates(store JobStore, list []jobs.Job, minRank int) []blockCandidate {
+	byIP := make(map[string]*blockCandidate)
+	kinds := make(map[string]map[string]bool)
+	jobSeen := make(map[string]map[string]bool)
+
+	for _, j := range list {
+		if j.AnomalyCount == 0 {
+			continue
+		}
+		full, err := store.GetJob(j.ID)
+		if err != nil {
+			continue
+		}
+		res, ok := full.Result.(Results)
+		if !ok {
+			continue
+		}
+		for _, a := range res.Anomalies {
+			if a.SrcIP == "" || severityRank(a.Severity) < minRank {
+				continue
+			}
+			addr, err := netip.ParseAddr(a.SrcIP)
+			if err != nil || !addr.IsGlobalUnicast() || addr.IsPrivate() {
+				continue
+			}
+			ip := addr.String()
+			c := byIP[ip]
+			if c == nil {
+				c = &blockCandidate{IP: ip, Severity: a.Severity, FirstSeen: j.Received, LastSeen: j.Received, Reason: a.Reason}
+				byIP[ip] = c
+				kinds[ip] = make(map[string]bool)
+				jobSeen[ip] = make(map[string]bool)
+			}
+			if severityRank(a.Severity) > severityRank(c.Severity) {
+				c.Severity, c.Reason = a.Severity, a.Reason
+			}
+			if j.Received.Before(c.FirstSeen) {
+				c.FirstSeen = j.Received
+			}
+			if j.Received.After(c.LastSeen) {
+				c.LastSeen = j.Received
+			}
+			if !kinds[ip][a.Kind] {
+				kinds[ip][a.Kind] = true
+				c.Kinds = append(c.Kinds, a.Kind)
+			}
+			if !jobSeen[ip][j.ID] {
+				jobSeen[ip][j.ID] = true
+				c.Jobs = append(c.Jobs, j.ID)
+			}
+		}
+	}
+
+	out := make([]blockCandidate, 0, len(byIP))
+	for _, c := range byIP {
+		d := blockBase[c.Severity]
+		for i := 1; i < len(c.Jobs) && d < blockMax; i++ {
+			d *= 2
+		}
+		d = min(d, blockMax)
+		c.DurationSeconds = int64(d / time.Second)
+		c.Expires = c.LastSeen.Add(d)
+		sort.Strings(c.Kinds)
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		ri, rj := severityRank(out[i].Severity), severityRank(out[j].Severity)
+		if ri != rj {
+			return ri > rj
+		}
+		if len(out[i].Jobs) != len(out[j].Jobs) {
+			return len(out[i].Jobs) > len(out[j].Jobs)
+		}
+		return out[i].IP < out[j].IP
+	})
+	return out
+}
+
+// Blocklist serves GET /api/blocklist using the Default service.
+func Blocklist(w http.ResponseWriter, r *http.Request) {
+	Default.Blocklist(w, r)
+}
+
+// Blocklist lists candidate IPs to block across the service's stored jobs,
+// with a suggested duration and expiry. ?format=csv returns a
+// firewall-ready CSV instead of JSON; ?minSeverity= defaults to medium.
+func (s *Service) Blocklist(w http.ResponseWriter, r *http.Request) {
+	rank, ok := minSeverity(r)
+	if !ok {
+		http.Error(w, "minSeverity must be one of: "+strings.Join(severityLevels, ", "), http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("minSeverity") == "" {
+		rank = severityRank("medium")
+	}
+	list, err := s.Jobs.ListJobs()
+	if err != nil {
+		http.Error(w, "could not list jobs", http.StatusInternalServerError)
+		return
+	}
+	cands := blockCandidates(s.Jobs, list, rank)
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		httputil.JSON(w, http.StatusOK, map[string]any{"candidates": cands})
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="blocklist.csv"`)
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"ip", "severity", "duration_seconds", "expires", "jobs", "kinds", "reason"})
+		for _, c := range cands {
+			_ = cw.Write([]string{
+				csvText(c.IP),
+				csvText(c.Severity),
+				strconv.FormatInt(c.DurationSeconds, 10),
+				c.Expires.UTC().Format(time.RFC3339),
+				strconv.Itoa(len(c.Jobs)),
+				csvText(strings.Join(c.Kinds, ";")),
+				csvText(c.Reason),
+			})
+		}
+		cw.Flush()
+	default:
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,40 @@
+package upload
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/jobs"
+)
+
+// TestBlocklistUsesServiceStore checks that the blocklist is drawn from the
+// store the service was built with, not the package default.
+func TestBlocklistUsesServiceStore(t *testing.T) {
+	s := testService(t)
+	res := Results{JobID: "j1", Anomalies: []Anomaly{
+		{Kind: "sensitive_paths", Severity: "high", SrcIP: "203.0.113.9", Reason: "probed /.env"},
+		{Kind: "sensitive_paths", Severity: "high", SrcIP: "10.0.0.1"},
+	}}
+	received := time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)
+	if _, err := s.Jobs.SaveJob(jobs.Job{ID: "j1", Received: received, Status: jobs.StatusDone, AnomalyCount: 2, Result: res}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	s.Blocklist(w, httptest.NewRequest("GET", "/api/blocklist", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Candidates []blockCandidate `json:"candidates"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Candidates) != 1 || body.Candidates[0].IP != "203.0.113.9" || len(body.Candidates[0].Jobs) != 1 {
+		t.Errorf("candidates = %+v, want 203.0.113.9 from job j1", body.Candidates)
+	}
+}
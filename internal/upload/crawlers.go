@@ -0,0 +1,104 @@
+package upload
+
+import (
+	"context"
+	"log"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/enrich"
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// VerifyCrawlers turns on crawler verification (env VERIFY_CRAWLERS).
+var VerifyCrawlers = true
+
+const (
+	maxCrawlerLookups = 200
+	crawlerWorkers    = 8
+)
+
+// tagCrawlers marks rows whose User-Agent claims to be a search engine
+// crawler and verifies each claimed source with forward-confirmed reverse
+// DNS, so detectors can leave genuine crawls alone. A spoofed claim keeps
+// Crawler set with CrawlerVerified false. Sources past maxCrawlerLookups, or
+// not resolved within the overall deadline, stay unverified.
+func tagCrawlers(ctx context.Context, rows []parse.Event) {
+	type claim struct {
+		name string
+		addr netip.Addr
+	}
+	var claims []claim
+	seen := make(map[claim]bool)
+	for i := range rows {
+		name := enrich.ClaimedCrawler(rows[i].UA)
+		if name == "" {
+			continue
+		}
+		rows[i].Crawler = name
+		addr, err := netip.ParseAddr(rows[i].SrcIP)
+		if err != nil {
+			continue
+		}
+		c := claim{name, addr.Unmap()}
+		if !seen[c] && len(claims) < maxCrawlerLookups {
+			seen[c] = true
+			claims = append(claims, c)
+		}
+	}
+	if !VerifyCrawlers || len(claims) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	var (
+		mu       sync.Mutex
+		verified = make(map[claim]bool)
+		wg       sync.WaitGroup
+		next     = make(chan claim)
+	)
+	for range min(crawlerWorkers, len(claims)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range next {
+				ok, err := enrich.VerifyCrawler(ctx, c.name, c.addr)
+				if err != nil {
+					log.Printf("verify %s %s: %v", c.name, c.addr, err)
+				}
+				if ok {
+					mu.Lock()
+					verified[c] = true
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, c := range claims {
+		next <- c
+	}
+	close(next)
+	wg.Wait()
+
+	for i := range rows {
+		if rows[i].Crawler == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(rows[i].SrcIP); err == nil {
+			rows[i].CrawlerVerified = verified[claim{rows[i].Crawler, addr.Unmap()}]
+		}
+	}
+}
+
+// withoutCrawlers drops rows from verified crawlers.
+func withoutCrawlers(rows []parse.Event) []parse.Event {
+	out := make([]parse.Event, 0, len(rows))
+	for _, ev := range rows {
+		if !ev.CrawlerVerified {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
@@ -0,0 +1,1285 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/analyze"
+	"github.com/allensuvorov/tenexlog/internal/enrich"
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// detector runs over the kept rows, or with runTimeline also over the
+// per-minute timeline of every scanned line. runShared detectors take the
+// rows with their shared aggregates and the upload's threshold overrides.
+type detector struct {
+	kind        string
+	run         func(rows []parse.Event) []Anomaly
+	runTimeline func(rows []parse.Event, timeline []parse.Bucket) []Anomaly
+	runShared   func(in shared) []Anomaly
+	// external detectors only make sense for internet-facing traffic; with
+	// ExcludeInternal they never see rows from internal addresses.
+	external bool
+	// skipCrawlers detectors never see rows from verified search engine
+	// crawlers, which legitimately fetch many paths quickly.
+	skipCrawlers bool
+}
+
+// detectors is the built-in set in default execution order.
+var detectors = []detector{
+	{kind: "rate_spike", external: true, skipCrawlers: true, runShared: runRateSpikes},
+	{kind: "path_rate_spike", runShared: runPathRateSpikes},
+	{kind: "known_bad_ip", external: true, run: runKnownBadIPs},
+	{kind: "anonymized_probe", external: true, run: runAnonymizedProbes},
+	{kind: "sensitive_paths", external: true, skipCrawlers: true, runShared: runSensitivePaths},
+	{kind: "auth_bruteforce", run: runAuthBruteForce},
+	{kind: "forced_browsing", external: true, skipCrawlers: true, run: runNotFoundScanning},
+	{kind: "error_rate", runShared: runErrorRate},
+	{kind: "server_error_burst", run: runServerErrorBursts},
+	{kind: "latency_spike", run: runLatencySpikes},
+	{kind: "traffic_surge", runTimeline: runTrafficSurges},
+	{kind: "low_and_slow", external: true, skipCrawlers: true, run: runLowAndSlow},
+	{kind: "impossible_travel", external: true, run: runImpossibleTravel},
+	{kind: "scanner_ua", external: true, run: runScannerUA},
+	{kind: "new_country_login", external: true, run: runNewCountry},
+	{kind: "concurrent_sessions", run: runConcurrentSessions},
+	{kind: "abnormal_ua", external: true, run: runAbnormalUA},
+	{kind: "rare_method", external: true, run: runRareMethods},
+	{kind: "response_size", run: runResponseSizes},
+	{kind: "id_enumeration", external: true, skipCrawlers: true, run: runIDEnumeration},
+	{kind: "distributed_attack", external: true, run: runCampaigns},
+	{kind: "behavior_cluster", external: true, skipCrawlers: true, runShared: runBehaviorClusters},
+	{kind: "behavioral_outlier", external: true, skipCrawlers: true, run: runBehavioralOutliers},
+	{kind: "sqli", run: runSQLi},
+	{kind: "path_traversal", run: runPathTraversal},
+	{kind: "log4shell", run: runJNDI},
+	{kind: "k8s_forbidden_burst", run: runForbiddenBursts},
+	{kind: "k8s_exec_spike", run: runExecSpikes},
+	{kind: "k8s_secrets_access", run: runSecretsAccess},
+	{kind: "db_privilege_burst", run: runPrivilegeBursts},
+	{kind: "db_bulk_select", run: runBulkSelects},
+}
+
+type DetectorConfig struct {
+	Order    []string // kinds to run first, in this order; unlisted kinds follow in default order
+	Disabled map[string]bool
+	Caps     map[string]int // per-kind output cap; 0 or missing means uncapped
+
+	RateBaseline string        // "static" (mean over the IP's history) or "ewma"
+	RateHalfLife time.Duration // EWMA half-life
+
+	// ExcludeInternal hides private, loopback, link-local and CGNAT sources
+	// from external detectors.
+	ExcludeInternal bool
+}
+
+var detectorConfig DetectorConfig
+
+func ConfigureDetectors(c DetectorConfig) {
+	detectorConfig = c
+}
+
+// EnvDetectorConfig reads DETECTORS_ORDER and DETECTORS_DISABLED (comma-separated
+// kinds), DETECTOR_CAPS (comma-separated kind=n pairs), RATE_BASELINE,
+// RATE_HALF_LIFE and EXCLUDE_INTERNAL.
+func EnvDetectorConfig() DetectorConfig {
+	c := DetectorConfig{
+		Order:        splitList(os.Getenv("DETECTORS_ORDER")),
+		Disabled:     make(map[string]bool),
+		Caps:         make(map[string]int),
+		RateBaseline: "static",
+		RateHalfLife: 10 * time.Minute,
+
+		ExcludeInternal: os.Getenv("EXCLUDE_INTERNAL") == "true",
+	}
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("RATE_BASELINE")), "ewma") {
+		c.RateBaseline = "ewma"
+	}
+	if d, err := time.ParseDuration(os.Getenv("RATE_HALF_LIFE")); err == nil && d > 0 {
+		c.RateHalfLife = d
+	}
+	for _, k := range splitList(os.Getenv("DETECTORS_DISABLED")) {
+		c.Disabled[k] = true
+	}
+	for _, kv := range splitList(os.Getenv("DETECTOR_CAPS")) {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && n >= 0 {
+			c.Caps[strings.TrimSpace(k)] = n
+		}
+	}
+	return c
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// allDetectors returns the built-in detectors followed by those registered
+// with analyze.Register. A registered detector cannot replace a built-in kind.
+func allDetectors() []detector {
+	all := slices.Clone(detectors)
+	for _, r := range analyze.Registered() {
+		if !slices.ContainsFunc(all, func(d detector) bool { return d.kind == r.Name() }) {
+			all = append(all, detector{kind: r.Name(), run: registeredRun(r)})
+		}
+	}
+	return all
+}
+
+// DetectorKinds lists every detector that can run, built-in and registered.
+func DetectorKinds() []string {
+	var kinds []string
+	for _, d := range allDetectors() {
+		kinds = append(kinds, d.kind)
+	}
+	return kinds
+}
+
+// Selection narrows the detectors run for one upload. When Only is set just
+// those kinds run; kinds in Skip never do. Both apply on top of the
+// configured DETECTORS_DISABLED. ExcludeInternal turns on internal-source
+// exclusion for this upload even when EXCLUDE_INTERNAL is off, and
+// Thresholds tune the detectors that run.
+type Selection struct {
+	Only            []string
+	Skip            []string
+	ExcludeInternal bool
+	Thresholds      Thresholds
+}
+
+type selectionKey struct{}
+
+func withSelection(ctx context.Context, sel Selection) context.Context {
+	return context.WithValue(ctx, selectionKey{}, sel)
+}
+
+func selectionFrom(ctx context.Context) Selection {
+	sel, _ := ctx.Value(selectionKey{}).(Selection)
+	return sel
+}
+
+// detectorSelection reads ?detectors= and ?skipDetectors= (comma-separated
+// kinds), ?excludeInternal=true and the threshold overrides, and rejects
+// unknown kinds and out-of-range thresholds.
+func detectorSelection(r *http.Request) (Selection, error) {
+	sel := Selection{
+		Only: splitList(r.URL.Query().Get("detectors")),
+		Skip: splitList(r.URL.Query().Get("skipDetectors")),
+	}
+	switch r.URL.Query().Get("excludeInternal") {
+	case "", "false":
+	case "true":
+		sel.ExcludeInternal = true
+	default:
+		return sel, errors.New("excludeInternal must be true or false")
+	}
+	t, err := thresholdOptions(r)
+	if err != nil {
+		return sel, err
+	}
+	sel.Thresholds = t
+	kinds := DetectorKinds()
+	for _, k := range append(slices.Clone(sel.Only), sel.Skip...) {
+		if !slices.Contains(kinds, k) {
+			return sel, errors.New("unknown detector " + strconv.Quote(k))
+		}
+	}
+	return sel, nil
+}
+
+// activeDetectors returns the enabled detectors in configured order.
+func activeDetectors(sel Selection) []detector {
+	all := allDetectors()
+	byKind := make(map[string]detector, len(all))
+	for _, d := range all {
+		byKind[d.kind] = d
+	}
+
+	out := make([]detector, 0, len(all))
+	used := make(map[string]bool)
+	for _, k := range detectorConfig.Order {
+		d, ok := byKind[k]
+		if !ok || used[k] {
+			continue
+		}
+		used[k] = true
+		out = append(out, d)
+	}
+	for _, d := range all {
+		if !used[d.kind] {
+			out = append(out, d)
+		}
+	}
+
+	enabled := out[:0]
+	for _, d := range out {
+		switch {
+		case detectorConfig.Disabled[d.kind], slices.Contains(sel.Skip, d.kind):
+		case len(sel.Only) > 0 && !slices.Contains(sel.Only, d.kind):
+		default:
+			enabled = append(enabled, d)
+		}
+	}
+	return enabled
+}
+
+// capFindings keeps the n most severe of one detector's findings, ties going
+// to the more confident, or all of them when n is 0.
+func capFindings(found []Anomaly, n int) []Anomaly {
+	if n <= 0 || len(found) <= n {
+		return found
+	}
+	slices.SortStableFunc(found, moreSevere)
+	return found[:n]
+}
+
+func runDetectors(ctx context.Context, rows []parse.Event, timeline []parse.Bucket, sel Selection, progress func(done, total, found int)) []Anomaly {
+	var merged []Anomaly
+	var external []parse.Event
+	if sel.ExcludeInternal || detectorConfig.ExcludeInternal {
+		external = externalRows(rows)
+	}
+	crawled := slices.ContainsFunc(rows, func(ev parse.Event) bool { return ev.CrawlerVerified })
+	anonymizers := anonymizersOf(rows)
+	// Row sets and their aggregates, built once and shared by every detector
+	// that sees the same rows.
+	type rowSet struct{ external, noCrawlers bool }
+	sets := make(map[rowSet]*shared)
+	active := activeDetectors(sel)
+	for n, d := range active {
+		if ctx.Err() != nil {
+			return nil
+		}
+		key := rowSet{d.external && external != nil, d.skipCrawlers && crawled}
+		in := sets[key]
+		if in == nil {
+			in = &shared{rows: rows, t: sel.Thresholds}
+			if key.external {
+				in.rows = external
+			}
+			if key.noCrawlers {
+				in.rows = withoutCrawlers(in.rows)
+			}
+			sets[key] = in
+		}
+		var found []Anomaly
+		switch {
+		case d.runTimeline != nil:
+			found = d.runTimeline(in.rows, timeline)
+		case d.runShared != nil:
+			if in.agg == nil {
+				in.agg = analyze.Aggregate(in.rows)
+			}
+			found = d.runShared(*in)
+		default:
+			found = d.run(in.rows)
+		}
+		for i := range found {
+			if len(found[i].Evidence) > 0 {
+				calibrate(&found[i])
+			}
+			found[i].SrcClass = parse.AddrClass(found[i].SrcIP)
+			found[i].Anonymizer = anonymizers[found[i].SrcIP]
+			found[i].Fingerprint = fingerprint(found[i])
+			found[i].Severity = severity(found[i])
+			found[i].Stage = stageOf(found[i])
+		}
+		found = capFindings(found, detectorConfig.Caps[d.kind])
+		merged = append(merged, found...)
+		if progress != nil {
+			progress(n+1, len(active), len(merged))
+		}
+	}
+	correlate(merged)
+	attachEvents(merged, rows)
+	return merged
+}
+
+// shared is the input of runShared detectors. agg is built from rows once per
+// distinct row set in runDetectors.
+type shared struct {
+	rows []parse.Event
+	agg  *analyze.Aggregates
+	t    Thresholds
+}
+
+// externalRows returns the rows whose source is not an internal address.
+// Rows without a parseable source are kept.
+func externalRows(rows []parse.Event) []parse.Event {
+	out := make([]parse.Event, 0, len(rows))
+	for _, ev := range rows {
+		if !parse.IsInternal(ev.SrcClass) {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+// correlate links each distributed_attack to the per-IP anomalies raised
+// against its participants during the campaign, by fingerprint.
+func correlate(anoms []Anomaly) {
+	for i := range anoms {
+		c := &anoms[i]
+		if c.members == nil || c.FirstSeen == nil || c.LastSeen == nil {
+			continue
+		}
+		for _, a := range anoms {
+			if a.Kind == c.Kind || !c.members[a.SrcIP] || !overlaps(a, *c.FirstSeen, *c.LastSeen) {
+				continue
+			}
+			c.Related = append(c.Related, a.Fingerprint)
+		}
+	}
+}
+
+// overlaps reports whether a's time span intersects [from, to]. Anomalies
+// without times are assumed to overlap.
+func overlaps(a Anomaly, from, to time.Time) bool {
+	start, end := a.FirstSeen, a.LastSeen
+	if a.Minute != nil {
+		m := a.Minute.Add(time.Minute - time.Second)
+		start, end = a.Minute, &m
+	}
+	if start == nil || end == nil {
+		return true
+	}
+	return !start.After(to) && !end.Before(from)
+}
+
+// registeredRun adapts a registered detector's findings to Anomaly.
+func registeredRun(d analyze.Detector) func(rows []parse.Event) []Anomaly {
+	return func(rows []parse.Event) []Anomaly {
+		found := d.Analyze(rows)
+		out := make([]Anomaly, 0, len(found))
+		for _, a := range found {
+			if a.Kind == "" {
+				a.Kind = d.Name()
+			}
+			an := Anomaly{
+				Kind:     a.Kind,
+				SrcIP:    a.SrcIP,
+				Path:     a.Path,
+				Evidence: a.Evidence,
+				Reason:   a.Reason,
+				Actions:  actionsFor(a.Kind),
+				Group:    a.Group,
+				User:     a.Group["user"],
+			}
+			if !a.Minute.IsZero() {
+				m := a.Minute
+				an.Minute = &m
+			}
+			if !a.FirstSeen.IsZero() {
+				fs := a.FirstSeen
+				an.FirstSeen = &fs
+			}
+			if !a.LastSeen.IsZero() {
+				ls := a.LastSeen
+				an.LastSeen = &ls
+			}
+			if a.Count > 0 {
+				c := a.Count
+				an.Count = &c
+			}
+			if a.Baseline != 0 || a.Z != 0 {
+				b, z := a.Baseline, a.Z
+				an.Baseline, an.Z = &b, &z
+			}
+			out = append(out, an)
+		}
+		return out
+	}
+}
+
+func runRateSpikes(in shared) []Anomaly {
+	t := in.t
+	var rateAnoms []analyze.Anomaly
+	if detectorConfig.RateBaseline == "ewma" {
+		rateAnoms = analyze.DetectRateSpikesEWMA(in.agg, t.maxAnoms(), t.absFloor(), t.z(), detectorConfig.RateHalfLife)
+	} else {
+		rateAnoms = analyze.DetectRateSpikes(in.agg, t.maxAnoms(), t.absFloor(), t.z())
+	}
+
+	out := make([]Anomaly, 0, len(rateAnoms))
+	for _, a := range rateAnoms {
+		m := a.Minute
+		c := a.Count
+		b := a.Baseline
+		z := a.Z
+		out = append(out, Anomaly{
+			Kind:     a.Kind,
+			SrcIP:    a.SrcIP,
+			Minute:   &m,
+			Count:    &c,
+			Baseline: &b,
+			Z:        &z,
+			Evidence: []analyze.Evidence{
+				{Signal: "z", Value: z, Threshold: 3, Weight: 1},
+				{Signal: "ratio", Value: ratio(c, b), Threshold: 2.5, Weight: 0.5},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runPathRateSpikes(in shared) []Anomaly {
+	const (
+		minCount = 20
+		maxAnoms = 50
+	)
+	pathAnoms := analyze.DetectPathRateSpikes(in.agg, minCount, maxAnoms)
+
+	out := make([]Anomaly, 0, len(pathAnoms))
+	for _, a := range pathAnoms {
+		m := a.Minute
+		c, b, z, u := a.Count, a.Baseline, a.Z, a.UniqueIPs
+		out = append(out, Anomaly{
+			Kind:      a.Kind,
+			Path:      a.Path,
+			IPs:       a.TopIPs,
+			Minute:    &m,
+			Count:     &c,
+			Baseline:  &b,
+			Z:         &z,
+			UniqueIPs: &u,
+			Evidence: []analyze.Evidence{
+				{Signal: "z", Value: z, Threshold: 3, Weight: 1},
+				{Signal: "ratio", Value: ratio(c, b), Threshold: 2.5, Weight: 0.5},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runTrafficSurges(rows []parse.Event, timeline []parse.Bucket) []Anomaly {
+	const (
+		window   = 30
+		minCount = 100
+	)
+	surges := analyze.DetectTrafficSurges(timeline, rows, window, minCount)
+
+	out := make([]Anomaly, 0, len(surges))
+	for _, a := range surges {
+		fs, ls := a.FirstSeen, a.LastSeen
+		c, p, b, z := a.Count, a.Peak, a.Baseline, a.Z
+		out = append(out, Anomaly{
+			Kind:      a.Kind,
+			IPs:       a.TopIPs,
+			FirstSeen: &fs,
+			LastSeen:  &ls,
+			Count:     &c,
+			Peak:      &p,
+			Baseline:  &b,
+			Z:         &z,
+			Evidence: []analyze.Evidence{
+				{Signal: "z", Value: z, Threshold: 3, Weight: 1},
+				{Signal: "ratio", Value: ratio(p, b), Threshold: 3, Weight: 0.5},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runSensitivePaths(in shared) []Anomaly {
+	minHits, minUnique := in.t.minHits(), in.t.minUnique()
+	sensAnoms := analyze.DetectSensitivePaths(in.rows, minHits, minUnique)
+
+	out := make([]Anomaly, 0, len(sensAnoms))
+	for _, s := range sensAnoms {
+		fs, ls := s.FirstSeen, s.LastSeen
+		h, u := s.Hits, s.UniquePref
+		out = append(out, Anomaly{
+			Kind:       s.Kind,
+			SrcIP:      s.SrcIP,
+			FirstSeen:  &fs,
+			LastSeen:   &ls,
+			Hits:       &h,
+			UniquePref: &u,
+			Evidence: []analyze.Evidence{
+				{Signal: "hits", Value: float64(h), Threshold: float64(minHits), Weight: 1},
+				{Signal: "uniquePrefixes", Value: float64(u), Threshold: float64(minUnique), Weight: 0.5},
+			},
+			Reason:  s.Reason,
+			Actions: actionsFor(s.Kind),
+		})
+	}
+	return out
+}
+
+func runAuthBruteForce(rows []parse.Event) []Anomaly {
+	const (
+		minFailures = 10
+		window      = 5 * time.Minute
+	)
+	bfAnoms := analyze.DetectAuthBruteForce(rows, minFailures, window)
+
+	out := make([]Anomaly, 0, len(bfAnoms))
+	for _, a := range bfAnoms {
+		fs, ls := a.FirstSeen, a.LastSeen
+		c, f, u := a.Peak, a.Failures, a.Usernames
+		out = append(out, Anomaly{
+			Kind:      a.Kind,
+			SrcIP:     a.SrcIP,
+			FirstSeen: &fs,
+			LastSeen:  &ls,
+			Count:     &c,
+			Failures:  &f,
+			Usernames: &u,
+			Evidence: []analyze.Evidence{
+				{Signal: "peakFailures", Value: float64(c), Threshold: minFailures, Weight: 1},
+				{Signal: "usernames", Value: float64(u), Threshold: 3, Weight: 0.5},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runNotFoundScanning(rows []parse.Event) []Anomaly {
+	const (
+		minCount  = 20
+		minUnique = 15
+		window    = 5 * time.Minute
+	)
+	nfAnoms := analyze.DetectNotFoundScanning(rows, minCount, minUnique, window)
+
+	out := make([]Anomaly, 0, len(nfAnoms))
+	for _, a := range nfAnoms {
+		fs, ls := a.FirstSeen, a.LastSeen
+		c, u := a.Count, a.UniquePaths
+		out = append(out, Anomaly{
+			Kind:        a.Kind,
+			SrcIP:       a.SrcIP,
+			FirstSeen:   &fs,
+			LastSeen:    &ls,
+			Count:       &c,
+			UniquePaths: &u,
+			TopPaths:    a.TopPaths,
+			Evidence: []analyze.Evidence{
+				{Signal: "uniquePaths", Value: float64(u), Threshold: minUnique, Weight: 1},
+				{Signal: "count", Value: float64(c), Threshold: minCount, Weight: 0.5},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runErrorRate(in shared) []Anomaly {
+	const minRequests = 20
+	erAnoms := analyze.DetectErrorRateSpikes(in.agg, minRequests)
+
+	out := make([]Anomaly, 0, len(erAnoms))
+	for _, a := range erAnoms {
+		m := a.Minute
+		c, e := a.Count, a.Errors
+		r, b, z := a.ErrorRate, a.Baseline, a.Z
+		out = append(out, Anomaly{
+			Kind:      a.Kind,
+			Minute:    &m,
+			Count:     &c,
+			Errors:    &e,
+			ErrorRate: &r,
+			Baseline:  &b,
+			Z:         &z,
+			Evidence: []analyze.Evidence{
+				{Signal: "z", Value: z, Threshold: 3, Weight: 1},
+				{Signal: "errors", Value: float64(e), Threshold: minRequests / 2, Weight: 0.25},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runServerErrorBursts(rows []parse.Event) []Anomaly {
+	const minErrors = 10
+	seAnoms := analyze.DetectServerErrorBursts(rows, minErrors)
+
+	out := make([]Anomaly, 0, len(seAnoms))
+	for _, a := range seAnoms {
+		fs, ls := a.FirstSeen, a.LastSeen
+		e, p, b, z := a.Errors, a.Peak, a.Baseline, a.Z
+		out = append(out, Anomaly{
+			Kind:       a.Kind,
+			FirstSeen:  &fs,
+			LastSeen:   &ls,
+			Errors:     &e,
+			Peak:       &p,
+			Baseline:   &b,
+			Z:          &z,
+			TopPaths:   a.TopPaths,
+			Indicators: a.Statuses,
+			Evidence: []analyze.Evidence{
+				{Signal: "z", Value: z, Threshold: 3, Weight: 1},
+				{Signal: "errors", Value: float64(e), Threshold: minErrors, Weight: 0.5},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runLatencySpikes(rows []parse.Event) []Anomaly {
+	const (
+		window     = 5 * time.Minute
+		minSamples = 10
+		factor     = 2
+		minDeltaMs = 100
+	)
+	latAnoms := analyze.DetectLatencySpikes(rows, window, minSamples, factor, minDeltaMs)
+
+	out := make([]Anomaly, 0, len(latAnoms))
+	for _, a := range latAnoms {
+		fs, ls := a.FirstSeen, a.LastSeen
+		c, p, b := a.Count, a.P95Ms, a.BaselineMs
+		out = append(out, Anomaly{
+			Kind:      a.Kind,
+			Path:      a.Template,
+			FirstSeen: &fs,
+			LastSeen:  &ls,
+			Count:     &c,
+			P95Ms:     &p,
+			Baseline:  &b,
+			Evidence: []analyze.Evidence{
+				{Signal: "p95Ratio", Value: p / max(b, 1), Threshold: factor, Weight: 1},
+				{Signal: "samples", Value: float64(c), Threshold: 5 * minSamples, Weight: 0.25},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runLowAndSlow(rows []parse.Event) []Anomaly {
+	const (
+		minUnique = 50
+		minSpan   = time.Hour
+		maxPerMin = 9 // just under the rate-spike absolute floor
+	)
+	lsAnoms := analyze.DetectLowAndSlow(rows, minUnique, minSpan, maxPerMin)
+
+	out := make([]Anomaly, 0, len(lsAnoms))
+	for _, a := range lsAnoms {
+		fs, ls := a.FirstSeen, a.LastSeen
+		c, u, p := a.Count, a.UniquePaths, a.PeakPerMin
+		out = append(out, Anomaly{
+			Kind:        a.Kind,
+			SrcIP:       a.SrcIP,
+			FirstSeen:   &fs,
+			LastSeen:    &ls,
+			Count:       &c,
+			UniquePaths: &u,
+			Peak:        &p,
+			Evidence: []analyze.Evidence{
+				{Signal: "uniquePaths", Value: float64(u), Threshold: minUnique, Weight: 1},
+				{Signal: "spanHours", Value: ls.Sub(fs).Hours(), Threshold: minSpan.Hours(), Weight: 0.5},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func geoLookup(ip string) (lat, lon float64, ok bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return 0, 0, false
+	}
+	g := enrich.GeoOf(addr)
+	if g == nil {
+		return 0, 0, false
+	}
+	return g.Lat, g.Lon, true
+}
+
+func runImpossibleTravel(rows []parse.Event) []Anomaly {
+	const (
+		minKm  = 500
+		maxKmh = 1000 // faster than a commercial flight
+	)
+	itAnoms := analyze.DetectImpossibleTravel(rows, geoLookup, minKm, maxKmh)
+
+	out := make([]Anomaly, 0, len(itAnoms))
+	for _, a := range itAnoms {
+		fs, ls := a.FirstSeen, a.LastSeen
+		d, sp := a.DistanceKm, a.SpeedKmh
+		out = append(out, Anomaly{
+			Kind:       a.Kind,
+			SrcIP:      a.SrcIP,
+			User:       a.User,
+			FromIP:     a.FromIP,
+			FirstSeen:  &fs,
+			LastSeen:   &ls,
+			DistanceKm: &d,
+			SpeedKmh:   &sp,
+			Evidence: []analyze.Evidence{
+				{Signal: "speedKmh", Value: sp, Threshold: maxKmh, Weight: 1},
+				{Signal: "distanceKm", Value: d, Threshold: 2 * minKm, Weight: 0.5},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func countryLookup(ip string) (string, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", false
+	}
+	g := enrich.GeoOf(addr)
+	if g == nil || g.Country == "" {
+		return "", false
+	}
+	return g.Country, true
+}
+
+func runNewCountry(rows []parse.Event) []Anomaly {
+	const minHistory = 3
+	ncAnoms := analyze.DetectNewCountry(rows, countryLookup, minHistory)
+
+	out := make([]Anomaly, 0, len(ncAnoms))
+	for _, a := range ncAnoms {
+		fs := a.FirstSeen
+		out = append(out, Anomaly{
+			Kind:      a.Kind,
+			SrcIP:     a.SrcIP,
+			User:      a.User,
+			Country:   a.Country,
+			Known:     a.Known,
+			FirstSeen: &fs,
+			Evidence: []analyze.Evidence{
+				{Signal: "newCountry", Value: 1, Threshold: 1, Weight: 0.75},
+				{Signal: "knownCountries", Value: float64(len(a.Known)), Threshold: 2, Weight: 0.25},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runConcurrentSessions(rows []parse.Event) []Anomaly {
+	const maxSession = 12 * time.Hour
+	csAnoms := analyze.DetectConcurrentSessions(rows, maxSession)
+
+	out := make([]Anomaly, 0, len(csAnoms))
+	for _, a := range csAnoms {
+		fs, ls := a.FirstSeen, a.LastSeen
+		p := a.Peak
+		out = append(out, Anomaly{
+			Kind:      a.Kind,
+			SrcIP:     a.SrcIP,
+			User:      a.User,
+			IPs:       a.IPs,
+			FirstSeen: &fs,
+			LastSeen:  &ls,
+			Peak:      &p,
+			Evidence: []analyze.Evidence{
+				{Signal: "concurrentIps", Value: float64(p), Threshold: 2, Weight: 1},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func intelLookup(ip string) []string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil
+	}
+	return enrich.IntelOf(addr)
+}
+
+func runKnownBadIPs(rows []parse.Event) []Anomaly {
+	kbAnoms := analyze.DetectKnownBadIPs(rows, intelLookup)
+
+	out := make([]Anomaly, 0, len(kbAnoms))
+	for _, a := range kbAnoms {
+		fs, ls := a.FirstSeen, a.LastSeen
+		c := a.Count
+		out = append(out, Anomaly{
+			Kind:      a.Kind,
+			SrcIP:     a.SrcIP,
+			Feeds:     a.Feeds,
+			FirstSeen: &fs,
+			LastSeen:  &ls,
+			Count:     &c,
+			Evidence: []analyze.Evidence{
+				{Signal: "feeds", Value: float64(len(a.Feeds)), Threshold: 1, Weight: 1.5},
+				{Signal: "succeeded", Value: float64(a.Succeeded), Threshold: 1, Weight: 0.5},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runAnonymizedProbes(rows []parse.Event) []Anomaly {
+	const minHits = 1
+	apAnoms := analyze.DetectAnonymizedProbes(rows, minHits)
+
+	out := make([]Anomaly, 0, len(apAnoms))
+	for _, a := range apAnoms {
+		fs, ls := a.FirstSeen, a.LastSeen
+		h, u := a.Hits, a.Unique
+		out = append(out, Anomaly{
+			Kind:        a.Kind,
+			SrcIP:       a.SrcIP,
+			TopPaths:    a.Paths,
+			FirstSeen:   &fs,
+			LastSeen:    &ls,
+			Hits:        &h,
+			UniquePaths: &u,
+			Evidence: []analyze.Evidence{
+				{Signal: "anonymizer", Value: 1, Threshold: 1, Weight: 1},
+				{Signal: "hits", Value: float64(h), Threshold: minHits, Weight: 1},
+				{Signal: "unique_paths", Value: float64(u), Threshold: 1, Weight: 0.5},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runScannerUA(rows []parse.Event) []Anomaly {
+	const curlBurst = 30
+	suAnoms := analyze.DetectScannerUA(rows, curlBurst)
+
+	out := make([]Anomaly, 0, len(suAnoms))
+	for _, a := range suAnoms {
+		fs, ls := a.FirstSeen, a.LastSeen
+		c := a.Count
+		out = append(out, Anomaly{
+			Kind:      a.Kind,
+			SrcIP:     a.SrcIP,
+			Tool:      a.Tool,
+			FirstSeen: &fs,
+			LastSeen:  &ls,
+			Count:     &c,
+			Evidence: []analyze.Evidence{
+				{Signal: "toolMatch", Value: 1, Threshold: 1, Weight: 1},
+				{Signal: "count", Value: float64(c), Threshold: curlBurst, Weight: 0.5},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runAbnormalUA(rows []parse.Event) []Anomaly {
+	const (
+		minRequests = 20
+		rotateRatio = 0.8
+	)
+	auAnoms := analyze.DetectAbnormalUA(rows, minRequests, rotateRatio)
+
+	out := make([]Anomaly, 0, len(auAnoms))
+	for _, a := range auAnoms {
+		fs, ls := a.FirstSeen, a.LastSeen
+		c, u := a.Count, a.UniqueUAs
+		shareAt := 0.5
+		if a.Signal == analyze.UASignalRotating {
+			shareAt = rotateRatio
+		}
+		out = append(out, Anomaly{
+			Kind:      a.Kind,
+			SrcIP:     a.SrcIP,
+			Signal:    a.Signal,
+			FirstSeen: &fs,
+			LastSeen:  &ls,
+			Count:     &c,
+			UniqueUAs: &u,
+			Evidence: []analyze.Evidence{
+				{Signal: "count", Value: float64(c), Threshold: minRequests, Weight: 0.5},
+				{Signal: "share", Value: a.Share, Threshold: shareAt, Weight: 1},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runRareMethods(rows []parse.Event) []Anomaly {
+	const (
+		minRequests = 20
+		mixShare    = 0.9
+	)
+	rmAnoms := analyze.DetectRareMethods(rows, minRequests, mixShare)
+
+	out := make([]Anomaly, 0, len(rmAnoms))
+	for _, a := range rmAnoms {
+		fs, ls := a.FirstSeen, a.LastSeen
+		c := a.Count
+		out = append(out, Anomaly{
+			Kind:       a.Kind,
+			SrcIP:      a.SrcIP,
+			Signal:     a.Signal,
+			Indicators: a.Methods,
+			FirstSeen:  &fs,
+			LastSeen:   &ls,
+			Count:      &c,
+			Evidence: []analyze.Evidence{
+				{Signal: "methods", Value: float64(len(a.Methods)), Threshold: 1, Weight: 1},
+				{Signal: "count", Value: float64(c), Threshold: minRequests, Weight: 0.5},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runResponseSizes(rows []parse.Event) []Anomaly {
+	const (
+		minSamples = 20
+		factor     = 20
+		minBytes   = 1 << 20
+	)
+	rsAnoms := analyze.DetectResponseSizes(rows, minSamples, factor, minBytes)
+
+	out := make([]Anomaly, 0, len(rsAnoms))
+	for _, a := range rsAnoms {
+		fs, ls := a.FirstSeen, a.LastSeen
+		c, b := a.Count, a.Baseline
+		mb, tb := a.MaxBytes, a.TotalBytes
+		out = append(out, Anomaly{
+			Kind:       a.Kind,
+			SrcIP:      a.SrcIP,
+			Path:       a.Path,
+			FirstSeen:  &fs,
+			LastSeen:   &ls,
+			Count:      &c,
+			Baseline:   &b,
+			MaxBytes:   &mb,
+			TotalBytes: &tb,
+			Evidence: []analyze.Evidence{
+				{Signal: "sizeRatio", Value: float64(mb) / max(b, 1), Threshold: factor, Weight: 1},
+				{Signal: "maxBytes", Value: float64(mb), Threshold: minBytes, Weight: 0.25},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runBehaviorClusters(in shared) []Anomaly {
+	const (
+		minRequests = 5
+		minSources  = 20
+		maxShare    = 0.05
+		minDistance = 3
+	)
+	clAnoms := analyze.DetectBehaviorClusters(in.agg, minRequests, minSources, maxShare, minDistance)
+
+	out := make([]Anomaly, 0, len(clAnoms))
+	for _, a := range clAnoms {
+		fs, ls := a.FirstSeen, a.LastSeen
+		c, n, d := a.ClusterSize, a.Sources, a.Distance
+		p := a.Profile
+		out = append(out, Anomaly{
+			Kind:        a.Kind,
+			SrcIP:       a.SrcIP,
+			FirstSeen:   &fs,
+			LastSeen:    &ls,
+			Count:       &p.Requests,
+			UniqueIPs:   &n,
+			ClusterSize: &c,
+			Distance:    &d,
+			Profile:     &p,
+			Indicators:  a.Traits,
+			Evidence: []analyze.Evidence{
+				{Signal: "clusterDistance", Value: d, Threshold: minDistance, Weight: 1},
+				{Signal: "clusterRarity", Value: maxShare * float64(n) / float64(c), Threshold: 1, Weight: 0.25},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runBehavioralOutliers(rows []parse.Event) []Anomaly {
+	const (
+		minRequests = 3
+		minPoints   = 100
+		minScore    = 0.7
+	)
+	boAnoms := analyze.DetectBehavioralOutliers(rows, minRequests, minPoints, minScore)
+
+	out := make([]Anomaly, 0, len(boAnoms))
+	for _, a := range boAnoms {
+		m := a.Minute
+		s := a.Score
+		p := a.Profile
+		out = append(out, Anomaly{
+			Kind:         a.Kind,
+			SrcIP:        a.SrcIP,
+			Minute:       &m,
+			Count:        &p.Requests,
+			OutlierScore: &s,
+			Profile:      &p,
+			Indicators:   a.Traits,
+			Evidence: []analyze.Evidence{
+				{Signal: "isolationMargin", Value: s - 0.5, Threshold: minScore - 0.5, Weight: 1},
+				{Signal: "outlyingMinutes", Value: float64(a.Minutes), Threshold: 5, Weight: 0.25},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runIDEnumeration(rows []parse.Event) []Anomaly {
+	const minDistinct = 30
+	enAnoms := analyze.DetectIDEnumeration(rows, minDistinct)
+
+	out := make([]Anomaly, 0, len(enAnoms))
+	for _, a := range enAnoms {
+		fs, ls := a.FirstSeen, a.LastSeen
+		c, d := a.Count, a.DistinctIDs
+		var inds []string
+		if a.Sequential {
+			inds = []string{"sequential"}
+		}
+		out = append(out, Anomaly{
+			Kind:        a.Kind,
+			SrcIP:       a.SrcIP,
+			Path:        a.Template,
+			FirstSeen:   &fs,
+			LastSeen:    &ls,
+			Count:       &c,
+			DistinctIDs: &d,
+			Indicators:  inds,
+			Samples:     a.Samples,
+			Evidence: []analyze.Evidence{
+				{Signal: "distinctIds", Value: float64(d), Threshold: minDistinct, Weight: 1},
+				{Signal: "sequential", Value: boolValue(a.Sequential), Threshold: 1, Weight: 0.5},
+				{Signal: "foundShare", Value: ratio(a.Found, float64(c)), Threshold: 0.5, Weight: 0.25},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runCampaigns(rows []parse.Event) []Anomaly {
+	const (
+		window   = 10 * time.Minute
+		minIPs   = 50
+		maxShare = 0.1
+		topIPs   = 10
+	)
+	camps := analyze.DetectCampaigns(rows, window, minIPs, maxShare)
+
+	out := make([]Anomaly, 0, len(camps))
+	for _, a := range camps {
+		fs, ls := a.FirstSeen, a.LastSeen
+		c, u, f, b := a.Count, a.UniqueIPs, a.Failures, a.Baseline
+		members := make(map[string]bool, len(a.IPs))
+		for _, ip := range a.IPs {
+			members[ip] = true
+		}
+		out = append(out, Anomaly{
+			Kind:      a.Kind,
+			Path:      a.Template,
+			IPs:       a.IPs[:min(topIPs, len(a.IPs))],
+			FirstSeen: &fs,
+			LastSeen:  &ls,
+			Count:     &c,
+			UniqueIPs: &u,
+			Failures:  &f,
+			Baseline:  &b,
+			Evidence: []analyze.Evidence{
+				{Signal: "uniqueIps", Value: float64(u), Threshold: minIPs, Weight: 1},
+				{Signal: "ratio", Value: ratio(c, b), Threshold: 3, Weight: 0.5},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+			members: members,
+		})
+	}
+	return out
+}
+
+func runSQLi(rows []parse.Event) []Anomaly {
+	return payloadAnoms(analyze.DetectSQLi(rows))
+}
+
+func runPathTraversal(rows []parse.Event) []Anomaly {
+	return payloadAnoms(analyze.DetectPathTraversal(rows))
+}
+
+func runJNDI(rows []parse.Event) []Anomaly {
+	return payloadAnoms(analyze.DetectJNDI(rows))
+}
+
+func payloadAnoms(found []analyze.AnomalyPayload) []Anomaly {
+	out := make([]Anomaly, 0, len(found))
+	for _, a := range found {
+		fs, ls := a.FirstSeen, a.LastSeen
+		c := a.Count
+		out = append(out, Anomaly{
+			Kind:       a.Kind,
+			SrcIP:      a.SrcIP,
+			FirstSeen:  &fs,
+			LastSeen:   &ls,
+			Count:      &c,
+			Indicators: a.Indicators,
+			Samples:    a.Samples,
+			Evidence: []analyze.Evidence{
+				{Signal: "requests", Value: float64(c), Threshold: 1, Weight: 1},
+				{Signal: "indicators", Value: float64(len(a.Indicators)), Threshold: 2, Weight: 0.25},
+				{Signal: "succeeded", Value: float64(a.Succeeded), Threshold: 1, Weight: 0.5},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runForbiddenBursts(rows []parse.Event) []Anomaly {
+	const (
+		minDenied = 10
+		window    = 5 * time.Minute
+	)
+	return k8sAnoms(analyze.DetectForbiddenBursts(rows, minDenied, window), minDenied)
+}
+
+func runExecSpikes(rows []parse.Event) []Anomaly {
+	const (
+		minExec = 5
+		window  = 10 * time.Minute
+	)
+	return k8sAnoms(analyze.DetectExecSpikes(rows, minExec, window), minExec)
+}
+
+func runSecretsAccess(rows []parse.Event) []Anomaly {
+	const minSecrets = 5
+	return k8sAnoms(analyze.DetectSecretsAccess(rows, minSecrets), minSecrets)
+}
+
+// k8sAnoms converts Kubernetes findings; threshold is the per-window count the
+// detector fired at.
+func k8sAnoms(found []analyze.AnomalyK8s, threshold int) []Anomaly {
+	out := make([]Anomaly, 0, len(found))
+	for _, a := range found {
+		fs, ls := a.FirstSeen, a.LastSeen
+		c, p := a.Count, a.Peak
+		out = append(out, Anomaly{
+			Kind:      a.Kind,
+			SrcIP:     a.SrcIP,
+			User:      a.User,
+			FirstSeen: &fs,
+			LastSeen:  &ls,
+			Count:     &c,
+			Peak:      &p,
+			TopPaths:  a.TopPaths,
+			Evidence: []analyze.Evidence{
+				{Signal: "peak", Value: float64(p), Threshold: float64(threshold), Weight: 1},
+				{Signal: "targets", Value: float64(len(a.TopPaths)), Threshold: 3, Weight: 0.25},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runPrivilegeBursts(rows []parse.Event) []Anomaly {
+	const (
+		minStatements = 5
+		window        = 10 * time.Minute
+	)
+	pbAnoms := analyze.DetectPrivilegeBursts(rows, minStatements, window)
+
+	out := make([]Anomaly, 0, len(pbAnoms))
+	for _, a := range pbAnoms {
+		fs, ls := a.FirstSeen, a.LastSeen
+		c, p := a.Count, a.Peak
+		out = append(out, Anomaly{
+			Kind:      a.Kind,
+			SrcIP:     a.SrcIP,
+			User:      a.User,
+			FirstSeen: &fs,
+			LastSeen:  &ls,
+			Count:     &c,
+			Peak:      &p,
+			Evidence: []analyze.Evidence{
+				{Signal: "peak", Value: float64(p), Threshold: minStatements, Weight: 1},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
+
+func runBulkSelects(rows []parse.Event) []Anomaly {
+	const (
+		minPerMin = 200
+		factor    = 5
+	)
+	bsAnoms := analyze.DetectBulkSelects(rows, minPerMin, factor)
+
+	out := make([]Anomaly, 0, len(bsAnoms))
+	for _, a := range bsAnoms {
+		m := a.Minute
+		c, b := a.Count, a.Baseline
+		out = append(out, Anomaly{
+			Kind:     a.Kind,
+			SrcIP:    a.SrcIP,
+			User:     a.User,
+			Minute:   &m,
+			Count:    &c,
+			Baseline: &b,
+			Evidence: []analyze.Evidence{
+				{Signal: "ratio", Value: ratio(c, b), Threshold: factor, Weight: 1},
+				{Signal: "count", Value: float64(c), Threshold: minPerMin, Weight: 0.5},
+			},
+			Reason:  a.Reason,
+			Actions: actionsFor(a.Kind),
+		})
+	}
+	return out
+}
@@ -0,0 +1,39 @@
+package upload
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCapFindings(t *testing.T) {
+	found := func() []Anomaly {
+		return []Anomaly{
+			{SrcIP: "a", Severity: "low", Confidence: 0.9},
+			{SrcIP: "b", Severity: "high", Confidence: 0.6},
+			{SrcIP: "c", Severity: "medium", Confidence: 0.8},
+			{SrcIP: "d", Severity: "high", Confidence: 0.7},
+			{SrcIP: "e", Severity: "critical", Confidence: 0.5},
+		}
+	}
+	tests := []struct {
+		name string
+		n    int
+		want []string
+	}{
+		{"uncapped", 0, []string{"a", "b", "c", "d", "e"}},
+		{"cap over the count", 9, []string{"a", "b", "c", "d", "e"}},
+		{"most severe first, then most confident", 3, []string{"e", "d", "b"}},
+		{"one", 1, []string{"e"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, a := range capFindings(found(), tt.n) {
+				got = append(got, a.SrcIP)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("kept %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package upload
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/analyze"
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// EvidenceRows is how many kept rows are attached to each anomaly as events;
+// 0 turns it off.
+var EvidenceRows = 5
+
+// eventFilters narrow the rows attached to an anomaly of a kind beyond its
+// sources, time span, path and user.
+var eventFilters = map[string]func(a Anomaly, ev parse.Event) bool{
+	"forced_browsing":    func(_ Anomaly, ev parse.Event) bool { return ev.Status == 404 },
+	"auth_bruteforce":    func(_ Anomaly, ev parse.Event) bool { return analyze.IsLoginFailure(ev) },
+	"error_rate":         func(_ Anomaly, ev parse.Event) bool { return ev.Status >= 400 },
+	"server_error_burst": func(_ Anomaly, ev parse.Event) bool { return ev.Status >= 500 },
+	"rare_method": func(a Anomaly, ev parse.Event) bool {
+		return slices.Contains(a.Indicators, strings.ToUpper(ev.Method))
+	},
+	"abnormal_ua": func(a Anomaly, ev parse.Event) bool {
+		return a.Signal == analyze.UASignalRotating || len([]rune(strings.Trim(strings.TrimSpace(ev.UA), "-"))) <= 1
+	},
+}
+
+// eventOrder ranks rows for kinds where the most extreme rows say more than
+// the earliest ones.
+var eventOrder = map[string]func(x, y parse.Event) int{
+	"latency_spike": func(x, y parse.Event) int { return cmp.Compare(y.DurationMs, x.DurationMs) },
+	"response_size": func(x, y parse.Event) int { return cmp.Compare(y.Bytes, x.Bytes) },
+}
+
+// attachEvents gives each anomaly up to EvidenceRows of the kept rows behind
+// it, so a finding can be checked without searching the rows by hand.
+func attachEvents(anoms []Anomaly, rows []parse.Event) {
+	if EvidenceRows <= 0 {
+		return
+	}
+	sensitive := analyze.SensitiveMatcher()
+	for i := range anoms {
+		a := &anoms[i]
+		filter := eventFilters[a.Kind]
+		if a.Kind == "sensitive_paths" || a.Kind == "anonymized_probe" {
+			filter = func(_ Anomaly, ev parse.Event) bool { return sensitive(ev.Path) }
+		}
+		var found []parse.Event
+		for _, ev := range rows {
+			if covers(*a, ev) && (filter == nil || filter(*a, ev)) {
+				found = append(found, ev)
+			}
+		}
+		if order, ok := eventOrder[a.Kind]; ok {
+			slices.SortStableFunc(found, order)
+		}
+		if len(found) > EvidenceRows {
+			found = found[:EvidenceRows]
+		}
+		a.Events = found
+	}
+}
+
+// covers reports whether ev falls within a's sources, time span, path and
+// user, for whichever of those a sets.
+func covers(a Anomaly, ev parse.Event) bool {
+	if a.Minute != nil {
+		if ev.TS.Before(*a.Minute) || !ev.TS.Before(a.Minute.Add(time.Minute)) {
+			return false
+		}
+	} else if a.FirstSeen != nil {
+		last := a.FirstSeen
+		if a.LastSeen != nil {
+			last = a.LastSeen
+		}
+		if ev.TS.Before(*a.FirstSeen) || ev.TS.After(*last) {
+			return false
+		}
+	}
+	if a.SrcIP != "" || a.FromIP != "" || len(a.IPs) > 0 || a.members != nil {
+		if ev.SrcIP != a.SrcIP && ev.SrcIP != a.FromIP && !slices.Contains(a.IPs, ev.SrcIP) && !a.members[ev.SrcIP] {
+			return false
+		}
+	}
+	if a.User != "" && !strings.EqualFold(ev.User, a.User) {
+		return false
+	}
+	if a.Path != "" && ev.Path != a.Path {
+		if tmpl, _, ok := analyze.PathTemplate(ev.Path); !ok || tmpl != a.Path {
+			return false
+		}
+	}
+	return true
+}
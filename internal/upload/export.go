@@ -0,0 +1,368 @@
+package upload
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parquet"
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// Export serves GET /api/jobs/{id}/export using the Default service.
+func Export(w http.ResponseWriter, r *http.Request) {
+	Default.Export(w, r)
+}
+
+// Export downloads a finished job's results. ?format=csv writes one table
+// per request, picked by ?table=: rows (the default, all kept rows narrowed
+// by ?where=), timeline or anomalies (narrowed by ?minSeverity=).
+// ?format=ndjson and ?format=parquet stream every event parsed from the stored
+// file instead; see exportEvents. ?format=pdf renders an incident report.
+func (s *Service) Export(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	switch q.Get("format") {
+	case "csv":
+	case "ndjson", "parquet":
+		s.exportEvents(w, r, q.Get("format"))
+		return
+	case "pdf":
+		s.exportReport(w, r)
+		return
+	default:
+		http.Error(w, "format must be csv, ndjson, parquet or pdf", http.StatusBadRequest)
+		return
+	}
+	table := q.Get("table")
+	if table == "" {
+		table = "rows"
+	}
+	if table != "rows" && table != "timeline" && table != "anomalies" {
+		http.Error(w, "table must be one of rows, timeline, anomalies", http.StatusBadRequest)
+		return
+	}
+	minRank, ok := minSeverity(r)
+	if !ok {
+		http.Error(w, "minSeverity must be one of low, medium, high, critical", http.StatusBadRequest)
+		return
+	}
+	j, err := s.Jobs.GetJob(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	resp, ok := storedResults(w, j)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+j.ID+`-`+table+`.csv"`)
+	cw := csv.NewWriter(w)
+	switch table {
+	case "rows":
+		rows := resp.Rows
+		if filters := whereFilters(r); len(filters) > 0 {
+			rows = filterRows(rows, filters)
+		}
+		writeRowsCSV(cw, rows)
+	case "timeline":
+		_ = cw.Write([]string{"t", "count"})
+		for _, b := range resp.Timeline {
+			_ = cw.Write([]string{b.T.UTC().Format(time.RFC3339), strconv.Itoa(b.Count)})
+		}
+	case "anomalies":
+		anoms := resp.Anomalies
+		if minRank > 0 {
+			anoms = filterSeverity(anoms, minRank)
+		}
+		writeAnomaliesCSV(cw, anoms)
+	}
+	cw.Flush()
+}
+
+// exportReport renders a finished job as a PDF incident report, with its
+// anomalies narrowed by ?minSeverity=.
+func (s *Service) exportReport(w http.ResponseWriter, r *http.Request) {
+	minRank, ok := minSeverity(r)
+	if !ok {
+		http.Error(w, "minSeverity must be one of low, medium, high, critical", http.StatusBadRequest)
+		return
+	}
+	j, err := s.Jobs.GetJob(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	resp, ok := storedResults(w, j)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+j.ID+`-report.pdf"`)
+	_, _ = incidentReport(resp, minRank, time.Now()).WriteTo(w)
+}
+
+// exportFlushEvery is how many events exportEvents buffers between flushes.
+const exportFlushEvery = 1000
+
+// eventSink encodes exported events in one format.
+type eventSink interface {
+	write(ev parse.Event) error
+	close() error
+}
+
+type ndjsonSink struct{ enc *json.Encoder }
+
+func (s ndjsonSink) write(ev parse.Event) error { return s.enc.Encode(ev) }
+func (ndjsonSink) close() error                 { return nil }
+
+// exportEvents streams every event of a finished job's stored file as NDJSON
+// or Parquet, not just the kept rows, narrowed by ?where=. Events are encoded
+// as they are parsed and flushed in chunks, so a slow reader holds back the
+// parser rather than letting output pile up in memory; the stream stops when
+// the client goes away.
+func (s *Service) exportEvents(w http.ResponseWriter, r *http.Request, format string) {
+	j, err := s.Jobs.GetJob(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if _, ok := storedResults(w, j); !ok {
+		return
+	}
+	if _, err := os.Stat(j.SavedTo); err != nil {
+		http.Error(w, "the uploaded file is no longer kept", http.StatusGone)
+		return
+	}
+	filters := whereFilters(r)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	rc := http.NewResponseController(w)
+	bw := bufio.NewWriterSize(w, 64<<10)
+	var sink eventSink = ndjsonSink{json.NewEncoder(bw)}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	if format == "parquet" {
+		sink = newParquetSink(bw)
+		w.Header().Set("Content-Type", "application/vnd.apache.parquet")
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="`+j.ID+`-events.`+format+`"`)
+	n := 0
+	_, err = parse.EachEvent(ctx, j.SavedTo, func(ev parse.Event) {
+		if ctx.Err() != nil {
+			return
+		}
+		for _, f := range filters {
+			if !ev.Matches(f.field, f.value) {
+				return
+			}
+		}
+		if sink.write(ev) != nil {
+			cancel()
+			return
+		}
+		if n++; n%exportFlushEvery == 0 && (bw.Flush() != nil || rc.Flush() != nil) {
+			cancel()
+		}
+	})
+	if err != nil && ctx.Err() == nil {
+		if n == 0 {
+			w.Header().Del("Content-Disposition")
+			http.Error(w, "could not read the uploaded file", http.StatusInternalServerError)
+		}
+		log.Printf("export %s: %v", j.ID, err)
+		return
+	}
+	if ctx.Err() == nil && sink.close() == nil {
+		_ = bw.Flush()
+	}
+}
+
+// eventColumns is the Parquet schema of an exported event: the Event fields
+// flattened, with Client split into its parts and Extras as a JSON object.
+var eventColumns = []parquet.Column{
+	{Name: "ts", Kind: parquet.Timestamp},
+	{Name: "srcIp", Kind: parquet.String},
+	{Name: "srcClass", Kind: parquet.String},
+	{Name: "anonymizer", Kind: parquet.String},
+	{Name: "dst", Kind: parquet.String},
+	{Name: "method", Kind: parquet.String},
+	{Name: "path", Kind: parquet.String},
+	{Name: "status", Kind: parquet.Int32},
+	{Name: "bytes", Kind: parquet.Int64},
+	{Name: "ua", Kind: parquet.String},
+	{Name: "crawler", Kind: parquet.String},
+	{Name: "crawlerVerified", Kind: parquet.Bool},
+	{Name: "clientBrowser", Kind: parquet.String},
+	{Name: "clientOs", Kind: parquet.String},
+	{Name: "clientDevice", Kind: parquet.String},
+	{Name: "clientBot", Kind: parquet.Bool},
+	{Name: "referer", Kind: parquet.String},
+	{Name: "rawQuery", Kind: parquet.String},
+	{Name: "durationMs", Kind: parquet.Double},
+	{Name: "user", Kind: parquet.String},
+	{Name: "workstation", Kind: parquet.String},
+	{Name: "category", Kind: parquet.String},
+	{Name: "statement", Kind: parquet.String},
+	{Name: "sessionId", Kind: parquet.String},
+	{Name: "resource", Kind: parquet.String},
+	{Name: "extras", Kind: parquet.String},
+}
+
+type parquetSink struct {
+	pw  *parquet.Writer
+	row []any
+}
+
+func newParquetSink(w io.Writer) *parquetSink {
+	return &parquetSink{pw: parquet.NewWriter(w, eventColumns, 0), row: make([]any, len(eventColumns))}
+}
+
+// write maps ev onto eventColumns; zero values are written as null.
+func (s *parquetSink) write(ev parse.Event) error {
+	var c parse.Client
+	if ev.Client != nil {
+		c = *ev.Client
+	}
+	var extras string
+	if len(ev.Extras) > 0 {
+		b, _ := json.Marshal(ev.Extras)
+		extras = string(b)
+	}
+	var ts any
+	if !ev.TS.IsZero() {
+		ts = ev.TS
+	}
+	s.row = append(s.row[:0], ts,
+		orNull(ev.SrcIP), orNull(ev.SrcClass), orNull(ev.Anonymizer), orNull(ev.Dst), orNull(ev.Method), orNull(ev.Path),
+		orNull(ev.Status), orNull(ev.Bytes), orNull(ev.UA), orNull(ev.Crawler), orNull(ev.CrawlerVerified),
+		orNull(c.Browser), orNull(c.OS), orNull(c.Device), orNull(c.Bot),
+		orNull(ev.Referer), orNull(ev.RawQuery), orNull(ev.DurationMs), orNull(ev.User), orNull(ev.Workstation),
+		orNull(ev.Category), orNull(ev.Statement), orNull(ev.SessionID), orNull(ev.Resource), orNull(extras),
+	)
+	return s.pw.Write(s.row)
+}
+
+// orNull returns nil for the zero value, so it is exported as null.
+func orNull[T comparable](v T) any {
+	var zero T
+	if v == zero {
+		return nil
+	}
+	return v
+}
+
+func (s *parquetSink) close() error { return s.pw.Close() }
+
+func writeRowsCSV(cw *csv.Writer, rows []parse.Event) {
+	_ = cw.Write([]string{"ts", "srcIp", "srcClass", "dst", "method", "path", "status", "bytes", "durationMs", "ua", "referer", "user", "category", "resource", "sessionId", "extras"})
+	for _, ev := range rows {
+		var extras string
+		if len(ev.Extras) > 0 {
+			b, _ := json.Marshal(ev.Extras)
+			extras = string(b)
+		}
+		_ = cw.Write([]string{
+			optTime(ev.TS),
+			csvText(ev.SrcIP),
+			csvText(ev.SrcClass),
+			csvText(ev.Dst),
+			csvText(ev.Method),
+			csvText(ev.Path),
+			optInt(ev.Status),
+			optInt(ev.Bytes),
+			optFloat(ev.DurationMs),
+			csvText(ev.UA),
+			csvText(ev.Referer),
+			csvText(ev.User),
+			csvText(ev.Category),
+			csvText(ev.Resource),
+			csvText(ev.SessionID),
+			csvText(extras),
+		})
+	}
+}
+
+// writeAnomaliesCSV writes one row per anomaly. Per-minute anomalies have
+// their minute as firstSeen too, and count falls back to the hits, failures
+// or errors of the kinds that report those instead.
+func writeAnomaliesCSV(cw *csv.Writer, anoms []Anomaly) {
+	_ = cw.Write([]string{"fingerprint", "kind", "severity", "confidence", "srcIp", "subnet", "user", "path", "minute", "firstSeen", "lastSeen", "count", "stage", "reason", "actions"})
+	for _, a := range anoms {
+		_ = cw.Write([]string{
+			csvText(a.Fingerprint),
+			csvText(a.Kind),
+			csvText(a.Severity),
+			strconv.FormatFloat(a.Confidence, 'f', 2, 64),
+			csvText(a.SrcIP),
+			csvText(a.Subnet),
+			csvText(a.User),
+			csvText(a.Path),
+			csvTime(a.Minute),
+			csvTime(cmp.Or(a.FirstSeen, a.Minute)),
+			csvTime(a.LastSeen),
+			csvCount(cmp.Or(a.Count, a.Hits, a.Failures, a.Errors)),
+			csvText(a.Stage),
+			csvText(a.Reason),
+			csvText(strings.Join(a.Actions, "; ")),
+		})
+	}
+}
+
+// csvText guards a value taken from a log or a rule against being run as a
+// spreadsheet formula by prefixing a leading =, +, -, @, tab or carriage
+// return with '. Every text cell goes through it, even those a parser
+// normally fills with safe values, as rules and odd formats can set them.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func optInt[T int | int64](n T) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatInt(int64(n), 10)
+}
+
+func optFloat(f float64) string {
+	if f == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// optTime leaves an event without a timestamp empty rather than writing the
+// zero time.
+func optTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func csvTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func csvCount(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
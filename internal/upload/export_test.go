@@ -0,0 +1,121 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+const formula = "=HYPERLINK(\"http://evil\")"
+
+// csvCell writes one table with write and returns the named cell of its
+// only data row.
+func csvCell(t *testing.T, write func(*csv.Writer), column string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	write(cw)
+	cw.Flush()
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want a header and one row", len(records))
+	}
+	for i, name := range records[0] {
+		if name == column {
+			return records[1][i]
+		}
+	}
+	t.Fatalf("no column %q in %v", column, records[0])
+	return ""
+}
+
+func TestWriteRowsCSVEscapes(t *testing.T) {
+	tests := []struct {
+		column string
+		set    func(*parse.Event)
+	}{
+		{"srcIp", func(ev *parse.Event) { ev.SrcIP = formula }},
+		{"srcClass", func(ev *parse.Event) { ev.SrcClass = formula }},
+		{"dst", func(ev *parse.Event) { ev.Dst = formula }},
+		{"method", func(ev *parse.Event) { ev.Method = formula }},
+		{"path", func(ev *parse.Event) { ev.Path = formula }},
+		{"ua", func(ev *parse.Event) { ev.UA = formula }},
+		{"referer", func(ev *parse.Event) { ev.Referer = formula }},
+		{"user", func(ev *parse.Event) { ev.User = formula }},
+		{"category", func(ev *parse.Event) { ev.Category = formula }},
+		{"resource", func(ev *parse.Event) { ev.Resource = formula }},
+		{"sessionId", func(ev *parse.Event) { ev.SessionID = formula }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.column, func(t *testing.T) {
+			var ev parse.Event
+			tt.set(&ev)
+			got := csvCell(t, func(cw *csv.Writer) { writeRowsCSV(cw, []parse.Event{ev}) }, tt.column)
+			if got != "'"+formula {
+				t.Errorf("%s = %q, want it prefixed with '", tt.column, got)
+			}
+		})
+	}
+}
+
+func TestWriteAnomaliesCSVEscapes(t *testing.T) {
+	tests := []struct {
+		column string
+		set    func(*Anomaly)
+	}{
+		{"fingerprint", func(a *Anomaly) { a.Fingerprint = formula }},
+		{"kind", func(a *Anomaly) { a.Kind = formula }},
+		{"severity", func(a *Anomaly) { a.Severity = formula }},
+		{"srcIp", func(a *Anomaly) { a.SrcIP = formula }},
+		{"subnet", func(a *Anomaly) { a.Subnet = formula }},
+		{"user", func(a *Anomaly) { a.User = formula }},
+		{"path", func(a *Anomaly) { a.Path = formula }},
+		{"stage", func(a *Anomaly) { a.Stage = formula }},
+		{"reason", func(a *Anomaly) { a.Reason = formula }},
+		{"actions", func(a *Anomaly) { a.Actions = []string{formula} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.column, func(t *testing.T) {
+			var a Anomaly
+			tt.set(&a)
+			got := csvCell(t, func(cw *csv.Writer) { writeAnomaliesCSV(cw, []Anomaly{a}) }, tt.column)
+			if got != "'"+formula {
+				t.Errorf("%s = %q, want it prefixed with '", tt.column, got)
+			}
+		})
+	}
+}
+
+func TestWriteAnomaliesCSVTimeAndCount(t *testing.T) {
+	minute := time.Date(2024, 5, 1, 13, 5, 0, 0, time.UTC)
+	first, last := minute.Add(-time.Hour), minute.Add(time.Hour)
+	n := func(v int) *int { return &v }
+	tests := []struct {
+		name string
+		a    Anomaly
+		want map[string]string
+	}{
+		{"per-minute spike", Anomaly{Kind: "error_spike", Minute: &minute, Errors: n(42)},
+			map[string]string{"minute": "2024-05-01T13:05:00Z", "firstSeen": "2024-05-01T13:05:00Z", "lastSeen": "", "count": "42"}},
+		{"span", Anomaly{Kind: "low_and_slow", FirstSeen: &first, LastSeen: &last, Count: n(7)},
+			map[string]string{"minute": "", "firstSeen": "2024-05-01T12:05:00Z", "lastSeen": "2024-05-01T14:05:00Z", "count": "7"}},
+		{"hits", Anomaly{Kind: "sensitive_paths", Hits: n(9)}, map[string]string{"count": "9"}},
+		{"failures", Anomaly{Kind: "brute_force", Failures: n(30)}, map[string]string{"count": "30"}},
+		{"count wins", Anomaly{Kind: "rate_spike", Count: n(3), Hits: n(5)}, map[string]string{"count": "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for column, want := range tt.want {
+				if got := csvCell(t, func(cw *csv.Writer) { writeAnomaliesCSV(cw, []Anomaly{tt.a}) }, column); got != want {
+					t.Errorf("%s = %q, want %q", column, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"slices"
+	"time"
+)
+
+// fingerprint identifies a finding so that the same actor tripping the same
+// detector in consecutive runs over one source yields the same value (cf.
+// SARIF partialFingerprints); custom rule findings include every value they
+// were grouped by. Findings pinned to one minute, and findings with no actor
+// at all (such as a global error-rate spike), are told apart by when they
+// happened instead.
+func fingerprint(a Anomaly) string {
+	key := "v2|" + a.Kind + "|" + a.SrcIP + "|" + a.User
+	if a.Path != "" {
+		key += "|" + a.Path
+	}
+	if a.Subnet != "" {
+		key += "|" + a.Subnet
+	}
+	for _, k := range slices.Sorted(maps.Keys(a.Group)) {
+		key += "|" + k + "=" + a.Group[k]
+	}
+	switch {
+	case a.Minute != nil:
+		key += "|" + a.Minute.UTC().Format(time.RFC3339)
+	case a.SrcIP == "" && a.User == "" && a.Path == "" && a.Subnet == "" && len(a.Group) == 0 && a.FirstSeen != nil:
+		key += "|" + a.FirstSeen.UTC().Truncate(time.Minute).Format(time.RFC3339)
+	}
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:8])
+}
@@ -1,165 +1,413 @@
 package upload
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"io"
-	"log"
 	"net/http"
-	"os"
-	"path/filepath"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strconv"
 	"time"
 
 	"github.com/allensuvorov/tenexlog/internal/analyze"
 	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/jobs"
+	"github.com/allensuvorov/tenexlog/internal/notify"
 	"github.com/allensuvorov/tenexlog/internal/parse"
 )
 
-type anyAnom struct {
-	Kind       string     `json:"kind"`
-	SrcIP      string     `json:"srcIp"`
-	Minute     *time.Time `json:"minute,omitempty"`
-	FirstSeen  *time.Time `json:"firstSeen,omitempty"`
-	LastSeen   *time.Time `json:"lastSeen,omitempty"`
-	Count      *int       `json:"count,omitempty"`
-	Baseline   *float64   `json:"baseline,omitempty"`
-	Z          *float64   `json:"z,omitempty"`
-	Hits       *int       `json:"hits,omitempty"`
-	UniquePref *int       `json:"uniquePref,omitempty"`
-	Confidence float64    `json:"confidence"`
-	Reason     string     `json:"reason"`
+// Anomaly is one finding as returned to clients. Each detector fills only the
+// fields that apply to its kind.
+type Anomaly struct {
+	Kind         string             `json:"kind"`
+	Fingerprint  string             `json:"fingerprint"`
+	SrcIP        string             `json:"srcIp"`
+	Subnet       string             `json:"subnet,omitempty"`
+	SrcClass     string             `json:"srcClass,omitempty"`
+	Anonymizer   string             `json:"anonymizer,omitempty"`
+	User         string             `json:"user,omitempty"`
+	FromIP       string             `json:"fromIp,omitempty"`
+	IPs          []string           `json:"ips,omitempty"`
+	Path         string             `json:"path,omitempty"`
+	Country      string             `json:"country,omitempty"`
+	Known        []string           `json:"knownCountries,omitempty"`
+	Tool         string             `json:"tool,omitempty"`
+	Feeds        []string           `json:"feeds,omitempty"`
+	Signal       string             `json:"signal,omitempty"`
+	Minute       *time.Time         `json:"minute,omitempty"`
+	FirstSeen    *time.Time         `json:"firstSeen,omitempty"`
+	LastSeen     *time.Time         `json:"lastSeen,omitempty"`
+	Count        *int               `json:"count,omitempty"`
+	Baseline     *float64           `json:"baseline,omitempty"`
+	Z            *float64           `json:"z,omitempty"`
+	Peak         *int               `json:"peak,omitempty"`
+	Hits         *int               `json:"hits,omitempty"`
+	UniquePref   *int               `json:"uniquePref,omitempty"`
+	Failures     *int               `json:"failures,omitempty"`
+	Usernames    *int               `json:"usernames,omitempty"`
+	UniquePaths  *int               `json:"uniquePaths,omitempty"`
+	UniqueUAs    *int               `json:"uniqueUAs,omitempty"`
+	UniqueIPs    *int               `json:"uniqueIps,omitempty"`
+	DistinctIDs  *int               `json:"distinctIds,omitempty"`
+	MaxBytes     *int64             `json:"maxBytes,omitempty"`
+	TotalBytes   *int64             `json:"totalBytes,omitempty"`
+	TopPaths     []string           `json:"topPaths,omitempty"`
+	Indicators   []string           `json:"indicators,omitempty"`
+	Samples      []string           `json:"samples,omitempty"`
+	Errors       *int               `json:"errors,omitempty"`
+	ErrorRate    *float64           `json:"errorRate,omitempty"`
+	P95Ms        *float64           `json:"p95Ms,omitempty"`
+	ClusterSize  *int               `json:"clusterSize,omitempty"`
+	Distance     *float64           `json:"distance,omitempty"`
+	OutlierScore *float64           `json:"outlierScore,omitempty"`
+	Profile      *analyze.Profile   `json:"profile,omitempty"`
+	DistanceKm   *float64           `json:"distanceKm,omitempty"`
+	SpeedKmh     *float64           `json:"speedKmh,omitempty"`
+	AbuseScore   *int               `json:"abuseScore,omitempty"`
+	AbuseReports *int               `json:"abuseReports,omitempty"`
+	Recurrent    bool               `json:"recurrent,omitempty"`
+	PriorJobs    []string           `json:"priorJobs,omitempty"`
+	State        string             `json:"state,omitempty"`
+	Occurrences  int                `json:"occurrences,omitempty"`
+	OpenedAt     *time.Time         `json:"openedAt,omitempty"`
+	ResolvedAt   *time.Time         `json:"resolvedAt,omitempty"`
+	TotalCount   *int               `json:"totalCount,omitempty"`
+	Confidence   float64            `json:"confidence"`
+	Evidence     []analyze.Evidence `json:"evidence,omitempty"`
+	Severity     string             `json:"severity"`
+	Stage        string             `json:"stage,omitempty"`
+	Reason       string             `json:"reason"`
+	Actions      []string           `json:"actions,omitempty"`
+	Related      []string           `json:"related,omitempty"`
+	Events       []parse.Event      `json:"events,omitempty"`
+	Group        map[string]string  `json:"group,omitempty"`
+
+	members map[string]bool // every source of a distributed_attack, for correlate
 }
 
+// Results is a finished job's analysis. RowsTotal and RowsCursor are filled
+// in when the results are viewed, with Rows cut down to its first page.
 type Results struct {
-	JobID     string         `json:"jobId"`
-	Filename  string         `json:"filename"`
-	SizeBytes int64          `json:"sizeBytes"`
-	SavedTo   string         `json:"savedTo"`
-	Received  string         `json:"received"`
-	Summary   parse.Summary  `json:"summary"`
-	Timeline  []parse.Bucket `json:"timeline"`
-	Rows      []parse.Event  `json:"rows"`
-	Anomalies []anyAnom      `json:"anomalies"`
-	Note      string         `json:"note,omitempty"`
+	JobID      string          `json:"jobId"`
+	Source     string          `json:"source,omitempty"`
+	Filename   string          `json:"filename"`
+	SizeBytes  int64           `json:"sizeBytes"`
+	SavedTo    string          `json:"savedTo,omitempty"`
+	Received   string          `json:"received"`
+	Summary    parse.Summary   `json:"summary"`
+	Timeline   []parse.Bucket  `json:"timeline"`
+	Gaps       []parse.Gap     `json:"gaps,omitempty"`
+	Tail       *parse.TailInfo `json:"tail,omitempty"`
+	Rows       []parse.Event   `json:"rows"`
+	RowsTotal  int             `json:"rowsTotal"`
+	RowsCursor string          `json:"rowsCursor,omitempty"`
+	Anomalies  []Anomaly       `json:"anomalies"`
+	Resolved   []Anomaly       `json:"resolved,omitempty"`
+	Actors     []Actor         `json:"actors"`
+	parse.Tops
+	Suppressed int    `json:"suppressed,omitempty"`
+	Dropped    int    `json:"dropped,omitempty"`
+	Executive  string `json:"executiveSummary"`
+	Note       string `json:"note,omitempty"`
+}
+
+// GuestView drops the server path of the upload from results shown through
+// a guest link.
+func (r Results) GuestView() any {
+	r.SavedTo = ""
+	return r
+}
+
+// GapAlertAfter is how long the uploaded timeline may go without any events
+// before the silence is reported as a gap.
+var GapAlertAfter = 15 * time.Minute
+
+// tailOptions reads ?tailMB= and ?tailHours=, which may be fractional, and
+// the RFC 3339 bounds ?from= and ?to=.
+func tailOptions(r *http.Request) (parse.TailOptions, error) {
+	var opt parse.TailOptions
+	q := r.URL.Query()
+	if v := q.Get("tailMB"); v != "" {
+		mb, err := strconv.ParseFloat(v, 64)
+		if err != nil || mb <= 0 {
+			return opt, errors.New("tailMB must be a positive number")
+		}
+		opt.Bytes = int64(mb * (1 << 20))
+	}
+	if v := q.Get("tailHours"); v != "" {
+		h, err := strconv.ParseFloat(v, 64)
+		if err != nil || h <= 0 {
+			return opt, errors.New("tailHours must be a positive number")
+		}
+		opt.Window = time.Duration(h * float64(time.Hour))
+	}
+	for _, b := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &opt.From}, {"to", &opt.To}} {
+		if v := q.Get(b.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return opt, errors.New(b.name + " must be an RFC 3339 time such as 2024-05-01T13:00:00Z")
+			}
+			*b.t = t.UTC()
+		}
+	}
+	if !opt.From.IsZero() && !opt.To.IsZero() && opt.To.Before(opt.From) {
+		return opt, errors.New("to must not be before from")
+	}
+	return opt, nil
+}
+
+// callbackOption reads ?callbackUrl=, an http(s) URL that is sent a signed
+// summary when the job finishes. Callbacks are only accepted when deliveries
+// are signed, and are only delivered to public addresses.
+func callbackOption(r *http.Request) (string, error) {
+	v := r.URL.Query().Get("callbackUrl")
+	if v == "" {
+		return "", nil
+	}
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("callbackUrl must be an http or https URL")
+	}
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil && !httputil.IsPublicAddr(ip) {
+		return "", errors.New("callbackUrl must be a public address")
+	}
+	if !notify.Signing() {
+		return "", errors.New("callbackUrl needs WEBHOOK_SECRET to be set on the server")
+	}
+	return v, nil
+}
+
+// validSource matches the names of ?source=.
+var validSource = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// aggregateOption reads ?aggregate=; only "subnet" is known.
+func aggregateOption(r *http.Request) (bySubnet, ok bool) {
+	switch r.URL.Query().Get("aggregate") {
+	case "":
+		return false, true
+	case "subnet":
+		return true, true
+	}
+	return false, false
 }
 
-// func Handler() http.Handler {
-// return http.HandlerFunc(
+// uploadOptions reads the analysis options of an upload from r's query.
+func uploadOptions(r *http.Request) (Options, error) {
+	tail, err := tailOptions(r)
+	if err != nil {
+		return Options{}, err
+	}
+	sel, err := detectorSelection(r)
+	if err != nil {
+		return Options{}, err
+	}
+	bySubnet, ok := aggregateOption(r)
+	if !ok {
+		return Options{}, errors.New("aggregate must be subnet")
+	}
+	callback, err := callbackOption(r)
+	if err != nil {
+		return Options{}, err
+	}
+	source := r.URL.Query().Get("source")
+	if source != "" && !validSource.MatchString(source) {
+		return Options{}, errors.New("source must be 1 to 64 letters, digits, '.', '_' or '-'")
+	}
+	return Options{Tail: tail, Detectors: sel, AggregateSubnets: bySubnet, Callback: callback, Source: source}, nil
+}
+
+// OptionsFromQuery reads upload options from parameters named as for
+// POST /api/upload, for transports that do not carry a URL.
+func OptionsFromQuery(q url.Values) (Options, error) {
+	return uploadOptions(&http.Request{URL: &url.URL{RawQuery: q.Encode()}})
+}
 
+// Handler serves POST /api/upload using the Default service.
 func Handler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Upload and analyse Handler - start")
+	Default.Handler(w, r)
+}
+
+// Handler accepts a multipart upload in the "file" field, queues it for
+// analysis and answers 202 Accepted with the job ID and its status URL. The
+// view options (minSeverity, where, fields) are carried over to that URL.
+func (s *Service) Handler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.Header().Set("Allow", http.MethodPost)
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	if _, ok := minSeverity(r); !ok {
+		http.Error(w, "minSeverity must be one of low, medium, high, critical", http.StatusBadRequest)
+		return
+	}
+
+	opts, err := uploadOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !limitUpload(w, r) {
+		return
+	}
 	file, header, err := r.FormFile("file")
+	if isTooLarge(err) {
+		tooLarge(w)
+		return
+	}
 	if err != nil {
 		http.Error(w, "file field 'file' is required", http.StatusBadRequest)
 		return
 	}
 	defer file.Close()
 
-	jobID := httputil.NewID()
-	dest := filepath.Join(os.TempDir(), jobID+".log")
+	s.accept(w, r, header.Filename, file, opts)
+}
 
-	out, err := os.Create(dest)
+// accept queues src as an upload and answers 202 Accepted with the job ID
+// and its status URL, carrying over r's view options (minSeverity, where,
+// fields), or with the error.
+func (s *Service) accept(w http.ResponseWriter, r *http.Request, filename string, src io.Reader, opts Options) {
+	j, err := s.Enqueue(r.Context(), filename, src, opts)
 	if err != nil {
-		http.Error(w, "could not create temp file", http.StatusInternalServerError)
+		if errors.Is(err, ErrQueueFull) || errors.Is(err, ErrStore) {
+			w.Header().Set("Retry-After", "30")
+			_, msg := ErrorStatus(err)
+			http.Error(w, msg, http.StatusServiceUnavailable)
+			return
+		}
+		if isTooLarge(err) {
+			tooLarge(w)
+			return
+		}
+		status, msg := ErrorStatus(err)
+		http.Error(w, msg, status)
 		return
 	}
 
-	n, copyErr := io.Copy(out, file)
-	closeErr := out.Close()
+	statusURL := jobStatusURL(r, j.ID)
+	w.Header().Set("Location", statusURL)
+	httputil.JSON(w, http.StatusAccepted, map[string]any{
+		"jobId":     j.ID,
+		"status":    j.Status,
+		"statusUrl": statusURL,
+	})
+}
 
-	if copyErr != nil || closeErr != nil {
-		_ = os.Remove(dest)
-		http.Error(w, "failed to save upload", http.StatusInternalServerError)
-		return
+// jobStatusURL is the status URL of job id, carrying over r's view options.
+func jobStatusURL(r *http.Request, id string) string {
+	view := url.Values{}
+	for _, k := range []string{"minSeverity", "where", "fields"} {
+		if v, ok := r.URL.Query()[k]; ok {
+			view[k] = v
+		}
 	}
-
-	const (
-		maxRowsScan = 100_000
-		keepRows    = 5_000
-	)
-	sum, timeline, rows, perr := parse.ParseTSVRows(dest, maxRowsScan, keepRows)
-	if perr != nil {
-		_ = os.Remove(dest)
-		http.Error(w, "parse error", http.StatusBadRequest)
-		return
+	statusURL := "/api/jobs/" + id + "/status"
+	if len(view) > 0 {
+		statusURL += "?" + view.Encode()
 	}
+	return statusURL
+}
 
-	const maxAnoms = 50
-	rateAnoms := analyze.DetectRateSpikes(rows, maxAnoms)
+// ErrorStatus maps an ingest error to an HTTP status and message.
+func ErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, parse.ErrTailUnsupported):
+		return http.StatusBadRequest, err.Error()
+	case isTooLarge(err):
+		return http.StatusRequestEntityTooLarge, tooLargeMessage()
+	case errors.Is(err, ErrSave):
+		return http.StatusInternalServerError, "failed to save upload"
+	case errors.Is(err, ErrQueueFull):
+		return http.StatusServiceUnavailable, ErrQueueFull.Error()
+	case errors.Is(err, ErrStore):
+		return http.StatusServiceUnavailable, ErrStore.Error()
+	case errors.Is(err, context.Canceled):
+		return http.StatusConflict, "analysis canceled"
+	case errors.Is(err, bufio.ErrTooLong):
+		return http.StatusBadRequest, "parse error: line exceeds " + strconv.Itoa(parse.MaxLineBytes) + " bytes"
+	}
+	return http.StatusBadRequest, "parse error"
+}
 
-	const (
-		minHits   = 5
-		minUnique = 2
-	)
-	sensAnoms := analyze.DetectSensitivePaths(rows, minHits, minUnique)
+// jobStatus is the body of GET /api/jobs/{id}/status. Result is set once the
+// job is done.
+type jobStatus struct {
+	JobID    string `json:"jobId"`
+	Status   string `json:"status"`
+	Progress int    `json:"progress"`
+	Error    string `json:"error,omitempty"`
+	Result   any    `json:"result,omitempty"`
+}
 
-	merged := make([]anyAnom, 0, len(rateAnoms)+len(sensAnoms))
+// Status serves GET /api/jobs/{id}/status using the Default service.
+func Status(w http.ResponseWriter, r *http.Request) {
+	Default.Status(w, r)
+}
 
-	for _, a := range rateAnoms {
-		m := a.Minute
-		c := a.Count
-		b := a.Baseline
-		z := a.Z
-		merged = append(merged, anyAnom{
-			Kind:       a.Kind,
-			SrcIP:      a.SrcIP,
-			Minute:     &m,
-			Count:      &c,
-			Baseline:   &b,
-			Z:          &z,
-			Confidence: a.Confidence,
-			Reason:     a.Reason,
-		})
+// Status reports whether an upload is queued, running, done or failed, with
+// its progress in percent. A done job's results are included, narrowed by
+// ?minSeverity=, ?where= and ?fields= as the upload itself would have been.
+func (s *Service) Status(w http.ResponseWriter, r *http.Request) {
+	minRank, ok := minSeverity(r)
+	if !ok {
+		http.Error(w, "minSeverity must be one of low, medium, high, critical", http.StatusBadRequest)
+		return
 	}
-
-	for _, s := range sensAnoms {
-		fs, ls := s.FirstSeen, s.LastSeen
-		h, u := s.Hits, s.UniquePref
-		merged = append(merged, anyAnom{
-			Kind:       s.Kind,
-			SrcIP:      s.SrcIP,
-			FirstSeen:  &fs,
-			LastSeen:   &ls,
-			Hits:       &h,
-			UniquePref: &u,
-			Confidence: s.Confidence,
-			Reason:     s.Reason,
-		})
+	j, err := s.Jobs.GetJob(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
 	}
-
-	if len(merged) > maxAnoms {
-		merged = merged[:maxAnoms]
+	st := jobStatus{JobID: j.ID, Status: j.Status, Progress: j.Progress, Error: j.Error}
+	if resp, ok := j.Result.(Results); ok {
+		if st.Result, err = view(r, resp, minRank); err != nil {
+			http.Error(w, "could not encode response", http.StatusInternalServerError)
+			return
+		}
 	}
-
-	if merged == nil {
-		merged = []anyAnom{}
+	if j.Status == jobs.StatusQueued || j.Status == jobs.StatusRunning {
+		w.Header().Set("Retry-After", "1")
 	}
+	httputil.JSON(w, http.StatusOK, st)
+}
 
-	note := ""
-	if sum.Lines > keepRows {
-		note = "Rows are truncated for display (showing first 5000). Summary/anomalies are computed over the scanned portion."
+// view narrows results by minRank and the request's ?where= and ?fields=,
+// and cuts the rows down to their first page.
+func view(r *http.Request, resp Results, minRank int) (any, error) {
+	if minRank > 0 {
+		resp.Anomalies = filterSeverity(resp.Anomalies, minRank)
+		resp.Actors = actors(resp.Anomalies)
 	}
-
-	resp := Results{
-		JobID:     jobID,
-		Filename:  header.Filename,
-		SizeBytes: n,
-		SavedTo:   dest,
-		Received:  time.Now().UTC().Format(time.RFC3339),
-		Summary:   sum,
-		Timeline:  timeline,
-		Rows:      rows,
-		Anomalies: merged,
-		Note:      note,
+	filters := whereFilters(r)
+	if len(filters) > 0 {
+		resp.Rows = filterRows(resp.Rows, filters)
 	}
-
-	httputil.JSON(w, http.StatusOK, resp)
-	log.Println("Upload and analyse Handler - end")
+	resp.RowsTotal = len(resp.Rows)
+	if len(resp.Rows) > defaultRowLimit {
+		resp.Rows = resp.Rows[:defaultRowLimit]
+		resp.RowsCursor = encodeRowCursor(defaultRowLimit, "", rowFilterKey(rowQuery{}, filters))
+	}
+	if fields := httputil.Fields(r); len(fields) > 0 {
+		return sparseResults(resp, fields)
+	}
+	return resp, nil
 }
 
-// )
-// }
+// DecodeResults turns a stored job result back into Results, for job stores
+// that keep results as JSON.
+func DecodeResults(raw json.RawMessage) (any, error) {
+	var res Results
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
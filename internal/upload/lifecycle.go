@@ -0,0 +1,71 @@
+package upload
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lifecycle states of an anomaly across the jobs of one source.
+const (
+	StateOpen     = "open"
+	StateOngoing  = "ongoing"
+	StateResolved = "resolved"
+)
+
+// lifecycles follows anomalies across the successive jobs of each source
+// (?source=), so that a condition that persists over several jobs is one
+// anomaly, open and then ongoing with updated counts, that is resolved once
+// a job no longer finds it.
+type lifecycles struct {
+	mu      sync.Mutex
+	sources map[string]map[string]Anomaly // source → fingerprint → latest sighting
+}
+
+var tracked = &lifecycles{sources: make(map[string]map[string]Anomaly)}
+
+// advance folds one job's anomalies into source's lifecycle. It sets the
+// state, occurrence count, opening time and total count of each of anoms in
+// place, and returns the anomalies the source's earlier jobs had open that
+// anoms no longer include, marked resolved at now. Jobs of one source are
+// taken in the order they finish.
+func (l *lifecycles) advance(source string, anoms []Anomaly, now time.Time) []Anomaly {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prev := l.sources[source]
+	next := make(map[string]Anomaly, len(anoms))
+	for i := range anoms {
+		a := &anoms[i]
+		a.State, a.Occurrences, a.OpenedAt, a.TotalCount = StateOpen, 1, &now, a.Count
+		if p, ok := prev[a.Fingerprint]; ok {
+			a.State, a.Occurrences, a.OpenedAt = StateOngoing, p.Occurrences+1, p.OpenedAt
+			if p.TotalCount != nil && a.Count != nil {
+				total := *p.TotalCount + *a.Count
+				a.TotalCount = &total
+			}
+		}
+		kept := *a
+		kept.Events, kept.members = nil, nil
+		next[a.Fingerprint] = kept
+	}
+
+	var resolved []Anomaly
+	for fp, p := range prev {
+		if _, ok := next[fp]; ok {
+			continue
+		}
+		p.State, p.ResolvedAt = StateResolved, &now
+		resolved = append(resolved, p)
+	}
+	if len(next) == 0 {
+		delete(l.sources, source)
+	} else {
+		l.sources[source] = next
+	}
+	slices.SortFunc(resolved, func(a, b Anomaly) int {
+		return cmp.Or(severityRank(b.Severity)-severityRank(a.Severity), strings.Compare(a.Fingerprint, b.Fingerprint))
+	})
+	return resolved
+}
@@ -0,0 +1,137 @@
+package upload
+
+import (
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// finding is an anomaly with fingerprint fp and, when count > 0, that count.
+func finding(fp, severity string, count int) Anomaly {
+	a := Anomaly{Fingerprint: fp, Severity: severity}
+	if count > 0 {
+		a.Count = &count
+	}
+	return a
+}
+
+func TestLifecycleAdvance(t *testing.T) {
+	type want struct {
+		state       string
+		occurrences int
+		openedPass  int // the pass whose time openedAt must hold
+		totalCount  int // 0 for none
+	}
+	passes := []struct {
+		name         string
+		source       string
+		anoms        []Anomaly
+		want         map[string]want
+		wantResolved []string
+	}{
+		{
+			name:   "first job opens everything",
+			source: "web",
+			anoms:  []Anomaly{finding("a", "high", 3), finding("b", "low", 0)},
+			want:   map[string]want{"a": {StateOpen, 1, 0, 3}, "b": {StateOpen, 1, 0, 0}},
+		},
+		{
+			name:   "another source is separate",
+			source: "db",
+			anoms:  []Anomaly{finding("a", "high", 1)},
+			want:   map[string]want{"a": {StateOpen, 1, 1, 1}},
+		},
+		{
+			name:         "persisting findings go ongoing and missing ones resolve",
+			source:       "web",
+			anoms:        []Anomaly{finding("a", "high", 4), finding("c", "medium", 2)},
+			want:         map[string]want{"a": {StateOngoing, 2, 0, 7}, "c": {StateOpen, 1, 2, 2}},
+			wantResolved: []string{"b"},
+		},
+		{
+			name:   "counts keep adding up",
+			source: "web",
+			anoms:  []Anomaly{finding("a", "high", 1), finding("c", "medium", 2)},
+			want:   map[string]want{"a": {StateOngoing, 3, 0, 8}, "c": {StateOngoing, 2, 2, 4}},
+		},
+		{
+			name:         "a quiet job resolves the rest, most severe first",
+			source:       "web",
+			anoms:        nil,
+			want:         map[string]want{},
+			wantResolved: []string{"a", "c"},
+		},
+		{
+			name:   "a finding that returns opens again",
+			source: "web",
+			anoms:  []Anomaly{finding("a", "high", 5)},
+			want:   map[string]want{"a": {StateOpen, 1, 5, 5}},
+		},
+	}
+
+	l := &lifecycles{sources: make(map[string]map[string]Anomaly)}
+	start := time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)
+	at := func(pass int) time.Time { return start.Add(time.Duration(pass) * time.Hour) }
+	for i, p := range passes {
+		t.Run(p.name, func(t *testing.T) {
+			resolved := l.advance(p.source, p.anoms, at(i))
+			for _, a := range p.anoms {
+				w := p.want[a.Fingerprint]
+				total := 0
+				if a.TotalCount != nil {
+					total = *a.TotalCount
+				}
+				if a.State != w.state || a.Occurrences != w.occurrences || total != w.totalCount {
+					t.Errorf("%s: state %s, occurrences %d, totalCount %d; want %s, %d, %d",
+						a.Fingerprint, a.State, a.Occurrences, total, w.state, w.occurrences, w.totalCount)
+				}
+				if a.OpenedAt == nil || !a.OpenedAt.Equal(at(w.openedPass)) {
+					t.Errorf("%s: openedAt %v, want %v", a.Fingerprint, a.OpenedAt, at(w.openedPass))
+				}
+			}
+			var got []string
+			for _, r := range resolved {
+				got = append(got, r.Fingerprint)
+				if r.State != StateResolved || r.ResolvedAt == nil || !r.ResolvedAt.Equal(at(i)) {
+					t.Errorf("%s: state %s, resolvedAt %v; want resolved at %v", r.Fingerprint, r.State, r.ResolvedAt, at(i))
+				}
+			}
+			if !slices.Equal(got, p.wantResolved) {
+				t.Errorf("resolved %v, want %v", got, p.wantResolved)
+			}
+		})
+	}
+	if _, ok := l.sources["web"]; !ok {
+		t.Error("source web was forgotten while a finding is open")
+	}
+}
+
+func TestSourceOption(t *testing.T) {
+	tests := []struct {
+		source  string
+		wantErr bool
+	}{
+		{"", false},
+		{"nginx-edge.01", false},
+		{"A_b", false},
+		{strings.Repeat("x", 64), false},
+		{strings.Repeat("x", 65), true},
+		{"-leading", true},
+		{"has space", true},
+		{"a/b", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/upload?source="+strings.ReplaceAll(tt.source, " ", "%20"), nil)
+			opts, err := uploadOptions(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && opts.Source != tt.source {
+				t.Errorf("Source = %q, want %q", opts.Source, tt.source)
+			}
+		})
+	}
+}
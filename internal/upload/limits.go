@@ -0,0 +1,73 @@
+package upload
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// MaxUploadBytes caps the body of one upload so that an arbitrarily large
+// request cannot fill the disk; zero means no limit. Multipart uploads count
+// the form envelope too, a few hundred bytes.
+var MaxUploadBytes int64 = 10 << 30
+
+// limitUpload refuses r with 413 when it declares a body over
+// MaxUploadBytes, and otherwise caps its body at that size.
+func limitUpload(w http.ResponseWriter, r *http.Request) bool {
+	if MaxUploadBytes <= 0 {
+		return true
+	}
+	if r.ContentLength > MaxUploadBytes {
+		tooLarge(w)
+		return false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MaxUploadBytes)
+	return true
+}
+
+// LimitUpload caps src at MaxUploadBytes for transports without an HTTP
+// body; reading past the limit fails with an *http.MaxBytesError.
+func LimitUpload(src io.Reader) io.Reader {
+	if MaxUploadBytes <= 0 {
+		return src
+	}
+	return http.MaxBytesReader(nil, io.NopCloser(src), MaxUploadBytes)
+}
+
+func isTooLarge(err error) bool {
+	var tooBig *http.MaxBytesError
+	return errors.As(err, &tooBig)
+}
+
+// tooLarge answers 413 with the limit, as JSON so that clients can show it.
+func tooLarge(w http.ResponseWriter) {
+	httputil.JSON(w, http.StatusRequestEntityTooLarge, map[string]any{
+		"error":    tooLargeMessage(),
+		"maxBytes": MaxUploadBytes,
+	})
+}
+
+func tooLargeMessage() string {
+	return "upload exceeds the limit of " + strconv.FormatInt(MaxUploadBytes, 10) + " bytes"
+}
+
+// Capabilities serves GET /api/capabilities: the limits and formats this
+// server accepts, so that clients can check a file before sending it.
+func Capabilities(w http.ResponseWriter, r *http.Request) {
+	httputil.JSON(w, http.StatusOK, map[string]any{
+		"maxUploadBytes": MaxUploadBytes,
+		"maxQuickBytes":  maxQuickBytes,
+		"maxLineBytes":   parse.MaxLineBytes,
+		"tus": map[string]any{
+			"version":    tusVersion,
+			"extensions": []string{"creation", "termination"},
+			"expiry":     TusExpiry.String(),
+		},
+		"exportFormats": []string{"csv", "ndjson", "parquet", "pdf"},
+		"detectors":     DetectorKinds(),
+	})
+}
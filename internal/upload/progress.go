@@ -0,0 +1,218 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/jobs"
+	"github.com/allensuvorov/tenexlog/internal/reqctx"
+)
+
+// Progress is a snapshot of one job's analysis, as streamed by Events.
+type Progress struct {
+	JobID          string `json:"jobId"`
+	Status         string `json:"status"`
+	Stage          string `json:"stage"` // queued, parsing, detecting, finishing, done or failed
+	Percent        int    `json:"percent"`
+	LinesScanned   int    `json:"linesScanned"`
+	BytesScanned   int64  `json:"bytesScanned"`
+	TotalBytes     int64  `json:"totalBytes"`
+	Detectors      int    `json:"detectorsCompleted"`
+	DetectorsTotal int    `json:"detectorsTotal"`
+	Anomalies      int    `json:"anomalies"`
+	Error          string `json:"error,omitempty"`
+}
+
+func (p Progress) finished() bool {
+	return p.Status != jobs.StatusQueued && p.Status != jobs.StatusRunning
+}
+
+// progressFeed holds a job's latest snapshot and its subscribers. Each
+// subscriber channel holds one snapshot; a slow reader only sees the latest.
+type progressFeed struct {
+	last Progress
+	subs map[chan Progress]bool
+}
+
+type progressHub struct {
+	mu    sync.Mutex
+	feeds map[string]*progressFeed
+}
+
+var hub = &progressHub{feeds: make(map[string]*progressFeed)}
+
+func (h *progressHub) publish(p Progress) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f := h.feeds[p.JobID]
+	if f == nil {
+		f = &progressFeed{subs: make(map[chan Progress]bool)}
+		h.feeds[p.JobID] = f
+	}
+	f.last = p
+	for ch := range f.subs {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- p
+		if p.finished() {
+			close(ch)
+		}
+	}
+	if p.finished() {
+		delete(h.feeds, p.JobID)
+	}
+}
+
+// subscribe returns the job's latest snapshot and a channel of later ones,
+// closed after the final one. ok is false when the hub is not tracking the
+// job, e.g. because it already finished or runs on another instance.
+func (h *progressHub) subscribe(id string) (last Progress, ch chan Progress, cancel func(), ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f := h.feeds[id]
+	if f == nil {
+		return Progress{}, nil, nil, false
+	}
+	ch = make(chan Progress, 1)
+	f.subs[ch] = true
+	cancel = func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if f.subs[ch] {
+			delete(f.subs, ch)
+		}
+	}
+	return f.last, ch, cancel, true
+}
+
+// tracker reports a running job's progress to the hub, at most every
+// progressEvery unless the stage changes, and mirrors the percentage into the
+// job store. A nil tracker reports nothing.
+type tracker struct {
+	s        *Service
+	logger   *log.Logger
+	job      jobs.Job
+	p        Progress
+	sent     time.Time
+	storeErr error // the first failed progress write
+}
+
+const progressEvery = 250 * time.Millisecond
+
+func newTracker(ctx context.Context, s *Service, j jobs.Job) *tracker {
+	return &tracker{s: s, logger: reqctx.Logger(ctx), job: j, p: Progress{JobID: j.ID, Status: j.Status, Stage: j.Status, TotalBytes: j.SizeBytes}}
+}
+
+func (t *tracker) publish(force bool) {
+	if !force && time.Since(t.sent) < progressEvery {
+		return
+	}
+	t.sent = time.Now()
+	hub.publish(t.p)
+	if t.p.Percent != t.job.Progress && !t.p.finished() && t.storeErr == nil {
+		t.job.Progress = t.p.Percent
+		if err := t.s.saveJob(t.job); err != nil {
+			t.logger.Printf("job %s: record progress: %v", t.job.ID, err)
+			t.storeErr = fmt.Errorf("%w: %w", ErrStore, err)
+		}
+	}
+}
+
+// err returns the first failed progress write, which fails the job: a store
+// that cannot record progress cannot be trusted with the results either.
+func (t *tracker) err() error {
+	if t == nil {
+		return nil
+	}
+	return t.storeErr
+}
+
+func (t *tracker) stage(name string, percent int) {
+	if t == nil {
+		return
+	}
+	t.p.Stage, t.p.Percent = name, percent
+	t.publish(true)
+}
+
+// parsing covers 5–70%, by the share of the file read.
+func (t *tracker) parsing(lines int, bytes int64) {
+	if bytes < t.p.BytesScanned {
+		return // a later pass over the file starts again from zero
+	}
+	t.p.LinesScanned, t.p.BytesScanned = lines, bytes
+	if t.p.TotalBytes > 0 {
+		t.p.Percent = 5 + int(65*min(bytes, t.p.TotalBytes)/t.p.TotalBytes)
+	}
+	t.publish(false)
+}
+
+// parseProgress sets the size parsing progress is measured against and
+// returns the function to report it to.
+func (t *tracker) parseProgress(size int64) func(lines int, bytes int64) {
+	if t == nil {
+		return nil
+	}
+	t.p.TotalBytes = size
+	return t.parsing
+}
+
+// detecting covers 70–95%, by the share of detectors run.
+func (t *tracker) detecting(done, total, found int) {
+	t.p.Stage = "detecting"
+	t.p.Detectors, t.p.DetectorsTotal, t.p.Anomalies = done, total, found
+	t.p.Percent = 70 + 25*done/max(total, 1)
+	t.publish(done == total)
+}
+
+func (t *tracker) detectorProgress() func(done, total, found int) {
+	if t == nil {
+		return nil
+	}
+	return t.detecting
+}
+
+// finish publishes the job's final state and ends its subscribers' streams.
+func (t *tracker) finish(j jobs.Job) {
+	if t == nil {
+		return
+	}
+	t.p.Status, t.p.Stage, t.p.Percent, t.p.Error = j.Status, j.Status, j.Progress, j.Error
+	if j.Status == jobs.StatusDone {
+		t.p.Anomalies = j.AnomalyCount
+	}
+	t.publish(true)
+}
+
+type detectorProgressKey struct{}
+
+func withDetectorProgress(ctx context.Context, fn func(done, total, found int)) context.Context {
+	if fn == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, detectorProgressKey{}, fn)
+}
+
+func detectorProgressFrom(ctx context.Context) func(done, total, found int) {
+	fn, _ := ctx.Value(detectorProgressKey{}).(func(done, total, found int))
+	return fn
+}
+
+type parseProgressKey struct{}
+
+func withParseProgress(ctx context.Context, fn func(lines int, bytes int64)) context.Context {
+	if fn == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, parseProgressKey{}, fn)
+}
+
+func parseProgressFrom(ctx context.Context) func(lines int, bytes int64) {
+	fn, _ := ctx.Value(parseProgressKey{}).(func(lines int, bytes int64))
+	return fn
+}
@@ -0,0 +1,209 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/jobs"
+	"github.com/allensuvorov/tenexlog/internal/reqctx"
+)
+
+// QueueSize is how many uploads may wait for a worker; Workers is the number
+// Run starts by default. Both are read when a Service is created or run.
+var (
+	QueueSize = 32
+	Workers   = 2
+)
+
+var (
+	ErrQueueFull = errors.New("upload queue is full")
+	// ErrStore reports that the job store could not record a job.
+	ErrStore = errors.New("job store unavailable")
+)
+
+type task struct {
+	ctx  context.Context
+	job  jobs.Job
+	opts Options
+	tr   *tracker
+}
+
+// Enqueue stores src as a new job's source file, records the job as queued
+// and returns it at once; a worker started by Run analyzes it later. The job
+// keeps the request values in ctx but not its cancellation.
+func (s *Service) Enqueue(ctx context.Context, filename string, src io.Reader, opts Options) (jobs.Job, error) {
+	j, err := s.save(ctx, filename, src)
+	if err != nil {
+		return jobs.Job{}, err
+	}
+	return s.enqueue(ctx, j, opts, func() { _ = s.Storage.Remove(j.SavedTo) })
+}
+
+// adopter is implemented by storages that can take over a file already on
+// local disk without copying it.
+type adopter interface {
+	Adopt(jobID, path string) (dest string, size int64, err error)
+}
+
+// EnqueueFile is Enqueue for a complete upload already on local disk, such
+// as a finished resumable upload. The file is moved into storage when the
+// storage can adopt it and copied otherwise; either way it is gone from path
+// once the job is queued. When the queue is full it stays at path.
+func (s *Service) EnqueueFile(ctx context.Context, filename, path string, opts Options) (jobs.Job, error) {
+	if ad, ok := s.Storage.(adopter); ok {
+		j, err := s.store(ctx, filename, func(jobID string) (string, int64, error) { return ad.Adopt(jobID, path) })
+		if err == nil {
+			return s.enqueue(ctx, j, opts, func() { _ = os.Rename(j.SavedTo, path) })
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return jobs.Job{}, fmt.Errorf("%w: %w", ErrSave, err)
+	}
+	j, err := s.Enqueue(ctx, filename, f, opts)
+	_ = f.Close()
+	if err == nil {
+		_ = os.Remove(path)
+	}
+	return j, err
+}
+
+// enqueue records the saved job j as queued and hands it to the workers.
+// When the queue is full or the job cannot be recorded it calls unsave to
+// drop j's file and fails j.
+func (s *Service) enqueue(ctx context.Context, j jobs.Job, opts Options, unsave func()) (jobs.Job, error) {
+	j.Status, j.Source = jobs.StatusQueued, opts.Source
+	if err := s.saveJob(j); err != nil {
+		reqctx.Logger(ctx).Printf("job %s: record as queued: %v", j.ID, err)
+		unsave()
+		return jobs.Job{}, fmt.Errorf("%w: %w", ErrStore, err)
+	}
+	// The tracker and the queued announcement go out before a worker can
+	// pick the task up, since from then on the worker owns the tracker.
+	tr := newTracker(ctx, s, j)
+	tr.stage(jobs.StatusQueued, 0)
+	announce(j, nil)
+	jctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	s.mu.Lock()
+	s.cancels[j.ID] = cancel
+	s.mu.Unlock()
+	select {
+	case s.tasks <- task{ctx: jctx, job: j, opts: opts, tr: tr}:
+		return j, nil
+	default:
+		s.forget(j.ID)
+		unsave()
+		j.Status, j.Error = jobs.StatusFailed, ErrQueueFull.Error()
+		if err := s.saveJob(j); err != nil {
+			reqctx.Logger(ctx).Printf("job %s: record as failed: %v", j.ID, err)
+		}
+		tr.finish(j)
+		announce(j, nil)
+		return jobs.Job{}, ErrQueueFull
+	}
+}
+
+// Run analyzes queued uploads with n workers until ctx is done.
+func (s *Service) Run(ctx context.Context, n int) {
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case t := <-s.tasks:
+					s.process(t)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *Service) process(t task) {
+	defer s.forget(t.job.ID)
+	logger := reqctx.Logger(t.ctx)
+	j := t.job
+	err := t.ctx.Err()
+	if err == nil {
+		j.Status = jobs.StatusRunning
+		if err = s.saveJob(j); err != nil {
+			logger.Printf("job %s: record as running: %v", j.ID, err)
+			err = fmt.Errorf("%w: %w", ErrStore, err)
+		}
+	}
+	if err == nil {
+		t.tr.job, t.tr.p.Status = j, j.Status
+		announce(j, nil)
+		_, err = s.analyze(t.ctx, j, t.opts, t.tr)
+	}
+	if err != nil {
+		_ = s.Storage.Remove(j.SavedTo)
+		_, j.Error = ErrorStatus(err)
+		j.Status, j.Progress = jobs.StatusFailed, t.tr.p.Percent
+		if errors.Is(err, context.Canceled) {
+			j.Status = jobs.StatusCanceled
+		}
+		if err := s.saveJob(j); err != nil {
+			logger.Printf("job %s: record as %s: %v", j.ID, j.Status, err)
+		}
+		t.tr.finish(j)
+		announce(j, nil)
+		notifyCallback(t.opts.Callback, j, nil)
+	}
+}
+
+func (s *Service) forget(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.cancels[id]; ok {
+		cancel()
+		delete(s.cancels, id)
+	}
+}
+
+// ErrNotCancelable is returned by CancelJob for jobs that are not queued or
+// running on this instance.
+var ErrNotCancelable = errors.New("job is not queued or running on this instance")
+
+// CancelJob stops a queued or running job. Parsing and detection notice the
+// cancellation within one read or one detector, and the job ends as canceled.
+func (s *Service) CancelJob(id string) error {
+	s.mu.Lock()
+	cancel, ok := s.cancels[id]
+	s.mu.Unlock()
+	if !ok {
+		return ErrNotCancelable
+	}
+	cancel()
+	return nil
+}
+
+// Cancel serves POST /api/jobs/{id}/cancel using the Default service.
+func Cancel(w http.ResponseWriter, r *http.Request) {
+	Default.Cancel(w, r)
+}
+
+// Cancel asks a queued or running job to stop and answers 202; the job's
+// status turns to canceled once the pipeline has unwound.
+func (s *Service) Cancel(w http.ResponseWriter, r *http.Request) {
+	j, err := s.Jobs.GetJob(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err := s.CancelJob(j.ID); err != nil {
+		http.Error(w, "job is "+j.Status+"; only queued or running jobs on this instance can be canceled", http.StatusConflict)
+		return
+	}
+	httputil.JSON(w, http.StatusAccepted, map[string]string{"jobId": j.ID, "status": j.Status})
+}
@@ -0,0 +1,101 @@
+package upload
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+const maxQuickBytes = 1 << 20
+
+type quickResults struct {
+	Summary   parse.Summary `json:"summary"`
+	Rows      []parse.Event `json:"rows"`
+	Anomalies []Anomaly     `json:"anomalies"`
+	Actors    []Actor       `json:"actors"`
+	parse.Tops
+	Executive string `json:"executiveSummary"`
+}
+
+// Quick serves POST /api/quick using the Default service.
+func Quick(w http.ResponseWriter, r *http.Request) {
+	Default.Quick(w, r)
+}
+
+// Quick analyzes a small log snippet sent as the raw request body (up to
+// 1 MiB) and answers synchronously. Nothing is kept: no job is recorded, no
+// alerts are sent and flagged IPs are not added to the sightings index.
+func (s *Service) Quick(w http.ResponseWriter, r *http.Request) {
+	minRank, ok := minSeverity(r)
+	if !ok {
+		http.Error(w, "minSeverity must be one of low, medium, high, critical", http.StatusBadRequest)
+		return
+	}
+
+	sel, err := detectorSelection(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	bySubnet, ok := aggregateOption(r)
+	if !ok {
+		http.Error(w, "aggregate must be subnet", http.StatusBadRequest)
+		return
+	}
+
+	f, err := os.CreateTemp("", "quick-*.log")
+	if err != nil {
+		http.Error(w, "failed to buffer snippet", http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(f.Name())
+	_, copyErr := f.ReadFrom(http.MaxBytesReader(w, r.Body, maxQuickBytes))
+	closeErr := f.Close()
+	var tooBig *http.MaxBytesError
+	switch {
+	case errors.As(copyErr, &tooBig):
+		http.Error(w, "snippet too large (max 1 MiB); use /api/upload", http.StatusRequestEntityTooLarge)
+		return
+	case copyErr != nil || closeErr != nil:
+		http.Error(w, "failed to read snippet", http.StatusBadRequest)
+		return
+	}
+
+	sum, timeline, rows, err := s.Parser.Parse(r.Context(), f.Name())
+	if err != nil {
+		http.Error(w, "parse error", http.StatusBadRequest)
+		return
+	}
+	if sum.Lines == 0 {
+		http.Error(w, "request body must contain log lines", http.StatusBadRequest)
+		return
+	}
+	tagCrawlers(r.Context(), rows)
+	tagAnonymizers(rows)
+	anoms, _ := suppress(s.Detectors.Detect(withSelection(r.Context(), sel), rows, timeline), rows)
+	anoms, _ = capAnomalies(anoms, sel.Thresholds.maxAnoms())
+	if anoms == nil {
+		anoms = []Anomaly{}
+	}
+	boostRecurrent(anoms, "", time.Now())
+	if bySubnet {
+		anoms = aggregateSubnets(anoms)
+	}
+	if minRank > 0 {
+		anoms = filterSeverity(anoms, minRank)
+	}
+
+	httputil.JSON(w, http.StatusOK, quickResults{
+		Summary:   sum,
+		Rows:      rows,
+		Anomalies: anoms,
+		Actors:    actors(anoms),
+		Tops:      sum.Top,
+		Executive: execSummary(sum, anoms),
+	})
+}
@@ -0,0 +1,58 @@
+package upload
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// RawHandler serves PUT /api/upload/raw using the Default service.
+func RawHandler(w http.ResponseWriter, r *http.Request) {
+	Default.RawHandler(w, r)
+}
+
+// RawHandler accepts the log file as the request body itself and streams it
+// to storage as it arrives, then answers like Handler. The file is named by
+// the X-Filename header or the filename of a Content-Disposition header;
+// the query options are those of Handler.
+func (s *Service) RawHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := minSeverity(r); !ok {
+		http.Error(w, "minSeverity must be one of low, medium, high, critical", http.StatusBadRequest)
+		return
+	}
+	opts, err := uploadOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filename := rawFilename(r)
+	if filename == "" {
+		http.Error(w, "X-Filename header is required", http.StatusBadRequest)
+		return
+	}
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); strings.HasPrefix(ct, "multipart/") {
+		http.Error(w, "send the file itself as the body, or use POST /api/upload for multipart forms", http.StatusUnsupportedMediaType)
+		return
+	}
+	if !limitUpload(w, r) {
+		return
+	}
+	s.accept(w, r, filename, r.Body, opts)
+}
+
+// rawFilename returns the base name given by X-Filename or by
+// Content-Disposition, or "" when neither names a file.
+func rawFilename(r *http.Request) string {
+	name := strings.TrimSpace(r.Header.Get("X-Filename"))
+	if name == "" {
+		if _, params, err := mime.ParseMediaType(r.Header.Get("Content-Disposition")); err == nil {
+			name = params["filename"]
+		}
+	}
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,61 @@
+package upload
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/analyze"
+	"github.com/allensuvorov/tenexlog/internal/enrich"
+)
+
+// RecurrenceHalfLife is how quickly earlier sightings of an IP lose their
+// influence on a new anomaly's confidence. Sightings older than eight
+// half-lives are ignored.
+var RecurrenceHalfLife = 30 * 24 * time.Hour
+
+// boostRecurrent raises the confidence of anomalies whose source IP was
+// flagged by earlier jobs and marks them as recurrent offenders. Each prior
+// job contributes a weight that halves every RecurrenceHalfLife; the combined
+// weight is added as priorJobs evidence and the confidence recalibrated.
+func boostRecurrent(anoms []Anomaly, jobID string, now time.Time) {
+	for i := range anoms {
+		a := &anoms[i]
+		if a.SrcIP == "" {
+			continue
+		}
+		var (
+			weight float64
+			prior  []string
+			seen   = make(map[string]bool)
+		)
+		for _, s := range enrich.Sightings.Of(a.SrcIP) {
+			if s.JobID == jobID || seen[s.JobID] {
+				continue
+			}
+			age := now.Sub(s.Seen)
+			if age > 8*RecurrenceHalfLife {
+				continue
+			}
+			seen[s.JobID] = true
+			prior = append(prior, s.JobID)
+			weight += math.Pow(0.5, max(age, 0).Hours()/RecurrenceHalfLife.Hours())
+		}
+		if len(prior) == 0 {
+			continue
+		}
+		a.Recurrent = true
+		a.PriorJobs = prior
+		a.Evidence = append(a.Evidence, analyze.Evidence{Signal: "priorJobs", Value: weight, Threshold: 1, Weight: 0.5})
+		calibrate(a)
+		a.Severity = severity(*a)
+		a.Reason += " Recurrent offender: also flagged in " + pluralJobs(len(prior)) + "."
+	}
+}
+
+func pluralJobs(n int) string {
+	if n == 1 {
+		return "1 earlier job"
+	}
+	return strconv.Itoa(n) + " earlier jobs"
+}
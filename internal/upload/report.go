@@ -0,0 +1,184 @@
+package upload
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/pdf"
+)
+
+// reportLayout flows wrapped lines down the pages of a PDF, starting a new
+// page when the current one is full.
+type reportLayout struct {
+	doc  *pdf.Doc
+	page *pdf.Page
+	y    float64
+}
+
+func (l *reportLayout) newPage() {
+	l.page = l.doc.AddPage()
+	l.y = pdf.PageHeight - pdf.Margin
+}
+
+func (l *reportLayout) line(size float64, bold bool, indent float64, s string) {
+	for _, ln := range wrapText(s, lineChars(size, indent)) {
+		if l.y-size*1.4 < pdf.Margin {
+			l.newPage()
+		}
+		l.y -= size * 1.4
+		l.page.Text(pdf.Margin+indent, l.y, size, bold, ln)
+	}
+}
+
+// field sets a bold label with its value beside it.
+func (l *reportLayout) field(label, value string) {
+	size, indent := 11.0, 130.0
+	for i, ln := range wrapText(value, lineChars(size, indent)) {
+		if l.y-size*1.4 < pdf.Margin {
+			l.newPage()
+		}
+		l.y -= size * 1.4
+		if i == 0 {
+			l.page.Text(pdf.Margin, l.y, size, true, label)
+		}
+		l.page.Text(pdf.Margin+indent, l.y, size, false, ln)
+	}
+}
+
+func (l *reportLayout) gap(h float64) { l.y -= h }
+
+func (l *reportLayout) rule() {
+	l.gap(6)
+	l.page.Line(pdf.Margin, pdf.PageWidth-pdf.Margin, l.y)
+	l.gap(6)
+}
+
+// lineChars estimates how many characters at size fit on a line indented by
+// indent, from Helvetica's average glyph width of about half the font size.
+func lineChars(size, indent float64) int {
+	return int((pdf.PageWidth - 2*pdf.Margin - indent) / (size * 0.5))
+}
+
+// wrapText breaks s into lines of at most width runes at spaces, splitting
+// words that are longer than a line.
+func wrapText(s string, width int) []string {
+	width = max(width, 10)
+	var out []string
+	cur := ""
+	for _, w := range strings.Fields(s) {
+		for len([]rune(w)) > width {
+			if cur != "" {
+				out = append(out, cur)
+				cur = ""
+			}
+			r := []rune(w)
+			out = append(out, string(r[:width]))
+			w = string(r[width:])
+		}
+		switch {
+		case cur == "":
+			cur = w
+		case len([]rune(cur))+1+len([]rune(w)) <= width:
+			cur += " " + w
+		default:
+			out = append(out, cur)
+			cur = w
+		}
+	}
+	if cur != "" || len(out) == 0 {
+		out = append(out, cur)
+	}
+	return out
+}
+
+// incidentReport lays out a finished job as a PDF: a title page with the job's
+// metadata and an executive summary of its anomalies by severity, then one
+// entry per anomaly, most severe first. With minRank > 0 both sections cover
+// only the anomalies at or above that severity.
+func incidentReport(res Results, minRank int, now time.Time) *pdf.Doc {
+	if minRank > 0 {
+		res.Anomalies = filterSeverity(res.Anomalies, minRank)
+		res.Executive = execSummary(res.Summary, res.Anomalies)
+	}
+	doc := &pdf.Doc{Title: "Incident report: " + res.Filename, Created: now}
+	l := &reportLayout{doc: doc}
+	l.newPage()
+
+	l.gap(60)
+	l.line(24, true, 0, "Incident report")
+	l.line(14, false, 0, res.Filename)
+	l.rule()
+	sum := res.Summary
+	meta := [][2]string{
+		{"Job ID", res.JobID},
+		{"File", fmt.Sprintf("%s (%s bytes)", res.Filename, strconv.FormatInt(res.SizeBytes, 10))},
+		{"Received", res.Received},
+		{"Format", cmp.Or(sum.Format, "tsv")},
+		{"Log lines", strconv.Itoa(sum.Lines)},
+		{"Unique source IPs", strconv.Itoa(sum.UniqueIPs)},
+	}
+	if !sum.Start.IsZero() {
+		meta = append(meta, [2]string{"Time range", sum.Start.UTC().Format(time.RFC3339) + " to " + sum.End.UTC().Format(time.RFC3339)})
+	}
+	if !sum.Exact {
+		meta = append(meta, [2]string{"Coverage", "partial: scanning stopped at the row cap"})
+	}
+	meta = append(meta, [2]string{"Generated", now.UTC().Format(time.RFC3339)})
+	for _, m := range meta {
+		l.field(m[0], m[1])
+	}
+
+	l.gap(24)
+	l.line(16, true, 0, "Executive summary")
+	l.gap(4)
+	bySeverity := make(map[string]int)
+	for _, a := range res.Anomalies {
+		bySeverity[a.Severity]++
+	}
+	l.line(11, false, 0, plural(len(res.Anomalies), "anomaly")+" found:")
+	for _, sev := range slices.Backward(severityLevels) {
+		l.line(11, bySeverity[sev] > 0, 16, fmt.Sprintf("%s: %d", strings.ToUpper(sev[:1])+sev[1:], bySeverity[sev]))
+	}
+	if res.Executive != "" {
+		l.gap(8)
+		l.line(11, false, 0, res.Executive)
+	}
+
+	l.newPage()
+	l.line(16, true, 0, "Anomalies")
+	l.rule()
+	if len(res.Anomalies) == 0 {
+		l.line(11, false, 0, "No anomalies were found.")
+	}
+	anoms := slices.Clone(res.Anomalies)
+	slices.SortStableFunc(anoms, func(a, b Anomaly) int {
+		return severityRank(b.Severity) - severityRank(a.Severity)
+	})
+	for _, a := range anoms {
+		head := fmt.Sprintf("[%s] %s", strings.ToUpper(a.Severity), kindLabel(a.Kind))
+		if src := cmp.Or(a.Subnet, a.SrcIP); src != "" {
+			head += " from " + src
+		}
+		l.line(11, true, 0, head)
+		detail := fmt.Sprintf("Confidence %.2f", a.Confidence)
+		switch {
+		case a.FirstSeen != nil && a.LastSeen != nil:
+			detail += ", " + a.FirstSeen.UTC().Format(time.RFC3339) + " to " + a.LastSeen.UTC().Format(time.RFC3339)
+		case a.Minute != nil:
+			detail += ", minute of " + a.Minute.UTC().Format(time.RFC3339)
+		case a.FirstSeen != nil:
+			detail += ", from " + a.FirstSeen.UTC().Format(time.RFC3339)
+		}
+		l.line(9, false, 12, detail)
+		l.line(10, false, 12, a.Reason)
+		for _, act := range a.Actions {
+			l.line(10, false, 24, "- "+act)
+		}
+		l.gap(8)
+	}
+	return doc
+}
@@ -0,0 +1,137 @@
+package upload
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	ref "github.com/ledongthuc/pdf"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+	"github.com/allensuvorov/tenexlog/internal/pdf"
+)
+
+// reportPages writes doc and reads it back with an independent PDF reader,
+// returning each page's text a line per baseline. Text set below the bottom
+// margin fails the test.
+func reportPages(t *testing.T, doc *pdf.Doc) []string {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := doc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	r, err := ref.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("reference reader: %v", err)
+	}
+	var pages []string
+	for i := 1; i <= r.NumPage(); i++ {
+		var b strings.Builder
+		y := -1.0
+		for _, g := range r.Page(i).Content().Text {
+			if g.Y < pdf.Margin {
+				t.Errorf("page %d: %q set at y=%.1f, below the margin", i, g.S, g.Y)
+			}
+			if g.Y != y && b.Len() > 0 {
+				b.WriteByte('\n')
+			}
+			y = g.Y
+			b.WriteString(g.S)
+		}
+		pages = append(pages, b.String())
+	}
+	return pages
+}
+
+// TestIncidentReportReadBack renders a report and reads it with an
+// independent PDF reader: every page parses, nothing is set below the bottom
+// margin, and the anomalies come most severe first.
+func TestIncidentReportReadBack(t *testing.T) {
+	severities := []string{"low", "critical", "medium", "high"}
+	res := Results{
+		JobID:     "job-1",
+		Filename:  "access.log",
+		SizeBytes: 4096,
+		Received:  "2024-05-01T13:00:00Z",
+		Summary:   parse.Summary{Lines: 1200, UniqueIPs: 40, Format: "tsv", Exact: true},
+		Executive: "Probing from several addresses.",
+	}
+	for i := range 30 {
+		res.Anomalies = append(res.Anomalies, Anomaly{
+			Kind:       "sensitive_paths",
+			Severity:   severities[i%len(severities)],
+			SrcIP:      fmt.Sprintf("203.0.113.%d", i),
+			Confidence: 0.9,
+			Reason:     strings.Repeat("probed /.env and /.git/config ", 6),
+			Actions:    []string{"Block the address at the edge"},
+		})
+	}
+
+	pages := reportPages(t, incidentReport(res, 0, time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)))
+	if len(pages) < 3 {
+		t.Fatalf("report has %d pages, want the title page and anomalies over at least two", len(pages))
+	}
+
+	for _, want := range []string{"Incident report", "access.log", "job-1", "30 anomalies found:", "Critical: 8", "High: 7", "Low: 8", "Probing from several addresses."} {
+		if !strings.Contains(pages[0], want) {
+			t.Errorf("title page lacks %q:\n%s", want, pages[0])
+		}
+	}
+	if !strings.HasPrefix(pages[1], "Anomalies\n") {
+		t.Errorf("page 2 starts %q, want the Anomalies heading", pages[1][:min(len(pages[1]), 20)])
+	}
+	rank := 4
+	headers := 0
+	for _, ln := range strings.Split(strings.Join(pages[1:], "\n"), "\n") {
+		sev, _, ok := strings.Cut(strings.TrimPrefix(ln, "["), "]")
+		if !ok || !strings.HasPrefix(ln, "[") {
+			continue
+		}
+		headers++
+		if got := severityRank(strings.ToLower(sev)); got > rank {
+			t.Errorf("%q follows a less severe anomaly", ln)
+		} else {
+			rank = got
+		}
+	}
+	if headers != len(res.Anomalies) {
+		t.Errorf("found %d anomaly headings, want %d", headers, len(res.Anomalies))
+	}
+}
+
+// TestIncidentReportMinSeverity checks that ?minSeverity= narrows the
+// executive summary like the anomaly entries, and that per-minute anomalies
+// show their minute.
+func TestIncidentReportMinSeverity(t *testing.T) {
+	minute := time.Date(2024, 5, 1, 13, 5, 0, 0, time.UTC)
+	res := Results{
+		JobID:     "job-1",
+		Filename:  "access.log",
+		Summary:   parse.Summary{Lines: 100, UniqueIPs: 2, Exact: true},
+		Executive: "Two findings, one of them low.",
+		Anomalies: []Anomaly{
+			{Kind: "error_spike", Severity: "high", Minute: &minute, Reason: "errors spiked"},
+			{Kind: "sensitive_paths", Severity: "low", SrcIP: "203.0.113.7", Reason: "probed /.env"},
+		},
+	}
+	pages := reportPages(t, incidentReport(res, severityRank("high"), time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)))
+	if len(pages) != 2 {
+		t.Fatalf("report has %d pages, want 2", len(pages))
+	}
+	for _, want := range []string{"1 anomaly found:", "High: 1", "Low: 0"} {
+		if !strings.Contains(pages[0], want) {
+			t.Errorf("title page lacks %q:\n%s", want, pages[0])
+		}
+	}
+	if strings.Contains(pages[0], res.Executive) || strings.Contains(pages[0], "203.0.113.7") {
+		t.Errorf("executive summary covers the low anomaly:\n%s", pages[0])
+	}
+	if !strings.Contains(pages[1], "minute of 2024-05-01T13:05:00Z") {
+		t.Errorf("per-minute anomaly shows no time:\n%s", pages[1])
+	}
+	if strings.Contains(pages[1], "probed /.env") {
+		t.Errorf("low anomaly listed:\n%s", pages[1])
+	}
+}
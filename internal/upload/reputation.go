@@ -0,0 +1,55 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/enrich"
+	"github.com/allensuvorov/tenexlog/internal/integrations"
+	"github.com/allensuvorov/tenexlog/internal/reqctx"
+)
+
+// annotateAbuse attaches AbuseIPDB scores to anomalies with a source IP. It
+// stops at the first rate-limit error so one job can't spend the whole daily
+// quota, gives up after a fixed overall deadline, and skips the lookups while
+// the AbuseIPDB circuit breaker is open. The returned note, if any, explains
+// missing scores. Canceling ctx, the job's context, stops the lookups too.
+func annotateAbuse(ctx context.Context, anoms []Anomaly) string {
+	if !enrich.AbuseIPDBEnabled() || len(anoms) == 0 {
+		return ""
+	}
+	logger := reqctx.Logger(ctx)
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	scores := make(map[string]*enrich.Abuse)
+	for i := range anoms {
+		ip := anoms[i].SrcIP
+		a, done := scores[ip]
+		if !done {
+			addr, err := netip.ParseAddr(ip)
+			if err != nil {
+				continue
+			}
+			a, err = enrich.AbuseOf(ctx, addr)
+			switch {
+			case errors.Is(err, integrations.ErrOpen):
+				return "AbuseIPDB is unavailable; reputation scores were skipped."
+			case errors.Is(err, enrich.ErrRateLimited), ctx.Err() != nil:
+				logger.Printf("abuseipdb %s: %v", ip, err)
+				return "AbuseIPDB rate limit or deadline reached; some reputation scores are missing."
+			case err != nil:
+				logger.Printf("abuseipdb %s: %v", ip, err)
+			}
+			scores[ip] = a
+		}
+		if a != nil {
+			score, reports := a.Score, a.Reports
+			anoms[i].AbuseScore = &score
+			anoms[i].AbuseReports = &reports
+		}
+	}
+	return ""
+}
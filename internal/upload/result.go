@@ -0,0 +1,99 @@
+package upload
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/jobs"
+)
+
+// resultKeys are the top-level JSON keys of Results, including those left out
+// when empty.
+var resultKeys = jsonKeys(reflect.TypeFor[Results]())
+
+func jsonKeys(t reflect.Type) map[string]bool {
+	out := make(map[string]bool)
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if f.Anonymous && name == "" {
+			for k := range jsonKeys(f.Type) {
+				out[k] = true
+			}
+			continue
+		}
+		if name != "" && name != "-" {
+			out[name] = true
+		}
+	}
+	return out
+}
+
+// Result serves GET /api/jobs/{id} using the Default service.
+func Result(w http.ResponseWriter, r *http.Request) {
+	Default.Result(w, r)
+}
+
+// Result returns a finished job's stored results. ?include= (comma-separated
+// top-level keys such as summary,anomalies) keeps only those sections, with
+// jobId always kept; ?minSeverity=, ?where= and ?fields= work as for the
+// upload. A job that is not done yet answers 409 with its status.
+func (s *Service) Result(w http.ResponseWriter, r *http.Request) {
+	minRank, ok := minSeverity(r)
+	if !ok {
+		http.Error(w, "minSeverity must be one of low, medium, high, critical", http.StatusBadRequest)
+		return
+	}
+	var include map[string]bool
+	for _, v := range r.URL.Query()["include"] {
+		for _, k := range strings.Split(v, ",") {
+			if k = strings.TrimSpace(k); k == "" {
+				continue
+			}
+			if !resultKeys[k] {
+				http.Error(w, "unknown include key "+k, http.StatusBadRequest)
+				return
+			}
+			if include == nil {
+				include = map[string]bool{"jobId": true}
+			}
+			include[k] = true
+		}
+	}
+
+	j, err := s.Jobs.GetJob(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	resp, ok := storedResults(w, j)
+	if !ok {
+		return
+	}
+
+	v, err := view(r, resp, minRank)
+	if err == nil && include != nil {
+		v, err = httputil.Project(v, include)
+	}
+	if err != nil {
+		http.Error(w, "could not encode response", http.StatusInternalServerError)
+		return
+	}
+	httputil.JSON(w, http.StatusOK, v)
+}
+
+// storedResults returns j's results, or answers with its status when it has
+// none: 409 while it is queued, running or failed.
+func storedResults(w http.ResponseWriter, j jobs.Job) (Results, bool) {
+	resp, ok := j.Result.(Results)
+	if !ok {
+		status := http.StatusConflict
+		if j.Status == jobs.StatusDone {
+			status = http.StatusInternalServerError
+		}
+		httputil.JSON(w, status, jobStatus{JobID: j.ID, Status: j.Status, Progress: j.Progress, Error: j.Error})
+	}
+	return resp, ok
+}
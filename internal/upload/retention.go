@@ -0,0 +1,160 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/jobs"
+)
+
+// ErrJobActive is returned when deleting a job that is still queued or
+// running.
+var ErrJobActive = errors.New("job is still being analyzed")
+
+// DeleteJob removes a finished job's saved upload and its stored results.
+func (s *Service) DeleteJob(id string) error {
+	j, err := s.Jobs.GetJob(id)
+	if err != nil {
+		return err
+	}
+	if j.Status == jobs.StatusQueued || j.Status == jobs.StatusRunning {
+		return ErrJobActive
+	}
+	return s.remove(j)
+}
+
+func (s *Service) remove(j jobs.Job) error {
+	if j.SavedTo != "" {
+		if err := s.Storage.Remove(j.SavedTo); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return s.Jobs.DeleteJob(j.ID)
+}
+
+// Purge deletes every finished job received before cutoff and returns how
+// many were removed.
+func (s *Service) Purge(cutoff time.Time) (int, error) {
+	list, err := s.Jobs.ListJobs()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	var errs []error
+	for _, j := range list {
+		if !j.Received.Before(cutoff) || j.Status == jobs.StatusQueued || j.Status == jobs.StatusRunning {
+			continue
+		}
+		if err := s.remove(j); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		n++
+	}
+	return n, errors.Join(errs...)
+}
+
+// Delete serves DELETE /api/jobs/{id} using the Default service.
+func Delete(w http.ResponseWriter, r *http.Request) {
+	Default.Delete(w, r)
+}
+
+// Delete removes one job, its saved upload and its results.
+func (s *Service) Delete(w http.ResponseWriter, r *http.Request) {
+	err := s.DeleteJob(r.PathValue("id"))
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrJobActive):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, "could not delete job", http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// PurgeHandler serves DELETE /api/jobs using the Default service.
+func PurgeHandler(w http.ResponseWriter, r *http.Request) {
+	Default.PurgeHandler(w, r)
+}
+
+// PurgeHandler deletes finished jobs older than ?olderThan= (a duration such
+// as 720h), which is required so a bare DELETE cannot wipe every job.
+func (s *Service) PurgeHandler(w http.ResponseWriter, r *http.Request) {
+	d, err := time.ParseDuration(r.URL.Query().Get("olderThan"))
+	if err != nil || d <= 0 {
+		http.Error(w, "olderThan must be a positive duration such as 720h", http.StatusBadRequest)
+		return
+	}
+	n, err := s.Purge(time.Now().Add(-d))
+	if err != nil {
+		http.Error(w, "purged "+strconv.Itoa(n)+" job(s) but some could not be deleted", http.StatusInternalServerError)
+		return
+	}
+	httputil.JSON(w, http.StatusOK, map[string]int{"purged": n})
+}
+
+// sweeper is implemented by storages that can list what they hold, so that
+// uploads no job refers to any more can be cleaned up.
+type sweeper interface {
+	Sweep(keep map[string]bool, cutoff time.Time) (int, error)
+}
+
+// Sweep removes the stored uploads last modified before cutoff that no job
+// refers to, such as those of jobs a store dropped or of a crashed save, and
+// returns how many were removed.
+func (s *Service) Sweep(cutoff time.Time) (int, error) {
+	sw, ok := s.Storage.(sweeper)
+	if !ok {
+		return 0, nil
+	}
+	list, err := s.Jobs.ListJobs()
+	if err != nil {
+		return 0, err
+	}
+	keep := make(map[string]bool, len(list))
+	for _, j := range list {
+		if j.SavedTo != "" {
+			keep[j.SavedTo] = true
+		}
+	}
+	return sw.Sweep(keep, cutoff)
+}
+
+// RunJanitor purges jobs older than ttl, with their uploads, and sweeps
+// uploads no job refers to that are older than ttl, until ctx is done. It
+// checks every quarter of ttl, but at least hourly and at most once a minute.
+func (s *Service) RunJanitor(ctx context.Context, ttl time.Duration) {
+	every := min(max(ttl/4, time.Minute), time.Hour)
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		cutoff := time.Now().Add(-ttl)
+		n, err := s.Purge(cutoff)
+		if err != nil {
+			log.Printf("janitor: %v", err)
+		}
+		if n > 0 {
+			log.Printf("janitor: purged %d job(s) older than %s", n, ttl)
+		}
+		n, err = s.Sweep(cutoff)
+		if err != nil {
+			log.Printf("janitor: %v", err)
+		}
+		if n > 0 {
+			log.Printf("janitor: removed %d upload(s) no job refers to", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
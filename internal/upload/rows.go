@@ -0,0 +1,272 @@
+package upload
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+const (
+	defaultRowLimit = 100
+	maxRowLimit     = 1000
+	maxRowOffset    = 10_000_000 // well past any job's kept rows
+)
+
+// rowSorts orders rows by a sortable field; ties keep the stored order.
+var rowSorts = map[string]func(a, b parse.Event) int{
+	"ts":         func(a, b parse.Event) int { return a.TS.Compare(b.TS) },
+	"srcIp":      func(a, b parse.Event) int { return cmp.Compare(a.SrcIP, b.SrcIP) },
+	"method":     func(a, b parse.Event) int { return cmp.Compare(a.Method, b.Method) },
+	"path":       func(a, b parse.Event) int { return cmp.Compare(a.Path, b.Path) },
+	"status":     func(a, b parse.Event) int { return cmp.Compare(a.Status, b.Status) },
+	"bytes":      func(a, b parse.Event) int { return cmp.Compare(a.Bytes, b.Bytes) },
+	"durationMs": func(a, b parse.Event) int { return cmp.Compare(a.DurationMs, b.DurationMs) },
+}
+
+// rowQuery is a parsed GET /api/jobs/{id}/rows request.
+type rowQuery struct {
+	src        netip.Prefix
+	pathPrefix string
+	statuses   []int // exact codes, or 1..5 for a class such as 4xx
+	methods    []string
+	from, to   time.Time
+	sortBy     string // "" keeps the stored (file) order
+	desc       bool
+	limit      int
+	offset     int
+}
+
+type rowPage struct {
+	JobID      string        `json:"jobId"`
+	Rows       []parse.Event `json:"rows"`
+	Total      int           `json:"total"`
+	Limit      int           `json:"limit"`
+	Offset     int           `json:"offset"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
+// encodeRowCursor returns the opaque cursor for the page starting at offset
+// in the given order: a rowSorts key, prefixed with - for descending, or ""
+// for the stored order. The order travels with the cursor so that following
+// it never skips or repeats rows; filters is the rowFilterKey of the request
+// that issued it, which a request following it must match.
+func encodeRowCursor(offset int, order, filters string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("o" + strconv.Itoa(offset) + ";" + order + ";" + filters))
+}
+
+func decodeRowCursor(c string) (offset int, order, filters string, ok bool) {
+	b, err := base64.RawURLEncoding.DecodeString(c)
+	if err != nil {
+		return 0, "", "", false
+	}
+	v, ok := strings.CutPrefix(string(b), "o")
+	parts := strings.SplitN(v, ";", 3)
+	if len(parts) != 3 {
+		return 0, "", "", false
+	}
+	n, err := strconv.Atoi(parts[0])
+	return n, parts[1], parts[2], ok && err == nil && n >= 0 && n <= maxRowOffset
+}
+
+// rowFilterKey identifies the filters of a rows request, its ?where= ones
+// included, so that a cursor is only followed under the filters it was
+// issued with.
+func rowFilterKey(rq rowQuery, where []rowFilter) string {
+	parts := []string{rq.pathPrefix, strings.Join(slices.Sorted(slices.Values(rq.methods)), ",")}
+	if rq.src.IsValid() {
+		parts = append(parts, "src="+rq.src.String())
+	}
+	for _, st := range slices.Sorted(slices.Values(rq.statuses)) {
+		parts = append(parts, "status="+strconv.Itoa(st))
+	}
+	if !rq.from.IsZero() {
+		parts = append(parts, "from="+rq.from.UTC().Format(time.RFC3339Nano))
+	}
+	if !rq.to.IsZero() {
+		parts = append(parts, "to="+rq.to.UTC().Format(time.RFC3339Nano))
+	}
+	var ws []string
+	for _, f := range where {
+		ws = append(ws, "where="+f.field+"="+f.value)
+	}
+	slices.Sort(ws)
+	h := sha256.Sum256([]byte(strings.Join(append(parts, ws...), "\x00")))
+	return hex.EncodeToString(h[:6])
+}
+
+// order is the query's sort in cursor form.
+func (rq rowQuery) order() string {
+	if rq.desc {
+		return "-" + rq.sortBy
+	}
+	return rq.sortBy
+}
+
+func parseRowQuery(r *http.Request) (rowQuery, string) {
+	q := r.URL.Query()
+	rq := rowQuery{sortBy: "ts", limit: defaultRowLimit, pathPrefix: q.Get("pathPrefix")}
+	if v := q.Get("srcIp"); v != "" {
+		p, err := netip.ParsePrefix(v)
+		if err != nil {
+			a, aerr := netip.ParseAddr(v)
+			if aerr != nil {
+				return rq, "srcIp must be an IP address or CIDR range"
+			}
+			p = netip.PrefixFrom(a, a.BitLen())
+		}
+		rq.src = p.Masked()
+	}
+	for _, v := range splitList(strings.Join(q["status"], ",")) {
+		if len(v) == 3 && strings.HasSuffix(strings.ToLower(v), "xx") && v[0] >= '1' && v[0] <= '5' {
+			rq.statuses = append(rq.statuses, int(v[0]-'0'))
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 100 || n > 599 {
+			return rq, "status must be a code such as 404 or a class such as 4xx"
+		}
+		rq.statuses = append(rq.statuses, n)
+	}
+	for _, v := range splitList(strings.Join(q["method"], ",")) {
+		rq.methods = append(rq.methods, strings.ToUpper(v))
+	}
+	for _, b := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &rq.from}, {"to", &rq.to}} {
+		if v := q.Get(b.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return rq, b.name + " must be an RFC 3339 time such as 2024-05-01T13:00:00Z"
+			}
+			*b.t = t
+		}
+	}
+	if v := q.Get("sort"); v != "" {
+		rq.sortBy, rq.desc = strings.CutPrefix(v, "-")
+		if rowSorts[rq.sortBy] == nil {
+			return rq, "sort must be one of ts, srcIp, method, path, status, bytes, durationMs, optionally prefixed with -"
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxRowLimit {
+			return rq, "limit must be an integer between 1 and 1000"
+		}
+		rq.limit = n
+	}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxRowOffset {
+			return rq, "offset must be an integer between 0 and 10000000"
+		}
+		rq.offset = n
+	}
+	if v := q.Get("cursor"); v != "" {
+		n, order, filters, ok := decodeRowCursor(v)
+		rq.sortBy, rq.desc = strings.CutPrefix(order, "-")
+		if !ok || (rq.sortBy != "" && rowSorts[rq.sortBy] == nil) {
+			return rq, "cursor is not valid"
+		}
+		if filters != rowFilterKey(rq, whereFilters(r)) {
+			return rq, "cursor was issued for other filters; repeat the filters it was issued with"
+		}
+		rq.offset = n
+	}
+	return rq, ""
+}
+
+func (rq rowQuery) matches(ev parse.Event) bool {
+	if rq.src.IsValid() {
+		a, err := netip.ParseAddr(ev.SrcIP)
+		if err != nil || !rq.src.Contains(a.Unmap()) {
+			return false
+		}
+	}
+	if rq.pathPrefix != "" && !strings.HasPrefix(ev.Path, rq.pathPrefix) {
+		return false
+	}
+	if len(rq.statuses) > 0 && !slices.ContainsFunc(rq.statuses, func(s int) bool {
+		return s == ev.Status || s == ev.Status/100
+	}) {
+		return false
+	}
+	if len(rq.methods) > 0 && !slices.Contains(rq.methods, strings.ToUpper(ev.Method)) {
+		return false
+	}
+	if !rq.from.IsZero() && ev.TS.Before(rq.from) {
+		return false
+	}
+	if !rq.to.IsZero() && ev.TS.After(rq.to) {
+		return false
+	}
+	return true
+}
+
+// Rows serves GET /api/jobs/{id}/rows using the Default service.
+func Rows(w http.ResponseWriter, r *http.Request) {
+	Default.Rows(w, r)
+}
+
+// Rows pages through a finished job's kept rows. ?srcIp= (address or CIDR),
+// ?pathPrefix=, ?status= (codes or classes such as 4xx), ?method=, ?from= and
+// ?to= (RFC 3339) filter them; ?sort= picks the order (default ts, prefix -
+// for descending); ?limit= (default 100, max 1000) and ?offset= or ?cursor=
+// page through the matches, and total counts them all. A cursor carries its
+// order, overriding ?sort=: the rowsCursor of a result continues in file
+// order. ?where= narrows the rows as for the results. A cursor is bound to
+// the filters it was issued with, ?where= included, and is refused under
+// any others, so the same filters must be passed along with it.
+func (s *Service) Rows(w http.ResponseWriter, r *http.Request) {
+	rq, msg := parseRowQuery(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	j, err := s.Jobs.GetJob(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	resp, ok := storedResults(w, j)
+	if !ok {
+		return
+	}
+
+	rows := resp.Rows
+	where := whereFilters(r)
+	if len(where) > 0 {
+		rows = filterRows(rows, where)
+	}
+	matched := make([]parse.Event, 0, len(rows))
+	for _, ev := range rows {
+		if rq.matches(ev) {
+			matched = append(matched, ev)
+		}
+	}
+	if by := rowSorts[rq.sortBy]; by != nil {
+		slices.SortStableFunc(matched, func(a, b parse.Event) int {
+			if rq.desc {
+				return by(b, a)
+			}
+			return by(a, b)
+		})
+	}
+	start := min(rq.offset, len(matched))
+	end := start + min(rq.limit, len(matched)-start)
+	out := rowPage{JobID: j.ID, Rows: matched[start:end], Total: len(matched), Limit: rq.limit, Offset: rq.offset}
+	if end < len(matched) {
+		out.NextCursor = encodeRowCursor(end, rq.order(), rowFilterKey(rq, where))
+	}
+	httputil.JSON(w, http.StatusOK, out)
+}
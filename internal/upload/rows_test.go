@@ -0,0 +1,184 @@
+package upload
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/jobs"
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// rowsJob stores a done job holding n rows, one a minute, and returns its ID.
+func rowsJob(t *testing.T, s *Service, n int) string {
+	t.Helper()
+	base := time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)
+	res := Results{JobID: "rows"}
+	for i := range n {
+		res.Rows = append(res.Rows, parse.Event{TS: base.Add(time.Duration(i) * time.Minute), SrcIP: "10.0.0." + strconv.Itoa(i), Status: 200 + i})
+	}
+	if _, err := s.Jobs.SaveJob(jobs.Job{ID: "rows", Status: jobs.StatusDone, Result: res}); err != nil {
+		t.Fatal(err)
+	}
+	return "rows"
+}
+
+func TestRowsPaging(t *testing.T) {
+	s := testService(t)
+	id := rowsJob(t, s, 5)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantRows   int
+		wantNext   bool
+	}{
+		{name: "first page", query: "limit=2", wantStatus: http.StatusOK, wantRows: 2, wantNext: true},
+		{name: "last page", query: "limit=2&offset=4", wantStatus: http.StatusOK, wantRows: 1},
+		{name: "past the end", query: "offset=9", wantStatus: http.StatusOK},
+		{name: "largest offset", query: "offset=" + strconv.Itoa(maxRowOffset), wantStatus: http.StatusOK},
+		{name: "offset too large", query: "offset=" + strconv.Itoa(math.MaxInt), wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/jobs/"+id+"/rows?"+tt.query, nil)
+			r.SetPathValue("id", id)
+			w := httptest.NewRecorder()
+			s.Rows(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var page rowPage
+			if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Rows) != tt.wantRows || page.Total != 5 || (page.NextCursor != "") != tt.wantNext {
+				t.Errorf("%d rows of %d, next cursor %q", len(page.Rows), page.Total, page.NextCursor)
+			}
+		})
+	}
+}
+
+func TestRowCursor(t *testing.T) {
+	key := rowFilterKey(rowQuery{}, nil)
+	for _, tt := range []struct {
+		offset int
+		order  string
+	}{{0, ""}, {100, ""}, {300, "ts"}, {5, "-bytes"}, {maxRowOffset, ""}} {
+		c := encodeRowCursor(tt.offset, tt.order, key)
+		offset, order, filters, ok := decodeRowCursor(c)
+		if !ok || offset != tt.offset || order != tt.order || filters != key {
+			t.Errorf("decodeRowCursor(encodeRowCursor(%d, %q)) = %d, %q, %q, %v", tt.offset, tt.order, offset, order, filters, ok)
+		}
+	}
+
+	raw := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	for _, c := range []string{
+		"", "%%%", raw("100"), raw("ox;ts;" + key), raw("o-5;ts;" + key), raw("o10;ts"),
+		raw("o" + strconv.Itoa(maxRowOffset+1) + ";;" + key), raw("o" + strconv.Itoa(math.MaxInt) + ";;" + key),
+	} {
+		if _, _, _, ok := decodeRowCursor(c); ok {
+			t.Errorf("decodeRowCursor(%q) accepted", c)
+		}
+	}
+}
+
+func TestParseRowQueryCursor(t *testing.T) {
+	raw := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	none := rowFilterKey(rowQuery{}, nil)
+	where404 := rowFilterKey(rowQuery{}, []rowFilter{{"status", "404"}})
+	client4xx := rowFilterKey(rowQuery{statuses: []int{4}, methods: []string{"GET"}}, nil)
+	tests := []struct {
+		name       string
+		query      url.Values
+		wantOffset int
+		wantSort   string
+		wantDesc   bool
+		wantErr    bool
+	}{
+		{name: "defaults", query: url.Values{}, wantSort: "ts"},
+		{name: "offset", query: url.Values{"offset": {"20"}}, wantOffset: 20, wantSort: "ts"},
+		{name: "sort", query: url.Values{"sort": {"-status"}}, wantSort: "status", wantDesc: true},
+		{name: "file order cursor", query: url.Values{"cursor": {encodeRowCursor(100, "", none)}}, wantOffset: 100},
+		{
+			name:       "cursor overrides sort and offset",
+			query:      url.Values{"cursor": {encodeRowCursor(40, "-bytes", none)}, "sort": {"path"}, "offset": {"7"}},
+			wantOffset: 40, wantSort: "bytes", wantDesc: true,
+		},
+		{name: "cursor with its where", query: url.Values{"cursor": {encodeRowCursor(100, "", where404)}, "where": {"status=404"}}, wantOffset: 100},
+		{name: "cursor without its where", query: url.Values{"cursor": {encodeRowCursor(100, "", where404)}}, wantErr: true},
+		{name: "cursor with another where", query: url.Values{"cursor": {encodeRowCursor(100, "", where404)}, "where": {"status=500"}}, wantErr: true},
+		{
+			name:       "cursor with its filters",
+			query:      url.Values{"cursor": {encodeRowCursor(10, "ts", client4xx)}, "status": {"4xx"}, "method": {"get"}},
+			wantOffset: 10, wantSort: "ts",
+		},
+		{name: "cursor with other filters", query: url.Values{"cursor": {encodeRowCursor(10, "ts", client4xx)}, "status": {"5xx"}, "method": {"get"}}, wantErr: true},
+		{name: "garbled cursor", query: url.Values{"cursor": {"not a cursor"}}, wantErr: true},
+		{name: "cursor with unknown order", query: url.Values{"cursor": {raw("o10;savedTo;" + none)}}, wantErr: true},
+		{name: "forged huge cursor", query: url.Values{"cursor": {raw("o" + strconv.Itoa(math.MaxInt) + ";;" + none)}}, wantErr: true},
+		{name: "negative offset", query: url.Values{"offset": {"-1"}}, wantErr: true},
+		{name: "unknown sort", query: url.Values{"sort": {"savedTo"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/jobs/x/rows?"+tt.query.Encode(), nil)
+			rq, msg := parseRowQuery(r)
+			if (msg != "") != tt.wantErr {
+				t.Fatalf("parseRowQuery error = %q, want error %v", msg, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if rq.offset != tt.wantOffset || rq.sortBy != tt.wantSort || rq.desc != tt.wantDesc {
+				t.Errorf("parseRowQuery = offset %d, sort %q, desc %v", rq.offset, rq.sortBy, rq.desc)
+			}
+		})
+	}
+}
+
+// TestRowsFollowCursor pages through filtered rows by nextCursor alone, with
+// the filters repeated, and checks every match comes back exactly once.
+func TestRowsFollowCursor(t *testing.T) {
+	s := testService(t)
+	id := rowsJob(t, s, 9)
+	seen := make(map[string]bool)
+	q := url.Values{"status": {"201", "203", "205", "207"}, "limit": {"3"}, "sort": {"-ts"}}
+	for pages := 0; ; pages++ {
+		r := httptest.NewRequest("GET", "/api/jobs/"+id+"/rows?"+q.Encode(), nil)
+		r.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		s.Rows(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("page %d: status %d: %s", pages, w.Code, w.Body)
+		}
+		var page rowPage
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		for _, ev := range page.Rows {
+			if seen[ev.SrcIP] {
+				t.Errorf("row %s repeated", ev.SrcIP)
+			}
+			seen[ev.SrcIP] = true
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		q.Del("sort")
+		q.Set("cursor", page.NextCursor)
+	}
+	if len(seen) != 4 {
+		t.Errorf("%d rows seen, want 4", len(seen))
+	}
+}
@@ -0,0 +1,28 @@
+package upload
+
+import (
+	"math"
+
+	"github.com/allensuvorov/tenexlog/internal/analyze"
+)
+
+// ratio is n over base, treating a base below 1 as 1 so a quiet baseline
+// does not divide by zero.
+func ratio(n int, base float64) float64 {
+	return float64(n) / max(base, 1)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// calibrate rounds a's evidence for output and sets its confidence from it.
+func calibrate(a *Anomaly) {
+	for i := range a.Evidence {
+		a.Evidence[i].Value = math.Round(a.Evidence[i].Value*100) / 100
+	}
+	a.Confidence = analyze.Calibrate(a.Evidence)
+}
@@ -0,0 +1,81 @@
+package upload
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// SeenTTL is how long an alerted finding stays known. A fingerprint not
+// seen again within it alerts as new.
+var SeenTTL = 30 * 24 * time.Hour
+
+// seenFindings remembers the fingerprints already alerted on, so that
+// recurring runs over one source only alert on what is new. When File is set
+// the set survives restarts.
+type seenFindings struct {
+	mu   sync.Mutex
+	File string
+	last map[string]time.Time // fingerprint → when it was last seen
+}
+
+var seen = &seenFindings{last: make(map[string]time.Time)}
+
+// LoadSeenFindings restores the fingerprints saved in path and keeps saving
+// there. A missing file is not an error.
+func LoadSeenFindings(path string) error {
+	seen.mu.Lock()
+	defer seen.mu.Unlock()
+	seen.File = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &seen.last)
+}
+
+// markNew records fps as seen at now and reports, for each, whether it was
+// unknown (or had expired).
+func (s *seenFindings) markNew(fps []string, now time.Time) []bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for fp, t := range s.last {
+		if now.Sub(t) > SeenTTL {
+			delete(s.last, fp)
+		}
+	}
+	fresh := make([]bool, len(fps))
+	for i, fp := range fps {
+		_, known := s.last[fp]
+		fresh[i] = !known
+		s.last[fp] = now
+	}
+	s.saveLocked()
+	return fresh
+}
+
+// saveLocked persists the set; s.mu must be held.
+func (s *seenFindings) saveLocked() {
+	if s.File == "" {
+		return
+	}
+	data, err := json.Marshal(s.last)
+	if err != nil {
+		log.Printf("notify: encode seen findings: %v", err)
+		return
+	}
+	tmp := s.File + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Printf("notify: save seen findings: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, s.File); err != nil {
+		log.Printf("notify: save seen findings: %v", err)
+	}
+}
@@ -0,0 +1,412 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/enrich"
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/jobs"
+	"github.com/allensuvorov/tenexlog/internal/parse"
+	"github.com/allensuvorov/tenexlog/internal/reqctx"
+)
+
+// Storage persists uploaded source files.
+type Storage interface {
+	Save(jobID string, src io.Reader) (path string, size int64, err error)
+	Remove(path string) error
+}
+
+// Parser reads a stored file into a summary, timeline and rows.
+type Parser interface {
+	Parse(ctx context.Context, path string) (parse.Summary, []parse.Bucket, []parse.Event, error)
+}
+
+// DetectorSet finds anomalies in parsed rows.
+type DetectorSet interface {
+	Detect(ctx context.Context, rows []parse.Event, timeline []parse.Bucket) []Anomaly
+}
+
+// JobStore records jobs as they move from queued to done or failed. SaveJob
+// returns the jobs it dropped to make room, whose uploads the service
+// removes.
+type JobStore interface {
+	SaveJob(j jobs.Job) (evicted []jobs.Job, err error)
+	GetJob(id string) (jobs.Job, error)
+	ListJobs() ([]jobs.Job, error)
+	DeleteJob(id string) error
+}
+
+// Service runs an upload through storage, parsing, detection and response
+// assembly, and records the job. Uploads queued with Enqueue are analyzed by
+// the workers started with Run.
+type Service struct {
+	Storage   Storage
+	Parser    Parser
+	Detectors DetectorSet
+	Jobs      JobStore
+
+	tasks   chan task
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc // queued and running jobs
+}
+
+func NewService(st Storage, p Parser, d DetectorSet, js JobStore) *Service {
+	return &Service{Storage: st, Parser: p, Detectors: d, Jobs: js, tasks: make(chan task, QueueSize), cancels: make(map[string]context.CancelFunc)}
+}
+
+// Default is the service used by Handler and Ingest.
+var Default = NewService(DiskStorage{}, FileParser{}, BuiltinDetectors{}, jobs.Default)
+
+// DiskStorage writes uploads to Dir, or the system temp directory when empty.
+type DiskStorage struct {
+	Dir string
+}
+
+func (d DiskStorage) Save(jobID string, src io.Reader) (string, int64, error) {
+	dir := d.Dir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	dest := filepath.Join(dir, jobID+".log")
+	out, err := os.Create(dest)
+	if err != nil {
+		return "", 0, err
+	}
+	n, copyErr := io.Copy(out, src)
+	closeErr := out.Close()
+	if copyErr != nil || closeErr != nil {
+		_ = os.Remove(dest)
+		return "", 0, errors.Join(copyErr, closeErr)
+	}
+	return dest, n, nil
+}
+
+// Adopt moves the file at path into the directory as jobID's upload. It fails
+// when path is on another file system, in which case the file stays put.
+func (d DiskStorage) Adopt(jobID, path string) (string, int64, error) {
+	dir := d.Dir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", 0, err
+	}
+	dest := filepath.Join(dir, jobID+".log")
+	if err := os.Rename(path, dest); err != nil {
+		return "", 0, err
+	}
+	return dest, info.Size(), nil
+}
+
+func (DiskStorage) Remove(path string) error { return os.Remove(path) }
+
+// Sweep removes the uploads in the directory that were last modified before
+// cutoff and are not in keep, and returns how many it removed. Only files
+// named the way Save names them are considered, since the directory may be
+// shared.
+func (d DiskStorage) Sweep(keep map[string]bool, cutoff time.Time) (int, error) {
+	dir := d.Dir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	var errs []error
+	for _, e := range entries {
+		name := e.Name()
+		id, ok := strings.CutSuffix(name, ".log")
+		if !ok || !e.Type().IsRegular() || !httputil.IsID(id) {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if keep[path] {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+		n++
+	}
+	return n, errors.Join(errs...)
+}
+
+// FileParser detects the file's format and parses up to MaxRows lines,
+// keeping the first KeepRows rows (defaults 100000 and 5000).
+type FileParser struct {
+	MaxRows  int
+	KeepRows int
+}
+
+func (p FileParser) Parse(ctx context.Context, path string) (parse.Summary, []parse.Bucket, []parse.Event, error) {
+	maxRows, keepRows := p.MaxRows, p.KeepRows
+	if maxRows == 0 {
+		maxRows = 100_000
+	}
+	if keepRows == 0 {
+		keepRows = 5_000
+	}
+	return parse.ParseFileContext(ctx, path, maxRows, keepRows, parse.Hooks{Progress: parseProgressFrom(ctx)})
+}
+
+// BuiltinDetectors runs the built-in and registered detectors as configured
+// by ConfigureDetectors and narrowed by the Selection in ctx.
+type BuiltinDetectors struct{}
+
+func (BuiltinDetectors) Detect(ctx context.Context, rows []parse.Event, timeline []parse.Bucket) []Anomaly {
+	return runDetectors(ctx, rows, timeline, selectionFrom(ctx), detectorProgressFrom(ctx))
+}
+
+var ErrSave = errors.New("failed to save upload")
+
+// Options adjust how one upload is analyzed.
+type Options struct {
+	Tail      parse.TailOptions // analyze only the end of the file or a time range
+	Detectors Selection
+	// AggregateSubnets folds per-IP anomalies of one kind into /24 and /48
+	// groups.
+	AggregateSubnets bool
+	// Callback receives a signed summary when the job finishes or fails.
+	Callback string
+	// Source names the stream the upload belongs to. Anomalies of the jobs
+	// of one source share a lifecycle, from open to resolved.
+	Source string
+}
+
+// Ingest stores src as a new job's source file, analyzes it and records the
+// job with the Default service. It is shared by every way logs can enter the
+// system. The job is attributed to the principal and request ID in ctx.
+func Ingest(ctx context.Context, filename string, src io.Reader) (Results, error) {
+	return Default.Ingest(ctx, filename, src)
+}
+
+func (s *Service) Ingest(ctx context.Context, filename string, src io.Reader) (Results, error) {
+	return s.IngestWith(ctx, filename, src, Options{})
+}
+
+func (s *Service) IngestWith(ctx context.Context, filename string, src io.Reader, opts Options) (Results, error) {
+	j, err := s.save(ctx, filename, src)
+	if err != nil {
+		return Results{}, err
+	}
+	resp, err := s.analyze(ctx, j, opts, nil)
+	if err != nil {
+		_ = s.Storage.Remove(j.SavedTo)
+		return Results{}, err
+	}
+	return resp, nil
+}
+
+// save stores src as a new job's source file and returns the job, not yet
+// recorded.
+func (s *Service) save(ctx context.Context, filename string, src io.Reader) (jobs.Job, error) {
+	return s.store(ctx, filename, func(jobID string) (string, int64, error) { return s.Storage.Save(jobID, src) })
+}
+
+// store records a new job whose source file put places in storage.
+func (s *Service) store(ctx context.Context, filename string, put func(jobID string) (string, int64, error)) (jobs.Job, error) {
+	jobID := httputil.NewID()
+	logger := reqctx.Logger(ctx)
+	dest, n, err := put(jobID)
+	if err != nil {
+		logger.Printf("job %s: save %s: %v", jobID, filename, err)
+		return jobs.Job{}, fmt.Errorf("%w: %w", ErrSave, err)
+	}
+	logger.Printf("job %s: received %s (%d bytes)", jobID, filename, n)
+	return jobs.Job{
+		ID:        jobID,
+		Filename:  filename,
+		SizeBytes: n,
+		SavedTo:   dest,
+		Received:  time.Now().UTC(),
+		Owner:     reqctx.PrincipalFrom(ctx).User,
+		RequestID: reqctx.RequestID(ctx),
+	}, nil
+}
+
+// analyze parses and analyzes j's saved file, reporting progress to tr, and
+// records j as done.
+func (s *Service) analyze(ctx context.Context, j jobs.Job, opts Options, tr *tracker) (Results, error) {
+	logger := reqctx.Logger(ctx)
+	jobID, filename, dest, size := j.ID, j.Filename, j.SavedTo, j.SizeBytes
+	src := dest
+	var tail *parse.TailInfo
+	if opts.Tail.Enabled() {
+		info, err := parse.Tail(dest, dest+".tail", opts.Tail)
+		if err != nil {
+			logger.Printf("job %s: tail: %v", jobID, err)
+			return Results{}, err
+		}
+		defer os.Remove(dest + ".tail")
+		src, tail = dest+".tail", &info
+		logger.Printf("job %s: analyzing last %d of %d bytes", jobID, info.Bytes, size)
+	}
+
+	tr.stage("parsing", 5)
+	parsed := size
+	if tail != nil {
+		parsed = tail.Bytes
+	}
+	sum, timeline, rows, err := s.Parser.Parse(withParseProgress(ctx, tr.parseProgress(parsed)), src)
+	if err != nil {
+		logger.Printf("job %s: parse: %v", jobID, err)
+		return Results{}, err
+	}
+	if err := tr.err(); err != nil {
+		return Results{}, err
+	}
+	tr.stage("detecting", 70)
+
+	tagCrawlers(ctx, rows)
+	tagAnonymizers(rows)
+
+	maxAnoms := opts.Detectors.Thresholds.maxAnoms()
+	merged := s.Detectors.Detect(withDetectorProgress(withSelection(ctx, opts.Detectors), tr.detectorProgress()), rows, timeline)
+	if err := ctx.Err(); err != nil {
+		logger.Printf("job %s: %v", jobID, err)
+		return Results{}, err
+	}
+	if err := tr.err(); err != nil {
+		return Results{}, err
+	}
+	merged, suppressed := suppress(merged, rows)
+	tr.stage("finishing", 95)
+	gaps := parse.FindGaps(timeline, GapAlertAfter)
+	for _, g := range gaps {
+		logger.Printf("job %s: no events between %s and %s (%d min)", jobID, g.From.Format(time.RFC3339), g.To.Format(time.RFC3339), g.Minutes)
+	}
+
+	merged, dropped := capAnomalies(merged, maxAnoms)
+
+	if merged == nil {
+		merged = []Anomaly{}
+	}
+	abuseNote := annotateAbuse(ctx, merged)
+
+	now := j.Received
+	boostRecurrent(merged, jobID, now)
+	for _, a := range merged {
+		if a.SrcIP != "" {
+			enrich.Sightings.Record(a.SrcIP, jobID, a.Kind, now)
+		}
+	}
+
+	if opts.AggregateSubnets {
+		merged = aggregateSubnets(merged)
+	}
+	var resolved []Anomaly
+	if opts.Source != "" {
+		resolved = tracked.advance(opts.Source, merged, now)
+	}
+
+	resp := assemble(jobID, filename, dest, size, now, sum, timeline, gaps, rows, merged)
+	resp.Source, resp.Resolved = opts.Source, resolved
+	if tail != nil {
+		resp.Tail = tail
+		if opts.Tail.From.IsZero() && opts.Tail.To.IsZero() {
+			resp.Note = strings.TrimSpace(resp.Note + " Tail mode: only the last " + strconv.FormatInt(tail.Bytes, 10) + " bytes of the file were analyzed.")
+		} else {
+			resp.Note = strings.TrimSpace(resp.Note + " Window mode: only the " + strconv.FormatInt(tail.Bytes, 10) + " bytes of the file" + windowText(tail) + " were analyzed.")
+		}
+	}
+	if suppressed > 0 {
+		resp.Suppressed = suppressed
+		resp.Note = strings.TrimSpace(resp.Note + " " + strconv.Itoa(suppressed) + " anomaly(ies) matched a suppression and were dropped.")
+	}
+	if dropped > 0 {
+		resp.Dropped = dropped
+		resp.Note = strings.TrimSpace(resp.Note + " " + strconv.Itoa(dropped) + " less severe anomaly(ies) were dropped over the limit of " + strconv.Itoa(maxAnoms) + " (?maxAnoms=).")
+	}
+	if abuseNote != "" {
+		resp.Note = strings.TrimSpace(resp.Note + " " + abuseNote)
+	}
+
+	j.Format, j.AnomalyCount, j.Source, j.Result = sum.Format, len(merged), opts.Source, resp
+	j.Status, j.Progress = jobs.StatusDone, 100
+	if err := s.saveJob(j); err != nil {
+		logger.Printf("job %s: record results: %v", jobID, err)
+		return Results{}, fmt.Errorf("%w: %w", ErrStore, err)
+	}
+	tr.finish(j)
+	announce(j, &resp)
+	logger.Printf("job %s: %s, %d lines, %d anomalies", jobID, sum.Format, sum.Lines, len(merged))
+	notifyJob(resp)
+	notifyCallback(opts.Callback, j, &resp)
+	return resp, nil
+}
+
+// saveJob records j and removes the uploads of the jobs the store dropped to
+// make room for it.
+func (s *Service) saveJob(j jobs.Job) error {
+	evicted, err := s.Jobs.SaveJob(j)
+	for _, e := range evicted {
+		if e.SavedTo == "" {
+			continue
+		}
+		if err := s.Storage.Remove(e.SavedTo); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("job %s: remove upload of evicted job: %v", e.ID, err)
+		}
+	}
+	return err
+}
+
+func assemble(jobID, filename, dest string, size int64, now time.Time, sum parse.Summary, timeline []parse.Bucket, gaps []parse.Gap, rows []parse.Event, anoms []Anomaly) Results {
+	note := ""
+	if sum.Lines > len(rows) {
+		note = "Rows are truncated for display (showing first " + strconv.Itoa(len(rows)) + "). Summary/anomalies are computed over the scanned portion."
+	}
+	if !sum.Exact {
+		note = strings.TrimSpace(note + " Scanning stopped after " + strconv.Itoa(sum.Lines) + " lines; summary figures cover only those lines.")
+		if e, ok := sum.Estimates["lines"]; ok {
+			note += " The file has an estimated " + strconv.FormatFloat(e.Value, 'f', 0, 64) + " lines (95% interval " +
+				strconv.FormatFloat(e.Low, 'f', 0, 64) + "–" + strconv.FormatFloat(e.High, 'f', 0, 64) + ")."
+		}
+	}
+	return Results{
+		JobID:     jobID,
+		Filename:  filename,
+		SizeBytes: size,
+		SavedTo:   dest,
+		Received:  now.UTC().Format(time.RFC3339),
+		Summary:   sum,
+		Timeline:  timeline,
+		Gaps:      gaps,
+		Rows:      rows,
+		Anomalies: anoms,
+		Actors:    actors(anoms),
+		Tops:      sum.Top,
+		Executive: execSummary(sum, anoms),
+		Note:      note,
+	}
+}
+
+// windowText describes the time bounds of a windowed analysis.
+func windowText(t *parse.TailInfo) string {
+	var out string
+	if t.Since != nil {
+		out += " from " + t.Since.Format(time.RFC3339)
+	}
+	if t.Until != nil {
+		out += " to " + t.Until.Format(time.RFC3339)
+	}
+	return out
+}
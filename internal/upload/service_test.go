@@ -0,0 +1,147 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/jobs"
+)
+
+// recordingStorage remembers the uploads removed through it.
+type recordingStorage struct {
+	removed []string
+}
+
+func (s *recordingStorage) Save(string, io.Reader) (string, int64, error) { return "", 0, nil }
+
+func (s *recordingStorage) Remove(path string) error {
+	s.removed = append(s.removed, path)
+	return nil
+}
+
+func TestSaveJobRemovesEvictedUploads(t *testing.T) {
+	st := &recordingStorage{}
+	s := NewService(st, FileParser{}, BuiltinDetectors{}, jobs.NewMemStore(1))
+	base := time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)
+	for _, j := range []jobs.Job{
+		{ID: "old", Received: base, Status: jobs.StatusDone, SavedTo: "/uploads/old.log"},
+		{ID: "new", Received: base.Add(time.Minute), Status: jobs.StatusDone, SavedTo: "/uploads/new.log"},
+	} {
+		if err := s.saveJob(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Equal(st.removed, []string{"/uploads/old.log"}) {
+		t.Errorf("removed %v, want the evicted job's upload", st.removed)
+	}
+}
+
+// failingStore refuses to record jobs in the status fail.
+type failingStore struct {
+	*jobs.MemStore
+	fail string
+}
+
+func (s failingStore) SaveJob(j jobs.Job) ([]jobs.Job, error) {
+	if j.Status == s.fail {
+		return nil, errors.New("database is locked")
+	}
+	return s.MemStore.SaveJob(j)
+}
+
+func TestStoreErrors(t *testing.T) {
+	const line = `127.0.0.1 - - [01/May/2024:13:00:00 +0000] "GET / HTTP/1.1" 200 5 "-" "curl/8.0"` + "\n"
+	t.Run("enqueue", func(t *testing.T) {
+		dir := t.TempDir()
+		s := NewService(DiskStorage{Dir: dir}, FileParser{}, BuiltinDetectors{}, failingStore{jobs.NewMemStore(0), jobs.StatusQueued})
+		_, err := s.Enqueue(context.Background(), "access.log", strings.NewReader(line), Options{})
+		if !errors.Is(err, ErrStore) {
+			t.Fatalf("err = %v, want ErrStore", err)
+		}
+		if status, _ := ErrorStatus(err); status != 503 {
+			t.Errorf("status = %d, want 503", status)
+		}
+		if files, _ := os.ReadDir(dir); len(files) != 0 {
+			t.Errorf("upload kept after the job could not be recorded: %v", files)
+		}
+	})
+	t.Run("results", func(t *testing.T) {
+		dir := t.TempDir()
+		s := NewService(DiskStorage{Dir: dir}, FileParser{}, BuiltinDetectors{}, failingStore{jobs.NewMemStore(0), jobs.StatusDone})
+		_, err := s.Ingest(context.Background(), "access.log", strings.NewReader(line))
+		if !errors.Is(err, ErrStore) {
+			t.Fatalf("err = %v, want ErrStore", err)
+		}
+		if files, _ := os.ReadDir(dir); len(files) != 0 {
+			t.Errorf("upload kept after the results could not be recorded: %v", files)
+		}
+	})
+	t.Run("running", func(t *testing.T) {
+		store := failingStore{jobs.NewMemStore(0), jobs.StatusRunning}
+		s := NewService(DiskStorage{Dir: t.TempDir()}, FileParser{}, BuiltinDetectors{}, store)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.Run(ctx, 1)
+		j, err := s.Enqueue(context.Background(), "access.log", strings.NewReader(line), Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			got, err := store.GetJob(j.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status == jobs.StatusFailed {
+				if got.Error != ErrStore.Error() {
+					t.Errorf("error = %q, want %q", got.Error, ErrStore.Error())
+				}
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("job still %s, want failed", got.Status)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+// TestQueueFullEndsProgress checks that a job refused by a full queue ends
+// its progress feed, so its event stream reports it failed.
+func TestQueueFullEndsProgress(t *testing.T) {
+	oldQueue := QueueSize
+	QueueSize = 0
+	t.Cleanup(func() { QueueSize = oldQueue })
+	s := testService(t)
+	if _, err := s.Enqueue(context.Background(), "access.log", strings.NewReader("x\n"), Options{}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("err = %v, want ErrQueueFull", err)
+	}
+	list, err := s.Jobs.ListJobs()
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ListJobs = %v, %v; want the refused job", list, err)
+	}
+	id := list[0].ID
+	if _, _, _, ok := hub.subscribe(id); ok {
+		t.Error("progress feed kept for a job the queue refused")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r := httptest.NewRequest("GET", "/api/jobs/"+id+"/events", nil).WithContext(ctx)
+	r.SetPathValue("id", id)
+	w := httptest.NewRecorder()
+	s.Events(w, r)
+	if ctx.Err() != nil {
+		t.Fatal("event stream did not end")
+	}
+	if body := w.Body.String(); !strings.HasPrefix(body, "event: failed\n") || !strings.Contains(body, ErrQueueFull.Error()) {
+		t.Errorf("events = %q, want one failed event", body)
+	}
+}
@@ -0,0 +1,156 @@
+package upload
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+var severityLevels = []string{"low", "medium", "high", "critical"}
+
+// kindSeverity is the starting severity for each kind, as an index into
+// severityLevels. Kinds that indicate exploitation or data access start
+// higher than reconnaissance and noise.
+var kindSeverity = map[string]int{
+	"rate_spike":          0,
+	"error_rate":          0,
+	"traffic_surge":       1,
+	"path_rate_spike":     1,
+	"rare_method":         0,
+	"response_size":       2,
+	"id_enumeration":      1,
+	"distributed_attack":  2,
+	"server_error_burst":  1,
+	"latency_spike":       0,
+	"forced_browsing":     0,
+	"low_and_slow":        0,
+	"scanner_ua":          0,
+	"abnormal_ua":         0,
+	"known_bad_ip":        1,
+	"sensitive_paths":     1,
+	"anonymized_probe":    1,
+	"auth_bruteforce":     1,
+	"impossible_travel":   1,
+	"new_country_login":   1,
+	"concurrent_sessions": 1,
+	"path_traversal":      1,
+	"k8s_forbidden_burst": 1,
+	"sqli":                2,
+	"log4shell":           2,
+	"k8s_exec_spike":      2,
+	"k8s_secrets_access":  2,
+	"db_privilege_burst":  2,
+	"db_bulk_select":      2,
+	"behavior_cluster":    0,
+	"behavioral_outlier":  0,
+}
+
+// severity starts from the kind's level, raises it one step for confidence of
+// 0.9 or more and another for 1000+ events, and lowers it one step below 0.5
+// confidence.
+func severity(a Anomaly) string {
+	lvl, ok := kindSeverity[a.Kind]
+	if !ok {
+		lvl = 1
+	}
+	switch {
+	case a.Confidence >= 0.9:
+		lvl++
+	case a.Confidence < 0.5:
+		lvl--
+	}
+	if volume(a) >= 1000 {
+		lvl++
+	}
+	return severityLevels[max(0, min(lvl, len(severityLevels)-1))]
+}
+
+// volume is the largest event count an anomaly reports.
+func volume(a Anomaly) int {
+	n := 0
+	for _, p := range []*int{a.Count, a.Hits, a.Failures, a.Errors, a.Peak} {
+		if p != nil && *p > n {
+			n = *p
+		}
+	}
+	return n
+}
+
+func severityRank(s string) int {
+	for i, l := range severityLevels {
+		if l == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// minSeverity reads ?minSeverity=; ok is false for an unknown level.
+func minSeverity(r *http.Request) (rank int, ok bool) {
+	v := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("minSeverity")))
+	if v == "" {
+		return 0, true
+	}
+	rank = severityRank(v)
+	return rank, rank >= 0
+}
+
+// FilterSeverity keeps the anomalies at or above level, one of low, medium,
+// high or critical; ok is false for any other level.
+func FilterSeverity(anoms []Anomaly, level string) (out []Anomaly, ok bool) {
+	rank := severityRank(strings.ToLower(strings.TrimSpace(level)))
+	if rank < 0 {
+		return nil, false
+	}
+	return filterSeverity(anoms, rank), true
+}
+
+func filterSeverity(anoms []Anomaly, rank int) []Anomaly {
+	out := make([]Anomaly, 0, len(anoms))
+	for _, a := range anoms {
+		if severityRank(a.Severity) >= rank {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// moreSevere orders anomalies most severe first, ties going to the more
+// confident.
+func moreSevere(a, b Anomaly) int {
+	return cmp.Or(severityRank(b.Severity)-severityRank(a.Severity), cmp.Compare(b.Confidence, a.Confidence))
+}
+
+// capAnomalies keeps n anomalies so that a flood of one kind cannot push
+// out another: each kind first keeps its most severe finding, then the rest
+// are filled by severity, ties going to the more confident. It returns how
+// many were dropped.
+func capAnomalies(anoms []Anomaly, n int) ([]Anomaly, int) {
+	if len(anoms) <= n {
+		return anoms, 0
+	}
+	slices.SortStableFunc(anoms, moreSevere)
+	keep := make([]bool, len(anoms))
+	kinds := make(map[string]bool)
+	kept := 0
+	for i, a := range anoms {
+		if kept < n && !kinds[a.Kind] {
+			kinds[a.Kind], keep[i] = true, true
+			kept++
+		}
+	}
+	for i := range anoms {
+		if kept < n && !keep[i] {
+			keep[i] = true
+			kept++
+		}
+	}
+	out := make([]Anomaly, 0, n)
+	for i, a := range anoms {
+		if keep[i] {
+			out = append(out, a)
+		}
+	}
+	return out, len(anoms) - n
+}
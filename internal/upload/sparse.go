@@ -0,0 +1,54 @@
+package upload
+
+import (
+	"encoding/json"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+)
+
+// sparseResults applies ?fields= selection. Names matching a top-level
+// Results key restrict the top level (jobId is always kept); any other name is
+// treated as a row field and projects every row onto the requested fields.
+func sparseResults(res Results, fields []string) (map[string]json.RawMessage, error) {
+	top, err := httputil.Project(res, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	keepTop := make(map[string]bool)
+	keepRow := make(map[string]bool)
+	for _, f := range fields {
+		if _, ok := top[f]; ok {
+			keepTop[f] = true
+		} else {
+			keepRow[f] = true
+		}
+	}
+
+	if len(keepTop) > 0 {
+		keepTop["jobId"] = true
+		if len(keepRow) > 0 {
+			keepTop["rows"] = true
+		}
+		for k := range top {
+			if !keepTop[k] {
+				delete(top, k)
+			}
+		}
+	}
+
+	if _, ok := top["rows"]; ok && len(keepRow) > 0 {
+		rows := make([]map[string]json.RawMessage, 0, len(res.Rows))
+		for _, r := range res.Rows {
+			pr, err := httputil.Project(r, keepRow)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, pr)
+		}
+		if top["rows"], err = json.Marshal(rows); err != nil {
+			return nil, err
+		}
+	}
+	return top, nil
+}
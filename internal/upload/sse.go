@@ -0,0 +1,102 @@
+package upload
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/jobs"
+)
+
+const (
+	sseHeartbeat = 15 * time.Second
+	ssePoll      = time.Second
+)
+
+// Events serves GET /api/jobs/{id}/events using the Default service.
+func Events(w http.ResponseWriter, r *http.Request) {
+	Default.Events(w, r)
+}
+
+// Events streams a job's progress as Server-Sent Events: a "progress" event
+// per snapshot (stage, percent, lines and bytes scanned, detectors completed,
+// anomalies so far), then one "done", "failed" or "canceled" event, after which the
+// stream ends. Jobs that already finished get only the final event. Jobs this
+// instance is not running are followed by polling the job store.
+func (s *Service) Events(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	j, err := s.Jobs.GetJob(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(p Progress) bool {
+		event := "progress"
+		if p.finished() {
+			event = p.Status
+		}
+		data, _ := json.Marshal(p)
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	last, ch, cancel, ok := hub.subscribe(id)
+	if !ok {
+		s.pollEvents(r, j, send)
+		return
+	}
+	defer cancel()
+	if !send(last) {
+		return
+	}
+	beat := time.NewTicker(sseHeartbeat)
+	defer beat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case p, open := <-ch:
+			if !open || !send(p) || p.finished() {
+				return
+			}
+		case <-beat.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil || rc.Flush() != nil {
+				return
+			}
+		}
+	}
+}
+
+// pollEvents follows a job through the store when no local tracker has it.
+func (s *Service) pollEvents(r *http.Request, j jobs.Job, send func(Progress) bool) {
+	t := time.NewTicker(ssePoll)
+	defer t.Stop()
+	prev := -1
+	for {
+		p := Progress{JobID: j.ID, Status: j.Status, Stage: j.Status, Percent: j.Progress, TotalBytes: j.SizeBytes, Anomalies: j.AnomalyCount, Error: j.Error}
+		if p.finished() || p.Percent != prev {
+			if !send(p) || p.finished() {
+				return
+			}
+			prev = p.Percent
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-t.C:
+		}
+		var err error
+		if j, err = s.Jobs.GetJob(j.ID); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package upload
+
+import (
+	"slices"
+
+	"github.com/allensuvorov/tenexlog/internal/analyze"
+)
+
+// Attack stages, a simplified kill chain. Operational findings such as
+// latency spikes and error bursts have no stage.
+const (
+	StageRecon         = "recon"
+	StageBruteForce    = "brute_force"
+	StageInitialAccess = "initial_access"
+	StageExfiltration  = "exfiltration"
+)
+
+// stageOrder is the order in which an attack usually unfolds.
+var stageOrder = []string{StageRecon, StageBruteForce, StageInitialAccess, StageExfiltration}
+
+var kindStages = map[string]string{
+	"rate_spike":          StageRecon,
+	"path_rate_spike":     StageRecon,
+	"known_bad_ip":        StageRecon,
+	"sensitive_paths":     StageRecon,
+	"anonymized_probe":    StageRecon,
+	"forced_browsing":     StageRecon,
+	"low_and_slow":        StageRecon,
+	"scanner_ua":          StageRecon,
+	"abnormal_ua":         StageRecon,
+	"rare_method":         StageRecon,
+	"id_enumeration":      StageRecon,
+	"distributed_attack":  StageRecon,
+	"behavior_cluster":    StageRecon,
+	"behavioral_outlier":  StageRecon,
+	"k8s_forbidden_burst": StageRecon,
+	"auth_bruteforce":     StageBruteForce,
+	"sqli":                StageInitialAccess,
+	"path_traversal":      StageInitialAccess,
+	"log4shell":           StageInitialAccess,
+	"impossible_travel":   StageInitialAccess,
+	"new_country_login":   StageInitialAccess,
+	"concurrent_sessions": StageInitialAccess,
+	"k8s_exec_spike":      StageInitialAccess,
+	"db_privilege_burst":  StageInitialAccess,
+	"response_size":       StageExfiltration,
+	"db_bulk_select":      StageExfiltration,
+	"k8s_secrets_access":  StageExfiltration,
+}
+
+// stageOf labels a by kind, refined by its evidence: enumeration that mostly
+// returned records is exfiltration, and rate spikes and campaigns against a
+// login endpoint are brute force.
+func stageOf(a Anomaly) string {
+	switch a.Kind {
+	case "id_enumeration":
+		if v, ok := evidenceValue(a, "foundShare"); ok && v >= 0.5 {
+			return StageExfiltration
+		}
+	case "path_rate_spike", "distributed_attack":
+		if a.Path != "" && analyze.IsLoginPath(a.Path) {
+			return StageBruteForce
+		}
+	}
+	return kindStages[a.Kind]
+}
+
+func evidenceValue(a Anomaly, signal string) (float64, bool) {
+	for _, e := range a.Evidence {
+		if e.Signal == signal {
+			return e.Value, true
+		}
+	}
+	return 0, false
+}
+
+// stageRank orders stages along the kill chain; anomalies without a stage
+// sort last.
+func stageRank(stage string) int {
+	if i := slices.Index(stageOrder, stage); i >= 0 {
+		return i
+	}
+	return len(stageOrder)
+}
+
+func stageLabel(stage string) string {
+	switch stage {
+	case StageBruteForce:
+		return "brute force"
+	case StageInitialAccess:
+		return "initial access"
+	case "":
+		return "other"
+	}
+	return stage
+}
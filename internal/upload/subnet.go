@@ -0,0 +1,146 @@
+package upload
+
+import (
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// subnetOf returns the /24 (IPv4) or /48 (IPv6) containing ip.
+func subnetOf(ip string) (netip.Prefix, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap()
+	bits := 24
+	if addr.Is6() {
+		bits = 48
+	}
+	p, err := addr.Prefix(bits)
+	return p, err == nil
+}
+
+// aggregateSubnets folds per-IP anomalies of one kind whose sources share a
+// subnet into one anomaly for the subnet, since botnets and cloud scanners
+// rotate addresses within a range. The folded anomaly keeps the fields of its
+// most confident member, lists the members in ips, sums their event counts
+// and spans their times. Single-IP groups and anomalies without a source IP are
+// left alone.
+func aggregateSubnets(anoms []Anomaly) []Anomaly {
+	type key struct {
+		kind   string
+		subnet netip.Prefix
+	}
+	groups := make(map[key][]int)
+	for i, a := range anoms {
+		if p, ok := subnetOf(a.SrcIP); ok {
+			k := key{a.Kind, p}
+			groups[k] = append(groups[k], i)
+		}
+	}
+
+	out := make([]Anomaly, 0, len(anoms))
+	for i, a := range anoms {
+		p, ok := subnetOf(a.SrcIP)
+		if !ok {
+			out = append(out, a)
+			continue
+		}
+		idx := groups[key{a.Kind, p}]
+		switch {
+		case len(idx) == 1:
+			out = append(out, a)
+		case idx[0] == i:
+			out = append(out, foldSubnet(anoms, idx, p))
+		}
+	}
+	return out
+}
+
+func foldSubnet(anoms []Anomaly, idx []int, p netip.Prefix) Anomaly {
+	top := anoms[idx[0]]
+	for _, i := range idx[1:] {
+		if anoms[i].Confidence > top.Confidence {
+			top = anoms[i]
+		}
+	}
+	merged := top
+	merged.SrcIP = ""
+	merged.Subnet = p.String()
+	merged.Minute = nil
+
+	var (
+		ips         []string
+		first, last time.Time
+		sev         = top.Severity
+	)
+	// Event counts add up across members; rates and scores stay the top's.
+	for _, field := range []func(*Anomaly) **int{
+		func(a *Anomaly) **int { return &a.Count },
+		func(a *Anomaly) **int { return &a.Hits },
+		func(a *Anomaly) **int { return &a.Failures },
+		func(a *Anomaly) **int { return &a.Errors },
+	} {
+		if *field(&merged) == nil {
+			continue
+		}
+		n := 0
+		for _, i := range idx {
+			if v := *field(&anoms[i]); v != nil {
+				n += *v
+			}
+		}
+		*field(&merged) = &n
+	}
+	for _, i := range idx {
+		a := anoms[i]
+		if !slices.Contains(ips, a.SrcIP) {
+			ips = append(ips, a.SrcIP)
+		}
+		start, end := a.FirstSeen, a.LastSeen
+		if a.Minute != nil {
+			m := a.Minute.Add(time.Minute - time.Second)
+			start, end = a.Minute, &m
+		}
+		if start != nil && (first.IsZero() || start.Before(first)) {
+			first = *start
+		}
+		if end != nil && end.After(last) {
+			last = *end
+		}
+		if severityRank(a.Severity) > severityRank(sev) {
+			sev = a.Severity
+		}
+		merged.Recurrent = merged.Recurrent || a.Recurrent
+		for _, r := range a.Related {
+			if !slices.Contains(merged.Related, r) {
+				merged.Related = append(merged.Related, r)
+			}
+		}
+	}
+	slices.SortFunc(ips, func(a, b string) int {
+		return netip.MustParseAddr(a).Compare(netip.MustParseAddr(b))
+	})
+	merged.IPs = ips
+	merged.Severity = sev
+	if !first.IsZero() {
+		merged.FirstSeen = &first
+	}
+	if !last.IsZero() {
+		merged.LastSeen = &last
+	}
+	merged.Fingerprint = fingerprint(merged)
+	merged.Reason = strconv.Itoa(len(ips)) + " sources in " + merged.Subnet + " raised " + kindLabel(top.Kind) + " findings" +
+		subnetSpan(first, last) + ". Most confident: " + strings.TrimSpace(top.Reason)
+	return merged
+}
+
+func subnetSpan(first, last time.Time) string {
+	if first.IsZero() || last.IsZero() {
+		return ""
+	}
+	return " between " + first.UTC().Format("15:04") + " and " + last.UTC().Format("15:04") + " UTC"
+}
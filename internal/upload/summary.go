@@ -0,0 +1,158 @@
+package upload
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+var kindLabels = map[string]string{
+	"rate_spike":          "rate spike",
+	"known_bad_ip":        "blocklisted IP",
+	"sensitive_paths":     "sensitive path probe",
+	"anonymized_probe":    "anonymized probe",
+	"auth_bruteforce":     "login brute-force attempt",
+	"forced_browsing":     "forced-browsing scan",
+	"error_rate":          "error-rate spike",
+	"traffic_surge":       "global traffic surge",
+	"path_rate_spike":     "endpoint rate spike",
+	"rare_method":         "unusual HTTP method use",
+	"response_size":       "oversized response",
+	"id_enumeration":      "identifier enumeration",
+	"distributed_attack":  "distributed attack campaign",
+	"server_error_burst":  "server error burst",
+	"latency_spike":       "latency spike",
+	"low_and_slow":        "low-and-slow scan",
+	"impossible_travel":   "impossible-travel login",
+	"scanner_ua":          "known scanner",
+	"new_country_login":   "login from a new country",
+	"concurrent_sessions": "concurrent session from multiple IPs",
+	"abnormal_ua":         "missing or rotating User-Agent",
+	"sqli":                "SQL injection attempt",
+	"path_traversal":      "path traversal / LFI attempt",
+	"log4shell":           "Log4Shell (JNDI) probe",
+	"k8s_forbidden_burst": "Kubernetes 403 burst",
+	"k8s_exec_spike":      "pod exec/port-forward spike",
+	"k8s_secrets_access":  "unusual Kubernetes secrets access",
+	"db_privilege_burst":  "database privilege-change burst",
+	"db_bulk_select":      "bulk database read",
+	"behavior_cluster":    "outlier behavior cluster",
+	"behavioral_outlier":  "statistical behavior outlier",
+}
+
+func kindLabel(kind string) string {
+	if l, ok := kindLabels[kind]; ok {
+		return l
+	}
+	return strings.ReplaceAll(kind, "_", " ")
+}
+
+// execSummary renders a deterministic plain-English paragraph describing the
+// analysis. The same inputs always produce the same text.
+func execSummary(sum parse.Summary, anoms []Anomaly) string {
+	const maxOffenders = 3
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Analyzed %s from %s", plural(sum.Lines, "log line"), plural(sum.UniqueIPs, "unique source IP"))
+	if !sum.Start.IsZero() && !sum.End.IsZero() {
+		fmt.Fprintf(&b, " between %s and %s UTC",
+			sum.Start.UTC().Format(time.DateTime), sum.End.UTC().Format(time.DateTime))
+	}
+	b.WriteString(".")
+
+	if len(anoms) == 0 {
+		b.WriteString(" No anomalies were detected; no action is required.")
+		return b.String()
+	}
+
+	byKind := make(map[string]int)
+	type offender struct {
+		ip       string
+		findings int
+		maxConf  float64
+	}
+	byIP := make(map[string]*offender)
+	for _, a := range anoms {
+		byKind[a.Kind]++
+		if a.SrcIP == "" {
+			continue
+		}
+		o, ok := byIP[a.SrcIP]
+		if !ok {
+			o = &offender{ip: a.SrcIP}
+			byIP[a.SrcIP] = o
+		}
+		o.findings++
+		if a.Confidence > o.maxConf {
+			o.maxConf = a.Confidence
+		}
+	}
+
+	kinds := make([]string, 0, len(byKind))
+	for k := range byKind {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+
+	parts := make([]string, 0, len(kinds))
+	for _, k := range kinds {
+		parts = append(parts, plural(byKind[k], kindLabel(k)))
+	}
+	fmt.Fprintf(&b, " %s detected: %s.", plural(len(anoms), "anomaly"), strings.Join(parts, ", "))
+
+	offenders := make([]*offender, 0, len(byIP))
+	for _, o := range byIP {
+		offenders = append(offenders, o)
+	}
+	sort.Slice(offenders, func(i, j int) bool {
+		if offenders[i].findings != offenders[j].findings {
+			return offenders[i].findings > offenders[j].findings
+		}
+		if offenders[i].maxConf != offenders[j].maxConf {
+			return offenders[i].maxConf > offenders[j].maxConf
+		}
+		return offenders[i].ip < offenders[j].ip
+	})
+	if len(offenders) > maxOffenders {
+		offenders = offenders[:maxOffenders]
+	}
+
+	top := make([]string, 0, len(offenders))
+	for _, o := range offenders {
+		top = append(top, fmt.Sprintf("%s (%s, max confidence %.2f)", o.ip, plural(o.findings, "finding"), o.maxConf))
+	}
+	if len(top) > 0 {
+		fmt.Fprintf(&b, " Top offenders: %s.", strings.Join(top, "; "))
+	}
+
+	var actions []string
+	for _, k := range kinds {
+		if acts := actionsFor(k); len(acts) > 0 {
+			actions = append(actions, strings.TrimSuffix(acts[0], "."))
+		}
+	}
+	if len(actions) == 0 {
+		actions = append(actions, "Review the listed source IPs and consider blocking those with high-confidence findings")
+	}
+	fmt.Fprintf(&b, " Recommended actions: %s.", strings.Join(actions, "; "))
+	return b.String()
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	switch {
+	case strings.HasSuffix(noun, "y"):
+		noun = strings.TrimSuffix(noun, "y") + "ies"
+	case strings.HasSuffix(noun, "s"):
+		noun += "es"
+	default:
+		noun += "s"
+	}
+	return fmt.Sprintf("%d %s", n, noun)
+}
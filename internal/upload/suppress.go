@@ -0,0 +1,163 @@
+package upload
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// Suppression silences anomalies from known-benign sources such as uptime
+// monitors and internal scanners. Every field that is set must match.
+// PathPrefix and UA are checked against the anomaly's path when it has one,
+// and otherwise against every kept row from its source IP, so a monitor that
+// only ever requests /healthz is suppressed whatever it triggers.
+type Suppression struct {
+	IP         string `json:"ip,omitempty"` // address or CIDR
+	PathPrefix string `json:"pathPrefix,omitempty"`
+	UA         string `json:"ua,omitempty"` // case-insensitive substring
+	Kind       string `json:"kind,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+
+	prefix netip.Prefix
+}
+
+const maxSuppressions = 1000
+
+var ErrBadSuppression = errors.New("invalid suppression")
+
+var (
+	suppressMu   sync.RWMutex
+	suppressions []Suppression
+)
+
+// SetSuppressions validates and replaces the suppression list.
+func SetSuppressions(list []Suppression) error {
+	if len(list) > maxSuppressions {
+		return fmt.Errorf("%w: more than %d suppressions", ErrBadSuppression, maxSuppressions)
+	}
+	out := make([]Suppression, 0, len(list))
+	for i, s := range list {
+		s.IP = strings.TrimSpace(s.IP)
+		s.Kind = strings.TrimSpace(s.Kind)
+		s.UA = strings.TrimSpace(s.UA)
+		if s.IP == "" && s.PathPrefix == "" && s.UA == "" && s.Kind == "" {
+			return fmt.Errorf("%w %d: set at least one of ip, pathPrefix, ua, kind", ErrBadSuppression, i+1)
+		}
+		if s.IP != "" {
+			p, err := netip.ParsePrefix(s.IP)
+			if err != nil {
+				addr, aerr := netip.ParseAddr(s.IP)
+				if aerr != nil {
+					return fmt.Errorf("%w %d: ip must be an address or CIDR", ErrBadSuppression, i+1)
+				}
+				p = netip.PrefixFrom(addr, addr.BitLen())
+			}
+			s.prefix = p.Masked()
+		}
+		out = append(out, s)
+	}
+	suppressMu.Lock()
+	suppressions = out
+	suppressMu.Unlock()
+	return nil
+}
+
+// Suppressions returns a copy of the current list.
+func Suppressions() []Suppression {
+	suppressMu.RLock()
+	defer suppressMu.RUnlock()
+	return append([]Suppression{}, suppressions...)
+}
+
+// LoadSuppressions reads a JSON file of the form {"suppressions": [...]}.
+func LoadSuppressions(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var body struct {
+		Suppressions []Suppression `json:"suppressions"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return err
+	}
+	return SetSuppressions(body.Suppressions)
+}
+
+// suppress drops anomalies matched by any suppression and returns the rest
+// with the number dropped.
+func suppress(anoms []Anomaly, rows []parse.Event) ([]Anomaly, int) {
+	list := Suppressions()
+	if len(list) == 0 {
+		return anoms, 0
+	}
+	paths := make(map[string][]string)
+	uas := make(map[string][]string)
+	for _, ev := range rows {
+		if ev.SrcIP != "" {
+			paths[ev.SrcIP] = append(paths[ev.SrcIP], ev.Path)
+			uas[ev.SrcIP] = append(uas[ev.SrcIP], ev.UA)
+		}
+	}
+
+	out := anoms[:0]
+	dropped := 0
+next:
+	for _, a := range anoms {
+		for _, s := range list {
+			if s.matches(a, paths[a.SrcIP], uas[a.SrcIP]) {
+				dropped++
+				continue next
+			}
+		}
+		out = append(out, a)
+	}
+	return out, dropped
+}
+
+// matches reports whether s covers a; paths and uas are the kept rows' values
+// for a's source IP.
+func (s Suppression) matches(a Anomaly, paths, uas []string) bool {
+	if s.Kind != "" && s.Kind != a.Kind {
+		return false
+	}
+	if s.IP != "" {
+		addr, err := netip.ParseAddr(a.SrcIP)
+		if err != nil || !s.prefix.Contains(addr.Unmap()) {
+			return false
+		}
+	}
+	if s.PathPrefix != "" {
+		if a.Path != "" {
+			if !strings.HasPrefix(a.Path, s.PathPrefix) {
+				return false
+			}
+		} else if !allMatch(paths, func(p string) bool { return strings.HasPrefix(p, s.PathPrefix) }) {
+			return false
+		}
+	}
+	if s.UA != "" {
+		sub := strings.ToLower(s.UA)
+		if !allMatch(uas, func(ua string) bool { return strings.Contains(strings.ToLower(ua), sub) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// allMatch is false for an empty list: with no rows to go by, nothing is
+// known about the source.
+func allMatch(vals []string, ok func(string) bool) bool {
+	for _, v := range vals {
+		if !ok(v) {
+			return false
+		}
+	}
+	return len(vals) > 0
+}
@@ -0,0 +1,85 @@
+package upload
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// Thresholds override detector constants for one upload; zero fields keep
+// the defaults. The sensitive-path thresholds also set what its evidence is
+// scored against, so a finding just over a tuned threshold gets the same
+// confidence as one just over the default.
+type Thresholds struct {
+	AbsFloor  int     // rate spikes: requests in the minute
+	Z         float64 // rate spikes: z-score against the IP's baseline
+	MinHits   int     // sensitive paths: probe requests
+	MinUnique int     // sensitive paths: distinct sensitive prefixes
+	MaxAnoms  int     // anomalies kept per upload
+}
+
+const (
+	defaultAbsFloor  = 10
+	defaultZ         = 2.0
+	defaultMinHits   = 5
+	defaultMinUnique = 2
+	defaultMaxAnoms  = 50
+)
+
+func (t Thresholds) absFloor() int  { return orInt(t.AbsFloor, defaultAbsFloor) }
+func (t Thresholds) minHits() int   { return orInt(t.MinHits, defaultMinHits) }
+func (t Thresholds) minUnique() int { return orInt(t.MinUnique, defaultMinUnique) }
+func (t Thresholds) maxAnoms() int  { return orInt(t.MaxAnoms, defaultMaxAnoms) }
+
+func (t Thresholds) z() float64 {
+	if t.Z > 0 {
+		return t.Z
+	}
+	return defaultZ
+}
+
+func orInt(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+// thresholdParams are the query parameters read by thresholdOptions, with
+// the bounds each must fall within.
+var thresholdParams = []struct {
+	name    string
+	lo, hi  float64
+	integer bool
+	set     func(*Thresholds, float64)
+}{
+	{"absFloor", 1, 10_000, true, func(t *Thresholds, v float64) { t.AbsFloor = int(v) }},
+	{"z", 0.5, 10, false, func(t *Thresholds, v float64) { t.Z = v }},
+	{"minHits", 1, 1_000, true, func(t *Thresholds, v float64) { t.MinHits = int(v) }},
+	{"minUnique", 1, 100, true, func(t *Thresholds, v float64) { t.MinUnique = int(v) }},
+	{"maxAnoms", 1, 500, true, func(t *Thresholds, v float64) { t.MaxAnoms = int(v) }},
+}
+
+// thresholdOptions reads ?absFloor=, ?z=, ?minHits=, ?minUnique= and
+// ?maxAnoms=, rejecting values outside their bounds.
+func thresholdOptions(r *http.Request) (Thresholds, error) {
+	var t Thresholds
+	q := r.URL.Query()
+	for _, p := range thresholdParams {
+		s := q.Get(p.name)
+		if s == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v < p.lo || v > p.hi || math.IsNaN(v) || p.integer && v != math.Trunc(v) {
+			kind := "a number"
+			if p.integer {
+				kind = "an integer"
+			}
+			return t, fmt.Errorf("%s must be %s between %g and %g", p.name, kind, p.lo, p.hi)
+		}
+		p.set(&t, v)
+	}
+	return t, nil
+}
@@ -0,0 +1,47 @@
+package upload
+
+import (
+	"testing"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/analyze"
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+// TestSensitivePathsConfidence checks that a finding at the tuned thresholds
+// scores like one at the defaults.
+func TestSensitivePathsConfidence(t *testing.T) {
+	base := time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)
+	probes := func(paths ...string) []parse.Event {
+		rows := make([]parse.Event, len(paths))
+		for i, p := range paths {
+			rows[i] = parse.Event{TS: base.Add(time.Duration(i) * time.Second), SrcIP: "203.0.113.9", Path: p}
+		}
+		return rows
+	}
+	tests := []struct {
+		name string
+		rows []parse.Event
+		t    Thresholds
+	}{
+		{"defaults", probes("/.env", "/.env", "/.env", "/.git/config", "/.git/HEAD"), Thresholds{}},
+		{"loosened", probes("/.env", "/.env"), Thresholds{MinHits: 2, MinUnique: 1}},
+		{"tightened", probes("/.env", "/.env", "/.env", "/.env", "/.git/config", "/.git/HEAD", "/admin", "/admin", "/admin", "/admin"), Thresholds{MinHits: 10, MinUnique: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			anoms := runSensitivePaths(shared{rows: tt.rows, t: tt.t})
+			if len(anoms) != 1 {
+				t.Fatalf("got %d anomalies, want 1", len(anoms))
+			}
+			ev := anoms[0].Evidence
+			if ev[0].Threshold != float64(tt.t.minHits()) || ev[1].Threshold != float64(tt.t.minUnique()) {
+				t.Errorf("evidence thresholds %v and %v, want %d and %d", ev[0].Threshold, ev[1].Threshold, tt.t.minHits(), tt.t.minUnique())
+			}
+			// Both signals at their thresholds: 1 - 2^-1.5.
+			if got := analyze.Calibrate(ev); got != 0.65 {
+				t.Errorf("confidence %v, want 0.65 for a finding at both thresholds", got)
+			}
+		})
+	}
+}
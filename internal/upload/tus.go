@@ -0,0 +1,351 @@
+package upload
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+)
+
+// Resumable uploads follow the tus 1.0.0 core protocol with the creation and
+// termination extensions (https://tus.io/protocols/resumable-upload). Chunks
+// are appended to a partial file; once the last byte arrives the file becomes
+// the source of a new job, exactly as if it had been uploaded in one piece.
+
+const tusVersion = "1.0.0"
+
+// TusExpiry is how long an unfinished resumable upload is kept after its
+// last chunk before it is discarded.
+var TusExpiry = 24 * time.Hour
+
+type tusUpload struct {
+	mu       sync.Mutex // held while a chunk is written
+	id       string
+	filename string
+	query    url.Values // the upload options given at creation
+	length   int64
+	offset   int64
+	path     string
+	touched  time.Time
+	jobID    string // set once the upload is complete and queued
+}
+
+var (
+	tusMu      sync.Mutex
+	tusUploads = make(map[string]*tusUpload)
+)
+
+func tusHeaders(w http.ResponseWriter) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// tusCheck answers 412 when the request does not speak tus 1.0.0.
+func tusCheck(w http.ResponseWriter, r *http.Request) bool {
+	tusHeaders(w)
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		http.Error(w, "Tus-Resumable must be "+tusVersion, http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+func lookupTus(w http.ResponseWriter, r *http.Request) *tusUpload {
+	tusMu.Lock()
+	u := tusUploads[r.PathValue("id")]
+	tusMu.Unlock()
+	if u == nil {
+		http.Error(w, "upload not found", http.StatusNotFound)
+	}
+	return u
+}
+
+// expireTus discards unfinished uploads untouched for TusExpiry.
+func expireTus(now time.Time) {
+	tusMu.Lock()
+	defer tusMu.Unlock()
+	for id, u := range tusUploads {
+		if u.mu.TryLock() {
+			if now.Sub(u.touched) > TusExpiry {
+				_ = os.Remove(u.path)
+				delete(tusUploads, id)
+			}
+			u.mu.Unlock()
+		}
+	}
+}
+
+// RunTusExpiry discards unfinished resumable uploads untouched for TusExpiry
+// until ctx is done. It checks every quarter of TusExpiry, but at least hourly
+// and at most once a minute.
+func RunTusExpiry(ctx context.Context) {
+	t := time.NewTicker(min(max(TusExpiry/4, time.Minute), time.Hour))
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			expireTus(now)
+		}
+	}
+}
+
+// TusOptions serves OPTIONS /api/upload/tus: the protocol versions and
+// extensions supported.
+func TusOptions(w http.ResponseWriter, r *http.Request) {
+	tusHeaders(w)
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", "creation,termination")
+	if MaxUploadBytes > 0 {
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(MaxUploadBytes, 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TusCreate serves POST /api/upload/tus using the Default service.
+func TusCreate(w http.ResponseWriter, r *http.Request) { Default.TusCreate(w, r) }
+
+// TusCreate starts a resumable upload of Upload-Length bytes and answers 201
+// with its URL in Location. Upload-Metadata should carry the filename; the
+// query options are those of POST /api/upload and apply to the job.
+func (s *Service) TusCreate(w http.ResponseWriter, r *http.Request) {
+	if !tusCheck(w, r) {
+		return
+	}
+	if _, ok := minSeverity(r); !ok {
+		http.Error(w, "minSeverity must be one of low, medium, high, critical", http.StatusBadRequest)
+		return
+	}
+	if _, err := uploadOptions(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(w, "Upload-Length must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+	if MaxUploadBytes > 0 && length > MaxUploadBytes {
+		tooLarge(w)
+		return
+	}
+	meta, err := tusMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filename := filepath.Base(strings.ReplaceAll(meta["filename"], `\`, "/"))
+	if filename == "." || filename == "/" {
+		filename = "upload.log"
+	}
+
+	now := time.Now()
+	u := &tusUpload{
+		id:       httputil.NewID(),
+		filename: filename,
+		query:    r.URL.Query(),
+		length:   length,
+		touched:  now,
+	}
+	u.path = filepath.Join(os.TempDir(), "tus-"+u.id+".part")
+	f, err := os.Create(u.path)
+	if err != nil {
+		http.Error(w, "failed to start upload", http.StatusInternalServerError)
+		return
+	}
+	_ = f.Close()
+	tusMu.Lock()
+	tusUploads[u.id] = u
+	tusMu.Unlock()
+
+	w.Header().Set("Location", "/api/upload/tus/"+u.id)
+	w.Header().Set("Upload-Expires", now.Add(TusExpiry).UTC().Format(http.TimeFormat))
+	if length == 0 {
+		if !s.finishTus(w, r, u) {
+			return
+		}
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// tusMetadata decodes Upload-Metadata: comma-separated keys, each followed
+// by a space and its base64 value when it has one.
+func tusMetadata(h string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, pair := range strings.Split(h, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if k == "" {
+			continue
+		}
+		b, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, errors.New("Upload-Metadata value for " + k + " is not base64")
+		}
+		out[k] = string(b)
+	}
+	return out, nil
+}
+
+// TusHead serves HEAD /api/upload/tus/{id}: how much of the upload has
+// arrived, and the job once it is complete.
+func TusHead(w http.ResponseWriter, r *http.Request) {
+	if !tusCheck(w, r) {
+		return
+	}
+	u := lookupTus(w, r)
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	tusState(w, u)
+	w.WriteHeader(http.StatusOK)
+}
+
+func tusState(w http.ResponseWriter, u *tusUpload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(u.length, 10))
+	if u.jobID != "" {
+		w.Header().Set("X-Job-Id", u.jobID)
+		w.Header().Set("X-Status-Url", "/api/jobs/"+u.jobID+"/status")
+	}
+}
+
+// TusPatch serves PATCH /api/upload/tus/{id} using the Default service.
+func TusPatch(w http.ResponseWriter, r *http.Request) { Default.TusPatch(w, r) }
+
+// TusPatch appends a chunk at Upload-Offset, which must be where the upload
+// stands, and answers 204 with the new offset. A chunk that runs past
+// Upload-Length is refused with 413 and none of it is kept. A client that loses the
+// connection asks TusHead for the offset and resumes from there. The chunk
+// that completes the upload queues the job, whose ID comes back in X-Job-Id.
+func (s *Service) TusPatch(w http.ResponseWriter, r *http.Request) {
+	if !tusCheck(w, r) {
+		return
+	}
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		http.Error(w, "Content-Type must be application/offset+octet-stream", http.StatusUnsupportedMediaType)
+		return
+	}
+	u := lookupTus(w, r)
+	if u == nil {
+		return
+	}
+	if !u.mu.TryLock() {
+		http.Error(w, "another chunk of this upload is being written", http.StatusConflict)
+		return
+	}
+	defer u.mu.Unlock()
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset != u.offset {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(u.offset, 10))
+		http.Error(w, "Upload-Offset must be "+strconv.FormatInt(u.offset, 10), http.StatusConflict)
+		return
+	}
+	if u.jobID != "" {
+		tusState(w, u)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	remaining := u.length - u.offset
+	if r.ContentLength > remaining {
+		tusState(w, u)
+		http.Error(w, "chunk runs past Upload-Length", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	f, err := os.OpenFile(u.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		http.Error(w, "upload not found", http.StatusNotFound)
+		return
+	}
+	// Whatever arrives is kept, even if the connection drops mid-chunk. A
+	// chunk of unknown length is read one byte past what fits, and dropped
+	// whole when that byte arrives.
+	n, copyErr := io.Copy(f, io.LimitReader(r.Body, remaining+1))
+	if n > remaining {
+		truncErr := f.Truncate(u.offset)
+		closeErr := f.Close()
+		if truncErr != nil || closeErr != nil {
+			http.Error(w, "failed to save chunk", http.StatusInternalServerError)
+			return
+		}
+		tusState(w, u)
+		http.Error(w, "chunk runs past Upload-Length", http.StatusRequestEntityTooLarge)
+		return
+	}
+	closeErr := f.Close()
+	u.offset += n
+	u.touched = time.Now()
+	if closeErr != nil {
+		http.Error(w, "failed to save chunk", http.StatusInternalServerError)
+		return
+	}
+	if copyErr != nil {
+		tusState(w, u)
+		http.Error(w, "chunk interrupted", http.StatusBadRequest)
+		return
+	}
+	if u.offset == u.length && !s.finishTus(w, r, u) {
+		return
+	}
+	tusState(w, u)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// finishTus queues a complete upload as a job, moving its partial file into
+// storage. It answers with the error and returns false when the job cannot be
+// queued.
+func (s *Service) finishTus(w http.ResponseWriter, r *http.Request, u *tusUpload) bool {
+	opts, err := OptionsFromQuery(u.query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	j, err := s.EnqueueFile(r.Context(), u.filename, u.path, opts)
+	if err != nil {
+		status, msg := ErrorStatus(err)
+		if status == http.StatusServiceUnavailable {
+			// The upload stays complete; an empty PATCH at the final
+			// offset queues it once there is room.
+			w.Header().Set("Retry-After", "30")
+		}
+		http.Error(w, msg, status)
+		return false
+	}
+	u.jobID = j.ID
+	return true
+}
+
+// TusDelete serves DELETE /api/upload/tus/{id}: it abandons an upload and
+// removes what has arrived.
+func TusDelete(w http.ResponseWriter, r *http.Request) {
+	if !tusCheck(w, r) {
+		return
+	}
+	u := lookupTus(w, r)
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	tusMu.Lock()
+	delete(tusUploads, u.id)
+	tusMu.Unlock()
+	_ = os.Remove(u.path)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,119 @@
+package upload
+
+import (
+	"cmp"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestTusPatchOffsets(t *testing.T) {
+	s := testService(t)
+	create := httptest.NewRequest("POST", "/api/upload/tus", nil)
+	create.Header.Set("Tus-Resumable", tusVersion)
+	create.Header.Set("Upload-Length", "10")
+	create.Header.Set("Upload-Metadata", "filename YS5sb2c=") // a.log
+	w := httptest.NewRecorder()
+	s.TusCreate(w, create)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("TusCreate = %d: %s", w.Code, w.Body)
+	}
+	id := path.Base(w.Header().Get("Location"))
+
+	steps := []struct {
+		name        string
+		offset      string
+		body        string
+		contentType string
+		version     string
+		chunked     bool // sent without Content-Length
+		wantStatus  int
+		wantOffset  string
+		wantJob     bool
+	}{
+		{name: "first chunk", offset: "0", body: "hello", wantStatus: http.StatusNoContent, wantOffset: "5"},
+		{name: "replayed chunk", offset: "0", body: "hello", wantStatus: http.StatusConflict, wantOffset: "5"},
+		{name: "gap", offset: "7", body: "xyz", wantStatus: http.StatusConflict, wantOffset: "5"},
+		{name: "garbled offset", offset: "five", body: "world", wantStatus: http.StatusConflict, wantOffset: "5"},
+		{name: "missing offset", body: "world", wantStatus: http.StatusConflict, wantOffset: "5"},
+		{name: "wrong content type", offset: "5", body: "world", contentType: "text/plain", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "wrong version", offset: "5", body: "world", version: "0.2.2", wantStatus: http.StatusPreconditionFailed},
+		{name: "chunk past the length", offset: "5", body: "world!!", wantStatus: http.StatusRequestEntityTooLarge, wantOffset: "5"},
+		{name: "chunk of unknown length past the length", offset: "5", body: "world!!", chunked: true, wantStatus: http.StatusRequestEntityTooLarge, wantOffset: "5"},
+		{name: "last chunk", offset: "5", body: "world", wantStatus: http.StatusNoContent, wantOffset: "10", wantJob: true},
+		{name: "empty chunk once complete", offset: "10", wantStatus: http.StatusNoContent, wantOffset: "10", wantJob: true},
+	}
+	for _, st := range steps {
+		r := httptest.NewRequest("PATCH", "/api/upload/tus/"+id, strings.NewReader(st.body))
+		r.SetPathValue("id", id)
+		if st.chunked {
+			r.ContentLength = -1
+		}
+		r.Header.Set("Tus-Resumable", cmp.Or(st.version, tusVersion))
+		r.Header.Set("Content-Type", cmp.Or(st.contentType, "application/offset+octet-stream"))
+		if st.offset != "" {
+			r.Header.Set("Upload-Offset", st.offset)
+		}
+		w := httptest.NewRecorder()
+		s.TusPatch(w, r)
+		if w.Code != st.wantStatus {
+			t.Fatalf("%s: status %d, want %d: %s", st.name, w.Code, st.wantStatus, w.Body)
+		}
+		if got := w.Header().Get("Upload-Offset"); st.wantOffset != "" && got != st.wantOffset {
+			t.Errorf("%s: Upload-Offset %q, want %q", st.name, got, st.wantOffset)
+		}
+		if got := w.Header().Get("X-Job-Id") != ""; got != st.wantJob {
+			t.Errorf("%s: X-Job-Id set = %v, want %v", st.name, got, st.wantJob)
+		}
+	}
+
+	r := httptest.NewRequest("HEAD", "/api/upload/tus/"+id, nil)
+	r.SetPathValue("id", id)
+	r.Header.Set("Tus-Resumable", tusVersion)
+	w = httptest.NewRecorder()
+	TusHead(w, r)
+	jobID := w.Header().Get("X-Job-Id")
+	j, err := s.Jobs.GetJob(jobID)
+	if err != nil {
+		t.Fatalf("GetJob(%q): %v", jobID, err)
+	}
+	data, err := os.ReadFile(j.SavedTo)
+	if err != nil || string(data) != "helloworld" || j.SizeBytes != 10 || j.Filename != "a.log" {
+		t.Errorf("queued upload = %q (%v), job %+v", data, err, j)
+	}
+}
+
+func TestTusCreateLength(t *testing.T) {
+	s := testService(t)
+	old := MaxUploadBytes
+	MaxUploadBytes = 100
+	t.Cleanup(func() { MaxUploadBytes = old })
+
+	tests := []struct {
+		length     string
+		wantStatus int
+	}{
+		{"50", http.StatusCreated},
+		{"100", http.StatusCreated},
+		{"101", http.StatusRequestEntityTooLarge},
+		{"-1", http.StatusBadRequest},
+		{"", http.StatusBadRequest},
+		{"ten", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/api/upload/tus", nil)
+		r.Header.Set("Tus-Resumable", tusVersion)
+		r.Header.Set("Upload-Length", tt.length)
+		w := httptest.NewRecorder()
+		s.TusCreate(w, r)
+		if w.Code != tt.wantStatus {
+			t.Errorf("Upload-Length %q: status %d, want %d", tt.length, w.Code, tt.wantStatus)
+		}
+		if w.Code == http.StatusCreated && w.Header().Get("Location") == "" {
+			t.Errorf("Upload-Length %q: no Location", tt.length)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package upload
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/allensuvorov/tenexlog/internal/parse"
+)
+
+type rowFilter struct{ field, value string }
+
+// whereFilters reads repeated ?where=field=value parameters. Fields are row
+// JSON names or extras.<key>.
+func whereFilters(r *http.Request) []rowFilter {
+	var out []rowFilter
+	for _, w := range r.URL.Query()["where"] {
+		if f, v, ok := strings.Cut(w, "="); ok && strings.TrimSpace(f) != "" {
+			out = append(out, rowFilter{field: strings.TrimSpace(f), value: v})
+		}
+	}
+	return out
+}
+
+// filterRows keeps the rows matching every filter.
+func filterRows(rows []parse.Event, filters []rowFilter) []parse.Event {
+	out := make([]parse.Event, 0, len(rows))
+next:
+	for _, ev := range rows {
+		for _, f := range filters {
+			if !ev.Matches(f.field, f.value) {
+				continue next
+			}
+		}
+		out = append(out, ev)
+	}
+	return out
+}
@@ -0,0 +1,245 @@
+package upload
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/allensuvorov/tenexlog/internal/httputil"
+	"github.com/allensuvorov/tenexlog/internal/parse"
+	"github.com/allensuvorov/tenexlog/internal/reqctx"
+)
+
+// LiveWindowLines and LiveWindowBytes bound the raw lines kept for each live
+// source; LiveEpoch is how often the window is saved as an epoch job. All
+// three are read when a source's first stream opens. LiveSources caps how
+// many sources may stream at once.
+var (
+	LiveWindowLines = 100000
+	LiveWindowBytes = 64 << 20
+	LiveEpoch       = 5 * time.Minute
+	LiveSources     = 64
+)
+
+// errTooManySources refuses a new live source once LiveSources stream.
+var errTooManySources = errors.New("too many live sources")
+
+// window is the sliding window of the most recent raw lines of one live
+// source. Its contents are saved now and then as an epoch: an ordinary job
+// of the source, analyzed like an upload, that stays in the job store after
+// the lines are gone.
+type window struct {
+	s    *Service
+	ctx  context.Context
+	opts Options
+	stop chan struct{}
+
+	mu       sync.Mutex
+	lines    [][]byte
+	bytes    int
+	fresh    int // the newest lines, which no epoch covers yet
+	epochs   int
+	streams  int
+	maxLines int
+	maxBytes int
+}
+
+// windows holds the windows of the sources with a stream open.
+var windows = struct {
+	mu sync.Mutex
+	m  map[string]*window
+}{m: make(map[string]*window)}
+
+// openWindow returns source's window with one more stream open, starting the
+// window and its epoch timer when it is the source's first stream. A new
+// source fails with errTooManySources when LiveSources already stream.
+func (s *Service) openWindow(ctx context.Context, opts Options) (*window, error) {
+	windows.mu.Lock()
+	defer windows.mu.Unlock()
+	w := windows.m[opts.Source]
+	if w == nil {
+		if len(windows.m) >= LiveSources {
+			return nil, errTooManySources
+		}
+		w = &window{s: s, ctx: context.WithoutCancel(ctx), opts: opts, stop: make(chan struct{}),
+			maxLines: max(LiveWindowLines, 1), maxBytes: max(LiveWindowBytes, 1)}
+		windows.m[opts.Source] = w
+		go w.tick(max(LiveEpoch, time.Second))
+	}
+	w.mu.Lock()
+	w.streams++
+	w.mu.Unlock()
+	return w, nil
+}
+
+// close ends one of w's streams. The last stream saves the lines no epoch
+// covers yet and drops the window; it returns that epoch's job ID, if any.
+func (w *window) close() string {
+	windows.mu.Lock()
+	w.mu.Lock()
+	w.streams--
+	last := w.streams == 0
+	if last {
+		delete(windows.m, w.opts.Source)
+		close(w.stop)
+	}
+	w.mu.Unlock()
+	windows.mu.Unlock()
+	if !last {
+		return ""
+	}
+	return w.epoch()
+}
+
+func (w *window) tick(every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-t.C:
+			w.epoch()
+		}
+	}
+}
+
+// add appends line to the window, evicting the oldest lines over the bounds.
+// Before it would evict a line no epoch covers, it saves the window as an
+// epoch, so every line is analyzed at least once.
+func (w *window) add(line []byte) {
+	w.mu.Lock()
+	evict, size := 0, w.bytes+len(line)
+	for evict < len(w.lines) && (len(w.lines)+1-evict > w.maxLines || size > w.maxBytes) {
+		size -= len(w.lines[evict])
+		evict++
+	}
+	var snap []byte
+	if evict > len(w.lines)-w.fresh {
+		snap = w.take()
+	}
+	clear(w.lines[:evict])
+	w.lines = append(w.lines[evict:], line)
+	w.bytes = size
+	w.fresh = min(w.fresh+1, len(w.lines))
+	n := len(w.lines)
+	w.mu.Unlock()
+	if snap != nil {
+		w.save(snap, n)
+	}
+}
+
+// take returns the window's lines as a file and marks them covered, or nil
+// when no line is fresh. The caller holds w.mu.
+func (w *window) take() []byte {
+	if w.fresh == 0 {
+		return nil
+	}
+	var b bytes.Buffer
+	b.Grow(w.bytes + len(w.lines))
+	for _, ln := range w.lines {
+		b.Write(ln)
+		b.WriteByte('\n')
+	}
+	w.fresh = 0
+	return b.Bytes()
+}
+
+// epoch saves the window as an epoch job when it has fresh lines and
+// returns the job's ID.
+func (w *window) epoch() string {
+	w.mu.Lock()
+	snap, n := w.take(), len(w.lines)
+	w.mu.Unlock()
+	if snap == nil {
+		return ""
+	}
+	return w.save(snap, n)
+}
+
+// save enqueues snap, the window's n lines, as the source's next epoch. When
+// that fails the lines count as fresh again, so a later epoch retries them.
+func (w *window) save(snap []byte, n int) string {
+	w.mu.Lock()
+	w.epochs++
+	filename := w.opts.Source + "-epoch-" + strconv.Itoa(w.epochs) + ".log"
+	w.mu.Unlock()
+	j, err := w.s.Enqueue(w.ctx, filename, bytes.NewReader(snap), w.opts)
+	if err != nil {
+		reqctx.Logger(w.ctx).Printf("live %s: save epoch: %v", w.opts.Source, err)
+		w.mu.Lock()
+		w.fresh = min(w.fresh+n, len(w.lines))
+		w.mu.Unlock()
+		return ""
+	}
+	return j.ID
+}
+
+// LiveHandler serves POST /api/live/{source} using the Default service.
+func LiveHandler(w http.ResponseWriter, r *http.Request) {
+	Default.LiveHandler(w, r)
+}
+
+// LiveHandler reads the request body as an endless stream of log lines of
+// the source named in the path, as in tail -F access.log | curl -T -, into
+// the source's window. The window is saved as an epoch job of the source
+// every LiveEpoch, before a line no epoch covers would be evicted, and when
+// the source's last stream ends. A source beyond the first LiveSources
+// streaming is refused with 429. The query options are those of Handler;
+// those of the stream that opened the window apply to all its epochs. The
+// answer, once the stream ends, counts the lines read and names the final
+// epoch.
+func (s *Service) LiveHandler(w http.ResponseWriter, r *http.Request) {
+	source := r.PathValue("source")
+	if !validSource.MatchString(source) {
+		http.Error(w, "source must be 1 to 64 letters, digits, '.', '_' or '-'", http.StatusBadRequest)
+		return
+	}
+	opts, err := uploadOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts.Source = source
+
+	limit := parse.MaxLineBytes
+	if limit <= 0 {
+		limit = 1024 * 1024
+	}
+	win, err := s.openWindow(r.Context(), opts)
+	if err != nil {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, err.Error()+" (at most "+strconv.Itoa(LiveSources)+")", http.StatusTooManyRequests)
+		return
+	}
+	sc := bufio.NewScanner(r.Body)
+	sc.Buffer(make([]byte, 0, min(64*1024, limit+2)), limit+2)
+	n := 0
+	for sc.Scan() {
+		line := bytes.TrimSuffix(sc.Bytes(), []byte("\r"))
+		if len(line) == 0 {
+			continue
+		}
+		win.add(bytes.Clone(line))
+		n++
+	}
+	jobID := win.close()
+	if err := sc.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			http.Error(w, "line longer than "+strconv.Itoa(limit)+" bytes", http.StatusRequestEntityTooLarge)
+			return
+		}
+		reqctx.Logger(r.Context()).Printf("live %s: read: %v", source, err)
+		return
+	}
+	resp := map[string]any{"source": source, "lines": n}
+	if jobID != "" {
+		resp["jobId"] = jobID
+	}
+	httputil.JSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,152 @@
+package upload
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLiveHandlerEpochs(t *testing.T) {
+	oldLines, oldEpoch := LiveWindowLines, LiveEpoch
+	LiveWindowLines, LiveEpoch = 4, time.Hour
+	t.Cleanup(func() { LiveWindowLines, LiveEpoch = oldLines, oldEpoch })
+
+	var body strings.Builder
+	for i := 1; i <= 10; i++ {
+		body.WriteString("line " + strconv.Itoa(i) + "\r\n")
+		if i == 6 {
+			body.WriteString("\n")
+		}
+	}
+	s := testService(t)
+	r := httptest.NewRequest("POST", "/api/live/edge?source=ignored", strings.NewReader(body.String()))
+	r.SetPathValue("source", "edge")
+	w := httptest.NewRecorder()
+	s.LiveHandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Source string `json:"source"`
+		Lines  int    `json:"lines"`
+		JobID  string `json:"jobId"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Source != "edge" || resp.Lines != 10 || resp.JobID == "" {
+		t.Errorf("answer = %+v, want 10 lines of edge and the final epoch", resp)
+	}
+
+	// An epoch is saved before an uncovered line would leave the window of
+	// four, and the rest when the stream ends.
+	want := map[string]string{
+		"edge-epoch-1.log": "line 1\nline 2\nline 3\nline 4\n",
+		"edge-epoch-2.log": "line 5\nline 6\nline 7\nline 8\n",
+		"edge-epoch-3.log": "line 7\nline 8\nline 9\nline 10\n",
+	}
+	list, err := s.Jobs.ListJobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != len(want) {
+		t.Fatalf("%d jobs saved, want %d", len(list), len(want))
+	}
+	for _, j := range list {
+		b, err := os.ReadFile(j.SavedTo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if j.Source != "edge" || string(b) != want[j.Filename] {
+			t.Errorf("job %s of source %q holds %q, want %q of edge", j.Filename, j.Source, b, want[j.Filename])
+		}
+		if j.Filename == "edge-epoch-3.log" && j.ID != resp.JobID {
+			t.Errorf("answer names job %s, want the final epoch %s", resp.JobID, j.ID)
+		}
+	}
+
+	windows.mu.Lock()
+	defer windows.mu.Unlock()
+	if _, ok := windows.m["edge"]; ok {
+		t.Error("window kept after its last stream ended")
+	}
+}
+
+func TestLiveHandlerSource(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/live/a%20b", strings.NewReader("x\n"))
+	r.SetPathValue("source", "a b")
+	w := httptest.NewRecorder()
+	testService(t).LiveHandler(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status %d, want 400", w.Code)
+	}
+}
+
+func TestWindowBounds(t *testing.T) {
+	tests := []struct {
+		name               string
+		maxLines, maxBytes int
+		add                []string
+		want               []string
+	}{
+		{"lines", 2, 100, []string{"a", "b", "c"}, []string{"b", "c"}},
+		{"bytes", 10, 6, []string{"aa", "bb", "cc", "ddd"}, []string{"cc", "ddd"}},
+		{"a line over the bytes stays alone", 10, 4, []string{"aa", "bbbbbb"}, []string{"bbbbbb"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &window{s: testService(t), ctx: t.Context(), opts: Options{Source: "t"}, maxLines: tt.maxLines, maxBytes: tt.maxBytes}
+			for _, ln := range tt.add {
+				w.add([]byte(ln))
+			}
+			var got []string
+			size := 0
+			for _, ln := range w.lines {
+				got = append(got, string(ln))
+				size += len(ln)
+			}
+			if !slices.Equal(got, tt.want) || w.bytes != size {
+				t.Errorf("window = %q (%d bytes counted), want %q", got, w.bytes, tt.want)
+			}
+		})
+	}
+}
+
+func TestLiveHandlerSourceCap(t *testing.T) {
+	oldSources := LiveSources
+	LiveSources = 1
+	t.Cleanup(func() { LiveSources = oldSources })
+	s := testService(t)
+	win, err := s.openWindow(t.Context(), Options{Source: "edge"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer win.close()
+
+	for _, tt := range []struct {
+		source string
+		want   int
+	}{
+		{"db", http.StatusTooManyRequests},
+		{"edge", http.StatusOK},
+	} {
+		r := httptest.NewRequest("POST", "/api/live/"+tt.source, strings.NewReader("x\n"))
+		r.SetPathValue("source", tt.source)
+		w := httptest.NewRecorder()
+		s.LiveHandler(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.source, w.Code, tt.want, w.Body)
+		}
+	}
+	windows.mu.Lock()
+	defer windows.mu.Unlock()
+	if _, ok := windows.m["db"]; ok {
+		t.Error("window opened for a refused source")
+	}
+}
@@ -17,12 +17,44 @@ type AnyAnom = {
 };
 type ApiResponse = {
   jobId: string; filename: string; sizeBytes: number; savedTo?: string; received: string;
-  summary: Summary; timeline: Bucket[]; rows: Row[]; anomalies: AnyAnom[]; note?: string;
+  summary: Summary; timeline: Bucket[]; rows: Row[]; rowsTotal?: number; anomalies: AnyAnom[]; note?: string;
 };
 
 const API_BASE = process.env.NEXT_PUBLIC_API_BASE ?? "http://localhost:8080";
 function basicHeader(user: string, pass: string): string { return "Basic " + btoa(`${user}:${pass}`); }
 
+type Accepted = { jobId: string; status: string; statusUrl: string };
+type JobStatus = { jobId: string; status: "queued" | "running" | "done" | "failed" | "canceled"; progress: number; error?: string; result?: ApiResponse };
+
+type Progress = { status: string; stage: string; percent: number; linesScanned: number; anomalies: number; error?: string };
+
+// followJob reads the job's Server-Sent Events stream (fetch rather than
+// EventSource, which cannot send Basic Auth) until the final event.
+async function followJob(jobId: string, auth: string, onProgress: (p: Progress) => void): Promise<Progress> {
+  const res = await fetch(`${API_BASE}/api/jobs/${jobId}/events`, { headers: { Authorization: auth } });
+  if (!res.ok || !res.body) throw new Error(`HTTP ${res.status}: ${await res.text()}`);
+  const reader = res.body.pipeThrough(new TextDecoderStream()).getReader();
+  let buf = "";
+  for (;;) {
+    const { value, done } = await reader.read();
+    if (done) throw new Error("Progress stream ended early");
+    buf += value;
+    let i;
+    while ((i = buf.indexOf("\n\n")) >= 0) {
+      const block = buf.slice(0, i);
+      buf = buf.slice(i + 2);
+      const data = block.split("\n").find(l => l.startsWith("data: "));
+      if (!data) continue;
+      const p = JSON.parse(data.slice(6)) as Progress;
+      onProgress(p);
+      if (p.status === "done" || p.status === "failed" || p.status === "canceled") {
+        await reader.cancel();
+        return p;
+      }
+    }
+  }
+}
+
 function hhmm(iso?: string): string {
   if (!iso) return "";
   const d = new Date(iso);
@@ -112,6 +144,7 @@ export default function UploadPage() {
   const [busy, setBusy] = useState(false);
   const [error, setError] = useState<string | null>(null);
   const [data, setData] = useState<ApiResponse | null>(null);
+  const [progress, setProgress] = useState<number | null>(null);
 
   const canSubmit = useMemo(() => !!user && !!pass && !!file && !busy, [user, pass, file, busy]);
 
@@ -119,6 +152,7 @@ export default function UploadPage() {
     e.preventDefault();
     setError(null);
     setData(null);
+    setProgress(null);
     if (!file) return;
     try {
       setBusy(true);
@@ -130,8 +164,17 @@ export default function UploadPage() {
         body: fd,
       });
       if (!res.ok) throw new Error(`HTTP ${res.status}: ${await res.text()}`);
-      const json = (await res.json()) as ApiResponse;
-      setData(json);
+      const accepted = (await res.json()) as Accepted;
+      const final = await followJob(accepted.jobId, basicHeader(user, pass), p => setProgress(p.percent));
+      if (final.status === "failed") throw new Error(final.error ?? "Analysis failed");
+      if (final.status === "canceled") throw new Error("Analysis canceled");
+      const sr = await fetch(`${API_BASE}${accepted.statusUrl}`, {
+        headers: { Authorization: basicHeader(user, pass) },
+      });
+      if (!sr.ok) throw new Error(`HTTP ${sr.status}: ${await sr.text()}`);
+      const st = (await sr.json()) as JobStatus;
+      if (!st.result) throw new Error("Job finished without results");
+      setData(st.result);
     } catch (err) {
       if (err instanceof Error) setError(err.message);
       else setError("Upload failed");
@@ -146,7 +189,7 @@ export default function UploadPage() {
     <main className="mx-auto max-w-3xl p-6 space-y-6">
       <h1 className="text-2xl font-semibold">Tenex Log Uploader (Prototype)</h1>
       <p className="text-sm text-gray-600">
-        This page calls <code>{API_BASE}/api/upload</code> with HTTP Basic Auth and displays the JSON result.
+        This page calls <code>{API_BASE}/api/upload</code> with HTTP Basic Auth, follows the job's progress stream until analysis finishes and displays the JSON result.
       </p>
 
       <form onSubmit={onSubmit} className="space-y-4 border rounded-lg p-4">
@@ -171,8 +214,9 @@ export default function UploadPage() {
           className={`px-4 py-2 rounded text-white ${canSubmit ? "bg-blue-600 hover:bg-blue-700" : "bg-gray-400 cursor-not-allowed"}`}
           title={canSubmit ? "Upload & analyze" : "Enter credentials and choose a file"}
         >
-          {busy ? "Uploading…" : "Upload & Analyze"}
+          {busy ? (progress === null ? "Uploading…" : `Analyzing… ${progress}%`) : "Upload & Analyze"}
         </button>
+        {busy && progress !== null && <progress className="w-full" value={progress} max={100} />}
         {error && <div className="text-sm text-red-600">{error}</div>}
       </form>
 
@@ -211,7 +255,7 @@ export default function UploadPage() {
           </div>
 
           <div className="border rounded p-3 overflow-x-auto">
-            <div className="font-medium mb-2">Rows (showing up to 20)</div>
+            <div className="font-medium mb-2">Rows (showing up to 20 of {data.rowsTotal ?? (data.rows ?? []).length})</div>
             <table className="min-w-full text-sm">
               <thead>
                 <tr className="text-left">